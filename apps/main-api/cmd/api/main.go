@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/database"
 	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/events"
+	"github.com/team556-mono/server/internal/handlers"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/push"
 	"github.com/team556-mono/server/internal/router"
 )
 
@@ -29,13 +34,29 @@ func main() {
 		log.Fatalf("Failed to initialize email client: %v", err)
 	}
 
+	// Start background jobs
+	jobs.StartAccountPurger(context.Background(), db, cfg.AccountDeletionGracePeriod, emailClient)
+	jobs.StartDataExporter(context.Background(), db, cfg, emailClient)
+	jobs.StartTransactionIndexer(context.Background(), db)
+	jobs.StartScheduledTransfers(context.Background(), db, cfg.SchedulerKey, cfg.Prices, emailClient)
+	jobs.StartSwapQuotePurger(context.Background(), db)
+	jobs.StartSwapSettler(context.Background(), db)
+	jobs.StartPriceSampler(context.Background(), db, cfg.Prices)
+	jobs.StartSwapOrders(context.Background(), db, cfg, handlers.Team556USDPrice(cfg))
+	jobs.StartPriceAlerts(context.Background(), db, cfg.Prices, emailClient, push.NewClient(cfg.PushURL, cfg.ExpoAccessToken))
+
+	// Live updates: watchers publish to the hub, the stream endpoint delivers to clients
+	hub := events.NewHub()
+	jobs.StartLiveUpdates(context.Background(), db, cfg.Prices, hub)
+	jobs.StartPaymentRequestWatcher(context.Background(), db, hub)
+
 	// Create Fiber app with a custom configuration for BodyLimit
 	app := fiber.New(fiber.Config{
 		BodyLimit: 10 * 1024 * 1024, // 10 MB limit
 	})
 
 	// Setup routes
-	router.SetupRoutes(app, db, cfg, emailClient)
+	router.SetupRoutes(app, db, cfg, emailClient, hub)
 
 	// Get port from environment or default
 	port := config.GetEnv("MAIN_API__PORT", "3000")
@@ -4,8 +4,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/team556-mono/server/internal/crypto"
+	"github.com/team556-mono/server/internal/pricing"
+	"github.com/team556-mono/server/internal/signer"
 )
 
 // Config holds the application configuration
@@ -19,6 +25,39 @@ type Config struct {
 	UploadthingSecret string // For GLOBAL__UPLOADTHING_SECRET
 	UploadthingApiURL string // For GLOBAL__UPLOADTHING_API_URL
 	AlchemyAPIKey     string // For GLOBAL__ALCHEMY_API_KEY
+	PublicAPIURL      string // For MAIN_API__PUBLIC_URL (base URL used in emailed links)
+	PushURL           string // For MAIN_API__PUSH_URL (Expo push API; defaults to Expo's)
+	ExpoAccessToken   string // For MAIN_API__EXPO_ACCESS_TOKEN (only if the Expo project requires authenticated pushes)
+
+	// Server key-encryption keys wrapping wallet data keys. MAIN_API__WALLET_KEK is a base64 32-byte key
+	// identified by MAIN_API__WALLET_KEK_ID; MAIN_API__WALLET_KEK_PREVIOUS lists rotated-out keys as id:key,...
+	WalletKeys *crypto.KeyRing
+
+	// Delegate that executes scheduled transfers. MAIN_API__SCHEDULER_SECRET_KEY is a base58 secret key;
+	// the account pays the fees of every execution, so it has to hold SOL.
+	SchedulerKey *signer.Keypair
+
+	// Per-account login throttling
+	LoginLockoutThreshold int           // MAIN_API__LOGIN_LOCKOUT_THRESHOLD: failed logins in window before lockout
+	LoginLockoutWindow    time.Duration // MAIN_API__LOGIN_LOCKOUT_WINDOW: window in which failures are counted
+	LoginLockoutDuration  time.Duration // MAIN_API__LOGIN_LOCKOUT_DURATION: how long a lockout lasts
+
+	AccountDeletionGracePeriod time.Duration // MAIN_API__ACCOUNT_DELETION_GRACE_PERIOD: delay before a deleted account is purged
+	DataExportTTL              time.Duration // MAIN_API__DATA_EXPORT_TTL: how long a data export and its download link stay valid
+
+	// Swap guardrails
+	SwapQuoteTTL          time.Duration // MAIN_API__SWAP_QUOTE_TTL: how long a server-issued swap quote can be executed
+	SwapMaxSlippageBps    int           // MAIN_API__SWAP_MAX_SLIPPAGE_BPS: highest slippage tolerance a quote may carry
+	SwapMaxPriceImpactBps int           // MAIN_API__SWAP_MAX_PRICE_IMPACT_BPS: highest price impact a quote may have
+
+	// Token prices, aggregated from Alchemy, Jupiter and the pools in MAIN_API__PRICE_POOLS
+	// (mint:baseVault:quoteVault,... of USDC pools). MAIN_API__PRICE_CACHE_TTL is how long a price is
+	// served before it is refreshed; MAIN_API__PRICE_MAX_AGE is the oldest a source quote, or a cached
+	// price served while every source is down, may be.
+	Prices *pricing.Service
+	// USD conversion for prices in other currencies: rates from MAIN_API__FX_RATES_URL cached for
+	// MAIN_API__FX_CACHE_TTL, with MAIN_API__FX_RATES (EUR:0.92,...) as a fixed fallback
+	FX *pricing.FXRates
 }
 
 // LoadConfig loads environment variables from the .env file at the project root.
@@ -47,6 +86,62 @@ cfg := &Config{
 		UploadthingSecret: os.Getenv("GLOBAL__UPLOADTHING_SECRET"),
 		UploadthingApiURL: os.Getenv("GLOBAL__UPLOADTHING_API_URL"), // Or use GetEnv with a default
 		AlchemyAPIKey:     os.Getenv("GLOBAL__ALCHEMY_API_KEY"),
+		PublicAPIURL:      GetEnv("MAIN_API__PUBLIC_URL", "https://team556-main-api.fly.dev"),
+		PushURL:           os.Getenv("MAIN_API__PUSH_URL"),
+		ExpoAccessToken:   os.Getenv("MAIN_API__EXPO_ACCESS_TOKEN"),
+
+		LoginLockoutThreshold: GetEnvInt("MAIN_API__LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginLockoutWindow:    GetEnvDuration("MAIN_API__LOGIN_LOCKOUT_WINDOW", 15*time.Minute),
+		LoginLockoutDuration:  GetEnvDuration("MAIN_API__LOGIN_LOCKOUT_DURATION", 30*time.Minute),
+
+		AccountDeletionGracePeriod: GetEnvDuration("MAIN_API__ACCOUNT_DELETION_GRACE_PERIOD", 14*24*time.Hour),
+		DataExportTTL:              GetEnvDuration("MAIN_API__DATA_EXPORT_TTL", 72*time.Hour),
+
+		SwapQuoteTTL:          GetEnvDuration("MAIN_API__SWAP_QUOTE_TTL", time.Minute),
+		SwapMaxSlippageBps:    GetEnvInt("MAIN_API__SWAP_MAX_SLIPPAGE_BPS", 300),
+		SwapMaxPriceImpactBps: GetEnvInt("MAIN_API__SWAP_MAX_PRICE_IMPACT_BPS", 500),
+	}
+
+	cfg.WalletKeys, err = crypto.ParseKeyRing(
+		GetEnv("MAIN_API__WALLET_KEK_ID", "v1"),
+		os.Getenv("MAIN_API__WALLET_KEK"),
+		os.Getenv("MAIN_API__WALLET_KEK_PREVIOUS"),
+	)
+	if err != nil {
+		log.Fatalf("Error: invalid wallet key-encryption key config: %v", err)
+	}
+
+	if secret := os.Getenv("MAIN_API__SCHEDULER_SECRET_KEY"); secret != "" {
+		cfg.SchedulerKey, err = signer.ParseSecretKey(secret)
+		if err != nil {
+			log.Fatalf("Error: invalid MAIN_API__SCHEDULER_SECRET_KEY: %v", err)
+		}
+	}
+
+	pools, err := pricing.ParsePools(os.Getenv("MAIN_API__PRICE_POOLS"))
+	if err != nil {
+		log.Fatalf("Error: invalid MAIN_API__PRICE_POOLS: %v", err)
+	}
+	sources := []pricing.Source{&pricing.JupiterSource{URL: GetEnv("MAIN_API__JUPITER_PRICE_URL", pricing.DefaultJupiterPriceURL)}}
+	if cfg.AlchemyAPIKey != "" {
+		sources = append(sources, &pricing.AlchemySource{APIKey: cfg.AlchemyAPIKey})
+	}
+	if len(pools) > 0 {
+		sources = append(sources, &pricing.PoolSource{Pools: pools})
+	}
+	cfg.Prices = pricing.NewService(sources,
+		GetEnvDuration("MAIN_API__PRICE_CACHE_TTL", 30*time.Second),
+		GetEnvDuration("MAIN_API__PRICE_MAX_AGE", 5*time.Minute))
+
+	staticRates, err := pricing.ParseStaticRates(os.Getenv("MAIN_API__FX_RATES"))
+	if err != nil {
+		log.Fatalf("Error: invalid MAIN_API__FX_RATES: %v", err)
+	}
+	cfg.FX = &pricing.FXRates{
+		URL:    GetEnv("MAIN_API__FX_RATES_URL", pricing.DefaultFXRatesURL),
+		TTL:    GetEnvDuration("MAIN_API__FX_CACHE_TTL", time.Hour),
+		MaxAge: GetEnvDuration("MAIN_API__FX_MAX_AGE", 24*time.Hour),
+		Static: staticRates,
 	}
 
 	if cfg.DatabaseURL == "" {
@@ -76,8 +171,16 @@ if cfg.MFAEncryptSecret == "" {
 	}
 	// cfg.UploadthingApiURL can be optional if there's a fallback in the handler
 
+	if cfg.WalletKeys == nil {
+		log.Println("Warning: MAIN_API__WALLET_KEK environment variable not set. Wallet secrets are wrapped by the account password only and password resets will lock wallets until they are recovered.")
+	}
+
+	if cfg.SchedulerKey == nil {
+		log.Println("Warning: MAIN_API__SCHEDULER_SECRET_KEY environment variable not set. Scheduled transfers are disabled.")
+	}
+
 	if cfg.AlchemyAPIKey == "" {
-		log.Println("Warning: GLOBAL__ALCHEMY_API_KEY environment variable not set. Price fetching via Alchemy will fail.")
+		log.Println("Warning: GLOBAL__ALCHEMY_API_KEY environment variable not set. Prices will come from Jupiter and configured pools only.")
 		// Depending on requirements, you might want to log.Fatal here if price fetching is critical
 	}
 
@@ -91,3 +194,31 @@ func GetEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// GetEnvInt retrieves an integer environment variable or returns a default value.
+func GetEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not an integer, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// GetEnvDuration retrieves a duration environment variable (e.g. "15m") or returns a default value.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a duration, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
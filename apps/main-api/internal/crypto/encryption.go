@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 
 	"golang.org/x/crypto/pbkdf2"
@@ -21,12 +22,40 @@ const (
 	pdkdf2KeyLength    = 32     // AES-256
 )
 
+// Wallet secret encryption versions, recorded in EncryptionMetadata.Version.
+const (
+	// EncryptionV1 seals the secret directly with a key derived from the password.
+	// Metadata written before versioning has no version field and is treated as v1.
+	EncryptionV1 = 1
+	// EncryptionV2 seals the secret with a random per-wallet data key. The data key is wrapped
+	// by the password-derived key and, when configured, by the server key-encryption key, so
+	// the password or KDF can change by re-wrapping 32 bytes without touching the secret.
+	EncryptionV2 = 2
+
+	CurrentEncryptionVersion = EncryptionV2
+
+	AlgorithmAES256GCM = "aes-256-gcm"
+	KDFPBKDF2SHA256    = "pbkdf2-sha256"
+)
+
 // EncryptionMetadata holds the necessary info to decrypt the mnemonic
 type EncryptionMetadata struct {
-	Salt         string `json:"salt"`         // Base64 encoded salt
-	Nonce        string `json:"nonce"`        // Base64 encoded nonce
-	Iterations   int    `json:"iterations"`   // PBKDF2 iterations used
-	// Algorithm string `json:"algorithm"` // Could add for future flexibility (e.g., "aes-gcm")
+	Version    int    `json:"version,omitempty"`   // Missing for legacy v1 rows
+	Algorithm  string `json:"algorithm,omitempty"` // Cipher for the secret and the wrapped data keys
+	KDF        string `json:"kdf,omitempty"`       // Password key derivation function
+	Salt       string `json:"salt"`                // Base64 encoded salt
+	Nonce      string `json:"nonce"`               // Base64 encoded nonce of the secret ciphertext
+	Iterations int    `json:"iterations"`          // PBKDF2 iterations used
+
+	PasswordKey *WrappedKey `json:"passwordKey,omitempty"` // v2: data key sealed with the password-derived key
+	ServerKey   *WrappedKey `json:"serverKey,omitempty"`   // v2: data key sealed with a server key-encryption key
+}
+
+// WrappedKey is a data key sealed with AES-GCM under another key.
+type WrappedKey struct {
+	KeyID string `json:"keyId,omitempty"` // Server key ID; empty for the password wrap
+	Nonce string `json:"nonce"`           // Base64 encoded nonce
+	Key   string `json:"key"`             // Base64 encoded sealed data key
 }
 
 // DeriveKey uses PBKDF2 to derive a key from a password and salt
@@ -34,101 +63,201 @@ func DeriveKey(password string, salt []byte, iterations int) []byte {
 	return pbkdf2.Key([]byte(password), salt, iterations, pdkdf2KeyLength, sha256.New)
 }
 
-// EncryptMnemonic encrypts the mnemonic using AES-GCM with a key derived from the password
-func EncryptMnemonic(mnemonic, password string) (string, datatypes.JSON, error) {
-	// 1. Generate a random salt
-	salt := make([]byte, pdkdf2SaltBytes)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return "", nil, errors.New("failed to generate salt: " + err.Error())
+// EncryptMnemonic encrypts the mnemonic with a fresh data key (v2 envelope). The data key is wrapped
+// with a key derived from the password and, if keys is non-nil, with the current server key.
+func EncryptMnemonic(mnemonic, password string, keys *KeyRing) (string, datatypes.JSON, error) {
+	// 1. Generate a random data key
+	dataKey := make([]byte, pdkdf2KeyLength)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", nil, errors.New("failed to generate data key: " + err.Error())
 	}
+	defer zero(dataKey)
 
-	// 2. Derive the encryption key from the password and salt
-	key := DeriveKey(password, salt, pdkdf2Iterations)
-
-	// 3. Create AES cipher block
-	block, err := aes.NewCipher(key)
+	// 2. Encrypt the mnemonic with the data key
+	nonce, ciphertext, err := seal(dataKey, []byte(mnemonic))
 	if err != nil {
-		return "", nil, errors.New("failed to create cipher block: " + err.Error())
+		return "", nil, err
 	}
 
-	// 4. Create GCM cipher
-	aesgcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", nil, errors.New("failed to create GCM cipher: " + err.Error())
+	// 3. Wrap the data key
+	metadata := EncryptionMetadata{
+		Version:   EncryptionV2,
+		Algorithm: AlgorithmAES256GCM,
+		KDF:       KDFPBKDF2SHA256,
+		Nonce:     base64.StdEncoding.EncodeToString(nonce),
 	}
-
-	// 5. Generate a random nonce
-	nonce := make([]byte, aesgcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", nil, errors.New("failed to generate nonce: " + err.Error())
+	if err := metadata.wrapForPassword(dataKey, password); err != nil {
+		return "", nil, err
 	}
-
-	// 6. Encrypt the mnemonic
-	ciphertext := aesgcm.Seal(nil, nonce, []byte(mnemonic), nil) // No additional authenticated data
-
-	// 7. Prepare metadata
-	metadata := EncryptionMetadata{
-		Salt:       base64.StdEncoding.EncodeToString(salt),
-		Nonce:      base64.StdEncoding.EncodeToString(nonce),
-		Iterations: pdkdf2Iterations,
+	if keys != nil {
+		if metadata.ServerKey, err = keys.wrap(dataKey); err != nil {
+			return "", nil, err
+		}
 	}
+
+	// 4. Encode ciphertext and metadata
 	metadataJSON, err := json.Marshal(metadata)
 	if err != nil {
 		return "", nil, errors.New("failed to marshal metadata: " + err.Error())
 	}
-
-	// 8. Encode ciphertext to base64
-	encryptedMnemonicBase64 := base64.StdEncoding.EncodeToString(ciphertext)
-
-	return encryptedMnemonicBase64, datatypes.JSON(metadataJSON), nil
+	return base64.StdEncoding.EncodeToString(ciphertext), datatypes.JSON(metadataJSON), nil
 }
 
 // DecryptMnemonic decrypts the base64 encoded mnemonic using the password and metadata
 func DecryptMnemonic(encryptedMnemonicBase64 string, metadataJSON datatypes.JSON, password string) (string, error) {
+	plaintext, err := DecryptMnemonicBytes(encryptedMnemonicBase64, metadataJSON, password)
+	if err != nil {
+		return "", err
+	}
+	defer zero(plaintext)
+	return string(plaintext), nil
+}
+
+// DecryptMnemonicBytes is DecryptMnemonic returning a byte slice the caller can zero after use.
+// Both legacy v1 and v2 envelope metadata are accepted.
+func DecryptMnemonicBytes(encryptedMnemonicBase64 string, metadataJSON datatypes.JSON, password string) ([]byte, error) {
 	// 1. Decode ciphertext from base64
 	ciphertext, err := base64.StdEncoding.DecodeString(encryptedMnemonicBase64)
 	if err != nil {
-		return "", errors.New("failed to decode mnemonic ciphertext: " + err.Error())
+		return nil, errors.New("failed to decode mnemonic ciphertext: " + err.Error())
 	}
 
 	// 2. Unmarshal metadata
+	metadata, err := parseMetadata(metadataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Recover the key the mnemonic was sealed with
+	var key []byte
+	if metadata.Version < EncryptionV2 {
+		salt, err := base64.StdEncoding.DecodeString(metadata.Salt)
+		if err != nil {
+			return nil, errors.New("failed to decode salt: " + err.Error())
+		}
+		// Use iterations from metadata in case it changes in the future
+		key = DeriveKey(password, salt, metadata.Iterations)
+	} else if key, err = metadata.unwrapWithPassword(password); err != nil {
+		return nil, err
+	}
+	defer zero(key)
+
+	// 4. Decrypt the mnemonic
+	plaintext, err := open(key, metadata.Nonce, ciphertext)
+	if err != nil {
+		// This error often means incorrect password/key or corrupted data
+		return nil, errors.New("failed to decrypt mnemonic: " + err.Error())
+	}
+	return plaintext, nil
+}
+
+// parseMetadata unmarshals metadata and rejects versions and algorithms this build can't handle.
+func parseMetadata(metadataJSON datatypes.JSON) (*EncryptionMetadata, error) {
 	var metadata EncryptionMetadata
 	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
-		return "", errors.New("failed to unmarshal encryption metadata: " + err.Error())
+		return nil, errors.New("failed to unmarshal encryption metadata: " + err.Error())
+	}
+	if metadata.Version > CurrentEncryptionVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", metadata.Version)
+	}
+	if metadata.Version >= EncryptionV2 {
+		if metadata.Algorithm != AlgorithmAES256GCM || metadata.KDF != KDFPBKDF2SHA256 {
+			return nil, fmt.Errorf("unsupported encryption algorithm %q/%q", metadata.Algorithm, metadata.KDF)
+		}
+		if metadata.PasswordKey == nil {
+			return nil, errors.New("encryption metadata has no password-wrapped key")
+		}
 	}
+	return &metadata, nil
+}
 
-	// 3. Decode salt and nonce from base64
-	salt, err := base64.StdEncoding.DecodeString(metadata.Salt)
+// wrapForPassword seals dataKey with a key derived from password under a fresh salt and the current iteration count.
+func (m *EncryptionMetadata) wrapForPassword(dataKey []byte, password string) error {
+	salt := make([]byte, pdkdf2SaltBytes)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return errors.New("failed to generate salt: " + err.Error())
+	}
+	key := DeriveKey(password, salt, pdkdf2Iterations)
+	defer zero(key)
+	nonce, wrapped, err := seal(key, dataKey)
 	if err != nil {
-		return "", errors.New("failed to decode salt: " + err.Error())
+		return err
 	}
-	nonce, err := base64.StdEncoding.DecodeString(metadata.Nonce)
+	m.Salt = base64.StdEncoding.EncodeToString(salt)
+	m.Iterations = pdkdf2Iterations
+	m.PasswordKey = &WrappedKey{Nonce: base64.StdEncoding.EncodeToString(nonce), Key: base64.StdEncoding.EncodeToString(wrapped)}
+	return nil
+}
+
+// unwrapWithPassword returns the data key of a v2 envelope.
+func (m *EncryptionMetadata) unwrapWithPassword(password string) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(m.Salt)
 	if err != nil {
-		return "", errors.New("failed to decode nonce: " + err.Error())
+		return nil, errors.New("failed to decode salt: " + err.Error())
 	}
+	key := DeriveKey(password, salt, m.Iterations)
+	defer zero(key)
+	return openWrapped(key, m.PasswordKey)
+}
 
-	// 4. Derive the decryption key
-	// Use iterations from metadata in case it changes in the future
-	key := DeriveKey(password, salt, metadata.Iterations)
+// seal encrypts plaintext with AES-256-GCM under key and a random nonce.
+func seal(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aesgcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, errors.New("failed to generate nonce: " + err.Error())
+	}
+	return nonce, aesgcm.Seal(nil, nonce, plaintext, nil), nil // No additional authenticated data
+}
 
-	// 5. Create AES cipher block
-	block, err := aes.NewCipher(key)
+// open decrypts ciphertext sealed by seal; nonceBase64 is the base64 encoded nonce.
+func open(key []byte, nonceBase64 string, ciphertext []byte) ([]byte, error) {
+	nonce, err := base64.StdEncoding.DecodeString(nonceBase64)
+	if err != nil {
+		return nil, errors.New("failed to decode nonce: " + err.Error())
+	}
+	aesgcm, err := newGCM(key)
 	if err != nil {
-		return "", errors.New("failed to create cipher block: " + err.Error())
+		return nil, err
 	}
+	if len(nonce) != aesgcm.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	return aesgcm.Open(nil, nonce, ciphertext, nil)
+}
 
-	// 6. Create GCM cipher
-	aesgcm, err := cipher.NewGCM(block)
+// openWrapped unseals a wrapped data key.
+func openWrapped(key []byte, w *WrappedKey) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(w.Key)
+	if err != nil {
+		return nil, errors.New("failed to decode wrapped key: " + err.Error())
+	}
+	dataKey, err := open(key, w.Nonce, wrapped)
 	if err != nil {
-		return "", errors.New("failed to create GCM cipher: " + err.Error())
+		// Keeps the cipher error text so callers can tell a wrong password from other failures
+		return nil, errors.New("failed to unwrap data key: " + err.Error())
 	}
+	return dataKey, nil
+}
 
-	// 7. Decrypt the mnemonic
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil) // No additional authenticated data
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		// This error often means incorrect password/key or corrupted data
-		return "", errors.New("failed to decrypt mnemonic: " + err.Error())
+		return nil, errors.New("failed to create cipher block: " + err.Error())
+	}
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.New("failed to create GCM cipher: " + err.Error())
 	}
+	return aesgcm, nil
+}
 
-	return string(plaintext), nil
+// zero overwrites b in place.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
 }
@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+// ErrNoServerKey is returned when a wallet's data key isn't wrapped by any server key in the key ring.
+var ErrNoServerKey = errors.New("data key is not wrapped with a known server key")
+
+// KeyRing holds the server key-encryption keys (KEKs) that wrap wallet data keys.
+// New wraps use the current key; previous keys are only used to unwrap during rotation.
+type KeyRing struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// ParseKeyRing builds a KeyRing from base64 encoded 32-byte keys. previous is a comma-separated
+// list of id:key pairs still accepted for unwrapping. It returns nil when currentKey is empty.
+func ParseKeyRing(currentID, currentKey, previous string) (*KeyRing, error) {
+	if currentKey == "" {
+		return nil, nil
+	}
+	if currentID == "" {
+		return nil, errors.New("server key ID is required")
+	}
+	ring := &KeyRing{currentID: currentID, keys: map[string][]byte{}}
+	if err := ring.add(currentID, currentKey); err != nil {
+		return nil, err
+	}
+	for _, entry := range strings.Split(previous, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, key, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("previous server key %q must be id:base64key", id)
+		}
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate server key ID %q", id)
+		}
+		if err := ring.add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+func (k *KeyRing) add(id, keyBase64 string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyBase64))
+	if err != nil {
+		return fmt.Errorf("server key %q is not valid base64: %w", id, err)
+	}
+	if len(key) != pdkdf2KeyLength {
+		return fmt.Errorf("server key %q must be %d bytes, got %d", id, pdkdf2KeyLength, len(key))
+	}
+	k.keys[id] = key
+	return nil
+}
+
+// CurrentID returns the ID of the key used for new wraps.
+func (k *KeyRing) CurrentID() string {
+	return k.currentID
+}
+
+func (k *KeyRing) wrap(dataKey []byte) (*WrappedKey, error) {
+	nonce, wrapped, err := seal(k.keys[k.currentID], dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &WrappedKey{
+		KeyID: k.currentID,
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		Key:   base64.StdEncoding.EncodeToString(wrapped),
+	}, nil
+}
+
+func (k *KeyRing) unwrap(w *WrappedKey) ([]byte, error) {
+	if k == nil || w == nil {
+		return nil, ErrNoServerKey
+	}
+	key, ok := k.keys[w.KeyID]
+	if !ok {
+		return nil, ErrNoServerKey
+	}
+	return openWrapped(key, w)
+}
+
+// RewrapMnemonic re-keys an encrypted mnemonic from oldPassword to newPassword. v2 envelopes keep
+// their ciphertext and only re-wrap the data key; legacy v1 secrets are re-encrypted as v2.
+// Passing the same password for both upgrades the KDF parameters and server wrap in place.
+func RewrapMnemonic(encryptedMnemonicBase64 string, metadataJSON datatypes.JSON, oldPassword, newPassword string, keys *KeyRing) (string, datatypes.JSON, error) {
+	metadata, err := parseMetadata(metadataJSON)
+	if err != nil {
+		return "", nil, err
+	}
+	if metadata.Version < EncryptionV2 {
+		secret, err := DecryptMnemonicBytes(encryptedMnemonicBase64, metadataJSON, oldPassword)
+		if err != nil {
+			return "", nil, err
+		}
+		defer zero(secret)
+		return EncryptMnemonic(string(secret), newPassword, keys)
+	}
+	dataKey, err := metadata.unwrapWithPassword(oldPassword)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zero(dataKey)
+	return rewrap(encryptedMnemonicBase64, metadata, dataKey, newPassword, keys)
+}
+
+// RewrapWithServerKey re-wraps a v2 envelope's data key for newPassword using the server key
+// instead of the old password, for operator-run migrations. Password resets don't use it, since the
+// reset email alone would then unlock the wallet. It returns ErrNoServerKey for
+// legacy secrets and for data keys wrapped by a key the ring doesn't hold.
+func RewrapWithServerKey(encryptedMnemonicBase64 string, metadataJSON datatypes.JSON, newPassword string, keys *KeyRing) (string, datatypes.JSON, error) {
+	metadata, err := parseMetadata(metadataJSON)
+	if err != nil {
+		return "", nil, err
+	}
+	if metadata.Version < EncryptionV2 {
+		return "", nil, ErrNoServerKey
+	}
+	dataKey, err := keys.unwrap(metadata.ServerKey)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zero(dataKey)
+	return rewrap(encryptedMnemonicBase64, metadata, dataKey, newPassword, keys)
+}
+
+// NeedsUpgrade reports whether metadata was written by an older scheme than EncryptMnemonic
+// would use now: a legacy version, fewer KDF iterations, or a missing or rotated server wrap.
+func NeedsUpgrade(metadataJSON datatypes.JSON, keys *KeyRing) bool {
+	var metadata EncryptionMetadata
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		return false
+	}
+	if metadata.Version < CurrentEncryptionVersion || metadata.Iterations < pdkdf2Iterations {
+		return true
+	}
+	return keys != nil && (metadata.ServerKey == nil || metadata.ServerKey.KeyID != keys.currentID)
+}
+
+// rewrap wraps dataKey for newPassword and, if the ring is configured, refreshes a missing or
+// rotated server wrap. The secret ciphertext is unchanged.
+func rewrap(encryptedMnemonicBase64 string, metadata *EncryptionMetadata, dataKey []byte, newPassword string, keys *KeyRing) (string, datatypes.JSON, error) {
+	if err := metadata.wrapForPassword(dataKey, newPassword); err != nil {
+		return "", nil, err
+	}
+	if keys != nil && (metadata.ServerKey == nil || metadata.ServerKey.KeyID != keys.currentID) {
+		var err error
+		if metadata.ServerKey, err = keys.wrap(dataKey); err != nil {
+			return "", nil, err
+		}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", nil, errors.New("failed to marshal metadata: " + err.Error())
+	}
+	return encryptedMnemonicBase64, datatypes.JSON(metadataJSON), nil
+}
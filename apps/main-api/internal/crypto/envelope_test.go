@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func testKeyRing(t *testing.T, currentID string, previous string) (*KeyRing, string) {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key)
+	ring, err := ParseKeyRing(currentID, encoded, previous)
+	if err != nil {
+		t.Fatalf("ParseKeyRing() error = %v", err)
+	}
+	return ring, encoded
+}
+
+// encryptLegacy writes a v1 secret the way EncryptMnemonic did before envelopes.
+func encryptLegacy(t *testing.T, mnemonic, password string) (string, datatypes.JSON) {
+	t.Helper()
+	salt := make([]byte, pdkdf2SaltBytes)
+	rand.Read(salt)
+	nonce, ciphertext, err := seal(DeriveKey(password, salt, pdkdf2Iterations), []byte(mnemonic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := json.Marshal(map[string]any{
+		"salt":       base64.StdEncoding.EncodeToString(salt),
+		"nonce":      base64.StdEncoding.EncodeToString(nonce),
+		"iterations": pdkdf2Iterations,
+	})
+	return base64.StdEncoding.EncodeToString(ciphertext), meta
+}
+
+func mustDecrypt(t *testing.T, enc string, meta datatypes.JSON, password string) {
+	t.Helper()
+	got, err := DecryptMnemonic(enc, meta, password)
+	if err != nil {
+		t.Fatalf("DecryptMnemonic() error = %v", err)
+	}
+	if got != testMnemonic {
+		t.Fatalf("DecryptMnemonic() = %q", got)
+	}
+}
+
+func TestEncryptMnemonicEnvelope(t *testing.T) {
+	ring, _ := testKeyRing(t, "k1", "")
+	enc, meta, err := EncryptMnemonic(testMnemonic, "pw-1", ring)
+	if err != nil {
+		t.Fatalf("EncryptMnemonic() error = %v", err)
+	}
+	var m EncryptionMetadata
+	json.Unmarshal(meta, &m)
+	if m.Version != EncryptionV2 || m.Algorithm != AlgorithmAES256GCM || m.ServerKey == nil || m.ServerKey.KeyID != "k1" {
+		t.Fatalf("metadata = %+v", m)
+	}
+	mustDecrypt(t, enc, meta, "pw-1")
+	if _, err := DecryptMnemonic(enc, meta, "wrong"); err == nil {
+		t.Fatal("DecryptMnemonic() with wrong password succeeded")
+	}
+	if NeedsUpgrade(meta, ring) {
+		t.Fatal("NeedsUpgrade() = true for a current envelope")
+	}
+}
+
+func TestRewrapMnemonic(t *testing.T) {
+	ring, _ := testKeyRing(t, "k1", "")
+
+	t.Run("v2 keeps ciphertext", func(t *testing.T) {
+		enc, meta, _ := EncryptMnemonic(testMnemonic, "old", ring)
+		newEnc, newMeta, err := RewrapMnemonic(enc, meta, "old", "new", ring)
+		if err != nil {
+			t.Fatalf("RewrapMnemonic() error = %v", err)
+		}
+		if newEnc != enc {
+			t.Fatal("RewrapMnemonic() changed the ciphertext of a v2 envelope")
+		}
+		mustDecrypt(t, newEnc, newMeta, "new")
+		if _, err := DecryptMnemonic(newEnc, newMeta, "old"); err == nil {
+			t.Fatal("old password still decrypts")
+		}
+	})
+
+	t.Run("legacy is upgraded", func(t *testing.T) {
+		enc, meta := encryptLegacy(t, testMnemonic, "old")
+		mustDecrypt(t, enc, meta, "old")
+		if !NeedsUpgrade(meta, ring) {
+			t.Fatal("NeedsUpgrade() = false for legacy metadata")
+		}
+		newEnc, newMeta, err := RewrapMnemonic(enc, meta, "old", "old", ring)
+		if err != nil {
+			t.Fatalf("RewrapMnemonic() error = %v", err)
+		}
+		mustDecrypt(t, newEnc, newMeta, "old")
+		if NeedsUpgrade(newMeta, ring) {
+			t.Fatal("NeedsUpgrade() = true after upgrade")
+		}
+	})
+}
+
+func TestRewrapWithServerKey(t *testing.T) {
+	old, oldKey := testKeyRing(t, "k1", "")
+	enc, meta, _ := EncryptMnemonic(testMnemonic, "forgotten", old)
+
+	// Rotate: k2 is current, k1 only unwraps
+	rotated, _ := testKeyRing(t, "k2", "k1:"+oldKey)
+	if !NeedsUpgrade(meta, rotated) {
+		t.Fatal("NeedsUpgrade() = false after rotation")
+	}
+	newEnc, newMeta, err := RewrapWithServerKey(enc, meta, "reset", rotated)
+	if err != nil {
+		t.Fatalf("RewrapWithServerKey() error = %v", err)
+	}
+	mustDecrypt(t, newEnc, newMeta, "reset")
+	var m EncryptionMetadata
+	json.Unmarshal(newMeta, &m)
+	if m.ServerKey.KeyID != "k2" {
+		t.Fatalf("server key = %q, want k2", m.ServerKey.KeyID)
+	}
+
+	unrelated, _ := testKeyRing(t, "k9", "")
+	if _, _, err := RewrapWithServerKey(enc, meta, "reset", unrelated); !errors.Is(err, ErrNoServerKey) {
+		t.Fatalf("unknown key: error = %v, want ErrNoServerKey", err)
+	}
+	legacyEnc, legacyMeta := encryptLegacy(t, testMnemonic, "forgotten")
+	if _, _, err := RewrapWithServerKey(legacyEnc, legacyMeta, "reset", old); !errors.Is(err, ErrNoServerKey) {
+		t.Fatalf("legacy: error = %v, want ErrNoServerKey", err)
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	if ring, err := ParseKeyRing("", "", ""); ring != nil || err != nil {
+		t.Fatalf("empty config = %v, %v", ring, err)
+	}
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	for name, args := range map[string][3]string{
+		"short key":     {"k1", short, ""},
+		"missing id":    {"", short, ""},
+		"bad previous":  {"k1", base64.StdEncoding.EncodeToString(make([]byte, 32)), "nocolon"},
+		"bad base64":    {"k1", "%%%", ""},
+		"duplicate ids": {"k1", base64.StdEncoding.EncodeToString(make([]byte, 32)), "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 32))},
+	} {
+		if _, err := ParseKeyRing(args[0], args[1], args[2]); err == nil {
+			t.Errorf("%s: ParseKeyRing() succeeded", name)
+		}
+	}
+}
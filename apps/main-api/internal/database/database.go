@@ -58,10 +58,32 @@ err = db.AutoMigrate(
 	&models.UserSession{},
 	&models.LoginActivity{},
 	&models.SecurityAuditLog{},
+	&models.EmailChangeRequest{},
+	&models.DataExport{},
 	// Referral models
 	&models.Referral{},
 	&models.ReferralStats{},
 	&models.ReferralEvent{},
+	// Transaction history
+	&models.WalletTransaction{},
+	&models.WalletIndexState{},
+	&models.AddressBookContact{},
+	// Spending policies
+	&models.WalletSpendingPolicy{},
+	&models.WalletAllowlistEntry{},
+	&models.WalletSpend{},
+	// Scheduled transfers
+	&models.ScheduledTransfer{},
+	&models.ScheduledTransferExecution{},
+	// Swaps
+	&models.SwapQuote{},
+	&models.Swap{},
+	&models.SwapOrder{},
+	// Prices
+	&models.PriceSample{},
+	&models.PriceAlert{},
+	// Payments
+	&models.PaymentRequest{},
 	)
 	if err != nil {
 		// Log migration errors but don't necessarily make it fatal
@@ -3,7 +3,9 @@ package email
 import (
 	"errors"
 	"fmt"
+	"html"
 	"strings"
+	"time"
 
 	"github.com/resend/resend-go/v2"
 )
@@ -154,6 +156,120 @@ func (c *Client) SendNewLoginEmail(toEmail, ip, ua, location string) error {
 	return c.sendSimple(toEmail, subject, html)
 }
 
+// SendAccountLockedEmail tells a user their account was locked after repeated failed logins
+// and offers a signed link to unlock it early.
+func (c *Client) SendAccountLockedEmail(toEmail, unlockURL string, lockedUntil time.Time) error {
+	subject := "Your Team556 account was temporarily locked"
+	html := `<p>Hello,</p><p>We locked your Team556 account after several failed sign-in attempts.</p>` +
+		fmt.Sprintf(`<p>It will unlock automatically at %s UTC. If these attempts were yours, you can <a href="%s">unlock your account now</a>.</p>`, lockedUntil.UTC().Format("Jan 2, 2006 15:04"), unlockURL) +
+		`<p>If you did not try to sign in, someone may be guessing your password. We recommend changing it and enabling 2FA.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendEmailChangeCodeEmail sends the confirmation code for an email change to the new address.
+func (c *Client) SendEmailChangeCodeEmail(toEmail, code string) error {
+	subject := "Confirm your new Team556 email address"
+	html := `<p>Hello,</p><p>We received a request to use this address for your Team556 account.</p>` +
+		fmt.Sprintf(`<p>Your confirmation code is: <strong>%s</strong></p>`, code) +
+		`<p>This code will expire in 30 minutes. If you did not request this, you can ignore this email.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendEmailChangeNoticeEmail warns the current address that a change was requested and
+// offers a link to cancel it.
+func (c *Client) SendEmailChangeNoticeEmail(toEmail, newEmail, cancelURL string) error {
+	subject := "Email change requested on your Team556 account"
+	html := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>A request was made to change your Team556 account email to <strong>%s</strong>. The change only happens once the new address is confirmed.</p>`, newEmail) +
+		fmt.Sprintf(`<p>If this wasn't you, <a href="%s">cancel the change</a> and reset your password immediately.</p>`, cancelURL)
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendEmailChangedEmail confirms to the previous address that the account email was changed.
+func (c *Client) SendEmailChangedEmail(toEmail, newEmail string) error {
+	subject := "Your Team556 email address was changed"
+	html := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>Your Team556 account email was changed to <strong>%s</strong>. Other signed-in devices have been signed out.</p>`, newEmail) +
+		`<p>If you did not perform this action, contact support immediately.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendAccountDeletionScheduledEmail confirms a deletion request and explains how to cancel it.
+func (c *Client) SendAccountDeletionScheduledEmail(toEmail string, scheduledFor time.Time) error {
+	subject := "Your Team556 account is scheduled for deletion"
+	html := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>We received your request to delete your Team556 account. Your account and all associated data will be permanently deleted on %s UTC.</p>`, scheduledFor.UTC().Format("Jan 2, 2006 15:04")) +
+		`<p>Changed your mind? Simply log in before then and the deletion will be cancelled. Make sure you have backed up your wallet recovery phrase if you still hold funds.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendAccountDeletionCancelledEmail notifies a user that logging in cancelled a pending deletion.
+func (c *Client) SendAccountDeletionCancelledEmail(toEmail string) error {
+	subject := "Your Team556 account deletion was cancelled"
+	html := `<p>Hello,</p><p>You logged in to your Team556 account, so its scheduled deletion has been cancelled. If this wasn't you, please change your password immediately.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendAccountDeletedEmail confirms that an account and its data were permanently removed.
+func (c *Client) SendAccountDeletedEmail(toEmail string) error {
+	subject := "Your Team556 account has been deleted"
+	html := `<p>Hello,</p><p>Your Team556 account and its associated data have been permanently deleted. This email address can now be used to register a new account.</p><p>Thank you for being part of Team556.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendRecoveryPhraseRevealRequestedEmail warns that a wallet's recovery phrase will be revealable
+// once its spending policy's delay has passed.
+func (c *Client) SendRecoveryPhraseRevealRequestedEmail(toEmail, walletAddress string, availableAt time.Time) error {
+	subject := "A recovery phrase reveal was requested for your Team556 wallet"
+	html := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>Someone entered your password to view the recovery phrase of wallet %s. Because the wallet has a spending policy, the phrase can only be viewed from %s UTC.</p>`, walletAddress, availableAt.UTC().Format("Jan 2, 2006 15:04")) +
+		`<p>If this wasn't you, cancel the request from the wallet's spending policy and change your password immediately.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendRecoveryPhraseRevealedEmail notifies a user that a wallet's recovery phrase was viewed.
+func (c *Client) SendRecoveryPhraseRevealedEmail(toEmail, walletAddress string) error {
+	subject := "Your Team556 wallet recovery phrase was viewed"
+	html := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>The recovery phrase of wallet %s was just viewed.</p>`, walletAddress) +
+		`<p>If this wasn't you, move your funds to a new wallet and change your password immediately.</p>`
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendDataExportReadyEmail sends the time-limited download link for a personal data export.
+func (c *Client) SendDataExportReadyEmail(toEmail, downloadURL string, expiresAt time.Time) error {
+	subject := "Your Team556 data export is ready"
+	html := `<p>Hello,</p><p>The copy of your Team556 data you requested is ready.</p>` +
+		fmt.Sprintf(`<p><a href="%s">Download your data</a></p>`, downloadURL) +
+		fmt.Sprintf(`<p>This link expires on %s UTC. If you didn't request this export, please change your password immediately.</p>`, expiresAt.UTC().Format("Jan 2, 2006 15:04"))
+	return c.sendSimple(toEmail, subject, html)
+}
+
+// SendScheduledTransferFailedEmail tells a user a scheduled transfer run failed, and whether the
+// schedule was paused because of it.
+func (c *Client) SendScheduledTransferFailedEmail(toEmail, name, amount, recipient, reason string, paused bool) error {
+	subject := "A scheduled transfer from your Team556 wallet failed"
+	body := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>Your scheduled transfer <strong>%s</strong> of %s to %s could not be completed.</p>`, html.EscapeString(name), html.EscapeString(amount), recipient) +
+		fmt.Sprintf(`<p>Reason: %s</p>`, html.EscapeString(reason))
+	if paused {
+		body += `<p>The schedule has been paused after repeated failures. Resume it in the app once the problem is fixed.</p>`
+	} else {
+		body += `<p>The next run will go ahead as scheduled. Make sure the wallet holds enough tokens.</p>`
+	}
+	return c.sendSimple(toEmail, subject, body)
+}
+
+// SendPriceAlertEmail tells a user one of their price alerts fired. condition describes it, e.g.
+// "rose above $0.05"; the alert is now off until they turn it back on.
+func (c *Client) SendPriceAlertEmail(toEmail, token, condition, price string) error {
+	subject := fmt.Sprintf("%s %s", token, condition)
+	body := `<p>Hello,</p>` +
+		fmt.Sprintf(`<p>Your price alert fired: <strong>%s</strong> %s and is now at $%s.</p>`, html.EscapeString(token), html.EscapeString(condition), html.EscapeString(price)) +
+		`<p>The alert has been turned off. You can turn it back on in the app.</p><p>To stop price alert emails, turn off the Alerts notification type.</p>`
+	return c.sendSimple(toEmail, subject, body)
+}
+
 // SendPasswordResetEmail sends the password reset code to the user.
 func (c *Client) SendPasswordResetEmail(toEmail, resetCode string) error {
 	subject := "Your Password Reset Code for Team556 Wallet"
@@ -0,0 +1,108 @@
+// Package events fans out live updates from background watchers to connected clients.
+// Subscriptions are per process: with several API instances, each delivers the events its own
+// watchers produce.
+package events
+
+import "sync"
+
+// Event types
+const (
+	TypePrice          = "price"
+	TypeBalance        = "balance"
+	TypePaymentRequest = "payment_request"
+)
+
+// subscriptionBuffer is how many events a slow client may fall behind before further events to
+// it are dropped.
+const subscriptionBuffer = 32
+
+// Event is one update. Data is sent to the client as JSON.
+type Event struct {
+	Type string
+	Data any
+}
+
+// Subscription receives the events for one connected client.
+type Subscription struct {
+	UserID uint
+	C      <-chan Event
+
+	c   chan Event
+	hub *Hub
+}
+
+// Close unsubscribes. C is closed once pending publishes have finished.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	subs := s.hub.subs[s.UserID]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(s.hub.subs, s.UserID)
+	}
+	close(s.c)
+}
+
+// Hub tracks the subscriptions of connected users.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[uint]map[*Subscription]struct{}
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{subs: map[uint]map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a client of userID.
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+	s := &Subscription{UserID: userID, C: c, c: c, hub: h}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][s] = struct{}{}
+	return s
+}
+
+// Users returns the users with at least one subscription, so watchers only poll for them.
+func (h *Hub) Users() []uint {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	users := make([]uint, 0, len(h.subs))
+	for id := range h.subs {
+		users = append(users, id)
+	}
+	return users
+}
+
+// Publish sends e to every client of userID without blocking; clients whose buffer is full miss it.
+func (h *Hub) Publish(userID uint, e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs[userID] {
+		select {
+		case s.c <- e:
+		default:
+		}
+	}
+}
+
+// Broadcast sends e to every connected client.
+func (h *Hub) Broadcast(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, subs := range h.subs {
+		for s := range subs {
+			select {
+			case s.c <- e:
+			default:
+			}
+		}
+	}
+}
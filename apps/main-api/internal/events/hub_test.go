@@ -0,0 +1,40 @@
+package events
+
+import "testing"
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	a1, a2, b := h.Subscribe(1), h.Subscribe(1), h.Subscribe(2)
+
+	h.Publish(1, Event{Type: TypeBalance})
+	h.Broadcast(Event{Type: TypePrice})
+	for _, s := range []*Subscription{a1, a2} {
+		if e := <-s.C; e.Type != TypeBalance {
+			t.Errorf("user 1 got %s first, want balance", e.Type)
+		}
+		if e := <-s.C; e.Type != TypePrice {
+			t.Errorf("user 1 got %s second, want price", e.Type)
+		}
+	}
+	if e := <-b.C; e.Type != TypePrice || len(b.C) != 0 {
+		t.Errorf("user 2 got %s and %d more, want only price", e.Type, len(b.C))
+	}
+
+	// A full buffer drops instead of blocking
+	for i := 0; i < subscriptionBuffer+5; i++ {
+		h.Publish(2, Event{Type: TypeBalance})
+	}
+	if len(b.C) != subscriptionBuffer {
+		t.Errorf("buffered %d events, want %d", len(b.C), subscriptionBuffer)
+	}
+
+	a1.Close()
+	a1.Close()
+	if _, open := <-a1.C; open {
+		t.Error("closed subscription should have a closed channel")
+	}
+	b.Close()
+	if users := h.Users(); len(users) != 1 || users[0] != 1 {
+		t.Errorf("Users() = %v, want [1]", users)
+	}
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"github.com/team556-mono/server/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	maxContactLabelLength = 50
+	maxContactNoteLength  = 500
+
+	// recipientLookupTimeout bounds looking up the owners of token accounts a transaction pays.
+	recipientLookupTimeout = 10 * time.Second
+
+	// WarnLookalikeAddress flags a recipient that resembles, but is not, a saved contact or own wallet.
+	WarnLookalikeAddress = "lookalike_address"
+)
+
+// CreateContactRequest defines the body for saving an address book contact
+type CreateContactRequest struct {
+	Label    string `json:"label" validate:"required"`
+	Address  string `json:"address" validate:"required"`
+	Note     string `json:"note,omitempty"`
+	Favorite bool   `json:"favorite,omitempty"`
+}
+
+// UpdateContactRequest changes the fields that are set. The address cannot be changed;
+// delete the contact and save the new address instead.
+type UpdateContactRequest struct {
+	Label    *string `json:"label,omitempty"`
+	Note     *string `json:"note,omitempty"`
+	Favorite *bool   `json:"favorite,omitempty"`
+}
+
+// ContactResponse is a saved contact along with lookalike warnings about its address
+type ContactResponse struct {
+	Contact  models.AddressBookContact `json:"contact"`
+	Warnings []signer.TxWarning        `json:"warnings"`
+}
+
+// CheckRecipientRequest defines the body for checking an address before sending to it
+type CheckRecipientRequest struct {
+	Address string `json:"address" validate:"required"`
+}
+
+// CheckRecipientResponse says what the user knows about an address they are about to send to
+type CheckRecipientResponse struct {
+	Address   string                     `json:"address"`
+	Valid     bool                       `json:"valid"`
+	Error     string                     `json:"error,omitempty"`
+	Contact   *models.AddressBookContact `json:"contact,omitempty"`   // Saved contact with exactly this address
+	OwnWallet *models.Wallet             `json:"ownWallet,omitempty"` // User's wallet with exactly this address
+	Warnings  []signer.TxWarning         `json:"warnings"`
+}
+
+func normalizeContactLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return "", errors.New("Contact label is required")
+	}
+	if len([]rune(label)) > maxContactLabelLength {
+		return "", errors.New("Contact label must be 50 characters or fewer")
+	}
+	return label, nil
+}
+
+func normalizeContactNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > maxContactNoteLength {
+		return "", errors.New("Contact note must be 500 characters or fewer")
+	}
+	return note, nil
+}
+
+// knownAddress is an address the user has saved, either as a contact or as one of their wallets.
+type knownAddress struct {
+	address string
+	label   string
+	wallet  bool
+}
+
+func userKnownAddresses(db *gorm.DB, userID uint) ([]knownAddress, error) {
+	var contacts []models.AddressBookContact
+	if err := db.Select("label", "address").Where("user_id = ?", userID).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	var wallets []models.Wallet
+	if err := db.Select("name", "address").Where("user_id = ?", userID).Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	known := make([]knownAddress, 0, len(contacts)+len(wallets))
+	for _, ct := range contacts {
+		known = append(known, knownAddress{address: ct.Address, label: ct.Label})
+	}
+	for _, w := range wallets {
+		known = append(known, knownAddress{address: w.Address, label: w.Name, wallet: true})
+	}
+	return known, nil
+}
+
+// lookalikeWarnings returns a warning for every address that resembles one of known without being
+// one of them, the pattern address-poisoning attacks rely on.
+func lookalikeWarnings(known []knownAddress, addresses []string) []signer.TxWarning {
+	warnings := []signer.TxWarning{}
+	for _, addr := range addresses {
+		exact := false
+		for _, k := range known {
+			if k.address == addr {
+				exact = true
+				break
+			}
+		}
+		if exact {
+			continue
+		}
+		for _, k := range known {
+			if !utils.IsLookalikeAddress(addr, k.address) {
+				continue
+			}
+			kind := "contact"
+			if k.wallet {
+				kind = "wallet"
+			}
+			warnings = append(warnings, signer.TxWarning{
+				Code: WarnLookalikeAddress,
+				Message: fmt.Sprintf("Recipient %s looks like your %s %q (%s) but is a different address. Check every character before sending.",
+					addr, kind, k.label, k.address),
+			})
+		}
+	}
+	return warnings
+}
+
+// recipientWarnings checks addresses against the user's contacts and wallets.
+func recipientWarnings(db *gorm.DB, userID uint, addresses []string) ([]signer.TxWarning, error) {
+	if len(addresses) == 0 {
+		return []signer.TxWarning{}, nil
+	}
+	known, err := userKnownAddresses(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return lookalikeWarnings(known, addresses), nil
+}
+
+// resolveTokenRecipients replaces the token accounts summary pays with their owners, so they can
+// be compared with contacts and wallets. Without an RPC upstream, or when the lookup fails, they
+// stay listed as token accounts.
+func resolveTokenRecipients(summary *signer.TxSummary, owner solana.PublicKey) {
+	if len(summary.TokenAccountRecipients) == 0 || len(solanarpc.Upstreams()) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recipientLookupTimeout)
+	defer cancel()
+	owners, err := solanarpc.TokenAccountOwners(ctx, summary.TokenAccountRecipients)
+	if err != nil {
+		log.Printf("Error looking up owners of token accounts %v: %v", summary.TokenAccountRecipients, err)
+		return
+	}
+	summary.ResolveTokenRecipients(owner, owners)
+}
+
+// contactFromParam loads the contact named by the :id route parameter for the authenticated user,
+// writing the error response itself when it returns nil.
+func contactFromParam(c *fiber.Ctx, db *gorm.DB) (*models.AddressBookContact, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return nil, 0, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, userID, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid contact ID"})
+	}
+	var contact models.AddressBookContact
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&contact).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, userID, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Contact not found"})
+		}
+		log.Printf("Error fetching contact %d for user %d: %v", id, userID, err)
+		return nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contact"})
+	}
+	return &contact, userID, nil
+}
+
+// ListContactsHandler returns the user's address book, favorites and recently used contacts first.
+// ?favorite=true limits it to favorites; ?q= matches label or address.
+func ListContactsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		q := db.Where("user_id = ?", userID)
+		if c.QueryBool("favorite") {
+			q = q.Where("favorite = ?", true)
+		}
+		if search := strings.TrimSpace(c.Query("q")); search != "" {
+			pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search) + "%"
+			q = q.Where("label ILIKE ? OR address LIKE ?", pattern, pattern)
+		}
+		contacts := []models.AddressBookContact{}
+		if err := q.Order("favorite DESC").Order("last_used_at DESC NULLS LAST").Order("label").Find(&contacts).Error; err != nil {
+			log.Printf("Error listing contacts for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contacts"})
+		}
+		return c.JSON(fiber.Map{"contacts": contacts})
+	}
+}
+
+// CreateContactHandler saves an address to the user's address book. The contact is saved even when
+// its address resembles another contact or wallet, but the response carries a warning.
+func CreateContactHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req CreateContactRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		address := utils.NormalizeWalletAddress(req.Address)
+		if err := utils.ValidateWalletAddress(address); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		label, err := normalizeContactLabel(req.Label)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		note, err := normalizeContactNote(req.Note)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		known, err := userKnownAddresses(db, userID)
+		if err != nil {
+			log.Printf("Error loading address book for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contacts"})
+		}
+		for _, k := range known {
+			if k.address == address && !k.wallet {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("This address is already saved as %q", k.label)})
+			}
+		}
+
+		contact := models.AddressBookContact{
+			UserID:   userID,
+			Label:    label,
+			Address:  address,
+			Note:     note,
+			Favorite: req.Favorite,
+		}
+		if err := db.Create(&contact).Error; err != nil {
+			log.Printf("Error saving contact for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save contact"})
+		}
+		return c.Status(fiber.StatusCreated).JSON(ContactResponse{
+			Contact:  contact,
+			Warnings: lookalikeWarnings(known, []string{address}),
+		})
+	}
+}
+
+// UpdateContactHandler changes a contact's label, note or favorite flag.
+func UpdateContactHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		contact, userID, err := contactFromParam(c, db)
+		if contact == nil {
+			return err
+		}
+
+		var req UpdateContactRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		updates := map[string]any{}
+		if req.Label != nil {
+			label, err := normalizeContactLabel(*req.Label)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			updates["label"] = label
+		}
+		if req.Note != nil {
+			note, err := normalizeContactNote(*req.Note)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			updates["note"] = note
+		}
+		if req.Favorite != nil {
+			updates["favorite"] = *req.Favorite
+		}
+		if len(updates) == 0 {
+			return c.JSON(contact)
+		}
+		if err := db.Model(contact).Updates(updates).Error; err != nil {
+			log.Printf("Error updating contact %d for user %d: %v", contact.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update contact"})
+		}
+		return c.JSON(contact)
+	}
+}
+
+// DeleteContactHandler removes a contact from the address book.
+func DeleteContactHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		contact, userID, err := contactFromParam(c, db)
+		if contact == nil {
+			return err
+		}
+		if err := db.Delete(contact).Error; err != nil {
+			log.Printf("Error deleting contact %d for user %d: %v", contact.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete contact"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// CheckRecipientHandler validates an address the user is about to send to, reports the contact or
+// wallet it belongs to, and warns when it only resembles one of them.
+func CheckRecipientHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req CheckRecipientRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		address := utils.NormalizeWalletAddress(req.Address)
+		resp := CheckRecipientResponse{Address: address, Valid: true, Warnings: []signer.TxWarning{}}
+		if err := utils.ValidateWalletAddress(address); err != nil {
+			resp.Valid, resp.Error = false, err.Error()
+			return c.JSON(resp)
+		}
+
+		var contact models.AddressBookContact
+		err := db.Where("user_id = ? AND address = ?", userID, address).First(&contact).Error
+		if err == nil {
+			resp.Contact = &contact
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error checking contacts for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contacts"})
+		}
+		var wallet models.Wallet
+		err = db.Where("user_id = ? AND address = ?", userID, address).First(&wallet).Error
+		if err == nil {
+			resp.OwnWallet = &wallet
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error checking wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+
+		if resp.Warnings, err = recipientWarnings(db, userID, []string{address}); err != nil {
+			log.Printf("Error loading address book for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contacts"})
+		}
+		return c.JSON(resp)
+	}
+}
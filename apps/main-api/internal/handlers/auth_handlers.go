@@ -1,43 +1,57 @@
 package handlers
 
 import (
+	"bytes"
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
+	"math"
 	"math/big"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt" // Added missing bcrypt import
 	"gorm.io/gorm"
 
+	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/email"
 	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/security"
 	"github.com/team556-mono/server/internal/utils"
 )
 
 // AuthHandler holds dependencies for authentication handlers
 type AuthHandler struct {
 	DB          *gorm.DB
+	Cfg         *config.Config
 	JWTSecret   []byte
 	Validate    *validator.Validate
 	EmailClient *email.Client
+	Lockout     security.LockoutPolicy
+	// Throttles logins to unregistered emails like Lockout throttles accounts
+	UnknownLogins *security.UnknownLogins
 }
 
 // Character set for user code generation
 const userCodeChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(db *gorm.DB, jwtSecret string, emailClient *email.Client) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, emailClient *email.Client) *AuthHandler {
 	return &AuthHandler{
 		DB:          db,
-		JWTSecret:   []byte(jwtSecret),
+		Cfg:         cfg,
+		JWTSecret:   []byte(cfg.JWTSecret),
 		Validate:    validator.New(),
 		EmailClient: emailClient,
+		Lockout:     security.LockoutPolicyFromConfig(cfg),
+
+		UnknownLogins: security.NewUnknownLogins(),
 	}
 }
 
@@ -149,7 +163,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	// If ErrRecordNotFound, proceed to create new user
 
 	// 4. Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := security.HashPassword(req.Password)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to hash password"})
 	}
@@ -173,7 +187,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 	user := models.User{
 		Email:                      strings.ToLower(req.Email),
-		Password:                   string(hashedPassword),
+		Password:                   hashedPassword,
 		EmailVerified:              false, // Always reset to false on registration/re-attempt
 		EmailVerificationCode:      codePtr,
 		EmailVerificationExpiresAt: expiresAtPtr,
@@ -280,25 +294,60 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Validation failed", "details": formatValidationErrors(errors)})
 	}
 
-	// 3. Check if email exists
+	// 3. Check if email exists. Soft-deleted accounts are included so a login during the grace
+	// period can cancel the deletion.
 	var user models.User
-	if err := h.DB.Where("email = ?", strings.ToLower(req.Email)).First(&user).Error; err != nil {
+	loginEmail := strings.ToLower(req.Email)
+	if err := h.DB.Unscoped().Where("email = ?", loginEmail).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Throttled like a real account, so delays and lockouts don't tell which emails exist
+			if gate := h.UnknownLogins.Check(h.Lockout, loginEmail, time.Now()); !gate.Allowed {
+				return loginThrottled(c, gate)
+			}
+			h.UnknownLogins.RecordFailure(h.Lockout, loginEmail, time.Now())
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error", "details": err.Error()})
 	}
 
+	// 3.5 Per-account throttling: refuse to evaluate the password while locked or delayed
+	gate, err := security.CheckLoginAllowed(h.DB, h.Lockout, &user, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error", "details": err.Error()})
+	}
+	if !gate.Allowed {
+		return loginThrottled(c, gate)
+	}
+
 	// 4. Compare hashed password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		// Record failed login activity for existing user
-		_ = h.DB.Create(&models.LoginActivity{UserID: user.ID, Status: "failed_login", IP: c.IP(), UserAgent: c.Get("User-Agent")}).Error
+	passwordOK, needsRehash, err := security.VerifyPassword(user.Password, req.Password)
+	if err != nil {
+		log.Printf("Error verifying password hash for user %d: %v", user.ID, err)
+	}
+	if !passwordOK {
+		// Record failed login activity for existing user; may trigger a lockout
+		locked, lockErr := security.RecordFailedLogin(h.DB, h.Lockout, &user, c.IP(), c.Get("User-Agent"), time.Now())
+		if lockErr != nil {
+			log.Printf("Error recording failed login for user %d: %v", user.ID, lockErr)
+		} else if locked {
+			h.sendUnlockEmail(user)
+		}
 		// Make sure the error message is generic for security
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
 	}
 
+	// 4.5 Transparently upgrade legacy or weaker hashes now that we know the plaintext
+	if needsRehash {
+		if upgraded, err := security.HashPassword(req.Password); err != nil {
+			log.Printf("Error rehashing password for user %d: %v", user.ID, err)
+		} else if err := h.DB.Model(&user).Update("password", upgraded).Error; err != nil {
+			log.Printf("Error storing rehashed password for user %d: %v", user.ID, err)
+		}
+	}
+
 	// 5. *** Check if account has been deleted ***
-	if user.DeletedAt.Valid {
+	// A soft-deleted account that hasn't been purged yet is still in its grace period (below)
+	if user.PurgedAt != nil {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error":   "Account deleted",
 			"message": "This account has been deleted and cannot be accessed.",
@@ -315,6 +364,32 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	// 6.5 Logging in during the deletion grace period cancels the deletion, restoring accounts
+	// soft-deleted before the grace period existed too
+	if user.DeletionScheduledFor != nil || user.DeletedAt.Valid {
+		ip := c.IP()
+		if err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Model(&user).Updates(map[string]any{
+				"deletion_requested_at":  gorm.Expr("NULL"),
+				"deletion_scheduled_for": gorm.Expr("NULL"),
+				"deleted_at":             gorm.Expr("NULL"),
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.SecurityAuditLog{UserID: user.ID, Action: "account_deletion_cancelled", IP: &ip}).Error
+		}); err != nil {
+			log.Printf("Error cancelling scheduled deletion for user %d: %v", user.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel scheduled account deletion"})
+		}
+		user.DeletionRequestedAt = nil
+		user.DeletionScheduledFor = nil
+		user.DeletedAt = gorm.DeletedAt{}
+		log.Printf("Scheduled deletion cancelled by login for user %d", user.ID)
+		if h.EmailClient != nil {
+			go h.EmailClient.SendAccountDeletionCancelledEmail(user.Email)
+		}
+	}
+
 	// 6. Generate JWT
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
@@ -329,6 +404,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// 7. Record successful login activity and create/update a session
+	_ = security.ResetFailedLogins(h.DB, &user, time.Now())
 	_ = h.DB.Create(&models.LoginActivity{UserID: user.ID, Status: "successful_login", IP: c.IP(), UserAgent: c.Get("User-Agent")}).Error
 	ua := c.Get("User-Agent")
 	ip := c.IP()
@@ -376,6 +452,102 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// loginThrottled answers a login refused by gate, the same way whether or not the account exists.
+func loginThrottled(c *fiber.Ctx, gate security.LoginGate) error {
+	retryAfter := int(math.Ceil(gate.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	if gate.Locked {
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"error":       "Account temporarily locked",
+			"message":     "Too many failed login attempts. Check your email for an unlock link or try again later.",
+			"retry_after": retryAfter,
+		})
+	}
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "Too many failed login attempts, please wait before retrying",
+		"retry_after": retryAfter,
+	})
+}
+
+// sendUnlockEmail emails a signed unlock link for the user's current lockout.
+func (h *AuthHandler) sendUnlockEmail(user models.User) {
+	if h.EmailClient == nil || user.LockedUntil == nil {
+		return
+	}
+	lockedUntil := *user.LockedUntil
+	token, err := security.IssueLinkToken(h.Cfg.JWTSecret, security.UnlockLinkPurpose, user.ID, security.UnlockRef(lockedUntil), h.Lockout.LockDuration)
+	if err != nil {
+		log.Printf("Error issuing unlock token for user %d: %v", user.ID, err)
+		return
+	}
+	unlockURL := fmt.Sprintf("%s/api/auth/unlock?token=%s", strings.TrimRight(h.Cfg.PublicAPIURL, "/"), url.QueryEscape(token))
+	go func(email string) {
+		if err := h.EmailClient.SendAccountLockedEmail(email, unlockURL, lockedUntil); err != nil {
+			log.Printf("Error sending account locked email to %s: %v", email, err)
+		}
+	}(user.Email)
+}
+
+// unlockPage is served for the emailed unlock link. Opening the link changes nothing, so link
+// scanners and prefetchers can't unlock the account; the button POSTs the token back.
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Unlock your Team556 account</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 48px auto; padding: 0 16px;">
+{{if .Message}}<p>{{.Message}}</p>{{else}}<h1>Unlock your account</h1>
+<p>Your account was locked after too many failed login attempts. If that was you, unlock it below and log in again.</p>
+<form method="POST" action="unlock"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">Unlock account</button></form>{{end}}
+</body>
+</html>`))
+
+func renderUnlockPage(c *fiber.Ctx, status int, token, message string) error {
+	var buf bytes.Buffer
+	if err := unlockPage.Execute(&buf, fiber.Map{"Token": token, "Message": message}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to render page"})
+	}
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
+
+// UnlockAccountPage handles GET /auth/unlock?token=... from the emailed unlock link. It only shows
+// a confirmation page; the unlock itself is the POST.
+func (h *AuthHandler) UnlockAccountPage(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return renderUnlockPage(c, fiber.StatusBadRequest, "", "This unlock link is incomplete.")
+	}
+	return renderUnlockPage(c, fiber.StatusOK, token, "")
+}
+
+// UnlockAccount handles POST /auth/unlock with the token from the emailed link, as a form post from
+// the unlock page or as JSON.
+func (h *AuthHandler) UnlockAccount(c *fiber.Ctx) error {
+	var req struct {
+		Token string `json:"token" form:"token"`
+	}
+	_ = c.BodyParser(&req)
+	fromPage := c.Is("application/x-www-form-urlencoded")
+	if req.Token == "" {
+		if fromPage {
+			return renderUnlockPage(c, fiber.StatusBadRequest, "", "This unlock link is incomplete.")
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing unlock token"})
+	}
+	user, err := security.UnlockAccount(h.DB, h.Cfg.JWTSecret, req.Token, c.IP())
+	if err != nil {
+		log.Printf("Account unlock rejected: %v", err)
+		if fromPage {
+			return renderUnlockPage(c, fiber.StatusBadRequest, "", "This unlock link is invalid or has expired.")
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired unlock link"})
+	}
+	log.Printf("Account unlocked via email link for user %d", user.ID)
+	if fromPage {
+		return renderUnlockPage(c, fiber.StatusOK, "", "Your account has been unlocked. You can now log in.")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Your account has been unlocked. You can now log in."})
+}
+
 // Logout handles user logout (best-effort session revocation by IP/UA).
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	log.Println("Logout request received.")
@@ -555,15 +727,16 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	}
 
 	// 6. Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := security.HashPassword(req.NewPassword)
 	if err != nil {
 		log.Printf("Failed to hash new password for user %s: %v", user.Email, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process new password"})
 	}
 
 	// 7. Update user's password and mark code as used in a transaction
+	var lockedWallets int
 	err = h.DB.Transaction(func(tx *gorm.DB) error {
-		user.Password = string(hashedPassword)
+		user.Password = hashedPassword
 		if err := tx.Save(&user).Error; err != nil {
 			return err // Rollback
 		}
@@ -572,7 +745,11 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 		if err := tx.Save(&resetEntry).Error; err != nil {
 			return err // Rollback
 		}
-		return nil // Commit
+
+		// The emailed code alone doesn't unlock wallets: every one is locked until it is recovered
+		locked, err := resetWalletSecrets(tx, user.ID)
+		lockedWallets = len(locked)
+		return err // Commit unless re-keying failed
 	})
 
 	if err != nil {
@@ -581,6 +758,14 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	}
 
 	log.Printf("Password successfully reset for user: %s (ID: %d)", user.Email, user.ID)
+	if lockedWallets > 0 {
+		log.Printf("Locked %d wallet(s) for recovery after password reset for user %d", lockedWallets, user.ID)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":          "Password successfully reset. Your wallets are locked until you recover them with their recovery phrase or your previous password.",
+			"walletsLocked":    lockedWallets,
+			"recoveryRequired": true,
+		})
+	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password successfully reset. You can now log in with your new password."})
 }
 
@@ -786,21 +971,40 @@ func (h *AuthHandler) DeleteAccount(c *fiber.Ctx) error {
 
 	// 5. Verify password
 	log.Printf("[DeleteAccount] User ID: %d, Received Password length: %d", userID, len(req.Password))
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if ok, _, err := security.VerifyPassword(user.Password, req.Password); !ok {
 		log.Printf("[DeleteAccount] Password verification failed for user %d: %v", userID, err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid password"})
 	}
 
-	// 6. Set DeletedAt to current time (soft delete)
+	// 6. Schedule the purge after the grace period; logging in before then cancels it
 	now := time.Now()
-	user.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
-
-	// 7. Save the user with DeletedAt set
-	if err := h.DB.Save(&user).Error; err != nil {
-		log.Printf("Error marking user %d as deleted: %v", userID, err)
+	scheduledFor := now.Add(h.Cfg.AccountDeletionGracePeriod)
+	ip := c.IP()
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]any{
+			"deletion_requested_at":  &now,
+			"deletion_scheduled_for": &scheduledFor,
+		}).Error; err != nil {
+			return err
+		}
+		// 7. Sign out every device so the next login is an explicit cancellation
+		if err := tx.Model(&models.UserSession{}).Where("user_id = ? AND is_revoked = false", userID).Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "account_deletion_requested", IP: &ip}).Error
+	})
+	if err != nil {
+		log.Printf("Error scheduling deletion for user %d: %v", userID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete account"})
 	}
 
-	log.Printf("User %d successfully marked as deleted", userID)
-	return c.SendStatus(fiber.StatusNoContent) // 204 No Content on success
+	if h.EmailClient != nil {
+		go h.EmailClient.SendAccountDeletionScheduledEmail(user.Email, scheduledFor)
+	}
+
+	log.Printf("User %d scheduled for deletion at %s", userID, scheduledFor.Format(time.RFC3339))
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":                "Account scheduled for deletion. Log in before the scheduled date to cancel.",
+		"deletion_scheduled_for": scheduledFor,
+	})
 }
\ No newline at end of file
@@ -118,7 +118,7 @@ func (h *NotificationHandler) UpdateSettings(c *fiber.Ctx) error {
     // Upsert
     var settings models.NotificationSettings
     tx := h.db.Where("user_id = ?", userID).First(&settings)
-    if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound && tx.Error != nil {
+    if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
         return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "failed to query settings"})
     }
 
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"gorm.io/gorm"
+)
+
+const (
+	nativeSOLMint  = "SOL"
+	wrappedSOLMint = "So11111111111111111111111111111111111111112"
+
+	// maxPortfolioWallets caps the RPC fan-out for one portfolio request.
+	maxPortfolioWallets = 25
+)
+
+// knownTokenSymbols labels the mints the app cares about; other tokens are shown by mint.
+var knownTokenSymbols = map[string]string{
+	nativeSOLMint:    "SOL",
+	team556TokenMint: "TEAM556",
+	"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v": "USDC",
+	"Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB": "USDT",
+}
+
+// PortfolioHolding is one wallet's share of a token
+type PortfolioHolding struct {
+	WalletID uint   `json:"walletId"`
+	Address  string `json:"address"`
+	Amount   string `json:"amount"`
+}
+
+// PortfolioToken is the combined balance of one token across all wallets
+type PortfolioToken struct {
+	Mint     string             `json:"mint"` // "SOL" for native SOL
+	Symbol   string             `json:"symbol,omitempty"`
+	Decimals uint8              `json:"decimals"`
+	Amount   string             `json:"amount"`
+	PriceUSD *string            `json:"priceUsd"` // null when no price source knows the token
+	ValueUSD *string            `json:"valueUsd"`
+	Holdings []PortfolioHolding `json:"holdings"`
+}
+
+// PortfolioWallet summarizes one wallet in the portfolio
+type PortfolioWallet struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	WatchOnly bool   `json:"watchOnly"`
+	ValueUSD  string `json:"valueUsd"`
+	Error     string `json:"error,omitempty"` // Set when balances for this wallet couldn't be fetched
+}
+
+// PortfolioResponse aggregates balances across all of a user's active wallets
+type PortfolioResponse struct {
+	TotalValueUSD string            `json:"totalValueUsd"`
+	Tokens        []PortfolioToken  `json:"tokens"`
+	Wallets       []PortfolioWallet `json:"wallets"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+}
+
+// tokenBalance is a raw on-chain balance of one mint in one wallet.
+type tokenBalance struct {
+	mint     string
+	raw      *big.Int
+	decimals uint8
+}
+
+// fetchWalletBalances returns the non-zero SOL and SPL token (classic and Token-2022) balances of address.
+func fetchWalletBalances(address string) ([]tokenBalance, error) {
+	lamports, tokens, err := solanarpc.Balances(context.Background(), address)
+	if err != nil {
+		return nil, err
+	}
+	balances := []tokenBalance{}
+	if lamports > 0 {
+		balances = append(balances, tokenBalance{mint: nativeSOLMint, raw: new(big.Int).SetUint64(lamports), decimals: 9})
+	}
+	for _, t := range tokens {
+		balances = append(balances, tokenBalance{mint: t.Mint, raw: t.Raw, decimals: t.Decimals})
+	}
+	return balances, nil
+}
+
+func uiAmount(raw *big.Int, decimals uint8) decimal.Decimal {
+	return decimal.NewFromBigInt(raw, -int32(decimals))
+}
+
+// GetPortfolioHandler aggregates SOL, TEAM556 and other SPL token balances across the user's
+// owned and watch-only wallets and values them in USD.
+func GetPortfolioHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var wallets []models.Wallet
+		if err := db.Where("user_id = ? AND archived_at IS NULL", userID).
+			Order("is_default DESC").Order("id").Limit(maxPortfolioWallets).
+			Find(&wallets).Error; err != nil {
+			log.Printf("Error listing wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallets"})
+		}
+		if len(solanarpc.Upstreams()) == 0 {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Balance service not configured"})
+		}
+
+		balances := make([][]tokenBalance, len(wallets))
+		errs := make([]error, len(wallets))
+		var wg sync.WaitGroup
+		for i := range wallets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				balances[i], errs[i] = fetchWalletBalances(wallets[i].Address)
+			}(i)
+		}
+		wg.Wait()
+
+		type aggregate struct {
+			token    PortfolioToken
+			total    *big.Int
+			holdings []tokenBalance
+			owners   []int
+		}
+		byMint := map[string]*aggregate{}
+		for i, list := range balances {
+			if errs[i] != nil {
+				log.Printf("Error fetching balances for wallet %d (user %d): %v", wallets[i].ID, userID, errs[i])
+				continue
+			}
+			for _, b := range list {
+				agg, ok := byMint[b.mint]
+				if !ok {
+					agg = &aggregate{token: PortfolioToken{Mint: b.mint, Symbol: knownTokenSymbols[b.mint], Decimals: b.decimals}, total: new(big.Int)}
+					byMint[b.mint] = agg
+				}
+				agg.total.Add(agg.total, b.raw)
+				agg.holdings = append(agg.holdings, b)
+				agg.owners = append(agg.owners, i)
+			}
+		}
+
+		// Price lookups use the wrapped SOL mint for native SOL
+		mints := make([]string, 0, len(byMint))
+		for mint := range byMint {
+			if mint == nativeSOLMint {
+				mint = wrappedSOLMint
+			}
+			mints = append(mints, mint)
+		}
+		sort.Strings(mints)
+		prices, err := cfg.Prices.USDPrices(c.Context(), mints)
+		if err != nil {
+			// Balances are still useful without prices
+			log.Printf("Error fetching portfolio prices for user %d: %v", userID, err)
+		}
+
+		resp := PortfolioResponse{Tokens: []PortfolioToken{}, Wallets: make([]PortfolioWallet, len(wallets)), UpdatedAt: time.Now()}
+		walletValues := make([]decimal.Decimal, len(wallets))
+		total := decimal.Zero
+		for mint, agg := range byMint {
+			priceMint := mint
+			if mint == nativeSOLMint {
+				priceMint = wrappedSOLMint
+			}
+			price, priced := prices[priceMint]
+
+			agg.token.Amount = uiAmount(agg.total, agg.token.Decimals).String()
+			for j, h := range agg.holdings {
+				w := wallets[agg.owners[j]]
+				amount := uiAmount(h.raw, h.decimals)
+				agg.token.Holdings = append(agg.token.Holdings, PortfolioHolding{WalletID: w.ID, Address: w.Address, Amount: amount.String()})
+				if priced {
+					walletValues[agg.owners[j]] = walletValues[agg.owners[j]].Add(amount.Mul(price))
+				}
+			}
+			if priced {
+				value := uiAmount(agg.total, agg.token.Decimals).Mul(price)
+				priceStr, valueStr := price.String(), value.StringFixed(2)
+				agg.token.PriceUSD, agg.token.ValueUSD = &priceStr, &valueStr
+				total = total.Add(value)
+			}
+			resp.Tokens = append(resp.Tokens, agg.token)
+		}
+		// Largest holdings first; unpriced tokens last, by mint
+		sort.Slice(resp.Tokens, func(i, j int) bool {
+			a, b := resp.Tokens[i], resp.Tokens[j]
+			if (a.ValueUSD == nil) != (b.ValueUSD == nil) {
+				return a.ValueUSD != nil
+			}
+			if a.ValueUSD != nil {
+				av, _ := decimal.NewFromString(*a.ValueUSD)
+				bv, _ := decimal.NewFromString(*b.ValueUSD)
+				if !av.Equal(bv) {
+					return av.GreaterThan(bv)
+				}
+			}
+			return a.Mint < b.Mint
+		})
+
+		for i, w := range wallets {
+			resp.Wallets[i] = PortfolioWallet{ID: w.ID, Name: w.Name, Address: w.Address, WatchOnly: w.WatchOnly, ValueUSD: walletValues[i].StringFixed(2)}
+			if errs[i] != nil {
+				resp.Wallets[i].Error = "Balances unavailable"
+			}
+		}
+		resp.TotalValueUSD = total.StringFixed(2)
+		return c.JSON(resp)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxActivePriceAlerts bounds the alerts a user may have armed at once.
+	maxActivePriceAlerts = 20
+	// Change alert windows: prices are sampled every minute, and kept long enough for a week
+	minAlertWindowMinutes = 5
+	maxAlertWindowMinutes = 7 * 24 * 60
+)
+
+// PriceAlertRequest creates or replaces a price alert. Above and below alerts take TargetUSD;
+// change alerts take ChangePercent (signed: 10 fires on a 10% rise, -10 on a 10% drop) and
+// WindowMinutes.
+type PriceAlertRequest struct {
+	Mint          string `json:"mint,omitempty"` // TEAM556 by default, or "SOL"
+	Kind          string `json:"kind"`           // "above", "below" or "change"
+	TargetUSD     string `json:"targetUsd,omitempty"`
+	ChangePercent string `json:"changePercent,omitempty"`
+	WindowMinutes int    `json:"windowMinutes,omitempty"`
+}
+
+// priceAlertFromRequest validates req, returning the alert it describes or a message for the client.
+func priceAlertFromRequest(req *PriceAlertRequest) (*models.PriceAlert, string) {
+	mint := req.Mint
+	switch mint {
+	case "":
+		mint = team556TokenMint
+	case nativeSOLMint:
+		mint = wrappedSOLMint
+	}
+	// Change alerts compare against recorded history, which is only kept for these
+	if !slices.Contains(jobs.SampledPriceMints, mint) {
+		return nil, "Price alerts are only available for TEAM556 and SOL"
+	}
+	alert := &models.PriceAlert{Mint: mint, Kind: req.Kind, Active: true}
+	switch req.Kind {
+	case models.AlertAbove, models.AlertBelow:
+		target, err := decimal.NewFromString(req.TargetUSD)
+		if err != nil || !target.IsPositive() {
+			return nil, "targetUsd must be a positive USD price"
+		}
+		alert.TargetUSD = target.String()
+	case models.AlertChange:
+		pct, err := decimal.NewFromString(req.ChangePercent)
+		if err != nil || pct.IsZero() || pct.Abs().GreaterThan(decimal.NewFromInt(1000)) || pct.LessThanOrEqual(decimal.NewFromInt(-100)) {
+			return nil, "changePercent must be a non-zero percentage above -100 and at most 1000"
+		}
+		if req.WindowMinutes < minAlertWindowMinutes || req.WindowMinutes > maxAlertWindowMinutes {
+			return nil, "windowMinutes must be between 5 and 10080"
+		}
+		alert.ChangePercent, alert.WindowMinutes = pct.String(), req.WindowMinutes
+	default:
+		return nil, "kind must be above, below or change"
+	}
+	return alert, ""
+}
+
+// priceAlertAlreadyMet checks whether an above or below alert would fire right away, which would
+// make it a notification about the past rather than a crossing. It answers false when the price is
+// unavailable.
+func priceAlertAlreadyMet(c *fiber.Ctx, cfg *config.Config, alert *models.PriceAlert) (bool, string) {
+	if alert.Kind == models.AlertChange {
+		return false, ""
+	}
+	price, err := cfg.Prices.Price(c.Context(), alert.Mint)
+	if err != nil {
+		return false, ""
+	}
+	fires, condition := jobs.AlertCondition(alert, price.USD, decimal.Zero)
+	return fires, condition
+}
+
+// priceAlertFromParam loads the alert named by the :id route parameter for the authenticated user,
+// writing the error response itself when it returns nil.
+func priceAlertFromParam(c *fiber.Ctx, db *gorm.DB) (*models.PriceAlert, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return nil, 0, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, userID, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid price alert ID"})
+	}
+	var alert models.PriceAlert
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&alert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, userID, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Price alert not found"})
+		}
+		log.Printf("Error fetching price alert %d for user %d: %v", id, userID, err)
+		return nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve price alert"})
+	}
+	return &alert, userID, nil
+}
+
+// armPriceAlert validates the request and checks the user's limit, writing the error response
+// itself when it returns nil. excludeID is an alert being replaced.
+func armPriceAlert(c *fiber.Ctx, db *gorm.DB, cfg *config.Config, userID, excludeID uint) (*models.PriceAlert, error) {
+	var req PriceAlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	alert, msg := priceAlertFromRequest(&req)
+	if alert == nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if met, condition := priceAlertAlreadyMet(c, cfg, alert); met {
+		return nil, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The price already " + condition})
+	}
+	var active int64
+	if err := db.Model(&models.PriceAlert{}).Where("user_id = ? AND active = ? AND id <> ?", userID, true, excludeID).Count(&active).Error; err != nil {
+		log.Printf("Error counting price alerts of user %d: %v", userID, err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save price alert"})
+	}
+	if active >= maxActivePriceAlerts {
+		return nil, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You can have at most 20 active price alerts"})
+	}
+	alert.UserID = userID
+	return alert, nil
+}
+
+// ListPriceAlertsHandler returns the user's price alerts, newest first, fired ones included.
+func ListPriceAlertsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		alerts := []models.PriceAlert{}
+		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&alerts).Error; err != nil {
+			log.Printf("Error listing price alerts for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve price alerts"})
+		}
+		return c.JSON(fiber.Map{"alerts": alerts})
+	}
+}
+
+// CreatePriceAlertHandler arms a new price alert. Above and below alerts the price already meets
+// are refused, since they would fire immediately.
+func CreatePriceAlertHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		alert, err := armPriceAlert(c, db, cfg, userID, 0)
+		if alert == nil {
+			return err
+		}
+		if err := db.Create(alert).Error; err != nil {
+			log.Printf("Error creating price alert for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save price alert"})
+		}
+		return c.Status(fiber.StatusCreated).JSON(alert)
+	}
+}
+
+// UpdatePriceAlertHandler replaces an alert's condition and arms it again, which is how a fired
+// alert is turned back on.
+func UpdatePriceAlertHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		existing, userID, err := priceAlertFromParam(c, db)
+		if existing == nil {
+			return err
+		}
+		alert, err := armPriceAlert(c, db, cfg, userID, existing.ID)
+		if alert == nil {
+			return err
+		}
+		alert.ID, alert.CreatedAt = existing.ID, existing.CreatedAt
+		if err := db.Save(alert).Error; err != nil {
+			log.Printf("Error updating price alert %d for user %d: %v", existing.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save price alert"})
+		}
+		return c.JSON(alert)
+	}
+}
+
+// DeletePriceAlertHandler removes an alert.
+func DeletePriceAlertHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		alert, userID, err := priceAlertFromParam(c, db)
+		if alert == nil {
+			return err
+		}
+		if err := db.Delete(alert).Error; err != nil {
+			log.Printf("Error deleting price alert %d for user %d: %v", alert.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete price alert"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
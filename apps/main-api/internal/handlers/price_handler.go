@@ -1,16 +1,19 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/jobs"
 	"github.com/gofiber/fiber/v2"
-	"strconv" // Added for price string validation
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/pricing"
 )
 
 // PriceHandler handles price-related requests.
@@ -23,35 +26,6 @@ func NewPriceHandler(cfg *config.Config) *PriceHandler {
 	return &PriceHandler{Config: cfg}
 }
 
-// AlchemyTokenPriceRequest defines the structure for Alchemy's token price API request.
-type AlchemyTokenPriceRequest struct {
-	Addresses []AlchemyAddress `json:"addresses"`
-}
-
-// AlchemyAddress defines the address and network for the token.
-type AlchemyAddress struct {
-	Network string `json:"network"`
-	Address string `json:"address"`
-}
-
-// AlchemyPriceData holds the price information for a token.
-type AlchemyPriceData struct {
-	Value     string `json:"value"`
-	Timestamp int64  `json:"timestamp"` // Unix timestamp
-	Currency  string `json:"currency"`
-}
-
-// AlchemyTokenPrice holds the token address and its prices.
-type AlchemyTokenPrice struct {
-	TokenAddress string             `json:"tokenAddress"`
-	Prices       []AlchemyPriceData `json:"prices"`
-}
-
-// AlchemyTokenPriceResponse defines the structure for Alchemy's token price API response.
-type AlchemyTokenPriceResponse struct {
-	Data []AlchemyTokenPrice `json:"data"`
-}
-
 // Team556PriceResponse is the structure for our API's response.
 type Team556PriceResponse struct {
 	Token      string `json:"token"`
@@ -59,108 +33,120 @@ type Team556PriceResponse struct {
 	Currency   string `json:"currency"`
 	Source     string `json:"source"`
 	Timestamp  int64  `json:"timestamp"` // Renamed from LastUpdate to match WP plugin expectation more closely
+	Stale      bool   `json:"stale,omitempty"` // Every source is down and this is the last price seen
 }
 
-const (
-	team556TokenMint = "AMNfeXpjD6kXyyTDB4LMKzNWypqNHwtgJUACHUmuKLD5"
-	alchemyNetwork   = "solana-mainnet"
-)
-
-// HandleGetTeam556UsdcPriceAlchemy fetches the TEAM556 price from Alchemy API.
-func (h *PriceHandler) HandleGetTeam556UsdcPriceAlchemy(c *fiber.Ctx) error {
-	if h.Config.AlchemyAPIKey == "" {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Alchemy API Key is not configured",
-		})
-	}
-
-	apiURL := fmt.Sprintf("https://api.g.alchemy.com/prices/v1/%s/tokens/by-address", h.Config.AlchemyAPIKey)
-
-	requestBody := AlchemyTokenPriceRequest{
-		Addresses: []AlchemyAddress{
-			{
-				Network: alchemyNetwork,
-				Address: team556TokenMint,
-			},
-		},
-	}
+const team556TokenMint = "AMNfeXpjD6kXyyTDB4LMKzNWypqNHwtgJUACHUmuKLD5"
 
-	jsonBody, err := json.Marshal(requestBody)
+// HandleGetTeam556UsdcPrice returns the aggregated TEAM556 price in USD. It is served from the
+// price cache, so most requests don't reach any source; when every source is down the last price is
+// returned until it is too old, after which this answers 503.
+func (h *PriceHandler) HandleGetTeam556UsdcPrice(c *fiber.Ctx) error {
+	price, err := h.Config.Prices.Price(c.Context(), team556TokenMint)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to marshal request body", "details": err.Error()})
+		log.Printf("Error fetching TEAM556 price: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "TEAM556 price is currently unavailable"})
 	}
+	return c.Status(fiber.StatusOK).JSON(Team556PriceResponse{
+		Token:     team556TokenMint,
+		PriceUSDC: price.USD.String(),
+		Currency:  "usd",
+		Source:    price.Source,
+		Timestamp: price.UpdatedAt.Unix(),
+		Stale:     price.Stale,
+	})
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create request to Alchemy API", "details": err.Error()})
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+// maxPriceMints bounds the mints one /price/tokens request may ask for.
+const maxPriceMints = 100
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Failed to reach Alchemy API", "details": err.Error()})
-	}
-	defer resp.Body.Close()
+// TokenPrice is one mint's price in the requested currency.
+type TokenPrice struct {
+	Price     string    `json:"price"`
+	USD       string    `json:"usd"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Stale     bool      `json:"stale,omitempty"`
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read response from Alchemy API", "details": err.Error()})
-	}
+// TokenPricesResponse maps each requested mint to its price. Mints no source prices are listed
+// under Unpriced.
+type TokenPricesResponse struct {
+	Currency string                `json:"currency"`
+	Rate     string                `json:"rate"` // Units of Currency per USD
+	Prices   map[string]TokenPrice `json:"prices"`
+	Unpriced []string              `json:"unpriced"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return c.Status(resp.StatusCode).JSON(fiber.Map{
-			"error":             "Alchemy API returned an error",
-			"alchemy_api_status": resp.StatusCode,
-			"alchemy_api_body":   string(bodyBytes), // Be cautious with large bodies
-		})
+// HandleGetTokenPrices returns the prices of ?mints= (comma separated; "SOL" for native SOL) in
+// ?currency= (USD, EUR, GBP or CAD; default USD). Prices are looked up in one batch and cached per
+// mint, so repeated requests for overlapping mints mostly hit the cache.
+func (h *PriceHandler) HandleGetTokenPrices(c *fiber.Ctx) error {
+	currency := strings.ToUpper(c.Query("currency", "USD"))
+	if !slices.Contains(pricing.Currencies, currency) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "currency must be one of " + strings.Join(pricing.Currencies, ", ")})
 	}
-
-	var alchemyResponse AlchemyTokenPriceResponse
-	if err := json.Unmarshal(bodyBytes, &alchemyResponse); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse price response from Alchemy API", "details": err.Error()})
+	var mints, lookup []string
+	for _, mint := range strings.Split(c.Query("mints"), ",") {
+		mint = strings.TrimSpace(mint)
+		if mint == "" || slices.Contains(mints, mint) {
+			continue
+		}
+		priceMint := mint
+		if mint == nativeSOLMint {
+			priceMint = wrappedSOLMint
+		} else if _, err := solana.PublicKeyFromBase58(mint); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid mint address: " + mint})
+		}
+		mints, lookup = append(mints, mint), append(lookup, priceMint)
 	}
-
-	if len(alchemyResponse.Data) == 0 || len(alchemyResponse.Data[0].Prices) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Price data not found for TEAM556 token in Alchemy response"})
+	if len(mints) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "mints is required"})
 	}
-
-	priceStr := alchemyResponse.Data[0].Prices[0].Value
-
-	// Validate priceStr from Alchemy
-	if priceStr == "" {
-		// Optionally log this server-side: log.Println("Error: Alchemy API returned an empty price string for token", team556TokenMint)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Alchemy API returned an empty price string",
-		})
+	if len(mints) > maxPriceMints {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At most 100 mints per request"})
 	}
 
-	priceFloat, err := strconv.ParseFloat(priceStr, 64)
+	rate, err := h.Config.FX.Rate(c.Context(), currency)
 	if err != nil {
-		// Optionally log this server-side: log.Println("Error: Alchemy API returned a non-numeric price string '" + priceStr + "' for token", team556TokenMint, "Details:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Alchemy API returned a non-numeric price string",
-			"details": fmt.Sprintf("Invalid price format received: %s", priceStr),
-		})
+		log.Printf("Error fetching %s exchange rate: %v", currency, err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Exchange rate for " + currency + " is currently unavailable"})
 	}
-
-	if priceFloat <= 0 {
-		// Optionally log this server-side: log.Println("Error: Alchemy API returned a non-positive price value", priceFloat, "for token", team556TokenMint)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Alchemy API returned a non-positive price",
-			"details": fmt.Sprintf("Invalid price value received: %f", priceFloat),
-		})
+	prices, err := h.Config.Prices.Prices(c.Context(), lookup)
+	if err != nil && len(prices) == 0 {
+		log.Printf("Error fetching token prices: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Prices are currently unavailable"})
 	}
-	// priceStr is now validated as a string representing a positive number.
 
-	apiResponse := Team556PriceResponse{
-		Token:      team556TokenMint,
-		PriceUSDC:  priceStr, // Use the string directly
-		Currency:   alchemyResponse.Data[0].Prices[0].Currency, // Should be USD
-		Source:     "alchemy",
-		Timestamp:  alchemyResponse.Data[0].Prices[0].Timestamp,
+	resp := TokenPricesResponse{Currency: currency, Rate: rate.String(), Prices: map[string]TokenPrice{}, Unpriced: []string{}}
+	for i, mint := range mints {
+		p, ok := prices[lookup[i]]
+		if !ok {
+			resp.Unpriced = append(resp.Unpriced, mint)
+			continue
+		}
+		resp.Prices[mint] = TokenPrice{
+			Price:     p.USD.Mul(rate).String(),
+			USD:       p.USD.String(),
+			Source:    p.Source,
+			UpdatedAt: p.UpdatedAt,
+			Stale:     p.Stale,
+		}
 	}
+	return c.JSON(resp)
+}
 
-	return c.Status(fiber.StatusOK).JSON(apiResponse)
+// Team556USDPrice is the price feed swap orders are evaluated against.
+func Team556USDPrice(cfg *config.Config) jobs.PriceFunc {
+	return func(ctx context.Context) (decimal.Decimal, error) {
+		price, err := cfg.Prices.Price(ctx, team556TokenMint)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if price.Stale {
+			// Orders shouldn't fill on a price nobody is quoting anymore
+			return decimal.Zero, errors.New("TEAM556 price is stale")
+		}
+		return price.USD, nil
+	}
 }
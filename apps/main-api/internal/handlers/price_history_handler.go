@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/jobs"
+	"gorm.io/gorm"
+)
+
+// maxCandles bounds one price history response.
+const maxCandles = 1500
+
+// candleResolutions maps each supported resolution to its bucket size, the date_trunc field that
+// produces it, and the range returned when none is given.
+var candleResolutions = map[string]struct {
+	size         time.Duration
+	truncate     string
+	defaultRange time.Duration
+}{
+	"1m": {time.Minute, "minute", 24 * time.Hour},
+	"1h": {time.Hour, "hour", 7 * 24 * time.Hour},
+	"1d": {24 * time.Hour, "day", 365 * 24 * time.Hour},
+}
+
+// Candle is the open, high, low and close USD price within one bucket, starting at Time (unix
+// seconds, UTC). Samples is how many recorded prices it was built from.
+type Candle struct {
+	Time    int64           `json:"time"`
+	Open    decimal.Decimal `json:"open"`
+	High    decimal.Decimal `json:"high"`
+	Low     decimal.Decimal `json:"low"`
+	Close   decimal.Decimal `json:"close"`
+	Samples int             `json:"samples"`
+}
+
+// PriceHistoryResponse holds the candles of a mint between From and To.
+type PriceHistoryResponse struct {
+	Mint       string   `json:"mint"`
+	Resolution string   `json:"resolution"`
+	From       int64    `json:"from"`
+	To         int64    `json:"to"`
+	Candles    []Candle `json:"candles"`
+}
+
+// parseHistoryTime accepts unix seconds or RFC 3339.
+func parseHistoryTime(v string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// PriceHistoryHandler serves OHLC candles of a sampled token's USD price. Query: mint (default
+// TEAM556; "SOL" for SOL), resolution 1m, 1h or 1d (default 1h), and from/to as unix seconds or
+// RFC 3339 (default to now, from a resolution-dependent range before it). Buckets without samples,
+// such as during a price outage, are left out.
+func PriceHistoryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mint := c.Query("mint", team556TokenMint)
+		if mint == nativeSOLMint {
+			mint = wrappedSOLMint
+		}
+		if !slices.Contains(jobs.SampledPriceMints, mint) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Price history is only kept for TEAM556 and SOL"})
+		}
+		resolution := c.Query("resolution", "1h")
+		res, ok := candleResolutions[resolution]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "resolution must be 1m, 1h or 1d"})
+		}
+
+		to := time.Now().UTC()
+		if v := c.Query("to"); v != "" {
+			t, err := parseHistoryTime(v)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to: use unix seconds or RFC 3339"})
+			}
+			to = t.UTC()
+		}
+		from := to.Add(-res.defaultRange)
+		if v := c.Query("from"); v != "" {
+			t, err := parseHistoryTime(v)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from: use unix seconds or RFC 3339"})
+			}
+			from = t.UTC()
+		}
+		// Whole buckets only, so the first candle isn't cut short
+		from = from.Truncate(res.size)
+		if !from.Before(to) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+		}
+		if to.Sub(from)/res.size > maxCandles {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Range spans more than %d candles; use a coarser resolution", maxCandles)})
+		}
+
+		var rows []struct {
+			Bucket                 time.Time
+			Open, High, Low, Close decimal.Decimal
+			Samples                int
+		}
+		err := db.Raw(`SELECT date_trunc(?, sampled_at AT TIME ZONE 'UTC') AS bucket,
+				(array_agg(usd ORDER BY sampled_at))[1] AS open, MAX(usd) AS high, MIN(usd) AS low,
+				(array_agg(usd ORDER BY sampled_at DESC))[1] AS close, COUNT(*) AS samples
+			FROM price_samples WHERE mint = ? AND sampled_at >= ? AND sampled_at < ?
+			GROUP BY bucket ORDER BY bucket`, res.truncate, mint, from, to).Scan(&rows).Error
+		if err != nil {
+			log.Printf("Error fetching price history for %s: %v", mint, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch price history"})
+		}
+
+		resp := PriceHistoryResponse{Mint: mint, Resolution: resolution, From: from.Unix(), To: to.Unix(), Candles: make([]Candle, 0, len(rows))}
+		for _, r := range rows {
+			// The bucket is a UTC wall time without a zone; read it as UTC whatever the driver assumed
+			bucket := time.Date(r.Bucket.Year(), r.Bucket.Month(), r.Bucket.Day(), r.Bucket.Hour(), r.Bucket.Minute(), 0, 0, time.UTC)
+			resp.Candles = append(resp.Candles, Candle{Time: bucket.Unix(), Open: r.Open, High: r.High, Low: r.Low, Close: r.Close, Samples: r.Samples})
+		}
+		return c.JSON(resp)
+	}
+}
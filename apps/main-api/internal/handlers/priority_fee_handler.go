@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// priorityFeeTimeout bounds the getRecentPrioritizationFees lookup.
+	priorityFeeTimeout = 10 * time.Second
+	// maxPriorityFeeAccounts is the most accounts getRecentPrioritizationFees accepts.
+	maxPriorityFeeAccounts = 128
+)
+
+// PriorityFeesRequest asks for compute-unit price tiers. With an unsigned transaction the estimate
+// uses fees paid for its writable accounts and prices its compute budget; otherwise fees across
+// recent slots and the default budget of one instruction.
+type PriorityFeesRequest struct {
+	UnsignedTransaction string   `json:"unsignedTransaction,omitempty"` // Base64 encoded transaction
+	Accounts            []string `json:"accounts,omitempty"`            // Extra accounts the transaction will write
+	ComputeUnitLimit    uint32   `json:"computeUnitLimit,omitempty"`    // Overrides the transaction's limit
+}
+
+// PriorityFeeOption is a fee tier priced for the transaction being built
+type PriorityFeeOption struct {
+	solanarpc.PriorityFeeTier
+	PriorityFeeLamports  uint64 `json:"priorityFeeLamports"`
+	EstimatedFeeLamports uint64 `json:"estimatedFeeLamports"` // Signature fees plus the priority fee
+}
+
+// PriorityFeesResponse lists fee tiers, cheapest first
+type PriorityFeesResponse struct {
+	Tiers            []PriorityFeeOption `json:"tiers"`
+	ComputeUnitLimit uint32              `json:"computeUnitLimit"`
+	BaseFeeLamports  uint64              `json:"baseFeeLamports"`
+	SampledSlots     int                 `json:"sampledSlots"`
+}
+
+// PriorityFeesHandler estimates compute-unit prices from recent prioritization fees so the app can
+// offer fee tiers before the transaction is signed.
+func PriorityFeesHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req PriorityFeesRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+
+		accounts := map[string]bool{}
+		for _, a := range req.Accounts {
+			if _, err := solana.PublicKeyFromBase58(a); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account " + a})
+			}
+			accounts[a] = true
+		}
+		resp := PriorityFeesResponse{ComputeUnitLimit: 200_000, BaseFeeLamports: 5000}
+		if req.UnsignedTransaction != "" {
+			tx, err := solana.TransactionFromBase64(req.UnsignedTransaction)
+			if err != nil || len(tx.Message.AccountKeys) == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid unsigned transaction"})
+			}
+			for _, k := range tx.Message.AccountKeys {
+				if writable, err := tx.Message.IsWritable(k); err == nil && writable {
+					accounts[k.String()] = true
+				}
+			}
+			summary, err := signer.InspectTransaction(req.UnsignedTransaction, tx.Message.AccountKeys[0])
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid unsigned transaction", "details": err.Error()})
+			}
+			resp.ComputeUnitLimit = summary.ComputeUnitLimit
+			resp.BaseFeeLamports = uint64(len(summary.Signers)) * 5000
+		}
+		if req.ComputeUnitLimit > 0 {
+			resp.ComputeUnitLimit = min(req.ComputeUnitLimit, 1_400_000)
+		}
+
+		keys := make([]string, 0, len(accounts))
+		for a := range accounts {
+			keys = append(keys, a)
+		}
+		if len(keys) > maxPriorityFeeAccounts {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Too many accounts; at most 128 are supported"})
+		}
+		ctx, cancel := context.WithTimeout(c.Context(), priorityFeeTimeout)
+		defer cancel()
+		fees, err := solanarpc.RecentPriorityFees(ctx, keys)
+		if err != nil {
+			if errors.Is(err, solanarpc.ErrNotConfigured) {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Solana RPC not configured"})
+			}
+			log.Printf("Error fetching recent prioritization fees: %v", err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to estimate priority fees"})
+		}
+
+		resp.SampledSlots = len(fees)
+		for _, tier := range solanarpc.PriorityFeeTiers(fees) {
+			// Micro-lamports per unit times units, rounded up to whole lamports
+			priority := (tier.MicroLamportsPerComputeUnit*uint64(resp.ComputeUnitLimit) + 999_999) / 1_000_000
+			resp.Tiers = append(resp.Tiers, PriorityFeeOption{
+				PriorityFeeTier:      tier,
+				PriorityFeeLamports:  priority,
+				EstimatedFeeLamports: resp.BaseFeeLamports + priority,
+			})
+		}
+		return c.JSON(resp)
+	}
+}
@@ -0,0 +1,616 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"github.com/team556-mono/server/internal/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxScheduleDuration is how far ahead a schedule may end; the approval covers every run until then.
+	maxScheduleDuration = 366 * 24 * time.Hour
+	maxScheduleLabel    = 50
+	// scheduleLookupTimeout bounds the mint lookup made while creating a schedule.
+	scheduleLookupTimeout = 10 * time.Second
+)
+
+// CreateScheduledTransferRequest defines a recurring transfer. The password unlocks the wallet once,
+// to approve the scheduler's delegate for every run.
+type CreateScheduledTransferRequest struct {
+	WalletSelector
+	Recipient string     `json:"recipient"`           // Owner address; optional when contactId is set
+	ContactID uint       `json:"contactId,omitempty"` // Address book contact to send to
+	Mint      string     `json:"mint,omitempty"`      // Defaults to the TEAM556 token
+	Amount    string     `json:"amount" validate:"required"`
+	Cadence   string     `json:"cadence" validate:"required"` // Five-field cron expression in UTC, e.g. "0 9 1 * *"
+	StartAt   *time.Time `json:"startAt,omitempty"`           // Defaults to now
+	EndAt     time.Time  `json:"endAt" validate:"required"`
+	Label     string     `json:"label,omitempty"`
+	Password  string     `json:"password" validate:"required"`
+}
+
+// CancelScheduledTransferRequest optionally carries the wallet password so the approval can be
+// reduced to what the wallet's remaining schedules need, or revoked.
+type CancelScheduledTransferRequest struct {
+	Password string `json:"password,omitempty"`
+}
+
+// ScheduledTransferResponse is a schedule along with its run history, newest first
+type ScheduledTransferResponse struct {
+	Schedule   models.ScheduledTransfer            `json:"schedule"`
+	Executions []models.ScheduledTransferExecution `json:"executions"`
+}
+
+// scheduleUnavailable answers when scheduled transfers can't run in this deployment.
+func scheduleUnavailable(c *fiber.Ctx, cfg *config.Config) error {
+	if cfg.SchedulerKey == nil || len(solanarpc.Upstreams()) == 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Scheduled transfers are not available"})
+	}
+	return nil
+}
+
+// scheduleFromParam loads the scheduled transfer named by the :id route parameter for the authenticated
+// user, writing the error response itself when it returns nil.
+func scheduleFromParam(c *fiber.Ctx, db *gorm.DB) (*models.ScheduledTransfer, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return nil, 0, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, userID, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid scheduled transfer ID"})
+	}
+	var st models.ScheduledTransfer
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&st).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, userID, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Scheduled transfer not found"})
+		}
+		log.Printf("Error fetching scheduled transfer %d for user %d: %v", id, userID, err)
+		return nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve scheduled transfer"})
+	}
+	return &st, userID, nil
+}
+
+// remainingRuns counts the runs a schedule has left from now on.
+func remainingRuns(st *models.ScheduledTransfer) uint64 {
+	if st.NextRunAt == nil {
+		return 0
+	}
+	schedule, err := jobs.ParseCron(st.Cadence)
+	if err != nil {
+		return 0
+	}
+	return uint64(schedule.CountRuns(st.NextRunAt.Add(-time.Second), st.EndAt))
+}
+
+// scheduleAllowance is the delegate allowance the wallet's active and paused schedules for mint need
+// to finish, leaving out excludeID.
+func scheduleAllowance(db *gorm.DB, walletID uint, mint string, excludeID uint) (uint64, error) {
+	var schedules []models.ScheduledTransfer
+	if err := db.Where("wallet_id = ? AND mint = ? AND status IN ? AND id <> ?", walletID, mint,
+		[]string{models.ScheduleActive, models.SchedulePaused}, excludeID).Find(&schedules).Error; err != nil {
+		return 0, err
+	}
+	var total uint64
+	for i := range schedules {
+		runs := remainingRuns(&schedules[i])
+		if runs > 0 && schedules[i].RawAmount > (math.MaxUint64-total)/runs {
+			return 0, errors.New("allowance overflows")
+		}
+		total += schedules[i].RawAmount * runs
+	}
+	return total, nil
+}
+
+// delegateAllowance is the allowance the scheduler's delegate needs on the wallet's token account for
+// mint: a token account has a single delegate, shared by scheduled transfers and swap orders.
+// excludeScheduleID and excludeOrderID leave out the schedule or order being cancelled.
+func delegateAllowance(db *gorm.DB, walletID uint, mint string, excludeScheduleID, excludeOrderID uint) (uint64, error) {
+	schedules, err := scheduleAllowance(db, walletID, mint, excludeScheduleID)
+	if err != nil {
+		return 0, err
+	}
+	orders, err := orderAllowance(db, walletID, mint, excludeOrderID)
+	if err != nil {
+		return 0, err
+	}
+	if orders > math.MaxUint64-schedules {
+		return 0, errors.New("allowance overflows")
+	}
+	return schedules + orders, nil
+}
+
+// scheduledDailyOutflowUSD is the most the wallet's active schedules and swap orders send in the day
+// after from, in USD at current prices. A limit order counts its one execution.
+func scheduledDailyOutflowUSD(db *gorm.DB, cfg *config.Config, walletID uint, from time.Time) (decimal.Decimal, error) {
+	var schedules []models.ScheduledTransfer
+	if err := db.Where("wallet_id = ? AND status = ?", walletID, models.ScheduleActive).Find(&schedules).Error; err != nil {
+		return decimal.Zero, err
+	}
+	var orders []models.SwapOrder
+	if err := db.Where("wallet_id = ? AND status = ?", walletID, models.OrderActive).Find(&orders).Error; err != nil {
+		return decimal.Zero, err
+	}
+	type outflow struct {
+		mint, amount string
+		runs         int
+	}
+	var outflows []outflow
+	for _, st := range schedules {
+		if schedule, err := jobs.ParseCron(st.Cadence); err == nil {
+			outflows = append(outflows, outflow{st.Mint, st.Amount, schedule.CountRuns(from, from.Add(spendingWindow))})
+		}
+	}
+	for _, o := range orders {
+		runs := 1
+		if o.Kind == models.OrderDCA {
+			schedule, err := jobs.ParseCron(o.Cadence)
+			if err != nil {
+				continue
+			}
+			runs = schedule.CountRuns(from, from.Add(spendingWindow))
+		}
+		outflows = append(outflows, outflow{o.InputMint, o.Amount, runs})
+	}
+	if len(outflows) == 0 {
+		return decimal.Zero, nil
+	}
+	var mints []string
+	for _, out := range outflows {
+		if !slices.Contains(mints, out.mint) {
+			mints = append(mints, out.mint)
+		}
+	}
+	prices, err := cfg.Prices.USDPrices(context.Background(), mints)
+	if err != nil && len(prices) < len(mints) {
+		return decimal.Zero, fmt.Errorf("price schedules: %w", err)
+	}
+	total := decimal.Zero
+	for _, out := range outflows {
+		amount, err := decimal.NewFromString(out.amount)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid amount %q", out.amount)
+		}
+		price, ok := prices[out.mint]
+		if !ok {
+			return decimal.Zero, fmt.Errorf("no USD price for %s", out.mint)
+		}
+		total = total.Add(amount.Mul(price).Mul(decimal.NewFromInt(int64(out.runs))))
+	}
+	return total.Round(2), nil
+}
+
+// recordDelegateApproval stores the approval signature on every schedule and order it covers.
+func recordDelegateApproval(tx *gorm.DB, walletID uint, mint, signature string) error {
+	if err := tx.Model(&models.ScheduledTransfer{}).Where("wallet_id = ? AND mint = ? AND status IN ?", walletID, mint,
+		[]string{models.ScheduleActive, models.SchedulePaused}).Update("approval_signature", signature).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.SwapOrder{}).Where("wallet_id = ? AND input_mint = ? AND status IN ?", walletID, mint,
+		[]string{models.OrderActive, models.OrderPaused}).Update("approval_signature", signature).Error
+}
+
+// sendWalletTransaction signs instructions with the wallet's key as fee payer and lands them.
+func sendWalletTransaction(keypair *signer.Keypair, instructions []solana.Instruction) (*solanarpc.SendResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendAndConfirmTimeout)
+	defer cancel()
+	blockhash, err := solanarpc.LatestBlockhash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get blockhash: %w", err)
+	}
+	unsigned, err := signer.BuildTransaction(instructions, blockhash, keypair.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.SignTransaction(keypair, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	result, err := solanarpc.SendAndConfirm(ctx, signed)
+	if err == nil && result.Status == solanarpc.StatusPending {
+		// It may still land, so it is no more settled than a timeout
+		return nil, fmt.Errorf("transaction %s is still pending", result.Signature)
+	}
+	return result, err
+}
+
+// ListScheduledTransfersHandler returns the user's scheduled transfers, newest first. ?status= filters by state.
+func ListScheduledTransfersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		q := db.Where("user_id = ?", userID)
+		if status := c.Query("status"); status != "" {
+			q = q.Where("status = ?", status)
+		}
+		schedules := []models.ScheduledTransfer{}
+		if err := q.Order("created_at DESC").Find(&schedules).Error; err != nil {
+			log.Printf("Error listing scheduled transfers for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve scheduled transfers"})
+		}
+		return c.JSON(fiber.Map{"scheduledTransfers": schedules})
+	}
+}
+
+// GetScheduledTransferHandler returns a schedule and its last 100 runs.
+func GetScheduledTransferHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		st, userID, err := scheduleFromParam(c, db)
+		if st == nil {
+			return err
+		}
+		executions := []models.ScheduledTransferExecution{}
+		if err := db.Where("scheduled_transfer_id = ?", st.ID).Order("scheduled_for DESC").Limit(100).Find(&executions).Error; err != nil {
+			log.Printf("Error fetching runs of scheduled transfer %d for user %d: %v", st.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve scheduled transfer history"})
+		}
+		return c.JSON(ScheduledTransferResponse{Schedule: *st, Executions: executions})
+	}
+}
+
+// CreateScheduledTransferHandler saves a recurring SPL token transfer. The wallet approves the
+// scheduler's delegate for everything its schedules and swap orders of that token still need, and
+// the schedule becomes active once the approval is confirmed on chain. The amount per run is checked
+// against the wallet's spending policy up front, since runs are signed without the password.
+func CreateScheduledTransferHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		if err := scheduleUnavailable(c, cfg); err != nil {
+			return err
+		}
+
+		var req CreateScheduledTransferRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		if req.Password == "" || req.Amount == "" || req.Cadence == "" || req.EndAt.IsZero() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password, amount, cadence and endAt are required"})
+		}
+		label := strings.TrimSpace(req.Label)
+		if len([]rune(label)) > maxScheduleLabel {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Label must be at most %d characters", maxScheduleLabel)})
+		}
+
+		wallet, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+			}
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+		if wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This is a watch-only wallet and can't sign transactions"})
+		}
+		if wallet.RecoveryRequiredAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+		}
+
+		// --- Recipient ---
+		recipient := strings.TrimSpace(req.Recipient)
+		var contactID *uint
+		if req.ContactID != 0 {
+			var contact models.AddressBookContact
+			if err := db.Where("id = ? AND user_id = ?", req.ContactID, userID).First(&contact).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Contact not found"})
+				}
+				log.Printf("Error fetching contact %d for user %d: %v", req.ContactID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contact"})
+			}
+			if recipient != "" && recipient != contact.Address {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Recipient does not match the contact's address"})
+			}
+			recipient, contactID = contact.Address, &contact.ID
+		}
+		if err := utils.ValidateWalletAddress(recipient); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid recipient: " + err.Error()})
+		}
+		if recipient == wallet.Address {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Recipient is the sending wallet"})
+		}
+
+		// --- Cadence ---
+		schedule, err := jobs.ParseCron(req.Cadence)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cadence: " + err.Error()})
+		}
+		if !schedule.RunsAtMostHourly() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cadence may run at most once an hour"})
+		}
+		now := time.Now()
+		startAt := now
+		if req.StartAt != nil && req.StartAt.After(now) {
+			startAt = *req.StartAt
+		}
+		if req.EndAt.After(now.Add(maxScheduleDuration)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "endAt must be within a year"})
+		}
+		// Next fires strictly after its argument, so step back to allow a run at startAt itself
+		nextRun := jobs.ScheduleNextRun(schedule, startAt.Add(-time.Second), req.EndAt)
+		if nextRun == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The cadence has no runs between startAt and endAt"})
+		}
+
+		// --- Token and amount ---
+		mint := strings.TrimSpace(req.Mint)
+		if mint == "" {
+			mint = team556TokenMint
+		}
+		if mint == nativeSOLMint || mint == wrappedSOLMint {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Only SPL tokens can be scheduled; native SOL can't be delegated"})
+		}
+		mintKey, err := solana.PublicKeyFromBase58(mint)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid token mint"})
+		}
+		ctx, cancel := context.WithTimeout(c.Context(), scheduleLookupTimeout)
+		program, decimals, err := solanarpc.MintInfo(ctx, mint)
+		cancel()
+		if err != nil {
+			if errors.Is(err, solanarpc.ErrAccountNotFound) || errors.Is(err, solanarpc.ErrNotAMint) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown token mint"})
+			}
+			log.Printf("Error looking up mint %s for user %d: %v", mint, userID, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to look up the token"})
+		}
+		amount, err := decimal.NewFromString(strings.TrimSpace(req.Amount))
+		if err != nil || !amount.IsPositive() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be a positive number"})
+		}
+		raw := amount.Shift(int32(decimals))
+		if !raw.Equal(raw.Truncate(0)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Amount has more than %d decimal places", decimals)})
+		}
+		if !raw.BigInt().IsUint64() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Amount is too large"})
+		}
+		rawAmount := raw.BigInt().Uint64()
+
+		// --- Spending policy ---
+		policy, err := loadSpendingPolicy(db, wallet.ID)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		if policy != nil && policyActive(policy.SpendingPolicySettings) {
+			d := decimals
+			run := &signer.TxSummary{
+				BalanceChanges: []signer.BalanceChange{{Mint: mint, Amount: amount.Neg().String(), RawAmount: "-" + raw.String(), Decimals: &d}},
+				Recipients:     []string{recipient},
+			}
+			check, err := checkSpendingPolicy(db, cfg, wallet, policy, run)
+			if err != nil {
+				log.Printf("Error checking spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the schedule against this wallet's spending policy"})
+			}
+			// A day's worth of this schedule's runs, along with the wallet's other schedules and orders, has to fit in the daily limit
+			if limit := policy.DailyLimitUSD; limit != nil {
+				first := nextRun.Add(-time.Second)
+				daily := check.OutflowUSD.Mul(decimal.NewFromInt(int64(schedule.CountRuns(first, first.Add(spendingWindow)))))
+				others, err := scheduledDailyOutflowUSD(db, cfg, wallet.ID, time.Now())
+				if err != nil {
+					log.Printf("Error totalling scheduled transfers of wallet %d (user %d): %v", wallet.ID, userID, err)
+					return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the schedule against this wallet's spending policy"})
+				}
+				if total := daily.Add(others); total.GreaterThan(*limit) {
+					msg := fmt.Sprintf("Schedule sends up to $%s a day, over the $%s daily limit", daily.StringFixed(2), limit.StringFixed(2))
+					if others.IsPositive() {
+						msg = fmt.Sprintf("Schedule sends up to $%s a day and this wallet's other schedules and orders $%s, over the $%s daily limit", daily.StringFixed(2), others.StringFixed(2), limit.StringFixed(2))
+					}
+					check.violate(PolicyDailyLimit, msg)
+				}
+			}
+			if len(check.Violations) > 0 {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Schedule blocked by this wallet's spending policy", "violations": check.Violations})
+			}
+		}
+
+		st := models.ScheduledTransfer{
+			UserID: userID, WalletID: wallet.ID, Label: label,
+			Recipient: recipient, ContactID: contactID,
+			Mint: mint, Symbol: knownTokenSymbols[mint], TokenProgram: program.String(), Decimals: decimals,
+			Amount: amount.String(), RawAmount: rawAmount,
+			Cadence: strings.Join(strings.Fields(req.Cadence), " "), StartAt: startAt, EndAt: req.EndAt, NextRunAt: nextRun,
+			Status: models.ScheduleActive,
+		}
+		allowance, err := delegateAllowance(db, wallet.ID, mint, 0, 0)
+		runs := remainingRuns(&st)
+		if err == nil && rawAmount > (math.MaxUint64-allowance)/runs {
+			err = errors.New("allowance overflows")
+		}
+		if err != nil {
+			log.Printf("Error computing schedule allowance for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The wallet's schedules and swap orders for this token add up to more than can be approved"})
+		}
+		allowance += rawAmount * runs
+
+		// --- Approve the delegate ---
+		keypair, err := unlockWallet(wallet, req.Password)
+		if err == nil {
+			upgradeWalletEncryption(db, cfg.WalletKeys, wallet, req.Password)
+		}
+		req.Password = ""
+		if err != nil {
+			log.Printf("Failed to derive signing key for wallet %d (user %d): %v", wallet.ID, userID, err)
+			if errors.Is(err, signer.ErrDecryptFailed) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet key"})
+		}
+		owner, recipientKey := keypair.PublicKey(), solana.MustPublicKeyFromBase58(recipient)
+		result, err := sendWalletTransaction(keypair, []solana.Instruction{
+			// Runs are paid by the delegate, which doesn't pay rent, so the recipient's token account is created now
+			signer.CreateAssociatedTokenAccountIdempotentInstruction(program, mintKey, recipientKey, owner),
+			signer.ApproveCheckedInstruction(program, mintKey, owner, cfg.SchedulerKey.PublicKey(), allowance, decimals),
+		})
+		keypair.Zero()
+		if err != nil {
+			log.Printf("Error approving schedule delegate for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "Approval status unknown; the schedule was not saved. Try again shortly."})
+		}
+		if result.Status != solanarpc.StatusConfirmed {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Approval transaction " + result.Status, "details": result.Error, "signature": result.Signature})
+		}
+		st.ApprovalSignature = result.Signature
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&st).Error; err != nil {
+				return err
+			}
+			if err := recordDelegateApproval(tx, wallet.ID, mint, result.Signature); err != nil {
+				return err
+			}
+			meta, _ := json.Marshal(map[string]any{"schedule_id": st.ID, "wallet_id": wallet.ID, "recipient": recipient, "mint": mint, "amount": st.Amount, "cadence": st.Cadence, "end_at": st.EndAt, "allowance": allowance, "signature": result.Signature})
+			ip := c.IP()
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "scheduled_transfer_created", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving scheduled transfer for wallet %d (user %d) after approval %s: %v", wallet.ID, userID, result.Signature, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save scheduled transfer", "signature": result.Signature})
+		}
+		log.Printf("Created scheduled transfer %d for wallet %d (user %d), approval %s", st.ID, wallet.ID, userID, result.Signature)
+		return c.Status(fiber.StatusCreated).JSON(st)
+	}
+}
+
+// PauseScheduledTransferHandler stops an active schedule from running. Its approval stays in place.
+func PauseScheduledTransferHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		st, userID, err := scheduleFromParam(c, db)
+		if st == nil {
+			return err
+		}
+		if st.Status != models.ScheduleActive {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only active schedules can be paused"})
+		}
+		if err := db.Model(st).Update("status", models.SchedulePaused).Error; err != nil {
+			log.Printf("Error pausing scheduled transfer %d for user %d: %v", st.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to pause scheduled transfer"})
+		}
+		return c.JSON(st)
+	}
+}
+
+// ResumeScheduledTransferHandler reactivates a paused schedule from its next run after now; runs
+// missed while paused are skipped.
+func ResumeScheduledTransferHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		st, userID, err := scheduleFromParam(c, db)
+		if st == nil {
+			return err
+		}
+		if st.Status != models.SchedulePaused {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only paused schedules can be resumed"})
+		}
+		schedule, err := jobs.ParseCron(st.Cadence)
+		if err != nil {
+			log.Printf("Scheduled transfer %d has an invalid cadence %q: %v", st.ID, st.Cadence, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Scheduled transfer is invalid"})
+		}
+		updates := map[string]any{"status": models.ScheduleActive, "consecutive_failures": 0, "next_run_at": nil}
+		if next := jobs.ScheduleNextRun(schedule, time.Now(), st.EndAt); next != nil {
+			updates["next_run_at"] = *next
+		} else {
+			updates["status"] = models.ScheduleCompleted
+		}
+		if err := db.Model(st).Updates(updates).Error; err != nil {
+			log.Printf("Error resuming scheduled transfer %d for user %d: %v", st.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resume scheduled transfer"})
+		}
+		return c.JSON(st)
+	}
+}
+
+// CancelScheduledTransferHandler ends a schedule for good. With the wallet password the approval is
+// also lowered to what the wallet's other schedules and swap orders of the token still need, or
+// revoked when none are left; without it the delegate keeps the allowance, though nothing will use it for this schedule.
+func CancelScheduledTransferHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		st, userID, err := scheduleFromParam(c, db)
+		if st == nil {
+			return err
+		}
+		var req CancelScheduledTransferRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+			}
+		}
+		if st.Status == models.ScheduleCancelled || st.Status == models.ScheduleCompleted {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Schedule has already ended"})
+		}
+
+		resp := fiber.Map{"cancelled": true, "approvalUpdated": false}
+		if req.Password != "" {
+			if err := scheduleUnavailable(c, cfg); err != nil {
+				return err
+			}
+			wallet, err := findUserWallet(db, userID, WalletSelector{WalletID: st.WalletID})
+			if err != nil {
+				log.Printf("Error fetching wallet %d for user %d: %v", st.WalletID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+			}
+			allowance, err := delegateAllowance(db, wallet.ID, st.Mint, st.ID, 0)
+			if err != nil {
+				log.Printf("Error computing schedule allowance for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel scheduled transfer"})
+			}
+			keypair, err := unlockWallet(wallet, req.Password)
+			req.Password = ""
+			if err != nil {
+				if errors.Is(err, signer.ErrDecryptFailed) {
+					return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."})
+				}
+				log.Printf("Failed to derive signing key for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet key"})
+			}
+			program, mint := solana.MustPublicKeyFromBase58(st.TokenProgram), solana.MustPublicKeyFromBase58(st.Mint)
+			ix := signer.RevokeInstruction(program, mint, keypair.PublicKey())
+			if allowance > 0 {
+				ix = signer.ApproveCheckedInstruction(program, mint, keypair.PublicKey(), cfg.SchedulerKey.PublicKey(), allowance, st.Decimals)
+			}
+			result, err := sendWalletTransaction(keypair, []solana.Instruction{ix})
+			keypair.Zero()
+			if err != nil || result.Status != solanarpc.StatusConfirmed {
+				log.Printf("Error updating schedule approval for wallet %d (user %d): %v %+v", wallet.ID, userID, err, result)
+				return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to update the approval; the schedule was not cancelled"})
+			}
+			resp["approvalUpdated"], resp["signature"], resp["remainingAllowance"] = true, result.Signature, allowance
+		}
+
+		if err := db.Model(st).Updates(map[string]any{"status": models.ScheduleCancelled, "next_run_at": nil}).Error; err != nil {
+			log.Printf("Error cancelling scheduled transfer %d for user %d: %v", st.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel scheduled transfer"})
+		}
+		meta, _ := json.Marshal(map[string]any{"schedule_id": st.ID, "wallet_id": st.WalletID, "approval_updated": resp["approvalUpdated"]})
+		ip := c.IP()
+		if err := db.Create(&models.SecurityAuditLog{UserID: userID, Action: "scheduled_transfer_cancelled", IP: &ip, Meta: datatypes.JSON(meta)}).Error; err != nil {
+			log.Printf("Error writing audit log for user %d: %v", userID, err)
+		}
+		return c.JSON(resp)
+	}
+}
@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/jobs"
 	"github.com/team556-mono/server/internal/models"
 	"github.com/team556-mono/server/internal/security"
 )
@@ -42,6 +47,14 @@ type enableTotpReq struct { Code string `json:"code"` }
 
 type disableMfaReq struct { Code string `json:"code"` }
 
+type changeEmailReq struct {
+	NewEmail        string `json:"newEmail"`
+	CurrentPassword string `json:"currentPassword"`
+	TotpCode        string `json:"totpCode"`
+}
+
+type confirmEmailReq struct { Code string `json:"code"` }
+
 // --- Handlers ---
 
 // GetSecurityOverview implements GET /me/security
@@ -117,7 +130,7 @@ func (h *SecurityHandler) ChangePassword(c *fiber.Ctx) error {
 	var user models.User
 	if err := h.DB.First(&user, userID).Error; err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()}) }
 	// Verify current password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+	if ok, _, _ := security.VerifyPassword(user.Password, req.CurrentPassword); !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_credentials", "message": "Current password is incorrect"}})
 	}
 	// If MFA is enabled, require TOTP or recovery code
@@ -130,10 +143,20 @@ func (h *SecurityHandler) ChangePassword(c *fiber.Ctx) error {
 		if !ok { return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_mfa", "message": "Invalid TOTP or recovery code"}}) }
 	}
 	// Hash and update
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hash, err := security.HashPassword(req.NewPassword)
 	if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to hash password"}) }
 	now := time.Now()
-	if err := h.DB.Model(&user).Updates(map[string]any{"password": string(hash), "password_changed_at": &now}).Error; err != nil {
+	// Wallet secrets are encrypted with the account password; re-key them in the same transaction
+	var locked []uint
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]any{"password": hash, "password_changed_at": &now}).Error; err != nil {
+			return err
+		}
+		var err error
+		locked, err = reencryptWalletSecrets(tx, h.Cfg.WalletKeys, user.ID, req.CurrentPassword, req.NewPassword)
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	// Audit
@@ -142,7 +165,10 @@ func (h *SecurityHandler) ChangePassword(c *fiber.Ctx) error {
 	if h.EmailClient != nil {
 		go h.EmailClient.SendPasswordChangedEmail(user.Email)
 	}
-	return c.JSON(fiber.Map{"ok": true})
+	if locked == nil {
+		locked = []uint{}
+	}
+	return c.JSON(fiber.Map{"ok": true, "walletsNeedingRecovery": locked})
 }
 
 // BeginTOTPSetup implements POST /me/mfa/totp/setup
@@ -259,3 +285,168 @@ func (h *SecurityHandler) RevokeSession(c *fiber.Ctx) error {
 	_ = h.DB.Create(&models.SecurityAuditLog{UserID: userID, Action: "session_revoked"}).Error
 	return c.JSON(fiber.Map{"ok": true})
 }
+
+// currentSessionID returns the most recent active session matching the caller's IP/UA (0 if none).
+func (h *SecurityHandler) currentSessionID(c *fiber.Ctx, userID uint) uint {
+	var sess models.UserSession
+	if err := h.DB.Where("user_id = ? AND ip = ? AND user_agent = ? AND is_revoked = false", userID, c.IP(), c.Get("User-Agent")).
+		Order("created_at DESC").First(&sess).Error; err != nil {
+		return 0
+	}
+	return sess.ID
+}
+
+// RequestEmailChange implements POST /me/email
+func (h *SecurityHandler) RequestEmailChange(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	var req changeEmailReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"code": "bad_request", "message": "invalid JSON"}})
+	}
+	if err := validator.New().Var(req.NewEmail, "required,email"); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_email", "message": "A valid new email address is required"}})
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()}) }
+	if ok, _, _ := security.VerifyPassword(user.Password, req.CurrentPassword); !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_credentials", "message": "Current password is incorrect"}})
+	}
+	if user.MFAEnabled {
+		if req.TotpCode == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": fiber.Map{"code": "mfa_required", "message": "TOTP or recovery code required"}})
+		}
+		ok, err := security.VerifyMFA(h.DB, h.Cfg, user.ID, req.TotpCode)
+		if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()}) }
+		if !ok { return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_mfa", "message": "Invalid TOTP or recovery code"}}) }
+	}
+
+	change, code, err := security.BeginEmailChange(h.DB, &user, req.NewEmail, c.IP())
+	switch {
+	case errors.Is(err, security.ErrEmailUnchanged):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": fiber.Map{"code": "email_unchanged", "message": "New email matches your current email"}})
+	case errors.Is(err, security.ErrEmailInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fiber.Map{"code": "email_in_use", "message": "That email address is already in use"}})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if h.EmailClient != nil {
+		token, err := security.IssueLinkToken(h.Cfg.JWTSecret, security.EmailChangeCancelPurpose, user.ID, security.EmailChangeRef(change), security.EmailChangeTTL)
+		if err != nil {
+			log.Printf("Error issuing email change cancel token for user %d: %v", user.ID, err)
+		} else {
+			cancelURL := fmt.Sprintf("%s/api/auth/email-change/cancel?token=%s", strings.TrimRight(h.Cfg.PublicAPIURL, "/"), url.QueryEscape(token))
+			go h.EmailClient.SendEmailChangeNoticeEmail(change.OldEmail, change.NewEmail, cancelURL)
+		}
+		go h.EmailClient.SendEmailChangeCodeEmail(change.NewEmail, code)
+	}
+	return c.JSON(fiber.Map{"ok": true, "pendingEmail": change.NewEmail, "expiresAt": change.ExpiresAt})
+}
+
+// ConfirmEmailChange implements POST /me/email/confirm
+func (h *SecurityHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	var req confirmEmailReq
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"code": "bad_request", "message": "code required"}})
+	}
+	change, err := security.ConfirmEmailChange(h.DB, userID, req.Code, h.currentSessionID(c, userID), c.IP())
+	switch {
+	case errors.Is(err, security.ErrNoPendingEmailChange):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fiber.Map{"code": "not_found", "message": "No pending email change"}})
+	case errors.Is(err, security.ErrInvalidEmailCode):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_code", "message": "Invalid confirmation code"}})
+	case errors.Is(err, security.ErrEmailInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fiber.Map{"code": "email_in_use", "message": "That email address is already in use"}})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.EmailClient != nil {
+		go h.EmailClient.SendEmailChangedEmail(change.OldEmail, change.NewEmail)
+	}
+	return c.JSON(fiber.Map{"ok": true, "email": change.NewEmail})
+}
+
+// CancelEmailChange implements GET /auth/email-change/cancel?token=... (link sent to the old address)
+func (h *SecurityHandler) CancelEmailChange(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"code": "bad_request", "message": "token required"}})
+	}
+	if _, err := security.CancelEmailChange(h.DB, h.Cfg.JWTSecret, token, c.IP()); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_link", "message": "This link is invalid, expired, or the change was already completed"}})
+	}
+	return c.JSON(fiber.Map{"ok": true, "message": "The email change was cancelled. We recommend resetting your password."})
+}
+
+// RequestDataExport implements POST /me/export. The archive is built by the background export job.
+func (h *SecurityHandler) RequestDataExport(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	var inFlight int64
+	if err := h.DB.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.DataExportPending, models.DataExportRunning}).
+		Count(&inFlight).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if inFlight > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fiber.Map{"code": "export_in_progress", "message": "A data export is already being prepared"}})
+	}
+
+	export := models.DataExport{UserID: userID, Status: models.DataExportPending}
+	ip := c.IP()
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&export).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "data_export_requested", IP: &ip}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"ok": true, "export": export})
+}
+
+// GetDataExport implements GET /me/export (status of the most recent export)
+func (h *SecurityHandler) GetDataExport(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	var export models.DataExport
+	err := h.DB.Omit("archive").Where("user_id = ?", userID).Order("created_at DESC").First(&export).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fiber.Map{"code": "not_found", "message": "No data export requested"}})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"export": export})
+}
+
+// DownloadDataExport implements GET /exports/download?token=... (signed link from the export email)
+func (h *SecurityHandler) DownloadDataExport(c *fiber.Ctx) error {
+	invalid := func() error {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fiber.Map{"code": "invalid_link", "message": "This download link is invalid or has expired"}})
+	}
+	claims, err := security.ParseLinkToken(h.Cfg.JWTSecret, jobs.DataExportDownloadPurpose, c.Query("token"))
+	if err != nil {
+		return invalid()
+	}
+	var export models.DataExport
+	if err := h.DB.Where("id = ? AND user_id = ? AND status = ? AND expires_at > ?", claims.Ref, claims.UID, models.DataExportReady, time.Now()).
+		First(&export).Error; err != nil {
+		return invalid()
+	}
+
+	now := time.Now()
+	ip := c.IP()
+	if err := h.DB.Model(&export).Update("downloaded_at", &now).Error; err != nil {
+		log.Printf("Error recording download of data export %d: %v", export.ID, err)
+	}
+	if err := h.DB.Create(&models.SecurityAuditLog{UserID: export.UserID, Action: "data_export_downloaded", IP: &ip}).Error; err != nil {
+		log.Printf("Error writing data export audit log for user %d: %v", export.UserID, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="team556-data-%s.zip"`, export.CompletedAt.UTC().Format("20060102")))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(export.Archive)
+}
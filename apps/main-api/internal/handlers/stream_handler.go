@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/events"
+	"github.com/team556-mono/server/internal/jobs"
+)
+
+// streamKeepAlive is how often an idle stream gets a comment line, which keeps proxies from closing
+// it and lets the server notice clients that went away.
+const streamKeepAlive = 25 * time.Second
+
+var streamEventTypes = map[string]bool{
+	events.TypePrice:          true,
+	events.TypeBalance:        true,
+	events.TypePaymentRequest: true,
+}
+
+// writeStreamEvent writes one server-sent event.
+func writeStreamEvent(w *bufio.Writer, eventType string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, body)
+	return err
+}
+
+// StreamHandler streams live updates to the signed-in user as server-sent events: price ticks,
+// balance changes of their wallets, and status changes of payment requests to their wallets. The
+// optional types query parameter (comma-separated) limits the stream to some event types. The
+// stream starts with a ready event and the current TEAM556 price; other state should be loaded
+// over REST, as only changes are streamed.
+func StreamHandler(cfg *config.Config, hub *events.Hub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		wanted := streamEventTypes
+		if types := c.Query("types"); types != "" {
+			wanted = map[string]bool{}
+			for _, t := range strings.Split(types, ",") {
+				t = strings.TrimSpace(t)
+				if !streamEventTypes[t] {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown event type: " + t})
+				}
+				wanted[t] = true
+			}
+		}
+
+		// The fiber context is released when this handler returns, before the stream is written
+		ready := make([]string, 0, len(wanted))
+		for t := range wanted {
+			ready = append(ready, t)
+		}
+		sort.Strings(ready)
+
+		var initial []events.Event
+		if wanted[events.TypePrice] {
+			if price, err := cfg.Prices.Price(c.Context(), team556TokenMint); err == nil {
+				initial = append(initial, jobs.NewPriceEvent(price))
+			} else {
+				log.Printf("Error fetching TEAM556 price for stream of user %d: %v", userID, err)
+			}
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		sub := hub.Subscribe(userID)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer sub.Close()
+			keepAlive := time.NewTicker(streamKeepAlive)
+			defer keepAlive.Stop()
+
+			if err := writeStreamEvent(w, "ready", fiber.Map{"types": ready}); err != nil {
+				return
+			}
+			for _, e := range initial {
+				if err := writeStreamEvent(w, e.Type, e.Data); err != nil {
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+			for {
+				select {
+				case e, open := <-sub.C:
+					if !open {
+						return
+					}
+					if !wanted[e.Type] {
+						continue
+					}
+					if err := writeStreamEvent(w, e.Type, e.Data); err != nil {
+						log.Printf("Error writing %s event to user %d: %v", e.Type, userID, err)
+						return
+					}
+				case <-keepAlive.C:
+					if _, err := w.WriteString(": keepalive\n\n"); err != nil {
+						return
+					}
+				}
+				// A failed flush means the client disconnected
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+		return nil
+	}
+}
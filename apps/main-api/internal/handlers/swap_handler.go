@@ -3,25 +3,19 @@ package handlers
 import (
 	"bytes"
 	"context"
-	"crypto/ed25519"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/gagliardetto/solana-go"
 	"github.com/gofiber/fiber/v2"
 	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/crypto"
 	"github.com/team556-mono/server/internal/models"
-	bip39 "github.com/tyler-smith/go-bip39"
-
-	// Imports for lyonnee/key25519 based derivation
-	"github.com/lyonnee/key25519/bip32"
-	"github.com/lyonnee/key25519/bip44"
+	"github.com/team556-mono/server/internal/signer"
 
 	"gorm.io/gorm"
 )
@@ -58,17 +52,32 @@ type SolanaAPIQuoteRequest struct {
 	SlippageBps *int   `json:"slippageBps,omitempty"`
 }
 
+// GetQuoteResponse wraps the Jupiter quote with the ID /execute accepts it by
+type GetQuoteResponse struct {
+	QuoteResponse json.RawMessage `json:"quoteResponse"`
+	QuoteID       string          `json:"quoteId"`
+	ExpiresAt     time.Time       `json:"expiresAt"`
+}
+
 // ExecuteSwapRequest defines the body for the /execute request from the frontend
 type ExecuteSwapRequest struct {
-	QuoteResponse json.RawMessage `json:"quoteResponse"` // Pass the raw JSON quote object
-	Password      string          `json:"password"`       // User's password for decryption
+	QuoteID  string `json:"quoteId"`  // Issued by /quote; raw quotes from the client are not accepted
+	Password string `json:"password"` // User's password for decryption
+	WalletSelector                     // Optional: wallet to swap from; default wallet if empty
 }
 
-// SolanaAPISwapRequest defines the body sent to the solana-api /swap endpoint
+// SolanaAPISwapRequest defines the body sent to the solana-api /swap endpoint.
+// Only the public key is sent; solana-api returns an unsigned transaction for main-api to sign.
 type SolanaAPISwapRequest struct {
 	QuoteResponse       json.RawMessage `json:"quoteResponse"`
-	UserPublicKeyString   string          `json:"userPublicKeyString"` // Renamed field and tag
-	UserPrivateKeyBase64 string          `json:"userPrivateKeyBase64,omitempty"` // Add back as optional
+	UserPublicKeyString string          `json:"userPublicKeyString"`
+}
+
+// SolanaAPISwapBuildResponse is returned by solana-api /swap when the swap is ready to sign
+type SolanaAPISwapBuildResponse struct {
+	Status               string `json:"status"` // "needs_signature"
+	SwapTransaction      string `json:"swapTransaction"` // Base64 encoded unsigned VersionedTransaction
+	LastValidBlockHeight uint64 `json:"lastValidBlockHeight"`
 }
 
 // SolanaAPISwapResponse from solana-api should contain the signature
@@ -80,10 +89,17 @@ type SolanaAPISwapResponse struct {
 type CreateTokenAccountsRequest struct {
 	SignedTransaction string `json:"signedTransaction"` // Base64 encoded signed transaction
 	Password          string `json:"password"`          // User's password for decryption
+	WalletSelector
 }
 
-// HandleGetSwapQuote fetches a swap quote by proxying the request to the solana-api
+// HandleGetSwapQuote fetches a swap quote from the solana-api, checks it against the slippage and
+// price impact limits and stores it under a short-lived ID for /execute.
 func (h *SwapHandler) HandleGetSwapQuote(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User ID not found or invalid in context"})
+	}
+
 	// 1. Parse request body from frontend
 	var reqBody GetQuoteRequest
 	if err := c.BodyParser(&reqBody); err != nil {
@@ -94,6 +110,9 @@ func (h *SwapHandler) HandleGetSwapQuote(c *fiber.Ctx) error {
 	if reqBody.InputMint == "" || reqBody.OutputMint == "" || reqBody.Amount == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required fields: inputMint, outputMint, amount"})
 	}
+	if reqBody.SlippageBps != nil && (*reqBody.SlippageBps < 0 || *reqBody.SlippageBps > h.Cfg.SwapMaxSlippageBps) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("slippageBps must be between 0 and %d", h.Cfg.SwapMaxSlippageBps)})
+	}
 
 	// 2. Construct request for solana-api
 	solanaAPIURL := fmt.Sprintf("%s/api/swap/quote", h.Cfg.SolanaAPIURL)
@@ -136,31 +155,22 @@ func (h *SwapHandler) HandleGetSwapQuote(c *fiber.Ctx) error {
 		return c.Status(resp.StatusCode).JSON(fiber.Map{"error": "Received non-OK status from solana-api", "details": string(responseBodyBytes)})
 	}
 
-	// 6. Unmarshal the successful quote response from solana-api
-	var rawQuoteResponse interface{}
-	if err := json.Unmarshal(responseBodyBytes, &rawQuoteResponse); err != nil {
-		c.SendStatus(fiber.StatusInternalServerError)
-		return fmt.Errorf("failed to unmarshal quote response from solana-api: %w", err)
-	}
-
-	// 7. Wrap the quote response in the structure expected by the frontend
-	type FrontendQuoteResponse struct {
-		QuoteResponse interface{} `json:"quoteResponse"`
-	}
-	frontendResponse := FrontendQuoteResponse{
-		QuoteResponse: rawQuoteResponse,
-	}
-
-	// 8. Marshal the new structure and send it
-	finalPayloadBytes, err := json.Marshal(frontendResponse)
+	// 6. Check the quote and store it so /execute can only run what was checked here
+	quote, reason, err := issueSwapQuote(h.DB, h.Cfg, userID, &reqBody, responseBodyBytes)
 	if err != nil {
-		c.SendStatus(fiber.StatusInternalServerError)
-		return fmt.Errorf("failed to marshal final response for frontend: %w", err)
+		fmt.Printf("Error issuing swap quote for user %d: %v\n", userID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Received an invalid quote from the swap service"})
+	}
+	if reason != "" {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": reason})
 	}
 
-	// Send the wrapped response
-	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
-	return c.Status(http.StatusOK).Send(finalPayloadBytes)
+	// 7. Wrap the quote response in the structure expected by the frontend
+	return c.Status(http.StatusOK).JSON(GetQuoteResponse{
+		QuoteResponse: json.RawMessage(responseBodyBytes),
+		QuoteID:       quote.ID,
+		ExpiresAt:     quote.ExpiresAt,
+	})
 }
 
 // HandleExecuteSwap handles the swap execution process (Revised Architecture)
@@ -180,13 +190,13 @@ func (h *SwapHandler) HandleExecuteSwap(c *fiber.Ctx) error {
 	if err := c.BodyParser(&reqBody); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse request body", "details": err.Error()})
 	}
-	if reqBody.Password == "" || len(reqBody.QuoteResponse) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required fields: quoteResponse, password"})
+	if reqBody.Password == "" || reqBody.QuoteID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required fields: quoteId, password"})
 	}
 
 	// 3. Fetch user's wallet
-	var userWallet models.Wallet
-	if err := h.DB.WithContext(ctx).Where("user_id = ?", userID).First(&userWallet).Error; err != nil {
+	userWallet, err := findUserWallet(h.DB.WithContext(ctx), userID, reqBody.WalletSelector)
+	if err != nil {
 		// Securely clear potentially sensitive password before returning
 		reqBody.Password = ""
 		if err == gorm.ErrRecordNotFound {
@@ -195,9 +205,20 @@ func (h *SwapHandler) HandleExecuteSwap(c *fiber.Ctx) error {
 		fmt.Printf("Error fetching wallet for user %d: %v\n", userID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error fetching wallet"})
 	}
+	if userWallet.WatchOnly {
+		reqBody.Password = ""
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Watch-only wallets can't swap"})
+	}
+	if userWallet.RecoveryRequiredAt != nil {
+		reqBody.Password = ""
+		return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+	}
 
-	// 4. Decrypt mnemonic
-	mnemonic, err := crypto.DecryptMnemonic(userWallet.EncryptedMnemonic, userWallet.EncryptionMetadata, reqBody.Password)
+	// 4. Decrypt mnemonic and derive the signing key
+	keypair, err := unlockWallet(userWallet, reqBody.Password)
+	if err == nil {
+		upgradeWalletEncryption(h.DB, h.Cfg.WalletKeys, userWallet, reqBody.Password)
+	}
 	// Clear password from memory ASAP regardless of decryption success/failure
 	reqBody.Password = ""
 	if err != nil {
@@ -209,89 +230,152 @@ func (h *SwapHandler) HandleExecuteSwap(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decrypt wallet key"})
 	}
 
-	// --- Derive User Keypair from Mnemonic using Standard Path (lyonnee/key25519) ---
-	seed := bip39.NewSeed(mnemonic, "")
-	mnemonic = "" // zero out mnemonic asap
-
-	// Create master key from seed
-	masterKey := bip32.GenerateMasterKey(seed)
-
-	// Define the standard Solana derivation path
-	path := "m/44'/501'/0'/0'"
-	indices, err := bip44.ParsePath(path)
-	if err != nil {
-		for i := range seed { seed[i] = 0 } // Clear seed
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse derivation path", "details": err.Error()})
+	// --- Derive User Keypair in-process; only the public key and signed transactions leave main-api ---
+	userPublicKeyString := keypair.PublicKey().String()
+	defer keypair.Zero()
+
+	// 5. Claim the server-issued quote; limits are re-checked in case they were tightened since it was issued
+	quote, err := claimSwapQuote(h.DB, userID, reqBody.QuoteID)
+	switch {
+	case errors.Is(err, errQuoteNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Quote not found; request a new quote"})
+	case errors.Is(err, errQuoteExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "Quote expired; request a new quote"})
+	case errors.Is(err, errQuoteUsed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Quote has already been used; request a new quote"})
+	case err != nil:
+		fmt.Printf("Error claiming swap quote for user %d: %v\n", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error fetching quote"})
+	}
+	var checked jupiterQuote
+	if err := json.Unmarshal(quote.QuoteResponse, &checked); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Stored quote is invalid; request a new quote"})
+	}
+	if reason := checkSwapQuote(h.Cfg, &checked); reason != "" {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": reason})
+	}
+	// Nothing has been signed until step 7, so earlier failures leave the quote usable
+	var spend *models.WalletSpend // Reserved against the daily limit until the swap is signed
+	release := func() {
+		if err := releaseSwapQuote(h.DB, quote.ID); err != nil {
+			fmt.Printf("Error releasing swap quote %s for user %d: %v\n", quote.ID, userID, err)
+		}
+		if spend != nil {
+			if err := h.DB.Delete(spend).Error; err != nil {
+				fmt.Printf("Error releasing spend %d for wallet %d (user %d): %v\n", spend.ID, userWallet.ID, userID, err)
+			}
+		}
 	}
 
-	// Derive the key by iterating through path indices
-	derivedKey := masterKey
-	for _, index := range indices {
-		derivedKey = bip32.CKDPriv(derivedKey, index)
+	// The wallet's spending policy applies to swaps too: the quoted input leaves the wallet
+	policy, err := loadSpendingPolicy(h.DB, userWallet.ID)
+	if err != nil {
+		release()
+		fmt.Printf("Error loading spending policy for wallet %d (user %d): %v\n", userWallet.ID, userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+	}
+	if policy != nil && policyActive(policy.SpendingPolicySettings) {
+		check, err := checkSwapPolicy(ctx, h.DB, h.Cfg, userWallet, policy, &checked)
+		if err != nil {
+			release()
+			fmt.Printf("Error checking spending policy for wallet %d (user %d): %v\n", userWallet.ID, userID, err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the swap against this wallet's spending policy"})
+		}
+		if len(check.Violations) > 0 {
+			release()
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Swap blocked by this wallet's spending policy", "violations": check.Violations})
+		}
+		if spend, err = reserveWalletSpend(h.DB, userWallet, check); err != nil {
+			release()
+			if errors.Is(err, errDailyLimitExceeded) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Swap blocked by this wallet's spending policy", "violations": []signer.TxWarning{{Code: PolicyDailyLimit, Message: "Swap would exceed the daily spending limit"}}})
+			}
+			fmt.Printf("Error recording spend for wallet %d (user %d): %v\n", userWallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record the swap against the spending policy"})
+		}
 	}
 
-	// Convert the derived private key bytes to an ed25519.PrivateKey
-	finalEd25519PrivKey := ed25519.NewKeyFromSeed(derivedKey.PrivKey)
-
-	// Get the public key string (using gagliardetto/solana-go type for consistency)
-	userPublicKey := solana.PublicKey(finalEd25519PrivKey.Public().(ed25519.PublicKey))
-	userPublicKeyString := userPublicKey.String()
-
-	// Convert the 32-byte private seed to base64 for sending
-	secretKeyBase64 := base64.StdEncoding.EncodeToString(derivedKey.PrivKey) // Send the 32-byte seed
-
-	// Clear sensitive data
-	for i := range seed { seed[i] = 0 }
-	for i := range derivedKey.PrivKey { derivedKey.PrivKey[i] = 0 }
-
-	// --- Call Solana API Swap Endpoint ---
-	// The solana-api /swap endpoint now expects userPublicKeyString
-	solanaAPIURL := fmt.Sprintf("%s/api/swap/swap", h.Cfg.SolanaAPIURL)
+	// 6. Ask solana-api to build the unsigned swap transaction
 	solanaReqBody := SolanaAPISwapRequest{
-		QuoteResponse:       reqBody.QuoteResponse,
+		QuoteResponse:       json.RawMessage(quote.QuoteResponse),
 		UserPublicKeyString: userPublicKeyString,
-		UserPrivateKeyBase64: secretKeyBase64, // Send the 32-byte seed (base64 encoded)
 	}
-
-	payloadBytes, err := json.Marshal(solanaReqBody)
+	status, responseBodyBytes, err := h.postToSolanaAPI("/api/swap/swap", solanaReqBody)
 	if err != nil {
-		fmt.Printf("Error marshaling swap request for solana-api: %v\n", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error creating swap request"})
+		release()
+		fmt.Printf("Error calling solana-api swap build: %v\n", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to connect to swap execution service"})
 	}
 
-	// 7. Call solana-api /swap endpoint
-	httpClient := &http.Client{Timeout: 90 * time.Second} // Increased timeout to 90 seconds
-	resp, err := httpClient.Post(solanaAPIURL, "application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		fmt.Printf("Error sending swap execution request to solana-api: %v\n", err)
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to connect to swap execution service"})
+	// Non-200 (including 202 needs_token_accounts) is forwarded to the client unchanged
+	if status != http.StatusOK {
+		release()
+		fmt.Printf("Non-OK status from solana-api swap build: %d - %s\n", status, string(responseBodyBytes))
+		var errorResp map[string]interface{}
+		if json.Unmarshal(responseBodyBytes, &errorResp) == nil {
+			return c.Status(status).JSON(errorResp)
+		}
+		return c.Status(status).JSON(fiber.Map{"error": "Received error from swap execution service", "details": string(responseBodyBytes)})
 	}
-	defer resp.Body.Close()
 
-	// 8. Read response body from solana-api
-	responseBodyBytes, err := io.ReadAll(resp.Body)
+	var built SolanaAPISwapBuildResponse
+	if err := json.Unmarshal(responseBodyBytes, &built); err != nil || built.SwapTransaction == "" {
+		release()
+		fmt.Printf("Unexpected swap build response from solana-api: %s\n", string(responseBodyBytes))
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Received invalid swap transaction from swap execution service"})
+	}
+
+	// 7. Sign locally
+	signedTx, err := signer.SignTransaction(keypair, built.SwapTransaction)
 	if err != nil {
-		fmt.Printf("Error reading swap execution response from solana-api: %v\n", err)
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to read response from swap execution service"})
+		release()
+		fmt.Printf("Error signing swap transaction for user %d: %v\n", userID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to sign swap transaction", "details": err.Error()})
 	}
 
-	// 9. Check solana-api response status and forward
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Non-OK status from solana-api swap execution: %d - %s\n", resp.StatusCode, string(responseBodyBytes))
-		// Try to forward the error structure from solana-api directly
+	// 8. Record the swap under its signature before it can land, so history never misses one
+	record := recordSwap(h.DB, userWallet, quote, &checked, signedTx)
+
+	// 9. Submit the signed transaction and forward the result (signature on success)
+	status, responseBodyBytes, err = h.postToSolanaAPI("/api/swap/submit", fiber.Map{"signedTransaction": signedTx})
+	if err != nil {
+		// The transaction may still land; the settle job finds out
+		fmt.Printf("Error submitting swap transaction to solana-api: %v\n", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to connect to swap execution service"})
+	}
+	settleSwapRecord(h.DB, record, status, responseBodyBytes)
+	if status != http.StatusOK {
+		fmt.Printf("Non-OK status from solana-api swap submit: %d - %s\n", status, string(responseBodyBytes))
 		var errorResp map[string]interface{}
 		if json.Unmarshal(responseBodyBytes, &errorResp) == nil {
-			return c.Status(resp.StatusCode).JSON(errorResp)
+			return c.Status(status).JSON(errorResp)
 		}
-		// Fallback if parsing fails
-		return c.Status(resp.StatusCode).JSON(fiber.Map{"error": "Received error from swap execution service", "details": string(responseBodyBytes)})
+		return c.Status(status).JSON(fiber.Map{"error": "Received error from swap execution service", "details": string(responseBodyBytes)})
 	}
 
-	// Forward the successful response (should contain signature) from solana-api
 	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
 	return c.Status(http.StatusOK).Send(responseBodyBytes)
 }
 
+// postToSolanaAPI POSTs a JSON body to solana-api and returns the status and raw response body.
+func (h *SwapHandler) postToSolanaAPI(path string, body interface{}) (int, []byte, error) {
+	payloadBytes, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	httpClient := &http.Client{Timeout: 90 * time.Second}
+	resp, err := httpClient.Post(h.Cfg.SolanaAPIURL+path, "application/json", bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBytes, nil
+}
+
 // HandleCreateTokenAccounts handles submission of signed token account creation tx
 func (h *SwapHandler) HandleCreateTokenAccounts(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -314,18 +398,26 @@ func (h *SwapHandler) HandleCreateTokenAccounts(c *fiber.Ctx) error {
 	}
 
 	// --- Fetch Wallet ---
-	var wallet models.Wallet
-	if err := h.DB.WithContext(ctx).Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	wallet, err := findUserWallet(h.DB.WithContext(ctx), userID, reqBody.WalletSelector)
+	if err != nil {
 		reqBody.Password = "" // clear sensitive data
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for user"})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error fetching wallet"})
 	}
+	if wallet.WatchOnly {
+		reqBody.Password = ""
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Watch-only wallets can't swap"})
+	}
+	if wallet.RecoveryRequiredAt != nil {
+		reqBody.Password = ""
+		return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+	}
 
 	// --- Decrypt Mnemonic ---
 	// Decrypt just to verify password, mnemonic is not needed further in this func
-	_, err := crypto.DecryptMnemonic(wallet.EncryptedMnemonic, wallet.EncryptionMetadata, reqBody.Password)
+	_, err = crypto.DecryptMnemonic(wallet.EncryptedMnemonic, wallet.EncryptionMetadata, reqBody.Password)
 	reqBody.Password = "" // zero out password asap
 	if err != nil {
 		if strings.Contains(err.Error(), "cipher: message authentication failed") {
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"gorm.io/gorm"
+)
+
+// swapSettleTimeout bounds the inline lookup of a just-submitted swap; the settle job retries.
+const swapSettleTimeout = 10 * time.Second
+
+// SwapHistoryItem is one executed swap with its quoted and realized prices.
+// Prices are output tokens per input token; amounts are in whole tokens.
+type SwapHistoryItem struct {
+	ID             uint       `json:"id"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	SettledAt      *time.Time `json:"settledAt,omitempty"`
+	WalletAddress  string     `json:"walletAddress"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	Signature      string     `json:"signature"`
+	InputMint      string     `json:"inputMint"`
+	InputSymbol    string     `json:"inputSymbol"`
+	OutputMint     string     `json:"outputMint"`
+	OutputSymbol   string     `json:"outputSymbol"`
+	SlippageBps    int        `json:"slippageBps"`
+	PriceImpactPct string     `json:"priceImpactPct"`
+	FeeLamports    uint64     `json:"feeLamports"`
+
+	// Raw amounts, always present
+	InAmountRaw          string `json:"inAmountRaw"`
+	QuotedOutAmountRaw   string `json:"quotedOutAmountRaw"`
+	MinOutAmountRaw      string `json:"minOutAmountRaw,omitempty"`
+	SpentInAmountRaw     string `json:"spentInAmountRaw,omitempty"`
+	ReceivedOutAmountRaw string `json:"receivedOutAmountRaw,omitempty"`
+
+	// Present once the swap has settled and the token decimals are known
+	InAmount          string `json:"inAmount,omitempty"`
+	QuotedOutAmount   string `json:"quotedOutAmount,omitempty"`
+	SpentInAmount     string `json:"spentInAmount,omitempty"`
+	ReceivedOutAmount string `json:"receivedOutAmount,omitempty"`
+	QuotedPrice       string `json:"quotedPrice,omitempty"`
+	RealizedPrice     string `json:"realizedPrice,omitempty"`
+}
+
+// recordSwap stores a signed swap as pending. A swap that can't be recorded is still submitted;
+// the failure is only logged.
+func recordSwap(db *gorm.DB, wallet *models.Wallet, quote *models.SwapQuote, q *jupiterQuote, signedTx string) *models.Swap {
+	tx, err := solana.TransactionFromBase64(signedTx)
+	if err != nil || len(tx.Signatures) == 0 {
+		fmt.Printf("Error reading signature of swap transaction for user %d: %v\n", wallet.UserID, err)
+		return nil
+	}
+	record := &models.Swap{
+		UserID: wallet.UserID, WalletID: wallet.ID, WalletAddress: wallet.Address, QuoteID: quote.ID,
+		InputMint: quote.InputMint, OutputMint: quote.OutputMint,
+		InAmount: quote.InAmount, QuotedOutAmount: quote.OutAmount, MinOutAmount: q.OtherAmountThreshold,
+		SlippageBps: quote.SlippageBps, PriceImpact: quote.PriceImpact,
+		Signature: tx.Signatures[0].String(),
+		Status:    models.SwapPending,
+	}
+	if err := db.Create(record).Error; err != nil {
+		fmt.Printf("Error recording swap for user %d: %v\n", wallet.UserID, err)
+		return nil
+	}
+	return record
+}
+
+// settleSwapRecord updates a recorded swap with the submit result. Successful submits are looked up
+// on chain right away, since solana-api waits for confirmation; anything unresolved is left pending
+// for the settle job, or resolved from the submit status alone when no RPC upstream is configured.
+func settleSwapRecord(db *gorm.DB, record *models.Swap, status int, body []byte) {
+	if record == nil {
+		return
+	}
+	onChain := len(solanarpc.Upstreams()) > 0
+	if status != http.StatusOK {
+		var resp struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		record.Error = resp.Error
+		if resp.Details != "" {
+			record.Error += ": " + resp.Details
+		}
+		if len(record.Error) > 500 {
+			record.Error = record.Error[:500]
+		}
+		if !onChain {
+			now := time.Now()
+			record.Status, record.SettledAt = models.SwapFailed, &now
+		}
+	} else if !onChain {
+		now := time.Now()
+		record.Status, record.SettledAt = models.SwapConfirmed, &now
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), swapSettleTimeout)
+		defer cancel()
+		if err := jobs.SettleSwap(ctx, db, record); err != nil {
+			fmt.Printf("Error settling swap %d: %v\n", record.ID, err)
+		}
+		return
+	}
+	if _, err := jobs.SaveSettledSwap(db, record); err != nil {
+		fmt.Printf("Error updating swap %d: %v\n", record.ID, err)
+	}
+}
+
+// swapUIAmount converts a raw amount to whole tokens, or returns "" when it can't.
+func swapUIAmount(raw string, decimals *uint8) string {
+	amount, ok := new(big.Int).SetString(raw, 10)
+	if !ok || decimals == nil {
+		return ""
+	}
+	return uiAmount(amount, *decimals).String()
+}
+
+// swapPrice returns out per in, or "" when either side is missing or in is zero.
+func swapPrice(in, out string) string {
+	inAmount, err := decimal.NewFromString(in)
+	if err != nil || inAmount.IsZero() {
+		return ""
+	}
+	outAmount, err := decimal.NewFromString(out)
+	if err != nil {
+		return ""
+	}
+	return outAmount.DivRound(inAmount, 12).String()
+}
+
+func swapHistoryItem(s models.Swap) SwapHistoryItem {
+	item := SwapHistoryItem{
+		ID: s.ID, CreatedAt: s.CreatedAt, SettledAt: s.SettledAt, WalletAddress: s.WalletAddress,
+		Status: s.Status, Error: s.Error, Signature: s.Signature,
+		InputMint: s.InputMint, InputSymbol: tokenLabel(s.InputMint),
+		OutputMint: s.OutputMint, OutputSymbol: tokenLabel(s.OutputMint),
+		SlippageBps: s.SlippageBps, PriceImpactPct: s.PriceImpact, FeeLamports: s.FeeLamports,
+		InAmountRaw: s.InAmount, QuotedOutAmountRaw: s.QuotedOutAmount, MinOutAmountRaw: s.MinOutAmount,
+		SpentInAmountRaw: s.SpentInAmount, ReceivedOutAmountRaw: s.ReceivedOutAmount,
+	}
+	item.InAmount = swapUIAmount(s.InAmount, s.InputDecimals)
+	item.QuotedOutAmount = swapUIAmount(s.QuotedOutAmount, s.OutputDecimals)
+	item.QuotedPrice = swapPrice(item.InAmount, item.QuotedOutAmount)
+	if s.Status == models.SwapConfirmed {
+		item.SpentInAmount = swapUIAmount(s.SpentInAmount, s.InputDecimals)
+		item.ReceivedOutAmount = swapUIAmount(s.ReceivedOutAmount, s.OutputDecimals)
+		item.RealizedPrice = swapPrice(item.SpentInAmount, item.ReceivedOutAmount)
+	}
+	return item
+}
+
+// HandleGetSwapHistory lists the user's swaps, newest first, with what was quoted and what was
+// actually spent and received. Optional filters: wallet_id, mint (either side), status.
+func (h *SwapHandler) HandleGetSwapHistory(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User ID not found or invalid in context"})
+	}
+
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := c.QueryInt("page_size", 20)
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	q := h.DB.Model(&models.Swap{}).Where("user_id = ?", userID)
+	if walletID := c.QueryInt("wallet_id"); walletID > 0 {
+		q = q.Where("wallet_id = ?", walletID)
+	}
+	if mint := c.Query("mint"); mint != "" {
+		q = q.Where("input_mint = ? OR output_mint = ?", mint, mint)
+	}
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case models.SwapPending, models.SwapConfirmed, models.SwapFailed, models.SwapExpired:
+			q = q.Where("status = ?", status)
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "status must be pending, confirmed, failed or expired"})
+		}
+	}
+	q = q.Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting swaps for user %d: %v\n", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch swap history"})
+	}
+	var swaps []models.Swap
+	if err := q.Order("created_at DESC").Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&swaps).Error; err != nil {
+		fmt.Printf("Error fetching swaps for user %d: %v\n", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch swap history"})
+	}
+
+	items := make([]SwapHistoryItem, 0, len(swaps))
+	for _, s := range swaps {
+		items = append(items, swapHistoryItem(s))
+	}
+	return c.JSON(fiber.Map{"swaps": items, "page": page, "pageSize": pageSize, "total": total})
+}
@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	// defaultOrderSlippageBps is the slippage tolerance of orders that don't set one.
+	defaultOrderSlippageBps = 100
+)
+
+// CreateSwapOrderRequest places a limit order or DCA plan between TEAM556 and USDC. The password
+// unlocks the wallet once, to approve the scheduler's delegate for the input token.
+type CreateSwapOrderRequest struct {
+	WalletSelector
+	Kind        string     `json:"kind"`                  // "limit" or "dca"
+	Side        string     `json:"side"`                  // "buy" spends USDC on TEAM556, "sell" sells TEAM556 for USDC
+	Amount      string     `json:"amount"`                // Input per execution: USDC when buying, TEAM556 when selling
+	LimitPrice  string     `json:"limitPrice,omitempty"`  // Limit orders: USD per TEAM556
+	Cadence     string     `json:"cadence,omitempty"`     // DCA: five-field cron expression in UTC, e.g. "0 9 * * 1"
+	StartAt     *time.Time `json:"startAt,omitempty"`     // DCA: defaults to now
+	EndAt       time.Time  `json:"endAt"`                 // Limit orders expire, DCA plans stop
+	SlippageBps *int       `json:"slippageBps,omitempty"` // Defaults to 1%
+	Password    string     `json:"password"`
+}
+
+// SwapOrderResponse is an order along with the swaps it executed, newest first
+type SwapOrderResponse struct {
+	Order models.SwapOrder  `json:"order"`
+	Swaps []SwapHistoryItem `json:"swaps"`
+}
+
+// swapOrderFromParam loads the swap order named by the :id route parameter for the authenticated
+// user, writing the error response itself when it returns nil.
+func swapOrderFromParam(c *fiber.Ctx, db *gorm.DB) (*models.SwapOrder, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return nil, 0, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, userID, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid swap order ID"})
+	}
+	var order models.SwapOrder
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, userID, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Swap order not found"})
+		}
+		log.Printf("Error fetching swap order %d for user %d: %v", id, userID, err)
+		return nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve swap order"})
+	}
+	return &order, userID, nil
+}
+
+// orderUnavailable answers when swap orders can't run in this deployment.
+func orderUnavailable(c *fiber.Ctx, cfg *config.Config) error {
+	if cfg.SchedulerKey == nil || len(solanarpc.Upstreams()) == 0 || cfg.SolanaAPIURL == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Swap orders are not available"})
+	}
+	return nil
+}
+
+// orderRemainingInput is the raw input an order may still spend.
+func orderRemainingInput(o *models.SwapOrder) uint64 {
+	if o.Kind == models.OrderLimit {
+		return o.RawAmount
+	}
+	if o.NextRunAt == nil {
+		return 0
+	}
+	schedule, err := jobs.ParseCron(o.Cadence)
+	if err != nil {
+		return 0
+	}
+	runs := uint64(schedule.CountRuns(o.NextRunAt.Add(-time.Second), o.EndAt))
+	if runs > 0 && o.RawAmount > math.MaxUint64/runs {
+		return math.MaxUint64
+	}
+	return o.RawAmount * runs
+}
+
+// orderAllowance is the delegate allowance the wallet's active and paused orders spending mint need,
+// leaving out excludeID.
+func orderAllowance(db *gorm.DB, walletID uint, mint string, excludeID uint) (uint64, error) {
+	var orders []models.SwapOrder
+	if err := db.Where("wallet_id = ? AND input_mint = ? AND status IN ? AND id <> ?", walletID, mint,
+		[]string{models.OrderActive, models.OrderPaused}, excludeID).Find(&orders).Error; err != nil {
+		return 0, err
+	}
+	var total uint64
+	for i := range orders {
+		need := orderRemainingInput(&orders[i])
+		if need > math.MaxUint64-total {
+			return 0, errors.New("allowance overflows")
+		}
+		total += need
+	}
+	return total, nil
+}
+
+// ListSwapOrdersHandler returns the user's swap orders, newest first. ?status= and ?kind= filter.
+func ListSwapOrdersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		q := db.Where("user_id = ?", userID)
+		if status := c.Query("status"); status != "" {
+			q = q.Where("status = ?", status)
+		}
+		if kind := c.Query("kind"); kind != "" {
+			q = q.Where("kind = ?", kind)
+		}
+		orders := []models.SwapOrder{}
+		if err := q.Order("created_at DESC").Find(&orders).Error; err != nil {
+			log.Printf("Error listing swap orders for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve swap orders"})
+		}
+		return c.JSON(fiber.Map{"orders": orders})
+	}
+}
+
+// GetSwapOrderHandler returns an order and its last 100 swaps.
+func GetSwapOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		order, userID, err := swapOrderFromParam(c, db)
+		if order == nil {
+			return err
+		}
+		var swaps []models.Swap
+		if err := db.Where("order_id = ?", order.ID).Order("created_at DESC").Limit(100).Find(&swaps).Error; err != nil {
+			log.Printf("Error fetching swaps of order %d for user %d: %v", order.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve swap order"})
+		}
+		resp := SwapOrderResponse{Order: *order, Swaps: make([]SwapHistoryItem, 0, len(swaps))}
+		for _, s := range swaps {
+			resp.Swaps = append(resp.Swaps, swapHistoryItem(s))
+		}
+		return c.JSON(resp)
+	}
+}
+
+// CreateSwapOrderHandler places a limit order or DCA plan. Executions are signed without the
+// password, so under a spending policy one execution is checked against the per-transaction limit
+// and a day's worth against the daily limit first. The wallet approves the scheduler's delegate for
+// everything its schedules and orders spending the input token still need and makes sure it has a
+// token account for the output, which executions pay into. The order becomes active once that
+// transaction is confirmed.
+func CreateSwapOrderHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		if err := orderUnavailable(c, cfg); err != nil {
+			return err
+		}
+
+		var req CreateSwapOrderRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		if req.Password == "" || req.Amount == "" || req.EndAt.IsZero() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password, amount and endAt are required"})
+		}
+		if req.Side != models.OrderBuy && req.Side != models.OrderSell {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "side must be buy or sell"})
+		}
+		now := time.Now()
+		if !req.EndAt.After(now) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "endAt must be in the future"})
+		}
+		if req.EndAt.After(now.Add(maxScheduleDuration)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "endAt must be within a year"})
+		}
+		slippage := defaultOrderSlippageBps
+		if req.SlippageBps != nil {
+			slippage = *req.SlippageBps
+		}
+		if slippage < 1 || slippage > cfg.SwapMaxSlippageBps {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("slippageBps must be between 1 and %d", cfg.SwapMaxSlippageBps)})
+		}
+
+		order := models.SwapOrder{UserID: userID, Kind: req.Kind, Side: req.Side, SlippageBps: slippage, EndAt: req.EndAt, Status: models.OrderActive}
+		var schedule *jobs.CronSchedule
+		switch req.Kind {
+		case models.OrderLimit:
+			limit, err := decimal.NewFromString(strings.TrimSpace(req.LimitPrice))
+			if err != nil || !limit.IsPositive() {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "limitPrice must be a positive USD price"})
+			}
+			order.LimitPrice = limit.String()
+			order.NextRunAt = &now
+		case models.OrderDCA:
+			if req.LimitPrice != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "DCA plans don't take a limitPrice"})
+			}
+			var err error
+			if schedule, err = jobs.ParseCron(req.Cadence); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cadence: " + err.Error()})
+			}
+			if !schedule.RunsAtMostHourly() {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cadence may run at most once an hour"})
+			}
+			startAt := now
+			if req.StartAt != nil && req.StartAt.After(now) {
+				startAt = *req.StartAt
+			}
+			// Next fires strictly after its argument, so step back to allow a run at startAt itself
+			order.NextRunAt = jobs.ScheduleNextRun(schedule, startAt.Add(-time.Second), req.EndAt)
+			if order.NextRunAt == nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The cadence has no runs between startAt and endAt"})
+			}
+			order.Cadence = strings.Join(strings.Fields(req.Cadence), " ")
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "kind must be limit or dca"})
+		}
+
+		wallet, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+			}
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+		if wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This is a watch-only wallet and can't sign transactions"})
+		}
+		if wallet.RecoveryRequiredAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+		}
+		order.WalletID = wallet.ID
+
+		// --- Tokens and amount ---
+		order.InputMint, order.OutputMint = usdcMint, team556TokenMint
+		if req.Side == models.OrderSell {
+			order.InputMint, order.OutputMint = team556TokenMint, usdcMint
+		}
+		ctx, cancel := context.WithTimeout(c.Context(), scheduleLookupTimeout)
+		inputProgram, inputDecimals, err := solanarpc.MintInfo(ctx, order.InputMint)
+		var outputProgram solana.PublicKey
+		if err == nil {
+			outputProgram, order.OutputDecimals, err = solanarpc.MintInfo(ctx, order.OutputMint)
+		}
+		cancel()
+		if err != nil {
+			log.Printf("Error looking up swap order mints for user %d: %v", userID, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to look up the tokens"})
+		}
+		order.InputProgram, order.InputDecimals = inputProgram.String(), inputDecimals
+		amount, err := decimal.NewFromString(strings.TrimSpace(req.Amount))
+		if err != nil || !amount.IsPositive() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be a positive number"})
+		}
+		raw := amount.Shift(int32(inputDecimals))
+		if !raw.Equal(raw.Truncate(0)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Amount has more than %d decimal places", inputDecimals)})
+		}
+		if !raw.BigInt().IsUint64() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Amount is too large"})
+		}
+		order.Amount, order.RawAmount = amount.String(), raw.BigInt().Uint64()
+
+		// --- Spending policy ---
+		policy, err := loadSpendingPolicy(db, wallet.ID)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		if policy != nil && policyActive(policy.SpendingPolicySettings) {
+			d := inputDecimals
+			execution := &signer.TxSummary{
+				BalanceChanges: []signer.BalanceChange{{Mint: order.InputMint, Amount: amount.Neg().String(), RawAmount: "-" + raw.String(), Decimals: &d}},
+			}
+			check, err := checkSpendingPolicy(db, cfg, wallet, policy, execution)
+			if err != nil {
+				log.Printf("Error checking spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the order against this wallet's spending policy"})
+			}
+			// A day's worth of executions (one for a limit order), along with the wallet's schedules and other orders, has to fit in the daily limit
+			if limit := policy.DailyLimitUSD; limit != nil {
+				runs := 1
+				if schedule != nil {
+					first := order.NextRunAt.Add(-time.Second)
+					runs = schedule.CountRuns(first, first.Add(spendingWindow))
+				}
+				daily := check.OutflowUSD.Mul(decimal.NewFromInt(int64(runs)))
+				others, err := scheduledDailyOutflowUSD(db, cfg, wallet.ID, now)
+				if err != nil {
+					log.Printf("Error totalling schedules and orders of wallet %d (user %d): %v", wallet.ID, userID, err)
+					return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the order against this wallet's spending policy"})
+				}
+				if total := daily.Add(others); total.GreaterThan(*limit) {
+					msg := fmt.Sprintf("Order spends up to $%s a day, over the $%s daily limit", daily.StringFixed(2), limit.StringFixed(2))
+					if others.IsPositive() {
+						msg = fmt.Sprintf("Order spends up to $%s a day and this wallet's schedules and other orders $%s, over the $%s daily limit", daily.StringFixed(2), others.StringFixed(2), limit.StringFixed(2))
+					}
+					check.violate(PolicyDailyLimit, msg)
+				}
+			}
+			if len(check.Violations) > 0 {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Order blocked by this wallet's spending policy", "violations": check.Violations})
+			}
+		}
+
+		allowance, err := delegateAllowance(db, wallet.ID, order.InputMint, 0, 0)
+		need := orderRemainingInput(&order)
+		if err == nil && (need == math.MaxUint64 || need > math.MaxUint64-allowance) {
+			err = errors.New("allowance overflows")
+		}
+		if err != nil {
+			log.Printf("Error computing swap order allowance for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The wallet's schedules and swap orders for this token add up to more than can be approved"})
+		}
+		allowance += need
+
+		// --- Approve the delegate ---
+		keypair, err := unlockWallet(wallet, req.Password)
+		if err == nil {
+			upgradeWalletEncryption(db, cfg.WalletKeys, wallet, req.Password)
+		}
+		req.Password = ""
+		if err != nil {
+			log.Printf("Failed to derive signing key for wallet %d (user %d): %v", wallet.ID, userID, err)
+			if errors.Is(err, signer.ErrDecryptFailed) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet key"})
+		}
+		owner := keypair.PublicKey()
+		inputMint, outputMint := solana.MustPublicKeyFromBase58(order.InputMint), solana.MustPublicKeyFromBase58(order.OutputMint)
+		result, err := sendWalletTransaction(keypair, []solana.Instruction{
+			// Executions pay the output into this account and the delegate doesn't pay rent, so it is created now
+			signer.CreateAssociatedTokenAccountIdempotentInstruction(outputProgram, outputMint, owner, owner),
+			signer.ApproveCheckedInstruction(inputProgram, inputMint, owner, cfg.SchedulerKey.PublicKey(), allowance, inputDecimals),
+		})
+		keypair.Zero()
+		if err != nil {
+			log.Printf("Error approving swap order delegate for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "Approval status unknown; the order was not placed. Try again shortly."})
+		}
+		if result.Status != solanarpc.StatusConfirmed {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Approval transaction " + result.Status, "details": result.Error, "signature": result.Signature})
+		}
+		order.ApprovalSignature = result.Signature
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			if err := recordDelegateApproval(tx, wallet.ID, order.InputMint, result.Signature); err != nil {
+				return err
+			}
+			meta, _ := json.Marshal(map[string]any{"order_id": order.ID, "wallet_id": wallet.ID, "kind": order.Kind, "side": order.Side, "amount": order.Amount, "limit_price": order.LimitPrice, "cadence": order.Cadence, "end_at": order.EndAt, "allowance": allowance, "signature": result.Signature})
+			ip := c.IP()
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "swap_order_created", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving swap order for wallet %d (user %d) after approval %s: %v", wallet.ID, userID, result.Signature, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save swap order", "signature": result.Signature})
+		}
+		log.Printf("Created %s %s order %d for wallet %d (user %d), approval %s", order.Kind, order.Side, order.ID, wallet.ID, userID, result.Signature)
+		return c.Status(fiber.StatusCreated).JSON(order)
+	}
+}
+
+// ResumeSwapOrderHandler reactivates an order paused after failures. A limit order is evaluated
+// again right away; a DCA plan continues from its next run after now.
+func ResumeSwapOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		order, userID, err := swapOrderFromParam(c, db)
+		if order == nil {
+			return err
+		}
+		if order.Status != models.OrderPaused {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only paused orders can be resumed"})
+		}
+		var pending int64
+		if err := db.Model(&models.Swap{}).Where("order_id = ? AND status = ?", order.ID, models.SwapPending).Count(&pending).Error; err != nil {
+			log.Printf("Error checking pending swaps of order %d for user %d: %v", order.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resume swap order"})
+		}
+		if pending > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A swap from this order hasn't settled yet; try again shortly"})
+		}
+
+		now := time.Now()
+		updates := map[string]any{"status": models.OrderActive, "consecutive_failures": 0, "next_run_at": now}
+		if order.Kind == models.OrderDCA {
+			schedule, err := jobs.ParseCron(order.Cadence)
+			if err != nil {
+				log.Printf("Swap order %d has an invalid cadence %q: %v", order.ID, order.Cadence, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Swap order is invalid"})
+			}
+			if next := jobs.ScheduleNextRun(schedule, now, order.EndAt); next != nil {
+				updates["next_run_at"] = *next
+			} else {
+				updates["status"], updates["next_run_at"] = models.OrderCompleted, nil
+			}
+		} else if !now.Before(order.EndAt) {
+			updates["status"], updates["next_run_at"] = models.OrderExpired, nil
+		}
+		if err := db.Model(order).Updates(updates).Error; err != nil {
+			log.Printf("Error resuming swap order %d for user %d: %v", order.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resume swap order"})
+		}
+		return c.JSON(order)
+	}
+}
+
+// CancelSwapOrderHandler cancels an open order. With the wallet password the approval is also
+// lowered to what the wallet's schedules and other orders of the input token still need, or revoked
+// when none are left; without it the delegate keeps the allowance, though nothing will use it for
+// this order.
+func CancelSwapOrderHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		order, userID, err := swapOrderFromParam(c, db)
+		if order == nil {
+			return err
+		}
+		var req CancelScheduledTransferRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+			}
+		}
+		if order.Status != models.OrderActive && order.Status != models.OrderPaused {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order has already ended"})
+		}
+
+		resp := fiber.Map{"cancelled": true, "approvalUpdated": false}
+		if req.Password != "" {
+			if err := orderUnavailable(c, cfg); err != nil {
+				return err
+			}
+			wallet, err := findUserWallet(db, userID, WalletSelector{WalletID: order.WalletID})
+			if err != nil {
+				log.Printf("Error fetching wallet %d for user %d: %v", order.WalletID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+			}
+			allowance, err := delegateAllowance(db, wallet.ID, order.InputMint, 0, order.ID)
+			if err != nil {
+				log.Printf("Error computing swap order allowance for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel swap order"})
+			}
+			keypair, err := unlockWallet(wallet, req.Password)
+			req.Password = ""
+			if err != nil {
+				if errors.Is(err, signer.ErrDecryptFailed) {
+					return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."})
+				}
+				log.Printf("Failed to derive signing key for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet key"})
+			}
+			program, mint := solana.MustPublicKeyFromBase58(order.InputProgram), solana.MustPublicKeyFromBase58(order.InputMint)
+			ix := signer.RevokeInstruction(program, mint, keypair.PublicKey())
+			if allowance > 0 {
+				ix = signer.ApproveCheckedInstruction(program, mint, keypair.PublicKey(), cfg.SchedulerKey.PublicKey(), allowance, order.InputDecimals)
+			}
+			result, err := sendWalletTransaction(keypair, []solana.Instruction{ix})
+			keypair.Zero()
+			if err != nil || result.Status != solanarpc.StatusConfirmed {
+				log.Printf("Error updating swap order approval for wallet %d (user %d): %v %+v", wallet.ID, userID, err, result)
+				return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to update the approval; the order was not cancelled"})
+			}
+			resp["approvalUpdated"], resp["signature"], resp["remainingAllowance"] = true, result.Signature, allowance
+		}
+
+		res := db.Model(&models.SwapOrder{}).Where("id = ? AND status IN ?", order.ID, []string{models.OrderActive, models.OrderPaused}).
+			Updates(map[string]any{"status": models.OrderCancelled, "next_run_at": nil})
+		if res.Error != nil {
+			log.Printf("Error cancelling swap order %d for user %d: %v", order.ID, userID, res.Error)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel swap order"})
+		}
+		if res.RowsAffected == 0 {
+			// Filled or ended while the approval was being updated
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order has already ended"})
+		}
+		meta, _ := json.Marshal(map[string]any{"order_id": order.ID, "wallet_id": order.WalletID, "approval_updated": resp["approvalUpdated"]})
+		ip := c.IP()
+		if err := db.Create(&models.SecurityAuditLog{UserID: userID, Action: "swap_order_cancelled", IP: &ip, Meta: datatypes.JSON(meta)}).Error; err != nil {
+			log.Printf("Error writing audit log for user %d: %v", userID, err)
+		}
+		return c.JSON(resp)
+	}
+}
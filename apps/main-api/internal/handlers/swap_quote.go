@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	errQuoteNotFound = errors.New("swap quote not found")
+	errQuoteExpired  = errors.New("swap quote expired")
+	errQuoteUsed     = errors.New("swap quote already used")
+)
+
+// jupiterQuote holds the fields of a Jupiter v6 quote the server checks before issuing it.
+type jupiterQuote struct {
+	InputMint            string `json:"inputMint"`
+	InAmount             string `json:"inAmount"`
+	OutputMint           string `json:"outputMint"`
+	OutAmount            string `json:"outAmount"`
+	OtherAmountThreshold string `json:"otherAmountThreshold"` // Least output after slippage
+	SwapMode             string `json:"swapMode"`
+	SlippageBps          int    `json:"slippageBps"`
+	PriceImpactPct       string `json:"priceImpactPct"` // A fraction despite the name: "0.01" is 1%
+}
+
+// bpsPercent formats basis points as a percentage for error messages.
+func bpsPercent(bps decimal.Decimal) string {
+	return bps.Div(decimal.NewFromInt(100)).StringFixed(2) + "%"
+}
+
+// checkSwapQuote returns why a quote is outside the configured guardrails, or "" when it may be executed.
+func checkSwapQuote(cfg *config.Config, q *jupiterQuote) string {
+	if q.SlippageBps < 0 || q.SlippageBps > cfg.SwapMaxSlippageBps {
+		return fmt.Sprintf("Slippage tolerance of %s is above the %s limit", bpsPercent(decimal.NewFromInt(int64(q.SlippageBps))), bpsPercent(decimal.NewFromInt(int64(cfg.SwapMaxSlippageBps))))
+	}
+	impact, err := decimal.NewFromString(q.PriceImpactPct)
+	if err != nil {
+		return "Quote does not report its price impact"
+	}
+	impactBps := impact.Abs().Mul(decimal.NewFromInt(10_000))
+	if impactBps.GreaterThan(decimal.NewFromInt(int64(cfg.SwapMaxPriceImpactBps))) {
+		return fmt.Sprintf("Price impact of %s is above the %s limit; try a smaller amount", bpsPercent(impactBps), bpsPercent(decimal.NewFromInt(int64(cfg.SwapMaxPriceImpactBps))))
+	}
+	return ""
+}
+
+// newSwapQuoteID returns a random, unguessable quote ID.
+func newSwapQuoteID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// issueSwapQuote validates a quote fetched for req against the request and the guardrails and
+// stores it for userID. A non-empty reason means the quote was refused.
+func issueSwapQuote(db *gorm.DB, cfg *config.Config, userID uint, req *GetQuoteRequest, raw []byte) (*models.SwapQuote, string, error) {
+	var q jupiterQuote
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return nil, "", fmt.Errorf("decode quote: %w", err)
+	}
+	if q.InputMint != req.InputMint || q.OutputMint != req.OutputMint || q.InAmount != strconv.FormatUint(req.Amount, 10) || (q.SwapMode != "" && q.SwapMode != "ExactIn") {
+		return nil, "", fmt.Errorf("quote does not match the request: %s -> %s, %s in (%s)", q.InputMint, q.OutputMint, q.InAmount, q.SwapMode)
+	}
+	if reason := checkSwapQuote(cfg, &q); reason != "" {
+		return nil, reason, nil
+	}
+	id, err := newSwapQuoteID()
+	if err != nil {
+		return nil, "", err
+	}
+	quote := &models.SwapQuote{
+		ID: id, UserID: userID,
+		InputMint: q.InputMint, OutputMint: q.OutputMint, InAmount: q.InAmount, OutAmount: q.OutAmount,
+		SlippageBps: q.SlippageBps, PriceImpact: q.PriceImpactPct,
+		QuoteResponse: datatypes.JSON(raw),
+		ExpiresAt:     time.Now().Add(cfg.SwapQuoteTTL),
+	}
+	if err := db.Create(quote).Error; err != nil {
+		return nil, "", err
+	}
+	return quote, "", nil
+}
+
+// claimSwapQuote marks the user's quote as being executed so it can't be executed twice.
+func claimSwapQuote(db *gorm.DB, userID uint, id string) (*models.SwapQuote, error) {
+	var quote models.SwapQuote
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&quote).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errQuoteNotFound
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !now.Before(quote.ExpiresAt) {
+		return nil, errQuoteExpired
+	}
+	res := db.Model(&models.SwapQuote{}).Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errQuoteUsed
+	}
+	quote.UsedAt = &now
+	return &quote, nil
+}
+
+// releaseSwapQuote makes a claimed quote executable again after a failure before anything was signed
+// or sent, e.g. when token accounts have to be created first.
+func releaseSwapQuote(db *gorm.DB, id string) error {
+	return db.Model(&models.SwapQuote{}).Where("id = ?", id).Update("used_at", nil).Error
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/jobs"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+
+	// initialIndexTimeout bounds the inline sync when history is requested for an address never indexed before.
+	initialIndexTimeout = 20 * time.Second
+)
+
+var transactionTypes = map[string]bool{
+	models.TxTypeSend:    true,
+	models.TxTypeReceive: true,
+	models.TxTypeSwap:    true,
+	models.TxTypeOther:   true,
+}
+
+// indexedTransaction converts an indexed row to the history response shape.
+func indexedTransaction(row models.WalletTransaction) Transaction {
+	tx := Transaction{
+		Signature:      row.Signature,
+		Type:           row.Type,
+		Amount:         row.Amount,
+		Token:          tokenLabel(row.Token),
+		Mint:           row.Token,
+		Status:         "success",
+		Slot:           row.Slot,
+		Fee:            row.Fee,
+		WalletAddress:  row.Address,
+		ReceivedToken:  tokenLabel(row.ReceivedToken),
+		ReceivedAmount: row.ReceivedAmount,
+	}
+	if !row.Success {
+		tx.Status = "failed"
+	}
+	if row.BlockTime != nil {
+		tx.Date = row.BlockTime.Format(time.RFC3339)
+	}
+	switch row.Type {
+	case models.TxTypeSend:
+		tx.From, tx.To = row.Address, row.Counterparty
+	case models.TxTypeReceive:
+		tx.From, tx.To = row.Counterparty, row.Address
+	default:
+		tx.From = row.Address
+	}
+	return tx
+}
+
+// tokenLabel returns the symbol of a known mint, or the mint itself.
+func tokenLabel(mint string) string {
+	if symbol, ok := knownTokenSymbols[mint]; ok {
+		return symbol
+	}
+	return mint
+}
+
+// userHistoryAddresses returns the addresses whose history req asks for when they belong to the
+// user's wallets (owned or watched), or nil when the request is for some other address.
+func userHistoryAddresses(db *gorm.DB, userID uint, req *GetTransactionsRequest) ([]string, error) {
+	if req.AllWallets {
+		var addresses []string
+		err := db.Model(&models.Wallet{}).Where("user_id = ? AND archived_at IS NULL", userID).
+			Distinct("address").Pluck("address", &addresses).Error
+		return addresses, err
+	}
+	var count int64
+	if err := db.Model(&models.Wallet{}).Where("user_id = ? AND address = ?", userID, req.Address).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	return []string{req.Address}, nil
+}
+
+// serveIndexedTransactions answers a history request from the local index, syncing addresses
+// that have never been indexed first. Pages are ordered newest first; NextCursor continues.
+func serveIndexedTransactions(c *fiber.Ctx, db *gorm.DB, userID uint, req *GetTransactionsRequest, addresses []string) error {
+	if req.Type != "" && !transactionTypes[req.Type] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type must be send, receive, swap or other"})
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	if limit > maxTransactionPageSize {
+		limit = maxTransactionPageSize
+	}
+
+	var states []models.WalletIndexState
+	if err := db.Where("address IN ?", addresses).Find(&states).Error; err != nil {
+		log.Printf("Error loading index state for user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve transactions"})
+	}
+	indexed := map[string]models.WalletIndexState{}
+	for _, s := range states {
+		indexed[s.Address] = s
+	}
+	indexing := false
+	for _, address := range addresses {
+		state, ok := indexed[address]
+		if !ok {
+			ctx, cancel := context.WithTimeout(c.Context(), initialIndexTimeout)
+			if err := jobs.IndexAddress(ctx, db, address); err != nil {
+				// Whatever was indexed is still served; the background job carries on
+				log.Printf("Error in initial transaction index of %s for user %d: %v", address, userID, err)
+			}
+			cancel()
+			db.Where("address = ?", address).First(&state)
+		}
+		if !state.BackfillDone {
+			indexing = true
+		}
+	}
+
+	q := db.Model(&models.WalletTransaction{}).Where("address IN ? AND parsed = ?", addresses, true)
+	if req.Type != "" {
+		q = q.Where("type = ?", req.Type)
+	}
+	if req.Token != "" {
+		token := req.Token
+		for mint, symbol := range knownTokenSymbols {
+			if strings.EqualFold(symbol, token) {
+				token = mint
+			}
+		}
+		q = q.Where("token = ? OR received_token = ?", token, token)
+	}
+	if req.Since != nil {
+		q = q.Where("block_time >= ?", *req.Since)
+	}
+	if req.Until != nil {
+		q = q.Where("block_time < ?", *req.Until)
+	}
+	if req.Cursor != "" {
+		id, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+		var last models.WalletTransaction
+		if err := db.Where("id = ? AND address IN ?", id, addresses).First(&last).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+			}
+			log.Printf("Error loading transaction cursor for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve transactions"})
+		}
+		q = q.Where("slot < ? OR (slot = ? AND id < ?)", last.Slot, last.Slot, last.ID)
+	}
+
+	var rows []models.WalletTransaction
+	if err := q.Order("slot DESC").Order("id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		log.Printf("Error querying transactions for user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve transactions"})
+	}
+	if !indexing {
+		var pending int64
+		db.Model(&models.WalletTransaction{}).Where("address IN ? AND parsed = ?", addresses, false).Limit(1).Count(&pending)
+		indexing = pending > 0
+	}
+
+	resp := GetTransactionsResponse{Transactions: make([]Transaction, 0, len(rows)), Indexing: indexing}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		resp.NextCursor = strconv.FormatUint(uint64(rows[limit-1].ID), 10)
+	}
+	for _, row := range rows {
+		resp.Transactions = append(resp.Transactions, indexedTransaction(row))
+	}
+	return c.JSON(resp)
+}
+
+// historyIndexAvailable reports whether indexed history can be served; without an RPC upstream
+// requests fall back to the solana-api proxy.
+func historyIndexAvailable() bool {
+	return len(solanarpc.Upstreams()) > 0
+}
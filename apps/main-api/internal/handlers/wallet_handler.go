@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,11 +12,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gagliardetto/solana-go"
 	"github.com/gofiber/fiber/v2"
 	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/crypto"
+	"github.com/team556-mono/server/internal/email"
 	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
 	"github.com/team556-mono/server/internal/utils"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -27,8 +33,9 @@ type SolanaWalletCreateResponse struct {
 
 // Response body for our main-api endpoint
 type CreateWalletResponse struct {
-	Message  string `json:"message"`
-	Mnemonic string `json:"mnemonic"` // IMPORTANT: Only return this, don't store it
+	Message  string         `json:"message"`
+	Mnemonic string         `json:"mnemonic"` // IMPORTANT: Only return this, don't store it
+	Wallet   *models.Wallet `json:"wallet,omitempty"`
 }
 
 // Response structure from solana-api/wallet/balance/:address
@@ -71,13 +78,14 @@ type RedeemPresaleCodeResponse struct {
 // Request body for creating an encrypted wallet
 type CreateWalletRequest struct {
 	Password string `json:"password" validate:"required,min=8"` // Require user's password for encryption
+	Name     string `json:"name,omitempty"`                     // Defaults to "Primary Wallet" for the first wallet
 }
 
 // SignTransactionRequest defines the structure for the transaction signing request
 type SignTransactionRequest struct {
 	Password             string `json:"password" validate:"required"`
 	UnsignedTransaction  string `json:"unsignedTransaction" validate:"required"` // Assume base64 encoded transaction
-	// WalletAddress string `json:"walletAddress,omitempty"` // Optional: if user can have multiple wallets, specify which one
+	WalletSelector                                                             // Optional: which of the user's wallets signs; default wallet if empty
 }
 
 // SignTransactionResponse defines the structure for the transaction signing response
@@ -85,9 +93,17 @@ type SignTransactionResponse struct {
 	SignedTransaction string `json:"signedTransaction"` // Base64 encoded signed transaction
 }
 
+// InspectTransactionRequest defines the structure for the pre-sign inspection request
+type InspectTransactionRequest struct {
+	UnsignedTransaction string `json:"unsignedTransaction" validate:"required"` // Base64 encoded transaction
+	WalletSelector
+}
+
 // SendTransactionRequest defines the structure for the transaction sending request
 type SendTransactionRequest struct {
 	SignedTransaction string `json:"signedTransaction" validate:"required"` // Base64 encoded signed transaction
+	WalletSelector                                                         // Optional: checked to be a signer of the transaction
+	ContactID         uint   `json:"contactId,omitempty"`                  // Optional: address book contact being paid, marked as used on success
 }
 
 // SendTransactionResponse defines the structure for the transaction sending response
@@ -95,8 +111,15 @@ type SendTransactionResponse struct {
 	Success      bool   `json:"success"`
 	Signature    string `json:"signature"`
 	Confirmation any    `json:"confirmation,omitempty"`
+	Status       string `json:"status,omitempty"` // confirmed, failed or expired
+	Slot         uint64 `json:"slot,omitempty"`
+	Error        string `json:"error,omitempty"` // Preflight or on-chain error of a failed transaction
+	Attempts     int    `json:"attempts,omitempty"`
 }
 
+// sendAndConfirmTimeout bounds the send-and-confirm loop; a blockhash expires after about a minute.
+const sendAndConfirmTimeout = 2 * time.Minute
+
 // SendWebhookRequest defines the structure for the webhook sending request
 type SendWebhookRequest struct {
 	WebhookURL  string `json:"webhookUrl"`
@@ -110,9 +133,19 @@ type SendWebhookResponse struct {
 }
 
 // GetTransactionsRequest defines the structure for the transaction history request
+// History of the user's own and watched wallets is served from the local index and supports the filters;
+// other addresses are proxied to solana-api, which only honors Address and Limit.
 type GetTransactionsRequest struct {
-	Address string `json:"address"`
+	Address string `json:"address"` // Any address; ignored when a wallet is selected
 	Limit   int    `json:"limit,omitempty"`
+	WalletSelector
+
+	AllWallets bool       `json:"allWallets,omitempty"` // Merge the history of all the user's active wallets
+	Type       string     `json:"type,omitempty"`       // send, receive, swap or other
+	Token      string     `json:"token,omitempty"`      // Mint or known symbol (SOL, TEAM556, USDC, USDT)
+	Since      *time.Time `json:"since,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+	Cursor     string     `json:"cursor,omitempty"` // nextCursor from the previous page
 }
 
 // Transaction defines the structure for a single transaction
@@ -124,31 +157,36 @@ type Transaction struct {
 	Token     string `json:"token"`
 	From      string `json:"from"`
 	To        string `json:"to"`
+
+	// Indexed history only
+	Mint           string `json:"mint,omitempty"`
+	Status         string `json:"status,omitempty"` // success or failed
+	Slot           uint64 `json:"slot,omitempty"`
+	Fee            uint64 `json:"fee,omitempty"` // Lamports paid by this wallet
+	WalletAddress  string `json:"walletAddress,omitempty"`
+	ReceivedToken  string `json:"receivedToken,omitempty"` // Swaps: what arrived (Token/Amount is what left)
+	ReceivedAmount string `json:"receivedAmount,omitempty"`
 }
 
 // GetTransactionsResponse defines the structure for the transaction history response
 type GetTransactionsResponse struct {
 	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+	Indexing     bool          `json:"indexing,omitempty"` // Older history is still being indexed
 }
 
 // --- Structs for Internal Solana API Communication ---
-type SolanaSignRequest struct {
-	Mnemonic            string `json:"mnemonic"`
-	UnsignedTransaction string `json:"unsignedTransaction"` // Base64 encoded
-}
-
-type SolanaSignResponse struct {
-	SignedTransaction string `json:"signedTransaction"` // Base64 encoded
-}
-
 // GetRecoveryPhraseRequest defines the expected request body for fetching the recovery phrase.
 type GetRecoveryPhraseRequest struct {
 	Password string `json:"password" validate:"required"`
+	WalletSelector
 }
 
 // GetRecoveryPhraseResponse defines the response body when successfully fetching the recovery phrase.
 type GetRecoveryPhraseResponse struct {
-	RecoveryPhrase string `json:"recoveryPhrase"`
+	RecoveryPhrase string `json:"recoveryPhrase"`           // Base58 secret key when SecretType is "private_key"
+	SecretType     string `json:"secretType"`               // "mnemonic" or "private_key"
+	DerivationPath string `json:"derivationPath,omitempty"` // Account within the phrase that holds this wallet
 }
 
 // CreateWalletHandler handles the creation of a new Solana wallet for the authenticated user.
@@ -230,17 +268,35 @@ func CreateWalletHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 		log.Printf("Received PublicKey: %s from Solana API", solanaResp.PublicKey)
 
 		// --- Encrypt Mnemonic ---
-		encryptedMnemonic, metadata, err := crypto.EncryptMnemonic(solanaResp.Mnemonic, req.Password)
+		encryptedMnemonic, metadata, err := crypto.EncryptMnemonic(solanaResp.Mnemonic, req.Password, cfg.WalletKeys)
 		if err != nil {
 			log.Printf("Error encrypting mnemonic for user %d: %v", userID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to secure wallet information"})
 		}
 
 		// --- Save Wallet to Database ---
+		// The first wallet becomes the default; later ones are added alongside it
+		hasDefault, err := hasDefaultWallet(db, userID)
+		if err != nil {
+			log.Printf("Error checking default wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+		}
+		name := "Primary Wallet" // Default name
+		if hasDefault {
+			name = "Wallet"
+		}
+		if strings.TrimSpace(req.Name) != "" {
+			if name, err = normalizeWalletName(req.Name); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
 		newWallet := models.Wallet{
 			UserID:           userID,
 			Address:          solanaResp.PublicKey,
-			Name:             "Primary Wallet", // Default name
+			Name:             name,
+			IsDefault:        !hasDefault,
+			DerivationPath:   signer.DefaultDerivationPath,
+			SecretType:       signer.SecretMnemonic,
 			EncryptedMnemonic: encryptedMnemonic,
 			EncryptionMetadata: metadata,
 		}
@@ -274,6 +330,7 @@ func CreateWalletHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 		return c.Status(fiber.StatusCreated).JSON(CreateWalletResponse{
 			Message:  "Wallet created successfully. Secure your mnemonic phrase!",
 			Mnemonic: solanaResp.Mnemonic,
+			Wallet:   &newWallet,
 		})
 	}
 }
@@ -295,15 +352,18 @@ func GetWalletBalanceHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 		log.Printf("User %d requesting wallet balance", userID)
 
 		// --- Get Wallet Address from DB ---
-		var wallet models.Wallet
-		// Assuming user has one primary wallet for now. Add logic if multiple wallets are possible.
-		result := db.Where("user_id = ?", userID).First(&wallet)
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				log.Printf("User %d has no wallet record", userID)
+		// ?walletId= or ?walletAddress= picks a wallet; otherwise the default wallet is used
+		var sel WalletSelector
+		if err := c.QueryParser(&sel); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid wallet selection: " + err.Error()})
+		}
+		wallet, err := findUserWallet(db, userID, sel)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				log.Printf("User %d has no matching wallet record", userID)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
 			}
-			log.Printf("Error fetching wallet for user %d: %v", userID, result.Error)
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
 		}
 		userWalletAddress := wallet.Address
@@ -391,16 +451,18 @@ func GetWalletTeamTokenBalanceHandler(db *gorm.DB, cfg *config.Config) fiber.Han
 
 		log.Printf("User %d requesting wallet TEAM token balance", userID)
 
-		// 2. Find the wallet for the user
-		var wallet models.Wallet
-		// Assuming user has one primary wallet for now. Add logic if multiple wallets are possible.
-		result := db.Where("user_id = ?", userID).First(&wallet)
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				log.Printf("User %d has no wallet record", userID)
+		// 2. Find the wallet for the user (?walletId= or ?walletAddress=, default wallet otherwise)
+		var sel WalletSelector
+		if err := c.QueryParser(&sel); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid wallet selection: " + err.Error()})
+		}
+		wallet, err := findUserWallet(db, userID, sel)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				log.Printf("User %d has no matching wallet record", userID)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
 			}
-			log.Printf("Error fetching wallet for user %d: %v", userID, result.Error)
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
 		}
 		userWalletAddress := wallet.Address
@@ -651,9 +713,9 @@ func RedeemPresaleCode(db *gorm.DB) fiber.Handler {
 
 		// Handle WalletAddress based on Type
 		if presaleCode.Type == 1 {
-			// For Type 1, find the user's primary wallet address
-			var userWallet models.Wallet // Assuming models.Wallet exists
-			if err := tx.Where("user_id = ?", userID).First(&userWallet).Error; err != nil {
+			// For Type 1, find the user's default wallet address
+			userWallet, err := findUserWallet(tx, userID, WalletSelector{})
+			if err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					tx.Rollback()
 					log.Printf("User %d does not have a wallet", userID)
@@ -700,9 +762,103 @@ func RedeemPresaleCode(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// phraseRevealWindow is how long a held recovery phrase reveal can be completed once available.
+const phraseRevealWindow = 24 * time.Hour
+
+// holdPhraseReveal decides whether the phrase behind wallet may be shown now. The phrase lifts every
+// spending limit, so if any wallet sharing it (derived accounts share their seed) has an active
+// policy, a reveal is first requested and then waits out the longest change delay; it returns when
+// the reveal becomes available, or nil when it may go ahead. A completed reveal is used up.
+func holdPhraseReveal(db *gorm.DB, wallet *models.Wallet, userID uint, ip string) (*time.Time, error) {
+	var walletIDs []uint
+	if err := db.Model(&models.Wallet{}).Where("user_id = ? AND encrypted_mnemonic = ?", userID, wallet.EncryptedMnemonic).
+		Pluck("id", &walletIDs).Error; err != nil {
+		return nil, err
+	}
+	var held []*models.WalletSpendingPolicy
+	for _, id := range walletIDs {
+		policy, err := loadSpendingPolicy(db, id)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil && policyActive(policy.SpendingPolicySettings) && policy.ChangeDelayHours > 0 {
+			held = append(held, policy)
+		}
+	}
+	if len(held) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var availableAt time.Time
+	requested := true
+	for _, policy := range held {
+		at := policy.PhraseRevealAvailableAt
+		if at == nil || now.After(at.Add(phraseRevealWindow)) {
+			requested = false
+			break
+		}
+		if at.After(availableAt) {
+			availableAt = *at
+		}
+	}
+	if requested && !now.Before(availableAt) {
+		return nil, db.Model(&models.WalletSpendingPolicy{}).Where("id IN ?", policyIDs(held)).
+			Update("phrase_reveal_available_at", gorm.Expr("NULL")).Error
+	}
+	if requested {
+		return &availableAt, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, policy := range held {
+			at := now.Add(time.Duration(policy.ChangeDelayHours) * time.Hour)
+			if at.After(availableAt) {
+				availableAt = at
+			}
+		}
+		if err := tx.Model(&models.WalletSpendingPolicy{}).Where("id IN ?", policyIDs(held)).
+			Update("phrase_reveal_available_at", availableAt).Error; err != nil {
+			return err
+		}
+		meta, _ := json.Marshal(map[string]any{"wallet_id": wallet.ID, "available_at": availableAt})
+		return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "recovery_phrase_reveal_requested", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &availableAt, nil
+}
+
+func policyIDs(policies []*models.WalletSpendingPolicy) []uint {
+	ids := make([]uint, len(policies))
+	for i, p := range policies {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// sendWalletSecurityEmail emails the user through send, in the background; failures are logged.
+func sendWalletSecurityEmail(db *gorm.DB, emailClient *email.Client, userID uint, send func(ec *email.Client, to string) error) {
+	if emailClient == nil {
+		return
+	}
+	var user models.User
+	if err := db.Select("id", "email").First(&user, userID).Error; err != nil {
+		log.Printf("Error loading email of user %d: %v", userID, err)
+		return
+	}
+	go func() {
+		if err := send(emailClient, user.Email); err != nil {
+			log.Printf("Error sending wallet security email to user %d: %v", userID, err)
+		}
+	}()
+}
+
 // GetRecoveryPhraseHandler handles the request to view the user's recovery phrase.
-// It requires the user's password to decrypt the stored mnemonic.
-func GetRecoveryPhraseHandler(db *gorm.DB) fiber.Handler {
+// It requires the user's password to decrypt the stored mnemonic. Under a spending policy the
+// first request only schedules the reveal (202 with revealAvailableAt), and the user is emailed.
+func GetRecoveryPhraseHandler(db *gorm.DB, emailClient *email.Client) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userIDInterface := c.Locals("userID")
 		if userIDInterface == nil {
@@ -730,8 +886,8 @@ func GetRecoveryPhraseHandler(db *gorm.DB) fiber.Handler {
 		}
 
 		// Fetch the user's wallet
-		var wallet models.Wallet
-		if err := db.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		wallet, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				log.Printf("Wallet not found for user %d", userID)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
@@ -740,6 +896,13 @@ func GetRecoveryPhraseHandler(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error fetching wallet"})
 		}
 
+		if wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This is a watch-only wallet and has no recovery phrase"})
+		}
+		if wallet.RecoveryRequiredAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+		}
+
 		// Check if mnemonic is actually encrypted
 		if wallet.EncryptedMnemonic == "" || wallet.EncryptionMetadata == nil || len(wallet.EncryptionMetadata) == 0 {
 			log.Printf("Error: Wallet %d for user %d does not have encrypted mnemonic data", wallet.ID, userID)
@@ -760,9 +923,42 @@ func GetRecoveryPhraseHandler(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."}) 
 		}
 
+		ip := c.IP()
+		availableAt, err := holdPhraseReveal(db, wallet, userID, ip)
+		if err != nil {
+			decryptedMnemonic = ""
+			log.Printf("Error checking spending policy before revealing wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check spending policy"})
+		}
+		if availableAt != nil {
+			decryptedMnemonic = ""
+			address, at := wallet.Address, *availableAt
+			sendWalletSecurityEmail(db, emailClient, userID, func(ec *email.Client, to string) error {
+				return ec.SendRecoveryPhraseRevealRequestedEmail(to, address, at)
+			})
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"message":           "This wallet has a spending policy, so its recovery phrase can be viewed once the policy's change delay has passed.",
+				"revealAvailableAt": at,
+			})
+		}
+		meta, _ := json.Marshal(map[string]any{"wallet_id": wallet.ID})
+		if err := db.Create(&models.SecurityAuditLog{UserID: userID, Action: "recovery_phrase_revealed", IP: &ip, Meta: datatypes.JSON(meta)}).Error; err != nil {
+			log.Printf("Error auditing recovery phrase reveal of wallet %d (user %d): %v", wallet.ID, userID, err)
+		}
+		address := wallet.Address
+		sendWalletSecurityEmail(db, emailClient, userID, func(ec *email.Client, to string) error {
+			return ec.SendRecoveryPhraseRevealedEmail(to, address)
+		})
+
 		// --- Return Decrypted Phrase ---
 		response := GetRecoveryPhraseResponse{
 			RecoveryPhrase: decryptedMnemonic,
+			SecretType:     signer.SecretMnemonic,
+		}
+		if wallet.SecretType == signer.SecretPrivateKey {
+			response.SecretType = signer.SecretPrivateKey
+		} else {
+			response.DerivationPath = walletDerivationPath(wallet)
 		}
 
 		// Clear decrypted mnemonic from memory after creating response
@@ -772,7 +968,7 @@ func GetRecoveryPhraseHandler(db *gorm.DB) fiber.Handler {
 	}
 }
 
-// SignTransactionHandler handles decrypting the user's mnemonic and requesting the solana-api to sign a transaction.
+// SignTransactionHandler decrypts the user's mnemonic and signs a transaction in-process via the signer package.
 func SignTransactionHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// --- Authentication ---
@@ -796,91 +992,176 @@ func SignTransactionHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 		}
 
 		// --- Get Wallet from DB ---
-		var wallet models.Wallet
-		// TODO: Add logic to select specific wallet if user can have multiple (using req.WalletAddress?)
-		result := db.Where("user_id = ?", userID).First(&wallet)
-		if result.Error != nil {
-			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				log.Printf("User %d has no wallet record", userID)
+		wallet, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("User %d has no matching wallet record", userID)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
 			}
-			log.Printf("Error fetching wallet for user %d: %v", userID, result.Error)
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
 		}
 
+		if wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This is a watch-only wallet and can't sign transactions"})
+		}
+		if wallet.RecoveryRequiredAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+		}
+
 		// Check if mnemonic is actually encrypted (for backward compatibility or error states)
 		if wallet.EncryptedMnemonic == "" || wallet.EncryptionMetadata == nil || len(wallet.EncryptionMetadata) == 0 {
 			log.Printf("Error: Wallet %d for user %d does not have encrypted mnemonic data", wallet.ID, userID)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Wallet data is incomplete or not configured for signing"})
 		}
 
-		// --- Decrypt Mnemonic ---
-		mnemonic, err := crypto.DecryptMnemonic(wallet.EncryptedMnemonic, wallet.EncryptionMetadata, req.Password)
+		// --- Enforce Spending Policy ---
+		policy, err := loadSpendingPolicy(db, wallet.ID)
 		if err != nil {
-			// Log the generic error but return a user-friendly one (likely wrong password)
-			log.Printf("Failed to decrypt mnemonic for wallet %d (user %d): %v", wallet.ID, userID, err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."}) // 401 Unauthorized suggests password issue
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		check := &policyCheck{}
+		if policy != nil && policyActive(policy.SpendingPolicySettings) {
+			owner, err := solana.PublicKeyFromBase58(wallet.Address)
+			if err != nil {
+				log.Printf("Error: Wallet %d for user %d has invalid address %q: %v", wallet.ID, userID, wallet.Address, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Wallet address is invalid"})
+			}
+			summary, err := signer.InspectTransaction(req.UnsignedTransaction, owner)
+			if err != nil {
+				if errors.Is(err, signer.ErrInvalidTx) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid unsigned transaction", "details": err.Error()})
+				}
+				log.Printf("Failed to inspect transaction for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to inspect transaction"})
+			}
+			resolveTokenRecipients(summary, owner)
+			if check, err = checkSpendingPolicy(db, cfg, wallet, policy, summary); err != nil {
+				log.Printf("Error checking spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the transaction against this wallet's spending policy"})
+			}
+			if len(check.Violations) > 0 {
+				log.Printf("Spending policy blocked signing for wallet %d (user %d): %d violation(s)", wallet.ID, userID, len(check.Violations))
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Transaction blocked by this wallet's spending policy", "violations": check.Violations})
+			}
 		}
 
-		// --- Call Solana API to Sign Transaction ---
-		solanaAPIURL := cfg.SolanaAPIURL
-		if strings.Contains(solanaAPIURL, "//localhost") { // Normalize localhost for local dev
-			solanaAPIURL = strings.Replace(solanaAPIURL, "//localhost", "//127.0.0.1", 1)
-		}
-		if solanaAPIURL == "" {
-			log.Println("Error: SOLANA_API_BASE_URL not configured")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Configuration error: Solana API URL missing"})
+		// --- Derive Key and Sign Locally ---
+		// The mnemonic and derived key stay in this process and are zeroed once signing is done.
+		keypair, err := unlockWallet(wallet, req.Password)
+		if err == nil {
+			upgradeWalletEncryption(db, cfg.WalletKeys, wallet, req.Password)
 		}
-		signURL := fmt.Sprintf("%s/api/wallet/sign", solanaAPIURL)
-
-		// Prepare request body for solana-api
-		solanaReqBody := SolanaSignRequest{
-			Mnemonic:            mnemonic, // Use the decrypted mnemonic
-			UnsignedTransaction: req.UnsignedTransaction,
+		req.Password = ""
+		if err != nil {
+			log.Printf("Failed to derive signing key for wallet %d (user %d): %v", wallet.ID, userID, err)
+			if errors.Is(err, signer.ErrDecryptFailed) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."}) // 401 Unauthorized suggests password issue
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet key"})
 		}
-		jsonReqBody, err := json.Marshal(solanaReqBody)
+		spend, err := reserveWalletSpend(db, wallet, check)
 		if err != nil {
-			log.Printf("Error marshaling request body for solana-api: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare signing request"})
+			keypair.Zero()
+			if errors.Is(err, errDailyLimitExceeded) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Transaction blocked by this wallet's spending policy", "violations": []signer.TxWarning{{Code: PolicyDailyLimit, Message: "Transaction would exceed the daily spending limit"}}})
+			}
+			log.Printf("Error recording spend for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to sign transaction"})
+		}
+		signedTx, err := signer.SignTransaction(keypair, req.UnsignedTransaction)
+		keypair.Zero()
+		if err != nil && spend != nil {
+			if delErr := db.Delete(spend).Error; delErr != nil {
+				log.Printf("Error releasing spend %d for wallet %d (user %d): %v", spend.ID, wallet.ID, userID, delErr)
+			}
 		}
-
-		// Make the POST request
-		log.Printf("Calling Solana API Signer: POST %s", signURL)
-		httpClient := &http.Client{Timeout: 15 * time.Second} // Add a timeout
-		resp, err := httpClient.Post(signURL, "application/json", bytes.NewBuffer(jsonReqBody))
 		if err != nil {
-			log.Printf("Error calling Solana API Signer: %v", err)
-			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Failed to connect to Solana signing service"})
+			log.Printf("Failed to sign transaction for wallet %d (user %d): %v", wallet.ID, userID, err)
+			switch {
+			case errors.Is(err, signer.ErrInvalidTx), errors.Is(err, signer.ErrMissingBlockhash):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid unsigned transaction", "details": err.Error()})
+			case errors.Is(err, signer.ErrNotASigner):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction does not require a signature from this wallet"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to sign transaction"})
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK { // Expect 200 OK for successful signing
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			log.Printf("Error from Solana API Signer (Status %d): %s", resp.StatusCode, string(bodyBytes))
-			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-				"error":         "Failed to sign transaction via Solana service",
-				"upstreamError": string(bodyBytes), // Provide upstream error if possible
-			})
+		log.Printf("Signed transaction locally for wallet %d (user %d)", wallet.ID, userID)
+
+		// --- Return Signed Transaction ---
+		return c.Status(fiber.StatusOK).JSON(SignTransactionResponse{
+			SignedTransaction: signedTx,
+		})
+	}
+}
+
+// InspectTransactionHandler decodes an unsigned transaction and summarizes what signing it would do
+// for the user's wallet, so the app can show a confirmation screen before asking for the password.
+func InspectTransactionHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userIDInterface := c.Locals("userID")
+		if userIDInterface == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+		userID, ok := userIDInterface.(uint)
+		if !ok {
+			log.Printf("Error: Invalid user ID type in context: %T", userIDInterface)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error: Invalid user ID type"})
 		}
 
-		// Decode the response from solana-api
-		var solanaResp SolanaSignResponse
-		if err := json.NewDecoder(resp.Body).Decode(&solanaResp); err != nil {
-			log.Printf("Error decoding Solana API Signer response: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process Solana signing service response"})
+		var req InspectTransactionRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		if req.UnsignedTransaction == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unsignedTransaction is required"})
 		}
 
-		if solanaResp.SignedTransaction == "" {
-			log.Println("Error: Invalid response from Solana API Signer - missing signedTransaction")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Received invalid data from Solana signing service"})
+		wallet, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+			}
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+		owner, err := solana.PublicKeyFromBase58(wallet.Address)
+		if err != nil {
+			log.Printf("Error: Wallet %d for user %d has invalid address %q: %v", wallet.ID, userID, wallet.Address, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Wallet address is invalid"})
 		}
 
-		log.Printf("Successfully received signed transaction from solana-api for wallet %d (user %d)", wallet.ID, userID)
+		summary, err := signer.InspectTransaction(req.UnsignedTransaction, owner)
+		if err != nil {
+			if errors.Is(err, signer.ErrInvalidTx) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid unsigned transaction", "details": err.Error()})
+			}
+			log.Printf("Failed to inspect transaction for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to inspect transaction"})
+		}
+		resolveTokenRecipients(summary, owner)
+		warnings, err := recipientWarnings(db, userID, summary.Recipients)
+		if err != nil {
+			log.Printf("Error checking recipients against address book for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to inspect transaction"})
+		}
+		summary.Warnings = append(summary.Warnings, warnings...)
 
-		// --- Return Signed Transaction ---
-		return c.Status(fiber.StatusOK).JSON(SignTransactionResponse{
-			SignedTransaction: solanaResp.SignedTransaction,
-		})
+		// Spending policy violations are reported here too so the app can explain a refusal before asking for the password
+		policy, err := loadSpendingPolicy(db, wallet.ID)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to inspect transaction"})
+		}
+		check, err := checkSpendingPolicy(db, cfg, wallet, policy, summary)
+		if err != nil {
+			log.Printf("Error checking spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Unable to check the transaction against this wallet's spending policy"})
+		}
+		summary.Warnings = append(summary.Warnings, check.Violations...)
+		return c.Status(fiber.StatusOK).JSON(summary)
 	}
 }
 
@@ -908,8 +1189,72 @@ func SendTransactionHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "signedTransaction is required"})
 		}
 
+		if req.ContactID != 0 {
+			var count int64
+			if err := db.Model(&models.AddressBookContact{}).Where("id = ? AND user_id = ?", req.ContactID, userID).Count(&count).Error; err != nil {
+				log.Printf("Error fetching contact %d for user %d: %v", req.ContactID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve contact"})
+			}
+			if count == 0 {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Contact not found"})
+			}
+		}
+
+		if req.WalletSelector.IsSet() {
+			wallet, err := findUserWallet(db, userID, req.WalletSelector)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+				}
+				log.Printf("Error fetching wallet for user %d: %v", userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+			}
+			tx, err := solana.TransactionFromBase64(req.SignedTransaction)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid signed transaction", "details": err.Error()})
+			}
+			walletKey, err := solana.PublicKeyFromBase58(wallet.Address)
+			if err != nil || !tx.IsSigner(walletKey) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction is not signed by the selected wallet"})
+			}
+		}
+
 		log.Printf("Processing send transaction request for user %d", userID)
 
+		// --- Send and Confirm via RPC ---
+		// Re-broadcasts until the transaction lands or its blockhash expires
+		if len(solanarpc.Upstreams()) > 0 {
+			tx, err := solana.TransactionFromBase64(req.SignedTransaction)
+			if err != nil || len(tx.Signatures) == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid signed transaction"})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), sendAndConfirmTimeout)
+			defer cancel()
+			result, err := solanarpc.SendAndConfirm(ctx, req.SignedTransaction)
+			if err != nil {
+				log.Printf("Error landing transaction %s for user %d: %v", tx.Signatures[0], userID, err)
+				return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+					"error":     "Transaction status unknown; check the signature before resending",
+					"signature": tx.Signatures[0].String(),
+				})
+			}
+			log.Printf("Transaction %s for user %d %s after %d broadcast(s)", result.Signature, userID, result.Status, result.Attempts)
+			if result.Status == solanarpc.StatusConfirmed && req.ContactID != 0 {
+				if err := db.Model(&models.AddressBookContact{}).Where("id = ? AND user_id = ?", req.ContactID, userID).
+					Update("last_used_at", time.Now()).Error; err != nil {
+					log.Printf("Error marking contact %d used for user %d: %v", req.ContactID, userID, err)
+				}
+			}
+			return c.Status(fiber.StatusOK).JSON(SendTransactionResponse{
+				Success:   result.Status == solanarpc.StatusConfirmed,
+				Signature: result.Signature,
+				Status:    result.Status,
+				Slot:      result.Slot,
+				Error:     result.Error,
+				Attempts:  result.Attempts,
+			})
+		}
+
 		// --- Call Solana API to Send Transaction ---
 		solanaAPIURL := cfg.SolanaAPIURL
 		if strings.Contains(solanaAPIURL, "//localhost") { // Normalize localhost for local dev
@@ -959,6 +1304,13 @@ func SendTransactionHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 
 		log.Printf("Successfully received transaction response from solana-api for user %d", userID)
 
+		if req.ContactID != 0 {
+			if err := db.Model(&models.AddressBookContact{}).Where("id = ? AND user_id = ?", req.ContactID, userID).
+				Update("last_used_at", time.Now()).Error; err != nil {
+				log.Printf("Error marking contact %d used for user %d: %v", req.ContactID, userID, err)
+			}
+		}
+
 		// --- Return Transaction Response ---
 		return c.Status(fiber.StatusOK).JSON(solanaResp)
 	}
@@ -984,8 +1336,35 @@ func GetTransactionsHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body format"})
 		}
 
-		if req.Address == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "address is required"})
+		if req.AllWallets && !historyIndexAvailable() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Transaction history index not configured"})
+		}
+
+		// A selected wallet (or the default wallet when no address is given) overrides address
+		if !req.AllWallets && (req.WalletSelector.IsSet() || req.Address == "") {
+			wallet, err := findUserWallet(db, userID, req.WalletSelector)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+				}
+				log.Printf("Error fetching wallet for user %d: %v", userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+			}
+			req.Address = wallet.Address
+		}
+
+		if historyIndexAvailable() {
+			addresses, err := userHistoryAddresses(db, userID, &req)
+			if err != nil {
+				log.Printf("Error checking wallet addresses for user %d: %v", userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+			}
+			if len(addresses) > 0 {
+				return serveIndexedTransactions(c, db, userID, &req, addresses)
+			}
+			if req.AllWallets {
+				return c.JSON(GetTransactionsResponse{Transactions: []Transaction{}})
+			}
 		}
 
 		log.Printf("Processing get transactions request for user %d", userID)
@@ -999,7 +1378,11 @@ func GetTransactionsHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
 		transactionsURL := fmt.Sprintf("%s/api/wallet/transactions", solanaAPIURL)
 
 		// Prepare request body for solana-api
-		jsonReqBody, err := json.Marshal(req)
+		solanaReqBody := map[string]any{"address": req.Address}
+		if req.Limit > 0 {
+			solanaReqBody["limit"] = req.Limit
+		}
+		jsonReqBody, err := json.Marshal(solanaReqBody)
 		if err != nil {
 			log.Printf("Error marshaling request body for solana-api: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare get transactions request"})
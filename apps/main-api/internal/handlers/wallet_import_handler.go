@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/crypto"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/security"
+	"github.com/team556-mono/server/internal/signer"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ImportWalletRequest imports a wallet from another app. Exactly one of Mnemonic or SecretKey is required.
+type ImportWalletRequest struct {
+	Password        string `json:"password" validate:"required"` // Account password; the secret is encrypted with it
+	Mnemonic        string `json:"mnemonic,omitempty"`
+	SecretKey       string `json:"secretKey,omitempty"`       // Base58, as exported by Phantom
+	DerivationPath  string `json:"derivationPath,omitempty"`  // Mnemonic only; defaults to m/44'/501'/0'/0'
+	ExpectedAddress string `json:"expectedAddress,omitempty"` // If set, the import fails unless the derived address matches
+	Name            string `json:"name,omitempty"`
+}
+
+// PreviewWalletImportRequest shows which addresses a secret resolves to without saving anything
+type PreviewWalletImportRequest struct {
+	Mnemonic       string `json:"mnemonic,omitempty"`
+	SecretKey      string `json:"secretKey,omitempty"`
+	DerivationPath string `json:"derivationPath,omitempty"` // Mnemonic only; common paths are tried when empty
+}
+
+// WalletImportCandidate is one address a secret resolves to
+type WalletImportCandidate struct {
+	DerivationPath string `json:"derivationPath,omitempty"`
+	Address        string `json:"address"`
+	AlreadyAdded   bool   `json:"alreadyAdded"`
+}
+
+// PreviewWalletImportResponse lists the candidate addresses for an import
+type PreviewWalletImportResponse struct {
+	SecretType string                  `json:"secretType"`
+	Candidates []WalletImportCandidate `json:"candidates"`
+}
+
+// resolveImportSecret validates the secret in an import request and returns its kind and normalized form.
+func resolveImportSecret(mnemonic, secretKey string) (kind, secret string, err error) {
+	mnemonic, secretKey = strings.TrimSpace(mnemonic), strings.TrimSpace(secretKey)
+	switch {
+	case mnemonic != "" && secretKey != "":
+		return "", "", errors.New("Provide either a recovery phrase or a secret key, not both")
+	case mnemonic != "":
+		normalized, err := signer.NormalizeMnemonic(mnemonic)
+		if err != nil {
+			return "", "", errors.New("Recovery phrase is not valid. Check the words and their order.")
+		}
+		return signer.SecretMnemonic, normalized, nil
+	case secretKey != "":
+		kp, err := signer.ParseSecretKey(secretKey)
+		if err != nil {
+			return "", "", errors.New("Secret key is not valid. Paste the base58 private key exported from your wallet.")
+		}
+		kp.Zero()
+		return signer.SecretPrivateKey, secretKey, nil
+	}
+	return "", "", errors.New("A recovery phrase or secret key is required")
+}
+
+// importKeypair returns the signing key for a secret resolved by resolveImportSecret.
+func importKeypair(kind, secret, path string) (*signer.Keypair, error) {
+	if kind == signer.SecretPrivateKey {
+		return signer.ParseSecretKey(secret)
+	}
+	mnemonic := []byte(secret)
+	defer signer.Zero(mnemonic)
+	return signer.DeriveKeypair(mnemonic, path)
+}
+
+// PreviewWalletImportHandler returns the address(es) a recovery phrase or secret key resolves to,
+// so the user can pick the derivation path that matches the wallet they hold. Nothing is stored.
+func PreviewWalletImportHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req PreviewWalletImportRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		kind, secret, err := resolveImportSecret(req.Mnemonic, req.SecretKey)
+		req.Mnemonic, req.SecretKey = "", ""
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		paths := []string{""}
+		if kind == signer.SecretMnemonic {
+			paths = signer.CommonDerivationPaths
+			if req.DerivationPath != "" {
+				if err := signer.ValidateDerivationPath(req.DerivationPath); err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+				}
+				paths = []string{req.DerivationPath}
+			}
+		}
+
+		resp := PreviewWalletImportResponse{SecretType: kind, Candidates: make([]WalletImportCandidate, 0, len(paths))}
+		addresses := make([]string, 0, len(paths))
+		for _, path := range paths {
+			kp, err := importKeypair(kind, secret, path)
+			if err != nil {
+				log.Printf("Error deriving import preview at %q for user %d: %v", path, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet address"})
+			}
+			address := kp.PublicKey().String()
+			kp.Zero()
+			resp.Candidates = append(resp.Candidates, WalletImportCandidate{DerivationPath: path, Address: address})
+			addresses = append(addresses, address)
+		}
+
+		var existing []string
+		if err := db.Model(&models.Wallet{}).Where("user_id = ? AND address IN ?", userID, addresses).Pluck("address", &existing).Error; err != nil {
+			log.Printf("Error checking existing wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+		for i := range resp.Candidates {
+			for _, addr := range existing {
+				if resp.Candidates[i].Address == addr {
+					resp.Candidates[i].AlreadyAdded = true
+				}
+			}
+		}
+		return c.JSON(resp)
+	}
+}
+
+// ImportWalletHandler imports a wallet from a bip39 recovery phrase (at a chosen derivation path)
+// or a base58 secret key, encrypting the secret with the user's account password.
+func ImportWalletHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req ImportWalletRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		kind, secret, err := resolveImportSecret(req.Mnemonic, req.SecretKey)
+		req.Mnemonic, req.SecretKey = "", ""
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if req.Password == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password is required"})
+		}
+
+		path := ""
+		if kind == signer.SecretMnemonic {
+			path = signer.DefaultDerivationPath
+			if req.DerivationPath != "" {
+				if err := signer.ValidateDerivationPath(req.DerivationPath); err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+				}
+				path = req.DerivationPath
+			}
+		}
+
+		// Wallet secrets are encrypted with the account password so they follow password changes
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Error loading user %d for wallet import: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load account"})
+		}
+		if ok, _, _ := security.VerifyPassword(user.Password, req.Password); !ok {
+			req.Password = ""
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+		}
+
+		kp, err := importKeypair(kind, secret, path)
+		if err != nil {
+			req.Password = ""
+			log.Printf("Error deriving imported wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet address"})
+		}
+		address := kp.PublicKey().String()
+		kp.Zero()
+
+		if req.ExpectedAddress != "" && strings.TrimSpace(req.ExpectedAddress) != address {
+			req.Password = ""
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":          "The imported secret does not match the expected address. Try a different derivation path.",
+				"derivedAddress": address,
+				"derivationPath": path,
+			})
+		}
+
+		var existing models.Wallet
+		err = db.Unscoped().Where("address = ? AND watch_only = ?", address, false).First(&existing).Error
+		if err == nil {
+			req.Password = ""
+			if existing.UserID == userID && !existing.DeletedAt.Valid {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This wallet is already in your account", "walletId": existing.ID})
+			}
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This wallet can't be imported"})
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			req.Password = ""
+			log.Printf("Error checking wallet address for import by user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+
+		encryptedSecret, metadata, err := crypto.EncryptMnemonic(secret, req.Password, cfg.WalletKeys)
+		req.Password = ""
+		if err != nil {
+			log.Printf("Error encrypting imported wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to secure wallet information"})
+		}
+
+		name := "Imported Wallet"
+		if strings.TrimSpace(req.Name) != "" {
+			if name, err = normalizeWalletName(req.Name); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		hasDefault, err := hasDefaultWallet(db, userID)
+		if err != nil {
+			log.Printf("Error checking default wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+		}
+
+		now := time.Now()
+		wallet := models.Wallet{
+			UserID:             userID,
+			Address:            address,
+			Name:               name,
+			IsDefault:          !hasDefault,
+			DerivationPath:     path,
+			AccountIndex:       signer.AccountIndex(path),
+			SecretType:         kind,
+			ImportedAt:         &now,
+			EncryptedMnemonic:  encryptedSecret,
+			EncryptionMetadata: metadata,
+		}
+		// Importing the key for an address the user already watches turns that entry into an owned wallet
+		var watched models.Wallet
+		if err := db.Where("user_id = ? AND address = ? AND watch_only = ?", userID, address, true).First(&watched).Error; err == nil {
+			wallet.ID = watched.ID
+			wallet.CreatedAt = watched.CreatedAt
+			if strings.TrimSpace(req.Name) == "" {
+				wallet.Name = watched.Name
+			}
+		}
+		ip := c.IP()
+		meta, _ := json.Marshal(map[string]any{"address": address, "secret_type": kind, "derivation_path": path})
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&wallet).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "wallet_imported", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving imported wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+		}
+
+		log.Printf("User %d imported wallet %d (%s)", userID, wallet.ID, kind)
+		return c.Status(fiber.StatusCreated).JSON(wallet)
+	}
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/utils"
+	"gorm.io/gorm"
+)
+
+const maxWalletNameLength = 50
+
+// WalletSelector picks one of the user's wallets by ID or address.
+// When both are empty the user's default wallet is used.
+type WalletSelector struct {
+	WalletID      uint   `json:"walletId,omitempty" query:"walletId"`
+	WalletAddress string `json:"walletAddress,omitempty" query:"walletAddress"`
+}
+
+// IsSet reports whether the request picked a specific wallet.
+func (s WalletSelector) IsSet() bool {
+	return s.WalletID != 0 || s.WalletAddress != ""
+}
+
+// CreateWalletAccountRequest derives another account from an existing wallet's recovery phrase
+type CreateWalletAccountRequest struct {
+	WalletSelector        // Wallet whose recovery phrase to derive from; default wallet if empty
+	Password       string `json:"password" validate:"required"`
+	Name           string `json:"name,omitempty"`
+}
+
+// AddWatchOnlyWalletRequest defines the body for watching an address the user holds elsewhere
+type AddWatchOnlyWalletRequest struct {
+	Address string `json:"address" validate:"required"`
+	Name    string `json:"name,omitempty"`
+}
+
+// RenameWalletRequest defines the body for renaming a wallet
+type RenameWalletRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// ListWalletsResponse lists the user's wallets, default first
+type ListWalletsResponse struct {
+	Wallets []models.Wallet `json:"wallets"`
+}
+
+// findUserWallet loads the wallet picked by sel, which must belong to userID.
+// Archived and watch-only wallets can be picked explicitly but are never the default.
+func findUserWallet(db *gorm.DB, userID uint, sel WalletSelector) (*models.Wallet, error) {
+	q := db.Where("user_id = ?", userID)
+	if sel.WalletID != 0 {
+		q = q.Where("id = ?", sel.WalletID)
+	}
+	if sel.WalletAddress != "" {
+		q = q.Where("address = ?", sel.WalletAddress)
+	}
+	if !sel.IsSet() {
+		// Fall back to the oldest active wallet for accounts that predate default selection
+		q = q.Where("archived_at IS NULL AND watch_only = ?", false).Order("is_default DESC")
+	}
+	var wallet models.Wallet
+	if err := q.First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// walletDerivationPath returns the key path to sign with for wallet.
+func walletDerivationPath(wallet *models.Wallet) string {
+	if wallet.DerivationPath == "" {
+		return signer.DefaultDerivationPath
+	}
+	return wallet.DerivationPath
+}
+
+var errWatchOnlyWallet = errors.New("watch-only wallet has no signing key")
+
+// unlockWallet decrypts wallet's secret with password and returns its signing key.
+// The caller must Zero the keypair.
+func unlockWallet(wallet *models.Wallet, password string) (*signer.Keypair, error) {
+	if wallet.WatchOnly {
+		return nil, errWatchOnlyWallet
+	}
+	return signer.UnlockEncrypted(wallet.SecretType, wallet.EncryptedMnemonic, wallet.EncryptionMetadata, password, walletDerivationPath(wallet))
+}
+
+// hasDefaultWallet reports whether the user already has an active default wallet.
+func hasDefaultWallet(db *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Wallet{}).
+		Where("user_id = ? AND is_default = ? AND archived_at IS NULL", userID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func normalizeWalletName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("Wallet name is required")
+	}
+	if len([]rune(name)) > maxWalletNameLength {
+		return "", errors.New("Wallet name must be 50 characters or fewer")
+	}
+	return name, nil
+}
+
+// walletFromParam loads the wallet named by the :id route parameter for the authenticated user,
+// writing the error response itself when it returns nil.
+func walletFromParam(c *fiber.Ctx, db *gorm.DB) (*models.Wallet, uint, error) {
+	userID, ok := c.Locals("userID").(uint)
+	if !ok || userID == 0 {
+		return nil, 0, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, userID, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid wallet ID"})
+	}
+	wallet, err := findUserWallet(db, userID, WalletSelector{WalletID: uint(id)})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, userID, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found"})
+		}
+		log.Printf("Error fetching wallet %d for user %d: %v", id, userID, err)
+		return nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+	}
+	return wallet, userID, nil
+}
+
+// ListWalletsHandler returns the user's wallets. Archived wallets are included with ?includeArchived=true.
+func ListWalletsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		q := db.Where("user_id = ?", userID)
+		if !c.QueryBool("includeArchived") {
+			q = q.Where("archived_at IS NULL")
+		}
+		wallets := []models.Wallet{}
+		if err := q.Order("is_default DESC").Order("id").Find(&wallets).Error; err != nil {
+			log.Printf("Error listing wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallets"})
+		}
+		return c.JSON(ListWalletsResponse{Wallets: wallets})
+	}
+}
+
+// CreateWalletAccountHandler derives the next bip44 account from an existing wallet's recovery phrase
+// and saves it as a new wallet. The new wallet shares the source wallet's encrypted mnemonic.
+func CreateWalletAccountHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req CreateWalletAccountRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		if req.Password == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password is required"})
+		}
+
+		source, err := findUserWallet(db, userID, req.WalletSelector)
+		if err != nil {
+			req.Password = ""
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Wallet not found for this user"})
+			}
+			log.Printf("Error fetching wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+		if source.EncryptedMnemonic == "" || len(source.EncryptionMetadata) == 0 || source.SecretType == signer.SecretPrivateKey {
+			req.Password = ""
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This wallet has no recovery phrase to derive accounts from"})
+		}
+		if source.RecoveryRequiredAt != nil {
+			req.Password = ""
+			return c.Status(fiber.StatusConflict).JSON(walletRecoveryRequiredResponse)
+		}
+
+		seedID := source.ID
+		if source.SeedWalletID != nil {
+			seedID = *source.SeedWalletID
+		}
+
+		// Include soft-deleted wallets: their addresses still hold the unique index
+		var maxIndex *uint32
+		if err := db.Unscoped().Model(&models.Wallet{}).
+			Where("user_id = ? AND (id = ? OR seed_wallet_id = ?)", userID, seedID, seedID).
+			Select("MAX(account_index)").Scan(&maxIndex).Error; err != nil {
+			req.Password = ""
+			log.Printf("Error finding next account index for wallet %d (user %d): %v", seedID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create wallet account"})
+		}
+		var next uint32
+		if maxIndex != nil {
+			next = *maxIndex + 1
+		}
+		path := signer.AccountPath(next)
+
+		keypair, err := signer.DeriveFromEncrypted(source.EncryptedMnemonic, source.EncryptionMetadata, req.Password, path)
+		req.Password = ""
+		if err != nil {
+			if errors.Is(err, signer.ErrDecryptFailed) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Decryption failed. Please check your password."})
+			}
+			log.Printf("Failed to derive account %d for wallet %d (user %d): %v", next, seedID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet account"})
+		}
+		address := keypair.PublicKey().String()
+		keypair.Zero()
+
+		// The same phrase may have been imported on its own at this account
+		var taken int64
+		if err := db.Unscoped().Model(&models.Wallet{}).Where("address = ? AND watch_only = ?", address, false).Count(&taken).Error; err != nil {
+			log.Printf("Error checking derived address for wallet %d (user %d): %v", seedID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create wallet account"})
+		}
+		if taken > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Account %d of this recovery phrase is already a wallet", next+1)})
+		}
+
+		name := req.Name
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("Account %d", next+1)
+		}
+		if name, err = normalizeWalletName(name); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		hasDefault, err := hasDefaultWallet(db, userID)
+		if err != nil {
+			log.Printf("Error checking default wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create wallet account"})
+		}
+
+		newWallet := models.Wallet{
+			UserID:             userID,
+			Address:            address,
+			Name:               name,
+			IsDefault:          !hasDefault,
+			DerivationPath:     path,
+			AccountIndex:       next,
+			SeedWalletID:       &seedID,
+			SecretType:         signer.SecretMnemonic,
+			EncryptedMnemonic:  source.EncryptedMnemonic,
+			EncryptionMetadata: source.EncryptionMetadata,
+		}
+		if err := db.Create(&newWallet).Error; err != nil {
+			log.Printf("Error saving derived wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+		}
+
+		log.Printf("Derived wallet %d (account %d of wallet %d) for user %d", newWallet.ID, next, seedID, userID)
+		return c.Status(fiber.StatusCreated).JSON(newWallet)
+	}
+}
+
+// AddWatchOnlyWalletHandler adds an address-only wallet, e.g. cold storage, so its balances show in the portfolio.
+func AddWatchOnlyWalletHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok || userID == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized: User ID not found in context"})
+		}
+
+		var req AddWatchOnlyWalletRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		address := utils.NormalizeWalletAddress(req.Address)
+		if err := utils.ValidateWalletAddress(address); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		name := "Watched Wallet"
+		if strings.TrimSpace(req.Name) != "" {
+			var err error
+			if name, err = normalizeWalletName(req.Name); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Deleted wallets keep their address in idx_wallets_user_address
+		var existing models.Wallet
+		err := db.Unscoped().Where("user_id = ? AND address = ?", userID, address).First(&existing).Error
+		if err == nil {
+			if !existing.DeletedAt.Valid {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This address is already in your wallets"})
+			}
+			if !existing.WatchOnly {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This address belongs to a wallet you deleted"})
+			}
+			// Watching a previously removed address again brings back its row
+			if err := db.Unscoped().Model(&existing).Updates(map[string]any{"deleted_at": nil, "archived_at": nil, "is_default": false, "name": name}).Error; err != nil {
+				log.Printf("Error restoring watch-only wallet %d for user %d: %v", existing.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+			}
+			existing.DeletedAt, existing.ArchivedAt, existing.IsDefault, existing.Name = gorm.DeletedAt{}, nil, false, name
+			return c.Status(fiber.StatusCreated).JSON(existing)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error checking wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve wallet information"})
+		}
+
+		wallet := models.Wallet{
+			UserID:    userID,
+			Address:   address,
+			Name:      name,
+			WatchOnly: true,
+		}
+		if err := db.Create(&wallet).Error; err != nil {
+			log.Printf("Error saving watch-only wallet for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save wallet information"})
+		}
+		return c.Status(fiber.StatusCreated).JSON(wallet)
+	}
+}
+
+// RenameWalletHandler changes a wallet's display name.
+func RenameWalletHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, userID, err := walletFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+
+		var req RenameWalletRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		name, err := normalizeWalletName(req.Name)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if err := db.Model(wallet).Update("name", name).Error; err != nil {
+			log.Printf("Error renaming wallet %d for user %d: %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to rename wallet"})
+		}
+		return c.JSON(wallet)
+	}
+}
+
+// SetDefaultWalletHandler makes a wallet the one used when requests don't pick a wallet.
+func SetDefaultWalletHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, userID, err := walletFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		if wallet.ArchivedAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Archived wallets cannot be the default. Restore it first."})
+		}
+		if wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Watch-only wallets cannot be the default"})
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Wallet{}).
+				Where("user_id = ? AND id <> ? AND is_default = ?", userID, wallet.ID, true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+			return tx.Model(wallet).Update("is_default", true).Error
+		})
+		if err != nil {
+			log.Printf("Error setting default wallet %d for user %d: %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to set default wallet"})
+		}
+		return c.JSON(wallet)
+	}
+}
+
+// ArchiveWalletHandler hides a wallet from the wallet list and default selection.
+// The encrypted keys are kept so the wallet can be restored. Archiving the default
+// wallet promotes the oldest remaining active wallet.
+func ArchiveWalletHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, userID, err := walletFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		if wallet.ArchivedAt != nil {
+			return c.JSON(wallet)
+		}
+
+		var active int64
+		if err := db.Model(&models.Wallet{}).Where("user_id = ? AND archived_at IS NULL AND watch_only = ?", userID, false).Count(&active).Error; err != nil {
+			log.Printf("Error counting wallets for user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to archive wallet"})
+		}
+		if active <= 1 && !wallet.WatchOnly {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You can't archive your only active wallet"})
+		}
+
+		now := time.Now()
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(wallet).Updates(map[string]any{"archived_at": &now, "is_default": false}).Error; err != nil {
+				return err
+			}
+			if !wallet.IsDefault {
+				return nil
+			}
+			var next models.Wallet
+			if err := tx.Where("user_id = ? AND archived_at IS NULL AND watch_only = ?", userID, false).Order("id").First(&next).Error; err != nil {
+				return err
+			}
+			return tx.Model(&next).Update("is_default", true).Error
+		})
+		if err != nil {
+			log.Printf("Error archiving wallet %d for user %d: %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to archive wallet"})
+		}
+		wallet.ArchivedAt = &now
+		wallet.IsDefault = false
+		return c.JSON(wallet)
+	}
+}
+
+// RestoreWalletHandler un-archives a wallet.
+func RestoreWalletHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, userID, err := walletFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		if wallet.ArchivedAt == nil {
+			return c.JSON(wallet)
+		}
+		if err := db.Model(wallet).Update("archived_at", nil).Error; err != nil {
+			log.Printf("Error restoring wallet %d for user %d: %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore wallet"})
+		}
+		wallet.ArchivedAt = nil
+		return c.JSON(wallet)
+	}
+}
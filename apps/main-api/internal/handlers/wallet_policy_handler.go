@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+	"github.com/team556-mono/server/internal/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPolicyChangeDelayHours = 24
+	maxPolicyChangeDelayHours     = 30 * 24
+	spendingWindow                = 24 * time.Hour
+)
+
+// Spending policy violations, reported as warnings by inspect-transaction and as the reason
+// sign-transaction refused to sign.
+const (
+	PolicyPerTxLimit          = "policy_per_tx_limit"
+	PolicyDailyLimit          = "policy_daily_limit"
+	PolicyRecipientNotAllowed = "policy_recipient_not_allowed"
+	PolicyUnpricedOutflow     = "policy_unpriced_outflow"
+	PolicyUnverifiable        = "policy_unverifiable"
+)
+
+// unverifiableWarnings are inspector findings that can move funds in ways the policy can't see.
+var unverifiableWarnings = map[string]string{
+	signer.WarnUnknownProgram:     "calls a program whose transfers can't be checked",
+	signer.WarnTokenApproval:      "approves a delegate that could spend outside the policy",
+	signer.WarnAuthorityChange:    "changes an account authority",
+	signer.WarnCloseAccount:       "closes a token account, which moves its balance out",
+	signer.WarnUnresolvedAccounts: "loads accounts from lookup tables that can't be checked",
+}
+
+// policyCheckedInstructions are the instruction types whose every outflow the inspector accounts
+// for; any other instruction is a violation.
+var policyCheckedInstructions = map[string]bool{
+	signer.InstrSOLTransfer:   true,
+	signer.InstrSPLTransfer:   true,
+	signer.InstrATACreate:     true,
+	signer.InstrMemo:          true,
+	signer.InstrComputeBudget: true,
+	signer.InstrCreateAccount: true,
+	signer.InstrTokenRevoke:   true,
+	signer.InstrTokenBurn:     true,
+	signer.InstrAssign:        true, // Reassigning the wallet itself is flagged as an authority change
+}
+
+// unverifiableInstructions explain instructions that aren't checked and have no warning of their own.
+var unverifiableInstructions = map[string]string{
+	signer.InstrJupiterSwap: "swaps through a Jupiter route whose amounts can't be checked",
+}
+
+var errDailyLimitExceeded = errors.New("daily spending limit exceeded")
+
+// UpdateSpendingPolicyRequest replaces a wallet's policy. Omitted limits are unlimited.
+type UpdateSpendingPolicyRequest struct {
+	DailyLimitUSD    *decimal.Decimal `json:"dailyLimitUsd"`
+	PerTxLimitUSD    *decimal.Decimal `json:"perTxLimitUsd"`
+	AllowlistEnabled bool             `json:"allowlistEnabled"`
+	ChangeDelayHours *int             `json:"changeDelayHours"` // Defaults to 24
+}
+
+// AddAllowlistEntryRequest defines the body for allowlisting a recipient
+type AddAllowlistEntryRequest struct {
+	Address string `json:"address" validate:"required"`
+	Label   string `json:"label,omitempty"`
+}
+
+// SpendingPolicyResponse is a wallet's policy, allowlist and recent outflow
+type SpendingPolicyResponse struct {
+	Policy          *models.WalletSpendingPolicy  `json:"policy"` // null when the wallet has no policy
+	Allowlist       []models.WalletAllowlistEntry `json:"allowlist"`
+	SpentLast24hUSD string                        `json:"spentLast24hUsd"`
+}
+
+// policyActive reports whether s restricts signing at all.
+func policyActive(s models.SpendingPolicySettings) bool {
+	return s.DailyLimitUSD != nil || s.PerTxLimitUSD != nil || s.AllowlistEnabled
+}
+
+// tighterLimit returns the stricter of two limits, where nil is unlimited.
+func tighterLimit(a, b *decimal.Decimal) *decimal.Decimal {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.LessThan(*b):
+		return a
+	}
+	return b
+}
+
+func sameLimit(a, b *decimal.Decimal) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// tighterSettings keeps the stricter value of each field of cur and next.
+func tighterSettings(cur, next models.SpendingPolicySettings) models.SpendingPolicySettings {
+	return models.SpendingPolicySettings{
+		DailyLimitUSD:    tighterLimit(cur.DailyLimitUSD, next.DailyLimitUSD),
+		PerTxLimitUSD:    tighterLimit(cur.PerTxLimitUSD, next.PerTxLimitUSD),
+		AllowlistEnabled: cur.AllowlistEnabled || next.AllowlistEnabled,
+		ChangeDelayHours: max(cur.ChangeDelayHours, next.ChangeDelayHours),
+	}
+}
+
+func sameSettings(a, b models.SpendingPolicySettings) bool {
+	return sameLimit(a.DailyLimitUSD, b.DailyLimitUSD) && sameLimit(a.PerTxLimitUSD, b.PerTxLimitUSD) &&
+		a.AllowlistEnabled == b.AllowlistEnabled && a.ChangeDelayHours == b.ChangeDelayHours
+}
+
+// loadSpendingPolicy returns the wallet's policy, or nil when it has none. A pending change whose
+// delay has passed is applied first.
+func loadSpendingPolicy(db *gorm.DB, walletID uint) (*models.WalletSpendingPolicy, error) {
+	var policy models.WalletSpendingPolicy
+	if err := db.Where("wallet_id = ?", walletID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if policy.Pending != nil && policy.PendingEffectiveAt != nil && !time.Now().Before(*policy.PendingEffectiveAt) {
+		policy.SpendingPolicySettings = *policy.Pending
+		policy.Pending, policy.PendingEffectiveAt = nil, nil
+		if err := db.Save(&policy).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &policy, nil
+}
+
+// spentSince sums the outflow recorded for walletID since from.
+func spentSince(db *gorm.DB, walletID uint, from time.Time) (decimal.Decimal, error) {
+	var total decimal.NullDecimal
+	err := db.Model(&models.WalletSpend{}).Where("wallet_id = ? AND created_at > ?", walletID, from).
+		Select("SUM(amount_usd)").Scan(&total).Error
+	if err != nil || !total.Valid {
+		return decimal.Zero, err
+	}
+	return total.Decimal, nil
+}
+
+// policyCheck is the outcome of checking a transaction against a wallet's spending policy.
+type policyCheck struct {
+	Policy     *models.WalletSpendingPolicy // nil when the wallet has no active policy
+	OutflowUSD decimal.Decimal
+	Violations []signer.TxWarning
+}
+
+func (p *policyCheck) violate(code, message string) {
+	p.Violations = append(p.Violations, signer.TxWarning{Code: code, Message: message})
+}
+
+// policyInputs is what checking a transaction against a policy needs from outside the transaction.
+type policyInputs struct {
+	Prices    map[string]decimal.Decimal // USD price per mint of what leaves the wallet
+	Spent     decimal.Decimal            // Outflow already recorded in the spending window
+	Allowlist []string                   // Allowlisted addresses in effect
+}
+
+// checkSpendingPolicy checks an inspected transaction against the wallet's policy (as loaded by
+// loadSpendingPolicy), loading the prices, recent outflow and allowlist it needs for
+// evaluateSpendingPolicy.
+func checkSpendingPolicy(db *gorm.DB, cfg *config.Config, wallet *models.Wallet, policy *models.WalletSpendingPolicy, summary *signer.TxSummary) (*policyCheck, error) {
+	if policy == nil || !policyActive(policy.SpendingPolicySettings) {
+		return &policyCheck{OutflowUSD: decimal.Zero}, nil
+	}
+	in := policyInputs{Spent: decimal.Zero}
+	if mints := outflowMints(summary); len(mints) > 0 && (policy.DailyLimitUSD != nil || policy.PerTxLimitUSD != nil) {
+		prices, err := cfg.Prices.USDPrices(context.Background(), mints)
+		// With every source down, cached prices still count; a mint without one can't be told apart
+		// from an unpriced token, so that fails the check
+		if err != nil && len(prices) < len(mints) {
+			return nil, fmt.Errorf("price outflow: %w", err)
+		}
+		in.Prices = prices
+	}
+	if policy.DailyLimitUSD != nil {
+		spent, err := spentSince(db, wallet.ID, time.Now().Add(-spendingWindow))
+		if err != nil {
+			return nil, err
+		}
+		in.Spent = spent
+	}
+	if policy.AllowlistEnabled && len(summary.Recipients) > 0 {
+		if err := db.Model(&models.WalletAllowlistEntry{}).Where("wallet_id = ? AND active_at <= ?", wallet.ID, time.Now()).
+			Pluck("address", &in.Allowlist).Error; err != nil {
+			return nil, err
+		}
+	}
+	return evaluateSpendingPolicy(policy, summary, in), nil
+}
+
+// outflowMints lists the mints summary sends out of the wallet, with SOL as wrapped SOL.
+func outflowMints(summary *signer.TxSummary) []string {
+	var mints []string
+	for _, change := range summary.BalanceChanges {
+		amount, err := decimal.NewFromString(change.Amount)
+		if err != nil || !amount.IsNegative() || change.Decimals == nil {
+			continue
+		}
+		mint := change.Mint
+		if mint == nativeSOLMint {
+			mint = wrappedSOLMint
+		}
+		if !slices.Contains(mints, mint) {
+			mints = append(mints, mint)
+		}
+	}
+	return mints
+}
+
+// evaluateSpendingPolicy checks summary against an active policy: the USD value of what leaves the
+// wallet against the per-transaction and daily limits, and every recipient against the allowlist.
+// Anything the inspector can't account for is a violation, so the policy fails closed.
+func evaluateSpendingPolicy(policy *models.WalletSpendingPolicy, summary *signer.TxSummary, in policyInputs) *policyCheck {
+	check := &policyCheck{Policy: policy, OutflowUSD: decimal.Zero}
+
+	warned := map[int]bool{}
+	for _, w := range summary.Warnings {
+		if reason, ok := unverifiableWarnings[w.Code]; ok {
+			check.violate(PolicyUnverifiable, "Transaction "+reason+"; wallets with a spending policy can't sign it")
+			if w.Instruction != nil {
+				warned[*w.Instruction] = true
+			}
+		}
+	}
+	for _, ix := range summary.Instructions {
+		if policyCheckedInstructions[ix.Type] || warned[ix.Index] {
+			continue
+		}
+		reason, ok := unverifiableInstructions[ix.Type]
+		if !ok {
+			reason = fmt.Sprintf("includes an instruction (%d) whose transfers can't be checked", ix.Index)
+		}
+		check.violate(PolicyUnverifiable, "Transaction "+reason+"; wallets with a spending policy can't sign it")
+	}
+
+	// Outflow in USD, priced per mint; only needed when there is a limit to check it against
+	limited := policy.DailyLimitUSD != nil || policy.PerTxLimitUSD != nil
+	outflows := map[string]decimal.Decimal{}
+	for _, change := range summary.BalanceChanges {
+		amount, err := decimal.NewFromString(change.Amount)
+		if err != nil || !amount.IsNegative() || !limited {
+			continue
+		}
+		if change.Decimals == nil {
+			check.violate(PolicyUnpricedOutflow, "Transaction sends a token whose amount can't be determined")
+			continue
+		}
+		mint := change.Mint
+		if mint == nativeSOLMint {
+			mint = wrappedSOLMint
+		}
+		outflows[mint] = outflows[mint].Add(amount.Neg())
+	}
+	for _, mint := range outflowMints(summary) {
+		amount, ok := outflows[mint]
+		if !ok {
+			continue
+		}
+		price, ok := in.Prices[mint]
+		if !ok {
+			if mint == wrappedSOLMint {
+				mint = nativeSOLMint
+			}
+			check.violate(PolicyUnpricedOutflow, fmt.Sprintf("Transaction sends %s, which has no USD price to check against the limits", tokenLabel(mint)))
+			continue
+		}
+		check.OutflowUSD = check.OutflowUSD.Add(amount.Mul(price))
+	}
+	check.OutflowUSD = check.OutflowUSD.Round(2)
+
+	if limit := policy.PerTxLimitUSD; limit != nil && check.OutflowUSD.GreaterThan(*limit) {
+		check.violate(PolicyPerTxLimit, fmt.Sprintf("Transaction sends $%s, over the $%s per-transaction limit", check.OutflowUSD.StringFixed(2), limit.StringFixed(2)))
+	}
+	if limit := policy.DailyLimitUSD; limit != nil && in.Spent.Add(check.OutflowUSD).GreaterThan(*limit) {
+		check.violate(PolicyDailyLimit, fmt.Sprintf("Transaction sends $%s; $%s of the $%s daily limit is left", check.OutflowUSD.StringFixed(2), decimal.Max(limit.Sub(in.Spent), decimal.Zero).StringFixed(2), limit.StringFixed(2)))
+	}
+
+	if policy.AllowlistEnabled && len(summary.Recipients) > 0 {
+		// Token transfers the owner of couldn't be looked up name the recipient's token account;
+		// accept the allowlisted owners' associated accounts
+		allowed := map[string]bool{}
+		for _, addr := range in.Allowlist {
+			allowed[addr] = true
+			owner, err := solana.PublicKeyFromBase58(addr)
+			if err != nil {
+				continue
+			}
+			for _, change := range summary.BalanceChanges {
+				mint, err := solana.PublicKeyFromBase58(change.Mint)
+				if err != nil {
+					continue
+				}
+				for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+					allowed[signer.AssociatedTokenAddress(owner, program, mint).String()] = true
+				}
+			}
+		}
+		for _, r := range summary.Recipients {
+			if !allowed[r] {
+				check.violate(PolicyRecipientNotAllowed, fmt.Sprintf("%s is not on this wallet's allowlist", r))
+			}
+		}
+	}
+	return check
+}
+
+// checkSwapPolicy checks a swap quote against the wallet's policy. Swap routes can't be inspected,
+// so the quote stands in for the transaction: its input leaves the wallet and its output comes back
+// to it, so there is no recipient.
+func checkSwapPolicy(ctx context.Context, db *gorm.DB, cfg *config.Config, wallet *models.Wallet, policy *models.WalletSpendingPolicy, q *jupiterQuote) (*policyCheck, error) {
+	raw, err := decimal.NewFromString(q.InAmount)
+	if err != nil || !raw.IsPositive() {
+		return nil, fmt.Errorf("invalid quote input amount %q", q.InAmount)
+	}
+	decimals := uint8(9)
+	if q.InputMint != wrappedSOLMint {
+		if _, decimals, err = solanarpc.MintInfo(ctx, q.InputMint); err != nil {
+			return nil, fmt.Errorf("look up input mint: %w", err)
+		}
+	}
+	summary := &signer.TxSummary{BalanceChanges: []signer.BalanceChange{{
+		Mint: q.InputMint, RawAmount: raw.Neg().String(), Amount: raw.Shift(-int32(decimals)).Neg().String(), Decimals: &decimals,
+	}}}
+	return checkSpendingPolicy(db, cfg, wallet, policy, summary)
+}
+
+// reserveWalletSpend records check's outflow, re-checking the daily limit with the policy row
+// locked so concurrent signings can't overspend it together. The caller releases the reservation
+// if signing fails.
+func reserveWalletSpend(db *gorm.DB, wallet *models.Wallet, check *policyCheck) (*models.WalletSpend, error) {
+	if check.Policy == nil || !check.OutflowUSD.IsPositive() {
+		return nil, nil
+	}
+	spend := &models.WalletSpend{WalletID: wallet.ID, UserID: wallet.UserID, AmountUSD: check.OutflowUSD}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var policy models.WalletSpendingPolicy
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", check.Policy.ID).First(&policy).Error; err != nil {
+			return err
+		}
+		if limit := policy.DailyLimitUSD; limit != nil {
+			spent, err := spentSince(tx, wallet.ID, time.Now().Add(-spendingWindow))
+			if err != nil {
+				return err
+			}
+			if spent.Add(check.OutflowUSD).GreaterThan(*limit) {
+				return errDailyLimitExceeded
+			}
+		}
+		return tx.Create(spend).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spend, nil
+}
+
+// policyFromParam loads the wallet named by :id along with its policy (nil when it has none).
+func policyFromParam(c *fiber.Ctx, db *gorm.DB) (*models.Wallet, *models.WalletSpendingPolicy, uint, error) {
+	wallet, userID, err := walletFromParam(c, db)
+	if wallet == nil {
+		return nil, nil, userID, err
+	}
+	if wallet.WatchOnly {
+		return nil, nil, userID, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Watch-only wallets can't sign, so they have no spending policy"})
+	}
+	policy, err := loadSpendingPolicy(db, wallet.ID)
+	if err != nil {
+		log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+		return nil, nil, userID, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+	}
+	return wallet, policy, userID, nil
+}
+
+func spendingPolicyResponse(db *gorm.DB, wallet *models.Wallet, policy *models.WalletSpendingPolicy) (*SpendingPolicyResponse, error) {
+	resp := &SpendingPolicyResponse{Policy: policy, Allowlist: []models.WalletAllowlistEntry{}}
+	if err := db.Where("wallet_id = ?", wallet.ID).Order("id").Find(&resp.Allowlist).Error; err != nil {
+		return nil, err
+	}
+	spent, err := spentSince(db, wallet.ID, time.Now().Add(-spendingWindow))
+	if err != nil {
+		return nil, err
+	}
+	resp.SpentLast24hUSD = spent.StringFixed(2)
+	return resp, nil
+}
+
+// GetSpendingPolicyHandler returns a wallet's spending policy, allowlist and outflow over the last 24 hours.
+func GetSpendingPolicyHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, policy, userID, err := policyFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		resp, err := spendingPolicyResponse(db, wallet, policy)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		return c.JSON(resp)
+	}
+}
+
+// UpdateSpendingPolicyHandler replaces a wallet's spending policy. Stricter values apply at once;
+// looser ones (higher or removed limits, allowlist off, shorter delay) wait out the current change delay.
+func UpdateSpendingPolicyHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, policy, userID, err := policyFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+
+		var req UpdateSpendingPolicyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		next := models.SpendingPolicySettings{
+			DailyLimitUSD:    req.DailyLimitUSD,
+			PerTxLimitUSD:    req.PerTxLimitUSD,
+			AllowlistEnabled: req.AllowlistEnabled,
+			ChangeDelayHours: defaultPolicyChangeDelayHours,
+		}
+		for _, limit := range []*decimal.Decimal{next.DailyLimitUSD, next.PerTxLimitUSD} {
+			if limit != nil && !limit.IsPositive() {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Limits must be positive; omit a limit to remove it"})
+			}
+		}
+		if next.DailyLimitUSD != nil {
+			rounded := next.DailyLimitUSD.Round(2)
+			next.DailyLimitUSD = &rounded
+		}
+		if next.PerTxLimitUSD != nil {
+			rounded := next.PerTxLimitUSD.Round(2)
+			next.PerTxLimitUSD = &rounded
+		}
+		if req.ChangeDelayHours != nil {
+			if *req.ChangeDelayHours < 0 || *req.ChangeDelayHours > maxPolicyChangeDelayHours {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("changeDelayHours must be between 0 and %d", maxPolicyChangeDelayHours)})
+			}
+			next.ChangeDelayHours = *req.ChangeDelayHours
+		}
+
+		applied := next
+		if policy == nil {
+			// Nothing to loosen yet; the whole policy applies at once
+			policy = &models.WalletSpendingPolicy{WalletID: wallet.ID, UserID: userID}
+		} else {
+			applied = tighterSettings(policy.SpendingPolicySettings, next)
+		}
+		delay := time.Duration(policy.ChangeDelayHours) * time.Hour
+		policy.SpendingPolicySettings = applied
+		policy.Pending, policy.PendingEffectiveAt = nil, nil
+		if !sameSettings(applied, next) {
+			pending, effective := next, time.Now().Add(delay)
+			policy.Pending, policy.PendingEffectiveAt = &pending, &effective
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(policy).Error; err != nil {
+				return err
+			}
+			meta, _ := json.Marshal(map[string]any{"wallet_id": wallet.ID, "policy": applied, "pending": policy.Pending, "pending_effective_at": policy.PendingEffectiveAt})
+			ip := c.IP()
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "wallet_policy_updated", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save spending policy"})
+		}
+
+		resp, err := spendingPolicyResponse(db, wallet, policy)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		return c.JSON(resp)
+	}
+}
+
+// CancelPendingPolicyChangeHandler drops a scheduled loosening of a wallet's policy, including a
+// requested recovery phrase reveal.
+func CancelPendingPolicyChangeHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, policy, userID, err := policyFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		if policy == nil || (policy.Pending == nil && policy.PhraseRevealAvailableAt == nil) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No pending policy change"})
+		}
+		if err := db.Model(policy).Select("pending", "pending_effective_at", "phrase_reveal_available_at").
+			Updates(&models.WalletSpendingPolicy{}).Error; err != nil {
+			log.Printf("Error cancelling policy change for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save spending policy"})
+		}
+		policy.Pending, policy.PendingEffectiveAt, policy.PhraseRevealAvailableAt = nil, nil, nil
+		resp, err := spendingPolicyResponse(db, wallet, policy)
+		if err != nil {
+			log.Printf("Error loading spending policy for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		return c.JSON(resp)
+	}
+}
+
+// AddAllowlistEntryHandler allowlists a recipient for a wallet. It becomes usable after the
+// policy's change delay.
+func AddAllowlistEntryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, policy, userID, err := policyFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+
+		var req AddAllowlistEntryRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
+		}
+		address := utils.NormalizeWalletAddress(req.Address)
+		if err := utils.ValidateWalletAddress(address); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		label := ""
+		if req.Label != "" {
+			if label, err = normalizeContactLabel(req.Label); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		var count int64
+		if err := db.Model(&models.WalletAllowlistEntry{}).Where("wallet_id = ? AND address = ?", wallet.ID, address).Count(&count).Error; err != nil {
+			log.Printf("Error checking allowlist of wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve spending policy"})
+		}
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This address is already on the allowlist"})
+		}
+
+		delay := time.Duration(defaultPolicyChangeDelayHours) * time.Hour
+		if policy != nil {
+			delay = time.Duration(policy.ChangeDelayHours) * time.Hour
+		}
+		entry := models.WalletAllowlistEntry{WalletID: wallet.ID, UserID: userID, Address: address, Label: label, ActiveAt: time.Now().Add(delay)}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			meta, _ := json.Marshal(map[string]any{"wallet_id": wallet.ID, "address": address, "active_at": entry.ActiveAt})
+			ip := c.IP()
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "wallet_allowlist_added", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving allowlist entry for wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save allowlist entry"})
+		}
+		return c.Status(fiber.StatusCreated).JSON(entry)
+	}
+}
+
+// RemoveAllowlistEntryHandler removes a recipient from a wallet's allowlist, effective immediately.
+func RemoveAllowlistEntryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, _, userID, err := policyFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		entryID, err := c.ParamsInt("entryId")
+		if err != nil || entryID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid allowlist entry ID"})
+		}
+		res := db.Where("id = ? AND wallet_id = ?", entryID, wallet.ID).Delete(&models.WalletAllowlistEntry{})
+		if res.Error != nil {
+			log.Printf("Error removing allowlist entry %d of wallet %d (user %d): %v", entryID, wallet.ID, userID, res.Error)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove allowlist entry"})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Allowlist entry not found"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
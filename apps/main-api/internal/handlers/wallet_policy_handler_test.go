@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/shopspring/decimal"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+)
+
+func usd(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func violationCodes(check *policyCheck) []string {
+	codes := []string{}
+	for _, v := range check.Violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func solOutflow(amount string) *signer.TxSummary {
+	decimals := uint8(9)
+	return &signer.TxSummary{BalanceChanges: []signer.BalanceChange{{Mint: nativeSOLMint, Amount: "-" + amount, Decimals: &decimals}}}
+}
+
+func TestEvaluateSpendingPolicyLimits(t *testing.T) {
+	prices := map[string]decimal.Decimal{wrappedSOLMint: decimal.NewFromInt(100)}
+	tests := []struct {
+		name   string
+		policy models.SpendingPolicySettings
+		in     policyInputs
+		amount string
+		want   []string
+	}{
+		{"within limits", models.SpendingPolicySettings{PerTxLimitUSD: usd("250"), DailyLimitUSD: usd("500")}, policyInputs{Prices: prices, Spent: decimal.NewFromInt(100)}, "2", []string{}},
+		{"over the per-transaction limit", models.SpendingPolicySettings{PerTxLimitUSD: usd("150")}, policyInputs{Prices: prices}, "2", []string{PolicyPerTxLimit}},
+		{"over the daily limit with earlier spending", models.SpendingPolicySettings{DailyLimitUSD: usd("500")}, policyInputs{Prices: prices, Spent: decimal.NewFromInt(400)}, "2", []string{PolicyDailyLimit}},
+		{"no price", models.SpendingPolicySettings{PerTxLimitUSD: usd("150")}, policyInputs{}, "2", []string{PolicyUnpricedOutflow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := evaluateSpendingPolicy(&models.WalletSpendingPolicy{SpendingPolicySettings: tt.policy}, solOutflow(tt.amount), tt.in)
+			if got := violationCodes(check); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSpendingPolicyAllowlist(t *testing.T) {
+	policy := &models.WalletSpendingPolicy{SpendingPolicySettings: models.SpendingPolicySettings{AllowlistEnabled: true}}
+	allowed, other := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	mint := solana.MustPublicKeyFromBase58(usdcMint)
+	in := policyInputs{Allowlist: []string{allowed.String()}}
+
+	decimals := uint8(6)
+	summary := &signer.TxSummary{
+		BalanceChanges: []signer.BalanceChange{{Mint: usdcMint, Amount: "-1", Decimals: &decimals}},
+		// An allowlisted owner's token account counts as the owner
+		Recipients: []string{allowed.String(), signer.AssociatedTokenAddress(allowed, solana.TokenProgramID, mint).String(), other.String()},
+	}
+	check := evaluateSpendingPolicy(policy, summary, in)
+	if got := violationCodes(check); len(got) != 1 || got[0] != PolicyRecipientNotAllowed {
+		t.Errorf("violations = %v, want one %s", got, PolicyRecipientNotAllowed)
+	}
+
+	// Funding a new account sends SOL to it, so it has to be allowlisted too
+	owner, created := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	b64, err := signer.BuildTransaction([]solana.Instruction{
+		system.NewCreateAccountInstruction(1_000_000, 0, solana.SystemProgramID, owner, created).Build(),
+	}, solana.MustHashFromBase58("4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZAMdL4VZHirAn"), owner)
+	if err != nil {
+		t.Fatalf("BuildTransaction() error = %v", err)
+	}
+	inspected, err := signer.InspectTransaction(b64, owner)
+	if err != nil {
+		t.Fatalf("InspectTransaction() error = %v", err)
+	}
+	check = evaluateSpendingPolicy(policy, inspected, in)
+	if got := violationCodes(check); len(got) != 1 || got[0] != PolicyRecipientNotAllowed {
+		t.Errorf("create account violations = %v, want one %s", got, PolicyRecipientNotAllowed)
+	}
+}
+
+func TestEvaluateSpendingPolicyUnverifiable(t *testing.T) {
+	policy := &models.WalletSpendingPolicy{SpendingPolicySettings: models.SpendingPolicySettings{PerTxLimitUSD: usd("100")}}
+
+	for code := range unverifiableWarnings {
+		t.Run(code, func(t *testing.T) {
+			summary := &signer.TxSummary{Warnings: []signer.TxWarning{{Code: code}}}
+			if got := violationCodes(evaluateSpendingPolicy(policy, summary, policyInputs{})); len(got) != 1 || got[0] != PolicyUnverifiable {
+				t.Errorf("violations = %v, want one %s", got, PolicyUnverifiable)
+			}
+		})
+	}
+
+	index := 1
+	summary := &signer.TxSummary{
+		Instructions: []signer.InstructionSummary{
+			{Index: 0, Type: signer.InstrComputeBudget},
+			{Index: 1, Type: signer.InstrUnknown},
+			{Index: 2, Type: signer.InstrJupiterSwap},
+			{Index: 3, Type: signer.InstrTokenOther},
+			{Index: 4, Type: signer.InstrSOLTransfer},
+		},
+		// The unknown program's warning covers its instruction, so it is reported once
+		Warnings: []signer.TxWarning{{Code: signer.WarnUnknownProgram, Instruction: &index}},
+	}
+	got := violationCodes(evaluateSpendingPolicy(policy, summary, policyInputs{}))
+	if len(got) != 3 {
+		t.Errorf("violations = %v, want 3 %s (unknown program, Jupiter route, unrecognized token instruction)", got, PolicyUnverifiable)
+	}
+	for _, code := range got {
+		if code != PolicyUnverifiable {
+			t.Errorf("violation %s, want %s", code, PolicyUnverifiable)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/crypto"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/security"
+	"github.com/team556-mono/server/internal/signer"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// walletRecoveryRequiredResponse is returned when a wallet's secret was locked by a password reset.
+var walletRecoveryRequiredResponse = fiber.Map{
+	"error":            "This wallet was locked when your password was reset. Recover it with its recovery phrase or your previous password.",
+	"recoveryRequired": true,
+}
+
+// RecoverWalletRequest re-keys a locked wallet to the current account password.
+// Exactly one of Mnemonic, SecretKey or PreviousPassword proves ownership of the secret.
+type RecoverWalletRequest struct {
+	Password         string `json:"password" validate:"required"` // Current account password
+	Mnemonic         string `json:"mnemonic,omitempty"`
+	SecretKey        string `json:"secretKey,omitempty"`
+	PreviousPassword string `json:"previousPassword,omitempty"` // Password the wallet was encrypted with before the reset
+}
+
+// secretBearingWallets scopes q to the user's wallets that hold an encrypted secret.
+func secretBearingWallets(q *gorm.DB, userID uint) *gorm.DB {
+	return q.Where("user_id = ? AND watch_only = ? AND encrypted_mnemonic <> ''", userID, false)
+}
+
+// rekeyed is the result of re-keying one wallet secret. Derived accounts share their seed wallet's
+// ciphertext, so results are cached by ciphertext and every account in the group gets the same one.
+type rekeyed struct {
+	encrypted string
+	metadata  datatypes.JSON
+	ok        bool
+}
+
+// rekeyWalletSecrets locks the user's unlocked secret-bearing wallets and re-keys each distinct
+// secret with rekey. Wallets whose secret can't be re-keyed are flagged for recovery; their IDs
+// are returned. It must run in the same transaction as the password update.
+func rekeyWalletSecrets(tx *gorm.DB, userID uint, rekey func(w *models.Wallet) (string, datatypes.JSON, error)) ([]uint, error) {
+	var wallets []models.Wallet
+	if err := secretBearingWallets(tx, userID).Where("recovery_required_at IS NULL").
+		Clauses(clause.Locking{Strength: "UPDATE"}).Order("id").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+
+	done := map[string]rekeyed{}
+	var locked []uint
+	now := time.Now()
+	for _, w := range wallets {
+		r, seen := done[w.EncryptedMnemonic]
+		if !seen {
+			var err error
+			r.encrypted, r.metadata, err = rekey(&w)
+			r.ok = err == nil
+			if err != nil && !errors.Is(err, crypto.ErrNoServerKey) {
+				log.Printf("Wallet %d (user %d) could not be re-keyed and needs recovery: %v", w.ID, userID, err)
+			}
+			done[w.EncryptedMnemonic] = r
+		}
+		if !r.ok {
+			locked = append(locked, w.ID)
+			if err := tx.Model(&w).Update("recovery_required_at", &now).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := tx.Model(&w).Updates(map[string]any{"encrypted_mnemonic": r.encrypted, "encryption_metadata": r.metadata}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return locked, nil
+}
+
+// reencryptWalletSecrets re-wraps every unlocked wallet secret of userID from oldPassword to
+// newPassword, upgrading legacy secrets to the current envelope on the way. Wallets whose secret
+// doesn't open with oldPassword are flagged for recovery; their IDs are returned.
+func reencryptWalletSecrets(tx *gorm.DB, keys *crypto.KeyRing, userID uint, oldPassword, newPassword string) ([]uint, error) {
+	return rekeyWalletSecrets(tx, userID, func(w *models.Wallet) (string, datatypes.JSON, error) {
+		return crypto.RewrapMnemonic(w.EncryptedMnemonic, w.EncryptionMetadata, oldPassword, newPassword, keys)
+	})
+}
+
+// resetWalletSecrets flags every secret-bearing wallet of userID for recovery after a password
+// reset, returning their IDs. Secrets are not re-wrapped with the server key: a reset only proves
+// control of the mailbox, so the wallets stay locked until the recovery phrase, secret key or
+// previous password is re-entered. It must run in the same transaction as the password update.
+func resetWalletSecrets(tx *gorm.DB, userID uint) ([]uint, error) {
+	var ids []uint
+	if err := secretBearingWallets(tx.Model(&models.Wallet{}), userID).Where("recovery_required_at IS NULL").
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := tx.Model(&models.Wallet{}).Where("id IN ?", ids).Update("recovery_required_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// upgradeWalletEncryption moves a wallet secret written by an older scheme (legacy v1, old KDF
+// parameters, missing or rotated server wrap) to the current envelope, for every wallet sharing it.
+// It is called after password unlocks, the only time legacy secrets can be read; failures are logged.
+func upgradeWalletEncryption(db *gorm.DB, keys *crypto.KeyRing, wallet *models.Wallet, password string) {
+	if wallet.WatchOnly || wallet.RecoveryRequiredAt != nil || !crypto.NeedsUpgrade(wallet.EncryptionMetadata, keys) {
+		return
+	}
+	encrypted, metadata, err := crypto.RewrapMnemonic(wallet.EncryptedMnemonic, wallet.EncryptionMetadata, password, password, keys)
+	if err != nil {
+		log.Printf("Error upgrading encryption of wallet %d (user %d): %v", wallet.ID, wallet.UserID, err)
+		return
+	}
+	// Only rows still holding the old ciphertext and metadata, so a concurrent password change wins
+	res := db.Model(&models.Wallet{}).
+		Where("user_id = ? AND encrypted_mnemonic = ? AND encryption_metadata = ?::jsonb", wallet.UserID, wallet.EncryptedMnemonic, string(wallet.EncryptionMetadata)).
+		Updates(map[string]any{"encrypted_mnemonic": encrypted, "encryption_metadata": metadata})
+	if res.Error != nil {
+		log.Printf("Error saving upgraded encryption of wallet %d (user %d): %v", wallet.ID, wallet.UserID, res.Error)
+		return
+	}
+	log.Printf("Upgraded encryption of %d wallet(s) for user %d", res.RowsAffected, wallet.UserID)
+}
+
+// RecoverWalletHandler unlocks a wallet flagged after a password reset by re-encrypting its secret
+// with the current account password. The secret is proven by re-entering the recovery phrase or
+// secret key (which must derive the wallet's address) or by the password it was encrypted with.
+// Derived accounts sharing the same recovery phrase are recovered together.
+func RecoverWalletHandler(db *gorm.DB, cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		wallet, userID, err := walletFromParam(c, db)
+		if wallet == nil {
+			return err
+		}
+		if wallet.RecoveryRequiredAt == nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This wallet doesn't need recovery"})
+		}
+
+		var req RecoverWalletRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		if req.Password == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Password is required"})
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Error loading user %d for wallet recovery: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load account"})
+		}
+		if ok, _, _ := security.VerifyPassword(user.Password, req.Password); !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Password is incorrect"})
+		}
+
+		var secret []byte
+		method := "previous_password"
+		if req.PreviousPassword != "" {
+			secret, err = crypto.DecryptMnemonicBytes(wallet.EncryptedMnemonic, wallet.EncryptionMetadata, req.PreviousPassword)
+			req.PreviousPassword = ""
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Previous password is incorrect"})
+			}
+		} else {
+			kind, normalized, err := resolveImportSecret(req.Mnemonic, req.SecretKey)
+			req.Mnemonic, req.SecretKey = "", ""
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			walletKind := wallet.SecretType
+			if walletKind != signer.SecretPrivateKey {
+				walletKind = signer.SecretMnemonic
+			}
+			if kind != walletKind {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This wallet was created from a " + walletKind + "; provide that instead"})
+			}
+			kp, err := importKeypair(kind, normalized, walletDerivationPath(wallet))
+			if err != nil {
+				log.Printf("Error deriving key for wallet recovery %d (user %d): %v", wallet.ID, userID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to derive wallet address"})
+			}
+			matches := kp.PublicKey().String() == wallet.Address
+			kp.Zero()
+			if !matches {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "That " + walletKind + " doesn't belong to this wallet"})
+			}
+			secret = []byte(normalized)
+			method = kind
+		}
+
+		encrypted, metadata, err := crypto.EncryptMnemonic(string(secret), req.Password, cfg.WalletKeys)
+		signer.Zero(secret)
+		req.Password = ""
+		if err != nil {
+			log.Printf("Error encrypting recovered wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to secure wallet information"})
+		}
+
+		ip := c.IP()
+		var recovered int64
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Every locked wallet with the same ciphertext holds the same secret
+			res := tx.Model(&models.Wallet{}).
+				Where("user_id = ? AND encrypted_mnemonic = ? AND recovery_required_at IS NOT NULL", userID, wallet.EncryptedMnemonic).
+				Updates(map[string]any{"encrypted_mnemonic": encrypted, "encryption_metadata": metadata, "recovery_required_at": nil})
+			if res.Error != nil {
+				return res.Error
+			}
+			recovered = res.RowsAffected
+			meta, _ := json.Marshal(map[string]any{"wallet_id": wallet.ID, "address": wallet.Address, "method": method, "wallets_recovered": recovered})
+			return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "wallet_recovered", IP: &ip, Meta: datatypes.JSON(meta)}).Error
+		})
+		if err != nil {
+			log.Printf("Error saving recovered wallet %d (user %d): %v", wallet.ID, userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to recover wallet"})
+		}
+
+		log.Printf("User %d recovered %d wallet(s) via %s", userID, recovered, method)
+		return c.JSON(fiber.Map{"message": "Wallet recovered", "walletsRecovered": recovered})
+	}
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/models"
+)
+
+// userOwnedTables lists every model keyed by user_id whose rows are removed outright on purge.
+var userOwnedTables = []interface{}{
+	// Policy, schedule and order rows reference wallets, so they go first
+	&models.ScheduledTransferExecution{},
+	&models.ScheduledTransfer{},
+	&models.SwapOrder{},
+	&models.WalletSpendingPolicy{},
+	&models.WalletAllowlistEntry{},
+	&models.WalletSpend{},
+	&models.Wallet{},
+	&models.AddressBookContact{},
+	&models.Firearm{},
+	&models.Ammo{},
+	&models.Gear{},
+	&models.Document{},
+	&models.NFA{},
+	&models.DistributorConnection{},
+	&models.NotificationSettings{},
+	&models.PushDevice{},
+	&models.PriceAlert{},
+	&models.MfaRecoveryCode{},
+	&models.UserSession{},
+	&models.LoginActivity{},
+	&models.PasswordResetCode{},
+	&models.EmailChangeRequest{},
+	&models.DataExport{},
+	&models.SwapQuote{},
+	&models.Swap{},
+	&models.ReferralStats{},
+}
+
+// AnonymizedEmail is the placeholder address written to a purged user row, freeing the
+// original address in the unique index.
+func AnonymizedEmail(userID uint) string {
+	return fmt.Sprintf("deleted+%d@deleted.team556.invalid", userID)
+}
+
+// PurgeAccount irreversibly removes or anonymizes everything owned by userID.
+// Audit logs and referral/presale records are kept for accounting but stripped of personal data.
+// It returns the address the account had before purging.
+func PurgeAccount(db *gorm.DB, userID uint) (string, error) {
+	var user models.User
+	if err := db.Unscoped().First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	originalEmail := user.Email
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range userOwnedTables {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return fmt.Errorf("purge %T: %w", model, err)
+			}
+		}
+		if err := tx.Unscoped().Model(&models.SecurityAuditLog{}).Where("user_id = ?", userID).
+			Updates(map[string]any{"ip": gorm.Expr("NULL"), "meta": gorm.Expr("'{}'::jsonb")}).Error; err != nil {
+			return fmt.Errorf("anonymize audit logs: %w", err)
+		}
+		if err := tx.Unscoped().Model(&models.Referral{}).Where("referred_user_id = ?", userID).
+			Update("signup_ip", gorm.Expr("NULL")).Error; err != nil {
+			return fmt.Errorf("anonymize referrals: %w", err)
+		}
+
+		now := time.Now()
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"email":                         AnonymizedEmail(userID),
+			"first_name":                    "",
+			"last_name":                     "",
+			"password":                      "",
+			"email_verification_code":       gorm.Expr("NULL"),
+			"email_verification_expires_at": gorm.Expr("NULL"),
+			"mfa_enabled":                   false,
+			"mfa_secret_encrypted":          gorm.Expr("NULL"),
+			"primary_wallet_address":        "",
+			"secondary_wallet_address":      gorm.Expr("NULL"),
+			"referral_code":                 gorm.Expr("NULL"),
+			"purged_at":                     &now,
+			"deleted_at":                    &now,
+		}).Error; err != nil {
+			return fmt.Errorf("anonymize user: %w", err)
+		}
+		return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: "account_purged"}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return originalEmail, nil
+}
+
+// ScheduleLegacyDeletions gives accounts soft-deleted without a schedule, from before the grace
+// period existed, a grace period of grace from now, so they can still log in to cancel.
+func ScheduleLegacyDeletions(ctx context.Context, db *gorm.DB, grace time.Duration) error {
+	result := db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND purged_at IS NULL AND deletion_scheduled_for IS NULL").
+		Updates(map[string]any{
+			"deletion_requested_at":  gorm.Expr("deleted_at"),
+			"deletion_scheduled_for": time.Now().Add(grace),
+		})
+	if result.RowsAffected > 0 {
+		log.Printf("[jobs] scheduled %d legacy soft-deleted accounts for purge", result.RowsAffected)
+	}
+	return result.Error
+}
+
+// PurgeDueAccounts purges every account whose deletion grace period has elapsed.
+func PurgeDueAccounts(ctx context.Context, db *gorm.DB, emailClient *email.Client) error {
+	var due []models.User
+	if err := db.WithContext(ctx).Unscoped().
+		Where("deletion_scheduled_for <= ? AND purged_at IS NULL", time.Now()).
+		Limit(100).Find(&due).Error; err != nil {
+		return err
+	}
+	for _, u := range due {
+		originalEmail, err := PurgeAccount(db.WithContext(ctx), u.ID)
+		if err != nil {
+			log.Printf("[jobs] account purge failed for user %d: %v", u.ID, err)
+			continue
+		}
+		log.Printf("[jobs] purged account for user %d", u.ID)
+		if emailClient != nil && originalEmail != "" {
+			if err := emailClient.SendAccountDeletedEmail(originalEmail); err != nil {
+				log.Printf("[jobs] error sending account deleted email for user %d: %v", u.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// StartAccountPurger periodically purges accounts past their deletion grace period, first
+// scheduling legacy soft-deleted accounts grace ahead.
+func StartAccountPurger(ctx context.Context, db *gorm.DB, grace time.Duration, emailClient *email.Client) {
+	Every(ctx, "account-purge", time.Hour, func(ctx context.Context) error {
+		if err := ScheduleLegacyDeletions(ctx, db, grace); err != nil {
+			return err
+		}
+		return PurgeDueAccounts(ctx, db, emailClient)
+	})
+}
@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 * * * *", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"45 10 * * *", time.Date(2026, 1, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 1, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 1 * *", time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2026, 1, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches
+		{"0 0 20 * 5", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron: %v", err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+
+	hourly := map[string]bool{"0 * * * *": true, "15 9 * * 1-5": true, "*/30 * * * *": false, "0,30 * * * *": false}
+	for expr, want := range hourly {
+		s, err := ParseCron(expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", expr, err)
+		}
+		if got := s.RunsAtMostHourly(); got != want {
+			t.Errorf("RunsAtMostHourly(%q) = %v, want %v", expr, got, want)
+		}
+	}
+
+	s, _ := ParseCron("0 9 * * *")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := s.CountRuns(from, from.AddDate(0, 0, 7)); got != 7 {
+		t.Errorf("CountRuns = %d, want 7", got)
+	}
+}
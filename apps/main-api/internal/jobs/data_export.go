@@ -0,0 +1,303 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/security"
+)
+
+// DataExportDownloadPurpose identifies download-link tokens for a finished export.
+const DataExportDownloadPurpose = "data_export_download"
+
+const exportReadme = `Team556 personal data export
+
+profile.json                user account details
+firearms.json, ammo.json,
+gear.json, documents.json,
+nfa.json                    armory records
+wallets.json                wallet names and public addresses (no recovery phrases or keys)
+address_book.json           saved contacts
+login_activity.csv          sign-in history
+audit_log.json              security-sensitive account actions
+referrals.json              referrals you made and the referral that brought you in
+notification_settings.json  notification preferences
+`
+
+// DataExportRef binds a download link to a single export.
+func DataExportRef(export *models.DataExport) string {
+	return strconv.FormatUint(uint64(export.ID), 10)
+}
+
+// BuildDataExport assembles every record held about userID into a ZIP archive.
+func BuildDataExport(db *gorm.DB, userID uint) ([]byte, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("load user: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	writeJSON := func(name string, v any) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w, err := zw.Create("README.txt")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte(exportReadme)); err != nil {
+		return nil, err
+	}
+
+	profile := map[string]any{
+		"id":                       user.ID,
+		"created_at":               user.CreatedAt,
+		"first_name":               user.FirstName,
+		"last_name":                user.LastName,
+		"email":                    user.Email,
+		"user_code":                user.UserCode,
+		"email_verified":           user.EmailVerified,
+		"mfa_enabled":              user.MFAEnabled,
+		"password_changed_at":      user.PasswordChangedAt,
+		"primary_wallet_address":   user.PrimaryWalletAddress,
+		"secondary_wallet_address": user.SecondaryWalletAddress,
+		"referral_code":            user.ReferralCode,
+		"referred_at":              user.ReferredAt,
+		"deletion_scheduled_for":   user.DeletionScheduledFor,
+	}
+	if err := writeJSON("profile.json", profile); err != nil {
+		return nil, err
+	}
+
+	var firearms []models.Firearm
+	var ammo []models.Ammo
+	var gear []models.Gear
+	var documents []models.Document
+	var nfa []models.NFA
+	armory := []struct {
+		name string
+		dest any
+	}{
+		{"firearms.json", &firearms},
+		{"ammo.json", &ammo},
+		{"gear.json", &gear},
+		{"documents.json", &documents},
+		{"nfa.json", &nfa},
+	}
+	for _, a := range armory {
+		if err := db.Where("user_id = ?", userID).Order("id").Find(a.dest).Error; err != nil {
+			return nil, fmt.Errorf("load %s: %w", a.name, err)
+		}
+		if err := writeJSON(a.name, a.dest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Addresses only; encrypted mnemonics never leave the server.
+	var wallets []models.Wallet
+	if err := db.Select("id", "created_at", "name", "address").Where("user_id = ?", userID).Order("id").Find(&wallets).Error; err != nil {
+		return nil, fmt.Errorf("load wallets: %w", err)
+	}
+	walletRows := make([]map[string]any, 0, len(wallets))
+	for _, wlt := range wallets {
+		walletRows = append(walletRows, map[string]any{"name": wlt.Name, "address": wlt.Address, "created_at": wlt.CreatedAt})
+	}
+	if err := writeJSON("wallets.json", walletRows); err != nil {
+		return nil, err
+	}
+
+	var contacts []models.AddressBookContact
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&contacts).Error; err != nil {
+		return nil, fmt.Errorf("load address book: %w", err)
+	}
+	contactRows := make([]map[string]any, 0, len(contacts))
+	for _, ct := range contacts {
+		contactRows = append(contactRows, map[string]any{"label": ct.Label, "address": ct.Address, "note": ct.Note, "favorite": ct.Favorite, "created_at": ct.CreatedAt})
+	}
+	if err := writeJSON("address_book.json", contactRows); err != nil {
+		return nil, err
+	}
+
+	var logins []models.LoginActivity
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&logins).Error; err != nil {
+		return nil, fmt.Errorf("load login activity: %w", err)
+	}
+	w, err = zw.Create("login_activity.csv")
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "status", "ip", "user_agent", "location"})
+	for _, la := range logins {
+		loc := ""
+		if la.Location != nil {
+			loc = *la.Location
+		}
+		_ = cw.Write([]string{la.CreatedAt.UTC().Format(time.RFC3339), la.Status, la.IP, la.UserAgent, loc})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+
+	var audit []models.SecurityAuditLog
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&audit).Error; err != nil {
+		return nil, fmt.Errorf("load audit log: %w", err)
+	}
+	if err := writeJSON("audit_log.json", audit); err != nil {
+		return nil, err
+	}
+
+	var made []models.Referral
+	if err := db.Where("referrer_user_id = ?", userID).Order("id").Find(&made).Error; err != nil {
+		return nil, fmt.Errorf("load referrals: %w", err)
+	}
+	// Referred users are other people; keep only what relates to this account.
+	madeRows := make([]map[string]any, 0, len(made))
+	for _, r := range made {
+		madeRows = append(madeRows, map[string]any{
+			"created_at":         r.CreatedAt,
+			"referral_code_used": r.ReferralCodeUsed,
+			"email_verified_at":  r.EmailVerifiedAt,
+			"wallet_created_at":  r.WalletCreatedAt,
+		})
+	}
+	var received *map[string]any
+	var in models.Referral
+	if err := db.Where("referred_user_id = ?", userID).First(&in).Error; err == nil {
+		row := map[string]any{
+			"created_at":         in.CreatedAt,
+			"referral_code_used": in.ReferralCodeUsed,
+			"signup_ip":          in.SignupIP,
+			"conversion_source":  in.ConversionSource,
+		}
+		received = &row
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("load referral: %w", err)
+	}
+	if err := writeJSON("referrals.json", map[string]any{"referrals_made": madeRows, "referred_by": received}); err != nil {
+		return nil, err
+	}
+
+	var settings []models.NotificationSettings
+	if err := db.Where("user_id = ?", userID).Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("load notification settings: %w", err)
+	}
+	var settingsOut any
+	if len(settings) > 0 {
+		settingsOut = settings[0]
+	}
+	if err := writeJSON("notification_settings.json", settingsOut); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runDataExport builds one pending export and emails its download link.
+func runDataExport(ctx context.Context, db *gorm.DB, cfg *config.Config, emailClient *email.Client, export *models.DataExport) error {
+	claimed := db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("id = ? AND status = ?", export.ID, models.DataExportPending).
+		Update("status", models.DataExportRunning)
+	if claimed.Error != nil {
+		return claimed.Error
+	}
+	if claimed.RowsAffected == 0 {
+		return nil // picked up by another instance
+	}
+
+	archive, err := BuildDataExport(db.WithContext(ctx), export.UserID)
+	if err != nil {
+		db.Model(export).Updates(map[string]any{"status": models.DataExportFailed, "error": err.Error()})
+		return fmt.Errorf("export %d: %w", export.ID, err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(cfg.DataExportTTL)
+	if err := db.WithContext(ctx).Model(export).Updates(map[string]any{
+		"status":       models.DataExportReady,
+		"archive":      archive,
+		"size_bytes":   len(archive),
+		"completed_at": &now,
+		"expires_at":   &expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := db.WithContext(ctx).First(&user, export.UserID).Error; err != nil {
+		return err
+	}
+	token, err := security.IssueLinkToken(cfg.JWTSecret, DataExportDownloadPurpose, user.ID, DataExportRef(export), cfg.DataExportTTL)
+	if err != nil {
+		return err
+	}
+	downloadURL := fmt.Sprintf("%s/api/exports/download?token=%s", strings.TrimRight(cfg.PublicAPIURL, "/"), url.QueryEscape(token))
+	if emailClient != nil {
+		if err := emailClient.SendDataExportReadyEmail(user.Email, downloadURL, expiresAt); err != nil {
+			log.Printf("[jobs] error sending data export email for user %d: %v", user.ID, err)
+		}
+	}
+	log.Printf("[jobs] data export %d ready for user %d (%d bytes)", export.ID, user.ID, len(archive))
+	return nil
+}
+
+// ProcessDataExports builds pending exports and drops archives past their expiry.
+func ProcessDataExports(ctx context.Context, db *gorm.DB, cfg *config.Config, emailClient *email.Client) error {
+	if err := db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("status = ? AND expires_at <= ?", models.DataExportReady, time.Now()).
+		Updates(map[string]any{"status": models.DataExportExpired, "archive": gorm.Expr("NULL")}).Error; err != nil {
+		return fmt.Errorf("expire exports: %w", err)
+	}
+	// Requeue exports left running by an instance that died mid-build.
+	if err := db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("status = ? AND updated_at <= ?", models.DataExportRunning, time.Now().Add(-30*time.Minute)).
+		Update("status", models.DataExportPending).Error; err != nil {
+		return fmt.Errorf("requeue exports: %w", err)
+	}
+
+	var pending []models.DataExport
+	if err := db.WithContext(ctx).Select("id", "user_id", "status").
+		Where("status = ?", models.DataExportPending).
+		Order("created_at").Limit(10).Find(&pending).Error; err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := runDataExport(ctx, db, cfg, emailClient, &pending[i]); err != nil {
+			log.Printf("[jobs] data export failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// StartDataExporter polls for requested exports.
+func StartDataExporter(ctx context.Context, db *gorm.DB, cfg *config.Config, emailClient *email.Client) {
+	Every(ctx, "data-export", time.Minute, func(ctx context.Context) error {
+		return ProcessDataExports(ctx, db, cfg, emailClient)
+	})
+}
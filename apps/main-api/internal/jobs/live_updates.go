@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/events"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/pricing"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// PriceTickInterval is how often connected clients get price ticks; it matches the default
+	// price cache TTL, so each tick can carry a new price.
+	PriceTickInterval = 30 * time.Second
+	// BalanceWatchInterval is how often the wallets of connected users are checked for changes.
+	BalanceWatchInterval = 15 * time.Second
+	// balanceWatchMaxWallets bounds the wallets watched per user.
+	balanceWatchMaxWallets = 10
+)
+
+// PriceEvent is the data of a price event.
+type PriceEvent struct {
+	Mint      string    `json:"mint"`
+	USD       string    `json:"usd"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Stale     bool      `json:"stale,omitempty"`
+}
+
+// NewPriceEvent wraps an aggregated price.
+func NewPriceEvent(p pricing.Price) events.Event {
+	return events.Event{Type: events.TypePrice, Data: PriceEvent{Mint: p.Mint, USD: p.USD.String(), Source: p.Source, UpdatedAt: p.UpdatedAt, Stale: p.Stale}}
+}
+
+// BalanceToken is one mint's balance within a balance event, in whole tokens.
+type BalanceToken struct {
+	Mint   string `json:"mint"`
+	Amount string `json:"amount"`
+}
+
+// BalanceEvent is the data of a balance event: a wallet's full balances after a change.
+type BalanceEvent struct {
+	WalletID uint           `json:"walletId"`
+	Address  string         `json:"address"`
+	SOL      string         `json:"sol"`
+	Tokens   []BalanceToken `json:"tokens"`
+}
+
+// PriceTicker broadcasts the sampled tokens' prices when they change.
+type PriceTicker struct {
+	Prices *pricing.Service
+	Hub    *events.Hub
+
+	last map[string]decimal.Decimal
+}
+
+// Tick publishes prices that moved since the last tick. Nothing is fetched while no one is connected.
+func (t *PriceTicker) Tick(ctx context.Context) error {
+	if len(t.Hub.Users()) == 0 {
+		return nil
+	}
+	prices, err := t.Prices.Prices(ctx, SampledPriceMints)
+	if err != nil && len(prices) == 0 {
+		return err
+	}
+	if t.last == nil {
+		t.last = map[string]decimal.Decimal{}
+	}
+	for _, mint := range SampledPriceMints {
+		p, ok := prices[mint]
+		if !ok {
+			continue
+		}
+		if last, seen := t.last[mint]; seen && last.Equal(p.USD) {
+			continue
+		}
+		t.last[mint] = p.USD
+		t.Hub.Broadcast(NewPriceEvent(p))
+	}
+	return nil
+}
+
+// BalanceWatcher polls the wallets of connected users and publishes their balances when they
+// change. A user's first poll after connecting only records the balances; clients load them
+// over REST and use the stream for changes.
+type BalanceWatcher struct {
+	DB  *gorm.DB
+	Hub *events.Hub
+
+	mu   sync.Mutex
+	seen map[uint]map[uint]string // user → wallet → balance fingerprint
+}
+
+// balanceFingerprint identifies a set of balances regardless of order.
+func balanceFingerprint(lamports uint64, tokens []solanarpc.TokenBalance) string {
+	parts := make([]string, 0, len(tokens)+1)
+	parts = append(parts, "SOL:"+strconv.FormatUint(lamports, 10))
+	for _, t := range tokens {
+		parts = append(parts, t.Mint+":"+t.Raw.String())
+	}
+	sort.Strings(parts[1:])
+	return strings.Join(parts, ",")
+}
+
+// Poll checks every connected user's active wallets once.
+func (w *BalanceWatcher) Poll(ctx context.Context) error {
+	users := w.Hub.Users()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen == nil {
+		w.seen = map[uint]map[uint]string{}
+	}
+	// Forget users who disconnected, so their next connection starts from fresh balances
+	connected := map[uint]bool{}
+	for _, id := range users {
+		connected[id] = true
+	}
+	for id := range w.seen {
+		if !connected[id] {
+			delete(w.seen, id)
+		}
+	}
+
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var wallets []models.Wallet
+		if err := w.DB.WithContext(ctx).Where("user_id = ? AND archived_at IS NULL", userID).
+			Order("is_default DESC").Order("id").Limit(balanceWatchMaxWallets).Find(&wallets).Error; err != nil {
+			log.Printf("[jobs] error listing wallets of user %d: %v", userID, err)
+			continue
+		}
+		seen := w.seen[userID]
+		first := seen == nil
+		if first {
+			seen = map[uint]string{}
+			w.seen[userID] = seen
+		}
+		for _, wallet := range wallets {
+			lamports, tokens, err := solanarpc.Balances(ctx, wallet.Address)
+			if err != nil {
+				log.Printf("[jobs] error fetching balances of wallet %d: %v", wallet.ID, err)
+				continue
+			}
+			fp := balanceFingerprint(lamports, tokens)
+			prev, known := seen[wallet.ID]
+			seen[wallet.ID] = fp
+			if first || !known || prev == fp {
+				continue
+			}
+			e := BalanceEvent{WalletID: wallet.ID, Address: wallet.Address, SOL: decimal.NewFromBigInt(new(big.Int).SetUint64(lamports), -9).String(), Tokens: make([]BalanceToken, 0, len(tokens))}
+			for _, t := range tokens {
+				e.Tokens = append(e.Tokens, BalanceToken{Mint: t.Mint, Amount: decimal.NewFromBigInt(t.Raw, -int32(t.Decimals)).String()})
+			}
+			w.Hub.Publish(userID, events.Event{Type: events.TypeBalance, Data: e})
+		}
+	}
+	return nil
+}
+
+// StartLiveUpdates runs the watchers that feed the event stream: price ticks and balance changes.
+// Payment requests are watched by StartPaymentRequestWatcher.
+func StartLiveUpdates(ctx context.Context, db *gorm.DB, prices *pricing.Service, hub *events.Hub) {
+	ticker := &PriceTicker{Prices: prices, Hub: hub}
+	Every(ctx, "price-ticks", PriceTickInterval, ticker.Tick)
+	if len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: Solana RPC upstream not configured; balance changes will not be streamed.")
+		return
+	}
+	watcher := &BalanceWatcher{DB: db, Hub: hub}
+	Every(ctx, "balance-watch", BalanceWatchInterval, watcher.Poll)
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/events"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// PaymentRequestExpiry is how long a payment request is watched before it expires.
+	PaymentRequestExpiry = time.Hour
+	// MaxPendingPaymentRequests bounds the pending requests one merchant wallet can have watched.
+	MaxPendingPaymentRequests = 100
+	// paymentRequestBatch bounds the pending requests one run looks up.
+	paymentRequestBatch = 100
+)
+
+// PaymentRequestEvent is the data of a payment_request event.
+type PaymentRequestEvent struct {
+	Reference      string     `json:"reference"`
+	MerchantWallet string     `json:"merchantWallet"`
+	OrderID        *int       `json:"orderId,omitempty"`
+	Status         string     `json:"status"`
+	Amount         string     `json:"amount"`
+	ReceivedAmount string     `json:"receivedAmount,omitempty"`
+	Signature      string     `json:"signature,omitempty"`
+	PaidAt         *time.Time `json:"paidAt,omitempty"`
+}
+
+// publishPaymentRequest notifies every user holding the merchant wallet of a status change.
+func publishPaymentRequest(db *gorm.DB, hub *events.Hub, pr *models.PaymentRequest) {
+	var userIDs []uint
+	if err := db.Model(&models.Wallet{}).Where("address = ? AND watch_only = false", pr.MerchantWallet).Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("[jobs] error finding owners of merchant wallet %s: %v", pr.MerchantWallet, err)
+		return
+	}
+	e := events.Event{Type: events.TypePaymentRequest, Data: PaymentRequestEvent{
+		Reference: pr.Reference, MerchantWallet: pr.MerchantWallet, OrderID: pr.OrderID, Status: pr.Status,
+		Amount: pr.Amount, ReceivedAmount: pr.ReceivedAmount, Signature: pr.Signature, PaidAt: pr.PaidAt,
+	}}
+	for _, id := range userIDs {
+		hub.Publish(id, e)
+	}
+}
+
+// checkPaymentRequest looks for the transaction carrying the request's reference and records what
+// the merchant received in it. It reports whether the request changed; an unchanged request has its
+// updated_at moved on, so it goes to the back of the queue.
+func checkPaymentRequest(ctx context.Context, db *gorm.DB, pr *models.PaymentRequest) (bool, error) {
+	var sigs []rpcSignature
+	opts := map[string]any{"limit": 10, "commitment": "confirmed"}
+	if err := solanarpc.CallContext(ctx, "getSignaturesForAddress", []any{pr.ReferenceKey, opts}, &sigs); err != nil {
+		return false, fmt.Errorf("getSignaturesForAddress: %w", err)
+	}
+	// Oldest successful transaction first; later ones would be duplicate payments
+	for i := len(sigs) - 1; i >= 0; i-- {
+		if sigs[i].Err != nil {
+			continue
+		}
+		var tx *rpcParsedTransaction
+		params := []any{sigs[i].Signature, map[string]any{"encoding": "jsonParsed", "maxSupportedTransactionVersion": 0, "commitment": "confirmed"}}
+		if err := solanarpc.CallContext(ctx, "getTransaction", params, &tx); err != nil {
+			return false, fmt.Errorf("getTransaction %s: %w", sigs[i].Signature, err)
+		}
+		if tx == nil || tx.Meta == nil || tx.Meta.Err != nil {
+			continue
+		}
+
+		lamports, _ := swapAmountDelta(tx, pr.MerchantWallet, wrappedSOLMint)
+		received := decimal.NewFromBigInt(lamports, -9)
+		want, err := decimal.NewFromString(pr.Amount)
+		if err != nil {
+			return false, fmt.Errorf("payment request %d has an invalid amount %q", pr.ID, pr.Amount)
+		}
+		paidAt := time.Now()
+		if tx.BlockTime != nil {
+			paidAt = time.Unix(*tx.BlockTime, 0).UTC()
+		}
+		pr.Status = models.PaymentRequestPaid
+		if received.LessThan(want) {
+			pr.Status = models.PaymentRequestUnderpaid
+		}
+		pr.Signature, pr.ReceivedAmount, pr.PaidAt = sigs[i].Signature, received.String(), &paidAt
+		return true, db.Save(pr).Error
+	}
+	if time.Since(pr.CreatedAt) >= PaymentRequestExpiry {
+		pr.Status = models.PaymentRequestExpired
+		return true, db.Save(pr).Error
+	}
+	return false, db.Model(pr).UpdateColumn("updated_at", time.Now()).Error
+}
+
+// CheckPaymentRequests settles pending payment requests that have been paid or have expired, and
+// publishes the change to the merchant's users. Each run checks the requests checked longest ago,
+// so every pending request gets its turn however many there are.
+func CheckPaymentRequests(ctx context.Context, db *gorm.DB, hub *events.Hub) error {
+	var pending []models.PaymentRequest
+	if err := db.WithContext(ctx).Where("status = ?", models.PaymentRequestPending).
+		Order("updated_at").Limit(paymentRequestBatch).Find(&pending).Error; err != nil {
+		return err
+	}
+	for i := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		changed, err := checkPaymentRequest(ctx, db, &pending[i])
+		if err != nil {
+			log.Printf("[jobs] error checking payment request %d: %v", pending[i].ID, err)
+			continue
+		}
+		if changed {
+			publishPaymentRequest(db, hub, &pending[i])
+		}
+	}
+	return nil
+}
+
+// StartPaymentRequestWatcher periodically checks pending payment requests on chain.
+func StartPaymentRequestWatcher(ctx context.Context, db *gorm.DB, hub *events.Hub) {
+	if len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: Solana RPC upstream not configured; payment requests will stay pending.")
+		return
+	}
+	Every(ctx, "payment-requests", 10*time.Second, func(ctx context.Context) error {
+		return CheckPaymentRequests(ctx, db, hub)
+	})
+}
@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/pricing"
+	"github.com/team556-mono/server/internal/push"
+)
+
+// PriceAlertInterval is how often price alerts are evaluated; it matches the price sampler.
+const PriceAlertInterval = time.Minute
+
+// PriceSymbol names a sampled mint in notifications.
+func PriceSymbol(mint string) string {
+	switch mint {
+	case team556Mint:
+		return "TEAM556"
+	case wrappedSOLMint:
+		return "SOL"
+	}
+	return mint
+}
+
+// formatWindow renders a change alert's window, e.g. 90 as "1h30m" and 60 as "1h".
+func formatWindow(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+	return fmt.Sprintf("%dh%dm", minutes/60, minutes%60)
+}
+
+// AlertCondition reports whether a fires at price and describes the condition, e.g. "rose above
+// $0.05". base is the price at the start of a change alert's window; without one (zero) a change
+// alert doesn't fire.
+func AlertCondition(a *models.PriceAlert, price, base decimal.Decimal) (bool, string) {
+	switch a.Kind {
+	case models.AlertAbove, models.AlertBelow:
+		target, err := decimal.NewFromString(a.TargetUSD)
+		if err != nil {
+			return false, ""
+		}
+		if a.Kind == models.AlertAbove {
+			return price.GreaterThanOrEqual(target), "rose above $" + target.String()
+		}
+		return price.LessThanOrEqual(target), "fell below $" + target.String()
+	case models.AlertChange:
+		want, err := decimal.NewFromString(a.ChangePercent)
+		if err != nil || want.IsZero() || !base.IsPositive() {
+			return false, ""
+		}
+		moved := price.Sub(base).Div(base).Mul(decimal.NewFromInt(100))
+		window := formatWindow(a.WindowMinutes)
+		if want.IsPositive() {
+			return moved.GreaterThanOrEqual(want), fmt.Sprintf("rose %s%% in %s", want, window)
+		}
+		return moved.LessThanOrEqual(want), fmt.Sprintf("fell %s%% in %s", want.Neg(), window)
+	}
+	return false, ""
+}
+
+// windowStartPrice returns the first recorded price of mint since since, or zero when there is none.
+func windowStartPrice(ctx context.Context, db *gorm.DB, mint string, since time.Time) (decimal.Decimal, error) {
+	var sample models.PriceSample
+	err := db.WithContext(ctx).Where("mint = ? AND sampled_at >= ?", mint, since).Order("sampled_at").Take(&sample).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, nil
+	}
+	return sample.USD, err
+}
+
+// alertRecipients returns where userID's price alerts go: their notification email ("" when email
+// is off) and active push tokens when push is on. Users who picked notification types without
+// "alerts" get neither; users who never picked any get every type.
+func alertRecipients(db *gorm.DB, userID uint) (string, []string, error) {
+	var settings models.NotificationSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	found := err == nil
+	if found && len(settings.Types) > 0 {
+		var types []string
+		if err := json.Unmarshal(settings.Types, &types); err == nil && types != nil && !slices.Contains(types, "alerts") {
+			return "", nil, nil
+		}
+	}
+	to, err := notificationEmail(db, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	var tokens []string
+	if found && settings.PushEnabled {
+		if err := db.Model(&models.PushDevice{}).Where("user_id = ? AND active = ?", userID, true).Pluck("token", &tokens).Error; err != nil {
+			return "", nil, err
+		}
+	}
+	return to, tokens, nil
+}
+
+// notifyPriceAlert delivers a fired alert by email and push. Delivery is best effort: the alert
+// stays fired either way.
+func notifyPriceAlert(ctx context.Context, db *gorm.DB, emailClient *email.Client, pusher *push.Client, a *models.PriceAlert, condition string, price decimal.Decimal) {
+	to, tokens, err := alertRecipients(db, a.UserID)
+	if err != nil {
+		log.Printf("[jobs] error loading notification settings of user %d: %v", a.UserID, err)
+		return
+	}
+	symbol, shown := PriceSymbol(a.Mint), price.Round(8).String()
+	if to != "" && emailClient != nil {
+		if err := emailClient.SendPriceAlertEmail(to, symbol, condition, shown); err != nil {
+			log.Printf("[jobs] error emailing price alert %d: %v", a.ID, err)
+		}
+	}
+	if len(tokens) == 0 || pusher == nil {
+		return
+	}
+	messages := make([]push.Message, 0, len(tokens))
+	for _, token := range tokens {
+		messages = append(messages, push.Message{
+			To:    token,
+			Title: symbol + " " + condition,
+			Body:  fmt.Sprintf("Now at $%s. The alert is off until you turn it back on.", shown),
+			Data:  map[string]any{"type": "price_alert", "alertId": a.ID, "mint": a.Mint},
+		})
+	}
+	unregistered, err := pusher.Send(ctx, messages)
+	if err != nil {
+		log.Printf("[jobs] error pushing price alert %d: %v", a.ID, err)
+	}
+	if len(unregistered) > 0 {
+		if err := db.Model(&models.PushDevice{}).Where("user_id = ? AND token IN ?", a.UserID, unregistered).
+			Updates(map[string]any{"active": false}).Error; err != nil {
+			log.Printf("[jobs] error deactivating push devices of user %d: %v", a.UserID, err)
+		}
+	}
+}
+
+// CheckPriceAlerts fires every active alert whose condition holds at the current price. A fired
+// alert is disabled before it is delivered, so it notifies once even with several runners.
+func CheckPriceAlerts(ctx context.Context, db *gorm.DB, prices *pricing.Service, emailClient *email.Client, pusher *push.Client) error {
+	var alerts []models.PriceAlert
+	if err := db.WithContext(ctx).Where("active = ?", true).Order("id").Find(&alerts).Error; err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+	var mints []string
+	for _, a := range alerts {
+		if !slices.Contains(mints, a.Mint) {
+			mints = append(mints, a.Mint)
+		}
+	}
+	current, err := prices.Prices(ctx, mints)
+	if err != nil && len(current) == 0 {
+		return err
+	}
+
+	now := time.Now().UTC()
+	bases := map[string]decimal.Decimal{} // mint/window → price at the start of the window
+	for i := range alerts {
+		a := &alerts[i]
+		// Stale prices could be far from the market, so nothing fires during an outage
+		p, ok := current[a.Mint]
+		if !ok || p.Stale {
+			continue
+		}
+		var base decimal.Decimal
+		if a.Kind == models.AlertChange {
+			key := fmt.Sprintf("%s/%d", a.Mint, a.WindowMinutes)
+			var seen bool
+			if base, seen = bases[key]; !seen {
+				if base, err = windowStartPrice(ctx, db, a.Mint, now.Add(-time.Duration(a.WindowMinutes)*time.Minute)); err != nil {
+					log.Printf("[jobs] error loading price history for alert %d: %v", a.ID, err)
+					continue
+				}
+				bases[key] = base
+			}
+		}
+		fires, condition := AlertCondition(a, p.USD, base)
+		if !fires {
+			continue
+		}
+		res := db.WithContext(ctx).Model(&models.PriceAlert{}).Where("id = ? AND active = ?", a.ID, true).
+			Updates(map[string]any{"active": false, "triggered_at": now, "triggered_usd": p.USD.String()})
+		if res.Error != nil {
+			log.Printf("[jobs] error disabling price alert %d: %v", a.ID, res.Error)
+			continue
+		}
+		if res.RowsAffected != 1 {
+			continue
+		}
+		notifyPriceAlert(ctx, db, emailClient, pusher, a, condition, p.USD)
+	}
+	return nil
+}
+
+// StartPriceAlerts periodically evaluates users' price alerts.
+func StartPriceAlerts(ctx context.Context, db *gorm.DB, prices *pricing.Service, emailClient *email.Client, pusher *push.Client) {
+	if prices == nil {
+		log.Println("Warning: no price service configured; price alerts will not fire.")
+		return
+	}
+	Every(ctx, "price-alerts", PriceAlertInterval, func(ctx context.Context) error {
+		return CheckPriceAlerts(ctx, db, prices, emailClient, pusher)
+	})
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/team556-mono/server/internal/models"
+)
+
+func TestAlertCondition(t *testing.T) {
+	above := &models.PriceAlert{Kind: models.AlertAbove, TargetUSD: "0.05"}
+	below := &models.PriceAlert{Kind: models.AlertBelow, TargetUSD: "0.05"}
+	rise := &models.PriceAlert{Kind: models.AlertChange, ChangePercent: "10", WindowMinutes: 60}
+	drop := &models.PriceAlert{Kind: models.AlertChange, ChangePercent: "-12.5", WindowMinutes: 90}
+	tests := []struct {
+		alert       *models.PriceAlert
+		price, base string
+		want        bool
+		condition   string
+	}{
+		{above, "0.05", "0", true, "rose above $0.05"},
+		{above, "0.0499", "0", false, "rose above $0.05"},
+		{below, "0.049", "0", true, "fell below $0.05"},
+		{below, "0.051", "0", false, "fell below $0.05"},
+		{rise, "1.10", "1", true, "rose 10% in 1h"},
+		{rise, "1.09", "1", false, "rose 10% in 1h"},
+		{rise, "0.5", "1", false, "rose 10% in 1h"},
+		{drop, "0.875", "1", true, "fell 12.5% in 1h30m"},
+		{drop, "0.9", "1", false, "fell 12.5% in 1h30m"},
+		// No price history yet
+		{rise, "5", "0", false, ""},
+	}
+	for _, tt := range tests {
+		fires, condition := AlertCondition(tt.alert, decimal.RequireFromString(tt.price), decimal.RequireFromString(tt.base))
+		if fires != tt.want || condition != tt.condition {
+			t.Errorf("%s alert at %s from %s = %v %q, want %v %q", tt.alert.Kind, tt.price, tt.base, fires, condition, tt.want, tt.condition)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/pricing"
+)
+
+const (
+	team556Mint = "AMNfeXpjD6kXyyTDB4LMKzNWypqNHwtgJUACHUmuKLD5"
+	// PriceSampleInterval is how often prices are recorded, and so the finest candle resolution.
+	PriceSampleInterval = time.Minute
+)
+
+// SampledPriceMints are the tokens with recorded price history.
+var SampledPriceMints = []string{team556Mint, wrappedSOLMint}
+
+// SamplePrices records the current price of each sampled mint. Stale prices are skipped, so an
+// outage shows as a gap rather than a flat line.
+func SamplePrices(ctx context.Context, db *gorm.DB, prices *pricing.Service) error {
+	current, err := prices.Prices(ctx, SampledPriceMints)
+	if err != nil && len(current) == 0 {
+		return err
+	}
+	now := time.Now().UTC()
+	var samples []models.PriceSample
+	for _, mint := range SampledPriceMints {
+		p, ok := current[mint]
+		if !ok || p.Stale {
+			continue
+		}
+		samples = append(samples, models.PriceSample{Mint: mint, SampledAt: now, USD: p.USD, Source: p.Source})
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).Create(&samples).Error
+}
+
+// priceSampleTiers thin out samples older than after to the first, last, highest and lowest of
+// each bucket, which keeps candles of the bucket's size and coarser exact. Minute samples are kept
+// for a week, as long as the longest price alert window.
+var priceSampleTiers = []struct {
+	age      string
+	after    time.Duration
+	truncate string // date_trunc field of the bucket
+	size     time.Duration
+}{
+	{"a week", 7 * 24 * time.Hour, "hour", time.Hour},
+	{"a year", 365 * 24 * time.Hour, "day", 24 * time.Hour},
+}
+
+// priceSampleLookback bounds how far past a tier's cutoff one run looks, so each run only scans
+// recent buckets. Samples that reach a tier while the job is down for longer are left as they are.
+const priceSampleLookback = 30 * 24 * time.Hour
+
+// DownsamplePriceSamples thins out old samples according to priceSampleTiers, only touching whole
+// buckets. Running it again over the same buckets deletes nothing more.
+func DownsamplePriceSamples(ctx context.Context, db *gorm.DB, now time.Time) error {
+	for _, tier := range priceSampleTiers {
+		cutoff := now.UTC().Add(-tier.after).Truncate(tier.size)
+		res := db.WithContext(ctx).Exec(`DELETE FROM price_samples p USING (
+				SELECT id,
+					ROW_NUMBER() OVER (PARTITION BY mint, bucket ORDER BY sampled_at, id) AS first,
+					ROW_NUMBER() OVER (PARTITION BY mint, bucket ORDER BY sampled_at DESC, id DESC) AS last,
+					ROW_NUMBER() OVER (PARTITION BY mint, bucket ORDER BY usd DESC, sampled_at) AS high,
+					ROW_NUMBER() OVER (PARTITION BY mint, bucket ORDER BY usd, sampled_at) AS low
+				FROM (SELECT id, mint, sampled_at, usd, date_trunc(?, sampled_at AT TIME ZONE 'UTC') AS bucket
+					FROM price_samples WHERE sampled_at >= ? AND sampled_at < ?) s
+			) r
+			WHERE p.id = r.id AND r.first > 1 AND r.last > 1 AND r.high > 1 AND r.low > 1`,
+			tier.truncate, cutoff.Add(-priceSampleLookback), cutoff)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("[jobs] price samples: thinned out %d older than %s to %s candles", res.RowsAffected, tier.age, tier.truncate)
+		}
+	}
+	return nil
+}
+
+// StartPriceSampler periodically records token prices for charts and historical valuations, and
+// thins out old samples every hour.
+func StartPriceSampler(ctx context.Context, db *gorm.DB, prices *pricing.Service) {
+	if prices == nil {
+		log.Println("Warning: no price service configured; price history will not be recorded.")
+		return
+	}
+	Every(ctx, "price-sample", PriceSampleInterval, func(ctx context.Context) error {
+		return SamplePrices(ctx, db, prices)
+	})
+	Every(ctx, "price-sample-downsample", time.Hour, func(ctx context.Context) error {
+		return DownsamplePriceSamples(ctx, db, time.Now())
+	})
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn once immediately and then on each tick until ctx is cancelled.
+// Errors and panics are logged so one bad run never stops the loop.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	run := func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[jobs] %s panicked: %v", name, r)
+			}
+		}()
+		if err := fn(ctx); err != nil {
+			log.Printf("[jobs] %s failed: %v", name, err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		run()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
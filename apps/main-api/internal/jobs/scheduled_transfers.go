@@ -0,0 +1,341 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/pricing"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// scheduledTransferBatch bounds the due schedules one run picks up.
+	scheduledTransferBatch = 100
+	// scheduledTransferWorkers is how many transfers are landed concurrently.
+	scheduledTransferWorkers = 4
+	// scheduledTransferTimeout bounds one execution, including confirmation.
+	scheduledTransferTimeout = 2 * time.Minute
+	// MaxScheduleFailures is how many runs in a row may fail before a schedule is paused.
+	MaxScheduleFailures = 3
+)
+
+// errOutcomeUnknown marks a run whose transaction may still land; it is never retried.
+var errOutcomeUnknown = errors.New("transfer outcome unknown")
+
+// ScheduleNextRun returns the first run of schedule after after, or nil when there is none before endAt.
+func ScheduleNextRun(schedule *CronSchedule, after, endAt time.Time) *time.Time {
+	next := schedule.Next(after)
+	if next.IsZero() || next.After(endAt) {
+		return nil
+	}
+	return &next
+}
+
+// notificationEmail returns where to email userID, or "" when they turned email notifications off.
+func notificationEmail(db *gorm.DB, userID uint) (string, error) {
+	var settings models.NotificationSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err == nil {
+		if !settings.EmailEnabled {
+			return "", nil
+		}
+		if settings.ContactEmail != nil && *settings.ContactEmail != "" {
+			return *settings.ContactEmail, nil
+		}
+	}
+	var user models.User
+	if err := db.Select("email").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	return user.Email, nil
+}
+
+// claimScheduledTransfer advances a due schedule to its next run so no other runner executes the
+// same run. Missed runs are skipped: a schedule that was due several times while the runner was
+// down sends once. It reports false if another runner claimed it first.
+func claimScheduledTransfer(db *gorm.DB, st *models.ScheduledTransfer, now time.Time) (bool, error) {
+	updates := map[string]any{"next_run_at": nil}
+	if schedule, err := ParseCron(st.Cadence); err == nil {
+		if next := ScheduleNextRun(schedule, now, st.EndAt); next != nil {
+			updates["next_run_at"] = *next
+		}
+	}
+	if updates["next_run_at"] == nil {
+		updates["status"] = models.ScheduleCompleted
+	}
+	res := db.Model(&models.ScheduledTransfer{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", st.ID, models.ScheduleActive, *st.NextRunAt).
+		Updates(updates)
+	return res.RowsAffected == 1, res.Error
+}
+
+// reserveDelegatedSpend records the USD value of amount of mint, about to leave walletID in a
+// transaction the delegate signs (what names it in errors), against the wallet's spending policy,
+// checking the limits with the policy row locked as signing does. It returns nil when the policy has
+// no limits.
+func reserveDelegatedSpend(ctx context.Context, db *gorm.DB, prices *pricing.Service, walletID, userID, policyID uint, mint, amount, what string) (*models.WalletSpend, error) {
+	var spend *models.WalletSpend
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var policy models.WalletSpendingPolicy
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", policyID).First(&policy).Error; err != nil {
+			return err
+		}
+		if policy.DailyLimitUSD == nil && policy.PerTxLimitUSD == nil {
+			return nil
+		}
+		value, err := decimal.NewFromString(amount)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", amount)
+		}
+		var usd map[string]decimal.Decimal
+		if prices != nil {
+			usd, _ = prices.USDPrices(ctx, []string{mint})
+		}
+		price, ok := usd[mint]
+		if !ok {
+			return errors.New("the token has no USD price to check against the spending policy")
+		}
+		outflow := value.Mul(price).Round(2)
+		if limit := policy.PerTxLimitUSD; limit != nil && outflow.GreaterThan(*limit) {
+			return fmt.Errorf("the %s is worth $%s, over the $%s per-transaction limit", what, outflow.StringFixed(2), limit.StringFixed(2))
+		}
+		if limit := policy.DailyLimitUSD; limit != nil {
+			var spent decimal.NullDecimal
+			if err := tx.Model(&models.WalletSpend{}).Where("wallet_id = ? AND created_at > ?", walletID, time.Now().Add(-24*time.Hour)).
+				Select("SUM(amount_usd)").Scan(&spent).Error; err != nil {
+				return err
+			}
+			if spent.Decimal.Add(outflow).GreaterThan(*limit) {
+				return fmt.Errorf("the %s is worth $%s, over what is left of the $%s daily limit", what, outflow.StringFixed(2), limit.StringFixed(2))
+			}
+		}
+		spend = &models.WalletSpend{WalletID: walletID, UserID: userID, AmountUSD: outflow}
+		return tx.Create(spend).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spend, nil
+}
+
+// sendScheduledTransfer signs one run with the delegate and lands it. Runs of wallets with a
+// spending policy count towards its daily limit like any other signature.
+func sendScheduledTransfer(ctx context.Context, db *gorm.DB, delegate *signer.Keypair, prices *pricing.Service, st *models.ScheduledTransfer, exec *models.ScheduledTransferExecution) error {
+	var wallet models.Wallet
+	if err := db.Where("id = ? AND user_id = ?", st.WalletID, st.UserID).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("the wallet no longer exists")
+		}
+		return err
+	}
+	// A policy's stored settings are never looser than its effective ones, so the allowlist is read as stored
+	var policy models.WalletSpendingPolicy
+	err := db.Where("wallet_id = ?", wallet.ID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && policy.AllowlistEnabled {
+		var count int64
+		if err := db.Model(&models.WalletAllowlistEntry{}).Where("wallet_id = ? AND address = ? AND active_at <= ?", wallet.ID, st.Recipient, time.Now()).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("the recipient is no longer on this wallet's allowlist")
+		}
+	}
+
+	owner, err := solana.PublicKeyFromBase58(wallet.Address)
+	if err != nil {
+		return fmt.Errorf("wallet address: %w", err)
+	}
+	recipient, err := solana.PublicKeyFromBase58(st.Recipient)
+	if err != nil {
+		return fmt.Errorf("recipient address: %w", err)
+	}
+	mint, err := solana.PublicKeyFromBase58(st.Mint)
+	if err != nil {
+		return fmt.Errorf("mint: %w", err)
+	}
+	program, err := solana.PublicKeyFromBase58(st.TokenProgram)
+	if err != nil {
+		return fmt.Errorf("token program: %w", err)
+	}
+
+	// The delegate pays fees but not rent, so the recipient's token account has to exist already
+	exists, err := solanarpc.AccountExists(ctx, signer.AssociatedTokenAddress(recipient, program, mint).String())
+	if err != nil {
+		return fmt.Errorf("check recipient token account: %w", err)
+	}
+	if !exists {
+		return errors.New("the recipient's token account has been closed")
+	}
+
+	blockhash, err := solanarpc.LatestBlockhash(ctx)
+	if err != nil {
+		return fmt.Errorf("get blockhash: %w", err)
+	}
+	unsigned, err := signer.BuildTransaction([]solana.Instruction{
+		signer.DelegatedTransferInstruction(program, mint, owner, recipient, delegate.PublicKey(), st.RawAmount, st.Decimals),
+	}, blockhash, delegate.PublicKey())
+	if err != nil {
+		return err
+	}
+	var spend *models.WalletSpend
+	if policy.ID != 0 {
+		if spend, err = reserveDelegatedSpend(ctx, db, prices, st.WalletID, st.UserID, policy.ID, st.Mint, st.Amount, "transfer"); err != nil {
+			return err
+		}
+	}
+	signed, err := signer.SignTransaction(delegate, unsigned)
+	if err != nil {
+		if spend != nil {
+			if delErr := db.Delete(spend).Error; delErr != nil {
+				log.Printf("[jobs] error releasing spend %d of scheduled transfer %d: %v", spend.ID, st.ID, delErr)
+			}
+		}
+		return err
+	}
+	result, err := solanarpc.SendAndConfirm(ctx, signed)
+	if err != nil {
+		if tx, decodeErr := solana.TransactionFromBase64(signed); decodeErr == nil && len(tx.Signatures) > 0 {
+			exec.Signature = tx.Signatures[0].String()
+		}
+		return fmt.Errorf("%w: %v", errOutcomeUnknown, err)
+	}
+	exec.Signature, exec.Attempts = result.Signature, result.Attempts
+	switch result.Status {
+	case solanarpc.StatusConfirmed:
+		return nil
+	case solanarpc.StatusExpired:
+		return errors.New("the transaction expired before it landed")
+	case solanarpc.StatusPending:
+		return fmt.Errorf("%w: the transaction was seen but not confirmed before its blockhash expired", errOutcomeUnknown)
+	}
+	return fmt.Errorf("the transaction failed: %s", result.Error)
+}
+
+// executeScheduledTransfer runs one claimed schedule, records the execution and, on failure,
+// notifies the user and pauses the schedule once it has failed MaxScheduleFailures times in a row.
+func executeScheduledTransfer(ctx context.Context, db *gorm.DB, delegate *signer.Keypair, prices *pricing.Service, emailClient *email.Client, st *models.ScheduledTransfer, scheduledFor time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, scheduledTransferTimeout)
+	defer cancel()
+
+	exec := models.ScheduledTransferExecution{ScheduledTransferID: st.ID, UserID: st.UserID, ScheduledFor: scheduledFor, Status: models.ExecutionConfirmed}
+	runErr := sendScheduledTransfer(ctx, db, delegate, prices, st, &exec)
+	if runErr != nil {
+		exec.Status, exec.Error = models.ExecutionFailed, runErr.Error()
+		if errors.Is(runErr, errOutcomeUnknown) {
+			exec.Status = models.ExecutionUnknown
+		}
+		if len(exec.Error) > 500 {
+			exec.Error = exec.Error[:500]
+		}
+	}
+	if err := db.Create(&exec).Error; err != nil {
+		log.Printf("[jobs] error recording run of scheduled transfer %d: %v", st.ID, err)
+	}
+	if runErr == nil {
+		log.Printf("[jobs] scheduled transfer %d sent %s", st.ID, exec.Signature)
+		if err := db.Model(&models.ScheduledTransfer{}).Where("id = ?", st.ID).Update("consecutive_failures", 0).Error; err != nil {
+			log.Printf("[jobs] error resetting failures of scheduled transfer %d: %v", st.ID, err)
+		}
+		return
+	}
+
+	log.Printf("[jobs] scheduled transfer %d failed: %v", st.ID, runErr)
+	failures := st.ConsecutiveFailures + 1
+	paused := false
+	if err := db.Model(&models.ScheduledTransfer{}).Where("id = ?", st.ID).Update("consecutive_failures", failures).Error; err != nil {
+		log.Printf("[jobs] error counting failure of scheduled transfer %d: %v", st.ID, err)
+	}
+	if failures >= MaxScheduleFailures {
+		res := db.Model(&models.ScheduledTransfer{}).Where("id = ? AND status = ?", st.ID, models.ScheduleActive).Update("status", models.SchedulePaused)
+		if res.Error != nil {
+			log.Printf("[jobs] error pausing scheduled transfer %d: %v", st.ID, res.Error)
+		}
+		paused = res.RowsAffected == 1
+	}
+
+	if emailClient == nil {
+		return
+	}
+	to, err := notificationEmail(db, st.UserID)
+	if err != nil {
+		log.Printf("[jobs] error looking up notification email for user %d: %v", st.UserID, err)
+		return
+	}
+	if to == "" {
+		return
+	}
+	name := st.Label
+	if name == "" {
+		name = fmt.Sprintf("#%d", st.ID)
+	}
+	token := st.Symbol
+	if token == "" {
+		token = st.Mint
+	}
+	if err := emailClient.SendScheduledTransferFailedEmail(to, name, st.Amount+" "+token, st.Recipient, exec.Error, paused); err != nil {
+		log.Printf("[jobs] error sending scheduled transfer failure email for user %d: %v", st.UserID, err)
+	}
+}
+
+// RunScheduledTransfers executes every active schedule whose next run is due.
+func RunScheduledTransfers(ctx context.Context, db *gorm.DB, delegate *signer.Keypair, prices *pricing.Service, emailClient *email.Client) error {
+	now := time.Now()
+	var due []models.ScheduledTransfer
+	if err := db.WithContext(ctx).Where("status = ? AND next_run_at <= ?", models.ScheduleActive, now).
+		Order("next_run_at").Limit(scheduledTransferBatch).Find(&due).Error; err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, scheduledTransferWorkers)
+	var wg sync.WaitGroup
+	for i := range due {
+		st := &due[i]
+		scheduledFor := *st.NextRunAt
+		claimed, err := claimScheduledTransfer(db.WithContext(ctx), st, now)
+		if err != nil {
+			log.Printf("[jobs] error claiming scheduled transfer %d: %v", st.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			executeScheduledTransfer(ctx, db, delegate, prices, emailClient, st, scheduledFor)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// StartScheduledTransfers runs due scheduled transfers every minute.
+func StartScheduledTransfers(ctx context.Context, db *gorm.DB, delegate *signer.Keypair, prices *pricing.Service, emailClient *email.Client) {
+	if delegate == nil || len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: scheduler key or Solana RPC upstream not configured; scheduled transfers will not run.")
+		return
+	}
+	Every(ctx, "scheduled-transfers", time.Minute, func(ctx context.Context) error {
+		return RunScheduledTransfers(ctx, db, delegate, prices, emailClient)
+	})
+}
@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+// maxOrderComputeUnitPrice caps the priority fee, in micro-lamports per compute unit, an order
+// execution may spend of the scheduler key's SOL.
+const maxOrderComputeUnitPrice = 1_000_000
+
+// jupiterSharedAccountsRoute is the Anchor discriminator of Jupiter's shared_accounts_route. Its
+// legs swap between Jupiter's own token accounts, so the signer's authority only moves the source.
+var jupiterSharedAccountsRoute = anchorDiscriminator("shared_accounts_route")
+
+// Accounts of shared_accounts_route checked here
+const (
+	sharedRouteAuthority   = 2
+	sharedRouteSource      = 3
+	sharedRouteDestination = 6
+	sharedRouteSourceMint  = 7
+	sharedRouteDestMint    = 8
+	// in_amount (u64), quoted_out_amount (u64), slippage_bps (u16) and platform_fee_bps (u8) end the data
+	sharedRouteTailSize = 19
+)
+
+func anchorDiscriminator(name string) []byte {
+	h := sha256.Sum256([]byte("global:" + name))
+	return h[:8]
+}
+
+// orderSwapAccounts are the accounts one execution of an order may use.
+type orderSwapAccounts struct {
+	delegate, owner         solana.PublicKey
+	inputMint, outputMint   solana.PublicKey
+	inputProgram            solana.PublicKey
+	ownerInput, ownerOutput solana.PublicKey // the wallet's token accounts
+	delegateInput           solana.PublicKey // where the input is moved before the swap
+}
+
+func newOrderSwapAccounts(o *models.SwapOrder, owner, delegate, outputProgram solana.PublicKey) (*orderSwapAccounts, error) {
+	inputMint, err := solana.PublicKeyFromBase58(o.InputMint)
+	if err != nil {
+		return nil, fmt.Errorf("input mint: %w", err)
+	}
+	outputMint, err := solana.PublicKeyFromBase58(o.OutputMint)
+	if err != nil {
+		return nil, fmt.Errorf("output mint: %w", err)
+	}
+	inputProgram, err := solana.PublicKeyFromBase58(o.InputProgram)
+	if err != nil {
+		return nil, fmt.Errorf("input token program: %w", err)
+	}
+	return &orderSwapAccounts{
+		delegate: delegate, owner: owner,
+		inputMint: inputMint, outputMint: outputMint,
+		inputProgram:  inputProgram,
+		ownerInput:    signer.AssociatedTokenAddress(owner, inputProgram, inputMint),
+		ownerOutput:   signer.AssociatedTokenAddress(owner, outputProgram, outputMint),
+		delegateInput: signer.AssociatedTokenAddress(delegate, inputProgram, inputMint),
+	}, nil
+}
+
+// verifyOrderSwap resolves what a delegated swap built by solana-api for o touches on chain and
+// refuses it unless it is exactly one execution of o (see checkOrderSwap). The scheduler key is the
+// delegate of many wallets' token accounts, so it never signs a transaction it hasn't checked.
+func verifyOrderSwap(ctx context.Context, txBase64 string, o *models.SwapOrder, owner, delegate solana.PublicKey) error {
+	tx, err := solana.TransactionFromBase64(txBase64)
+	if err != nil {
+		return fmt.Errorf("decode swap: %w", err)
+	}
+	tables := map[solana.PublicKey]solana.PublicKeySlice{}
+	for _, lookup := range tx.Message.GetAddressTableLookups() {
+		addresses, err := solanarpc.LookupTableAddresses(ctx, lookup.AccountKey.String())
+		if err != nil {
+			return fmt.Errorf("lookup table %s: %w", lookup.AccountKey, err)
+		}
+		tables[lookup.AccountKey] = addresses
+	}
+	if len(tables) > 0 {
+		if err := tx.Message.SetAddressTables(tables); err != nil {
+			return err
+		}
+	}
+	keys, err := tx.Message.GetAllKeys()
+	if err != nil {
+		return fmt.Errorf("resolve swap accounts: %w", err)
+	}
+
+	outputProgram, _, err := solanarpc.MintInfo(ctx, o.OutputMint)
+	if err != nil {
+		return fmt.Errorf("output mint: %w", err)
+	}
+	accounts, err := newOrderSwapAccounts(o, owner, delegate, outputProgram)
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]string, len(keys))
+	for i, k := range keys {
+		addresses[i] = k.String()
+	}
+	delegates, err := solanarpc.TokenAccountDelegates(ctx, addresses)
+	if err != nil {
+		return fmt.Errorf("token account delegates: %w", err)
+	}
+	delegated := map[solana.PublicKey]bool{}
+	for address, d := range delegates {
+		if d == delegate.String() {
+			delegated[solana.MustPublicKeyFromBase58(address)] = true
+		}
+	}
+	return checkOrderSwap(txBase64, keys, o, accounts, delegated)
+}
+
+// checkOrderSwap refuses a delegated swap unless it only does what one execution of o needs, with
+// keys the transaction's accounts including those loaded from lookup tables, and delegated the
+// token accounts among them the delegate may move funds out of:
+//   - it is paid and signed by the delegate alone, at a compute-unit price of at most
+//     maxOrderComputeUnitPrice;
+//   - it creates the delegate's or the wallet's token accounts for the order's mints, paid by the
+//     delegate;
+//   - the only debit of a delegated account is one TransferChecked of o.RawAmount from the wallet's
+//     input token account into the delegate's;
+//   - one Jupiter shared_accounts_route swaps exactly that amount out of the delegate's account,
+//     with no platform fee, paying the output to the wallet's output token account.
+func checkOrderSwap(txBase64 string, keys solana.PublicKeySlice, o *models.SwapOrder, acc *orderSwapAccounts, delegated map[solana.PublicKey]bool) error {
+	summary, err := signer.InspectTransaction(txBase64, acc.delegate)
+	if err != nil {
+		return err
+	}
+	if summary.FeePayer != acc.delegate.String() || len(summary.Signers) != 1 {
+		return errors.New("the swap must be paid and signed by the scheduler key alone")
+	}
+	if summary.ComputeUnitPriceMicroLamports > maxOrderComputeUnitPrice {
+		return fmt.Errorf("the swap's priority fee of %d micro-lamports per compute unit is over the %d cap", summary.ComputeUnitPriceMicroLamports, maxOrderComputeUnitPrice)
+	}
+	tx, err := solana.TransactionFromBase64(txBase64)
+	if err != nil {
+		return err
+	}
+
+	var transfers, swaps int
+	for i, ix := range tx.Message.Instructions {
+		account := func(n int) solana.PublicKey {
+			if n >= len(ix.Accounts) || int(ix.Accounts[n]) >= len(keys) {
+				return solana.PublicKey{}
+			}
+			return keys[ix.Accounts[n]]
+		}
+		// Position of the account each instruction may legitimately reference while delegated
+		allowed := -1
+		switch summary.Instructions[i].Type {
+		case signer.InstrComputeBudget:
+		case signer.InstrATACreate:
+			payer, owner, mint, program := account(0), account(2), account(3), account(5)
+			if !payer.Equals(acc.delegate) || (!owner.Equals(acc.delegate) && !owner.Equals(acc.owner)) ||
+				(!mint.Equals(acc.inputMint) && !mint.Equals(acc.outputMint)) ||
+				!account(1).Equals(signer.AssociatedTokenAddress(owner, program, mint)) {
+				return fmt.Errorf("instruction %d creates a token account the order doesn't use", i)
+			}
+		case signer.InstrSPLTransfer:
+			transfers++
+			data := ix.Data
+			if transfers > 1 || !keys[ix.ProgramIDIndex].Equals(acc.inputProgram) || len(data) != 10 || data[0] != 12 ||
+				!account(0).Equals(acc.ownerInput) || !account(1).Equals(acc.inputMint) ||
+				!account(2).Equals(acc.delegateInput) || !account(3).Equals(acc.delegate) ||
+				binary.LittleEndian.Uint64(data[1:9]) != o.RawAmount || data[9] != o.InputDecimals {
+				return fmt.Errorf("instruction %d moves tokens other than the order's input", i)
+			}
+			allowed = 0
+		case signer.InstrJupiterSwap:
+			swaps++
+			data := ix.Data
+			if swaps > 1 || len(data) < 8+1+4+sharedRouteTailSize || !bytes.Equal(data[:8], jupiterSharedAccountsRoute) {
+				return fmt.Errorf("instruction %d is not a single shared-accounts Jupiter route", i)
+			}
+			tail := data[len(data)-sharedRouteTailSize:]
+			if !account(sharedRouteAuthority).Equals(acc.delegate) || !account(sharedRouteSource).Equals(acc.delegateInput) ||
+				!account(sharedRouteSourceMint).Equals(acc.inputMint) || !account(sharedRouteDestMint).Equals(acc.outputMint) ||
+				binary.LittleEndian.Uint64(tail[0:8]) != o.RawAmount || tail[18] != 0 {
+				return fmt.Errorf("instruction %d doesn't swap the order's input", i)
+			}
+			if !account(sharedRouteDestination).Equals(acc.ownerOutput) {
+				return fmt.Errorf("instruction %d doesn't pay the output to the wallet", i)
+			}
+			allowed = sharedRouteDestination
+		default:
+			return fmt.Errorf("instruction %d (%s) is not part of an order swap", i, summary.Instructions[i].Type)
+		}
+		for n := range ix.Accounts {
+			if n != allowed && delegated[account(n)] {
+				return fmt.Errorf("instruction %d uses %s, a token account the scheduler key can spend from", i, account(n))
+			}
+		}
+	}
+	if transfers != 1 || swaps != 1 {
+		return errors.New("the swap must move the order's input once and swap it through Jupiter")
+	}
+	return nil
+}
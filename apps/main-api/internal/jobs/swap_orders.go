@@ -0,0 +1,387 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// swapOrderBatch bounds the due orders one run picks up.
+	swapOrderBatch = 100
+	// swapOrderWorkers is how many order swaps are landed concurrently.
+	swapOrderWorkers = 4
+	// swapOrderTimeout bounds one execution, including confirmation.
+	swapOrderTimeout = 2 * time.Minute
+	// LimitOrderCheckInterval is how often an active limit order is evaluated against the price.
+	LimitOrderCheckInterval = time.Minute
+	// MaxSwapOrderFailures is how many executions in a row may fail before an order is paused.
+	MaxSwapOrderFailures = 3
+)
+
+// errLimitNotReached marks a limit order whose quote can't fill at the limit price yet; it isn't a failure.
+var errLimitNotReached = errors.New("limit price not reached")
+
+// PriceFunc returns the current TEAM556 price in USD.
+type PriceFunc func(ctx context.Context) (decimal.Decimal, error)
+
+// orderQuote holds the fields of a Jupiter quote an order execution checks.
+type orderQuote struct {
+	InputMint            string `json:"inputMint"`
+	InAmount             string `json:"inAmount"`
+	OutputMint           string `json:"outputMint"`
+	OutAmount            string `json:"outAmount"`
+	OtherAmountThreshold string `json:"otherAmountThreshold"`
+	PriceImpactPct       string `json:"priceImpactPct"`
+}
+
+// LimitReached reports whether a TEAM556 price in USD satisfies the order's limit price.
+func LimitReached(o *models.SwapOrder, price decimal.Decimal) bool {
+	limit, err := decimal.NewFromString(o.LimitPrice)
+	if err != nil || !price.IsPositive() {
+		return false
+	}
+	if o.Side == models.OrderBuy {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+// worstQuotePrice is the least favourable USD price per TEAM556 a quote for o can execute at: the
+// input against the output after slippage. USDC is taken at $1.
+func worstQuotePrice(o *models.SwapOrder, q *orderQuote) (decimal.Decimal, error) {
+	in, err := decimal.NewFromString(q.InAmount)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("quote input amount: %w", err)
+	}
+	minOut, err := decimal.NewFromString(q.OtherAmountThreshold)
+	if err != nil || !minOut.IsPositive() {
+		return decimal.Zero, errors.New("quote has no minimum output")
+	}
+	in, minOut = in.Shift(-int32(o.InputDecimals)), minOut.Shift(-int32(o.OutputDecimals))
+	if o.Side == models.OrderBuy {
+		return in.Div(minOut), nil
+	}
+	return minOut.Div(in), nil
+}
+
+// postSolanaAPI POSTs body to solana-api and decodes a 200 response into out.
+func postSolanaAPI(ctx context.Context, baseURL, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("solana-api %s returned %d: %s", path, resp.StatusCode, respBody)
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// fillLimitOrder marks a limit order filled once one of its swaps confirmed.
+func fillLimitOrder(db *gorm.DB, orderID uint) error {
+	return db.Model(&models.SwapOrder{}).
+		Where("id = ? AND kind = ? AND status IN ?", orderID, models.OrderLimit, []string{models.OrderActive, models.OrderPaused}).
+		Updates(map[string]any{"status": models.OrderFilled, "next_run_at": nil, "consecutive_failures": 0, "last_error": ""}).Error
+}
+
+// claimSwapOrder advances a due order to its next evaluation so no other runner executes it too.
+// A DCA plan with no runs left before its end date completes with this run.
+func claimSwapOrder(db *gorm.DB, o *models.SwapOrder, now time.Time) (bool, error) {
+	updates := map[string]any{"next_run_at": now.Add(LimitOrderCheckInterval)}
+	if o.Kind == models.OrderDCA {
+		updates["next_run_at"] = nil
+		if schedule, err := ParseCron(o.Cadence); err == nil {
+			if next := ScheduleNextRun(schedule, now, o.EndAt); next != nil {
+				updates["next_run_at"] = *next
+			}
+		}
+		if updates["next_run_at"] == nil {
+			updates["status"] = models.OrderCompleted
+		}
+	}
+	res := db.Model(&models.SwapOrder{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", o.ID, models.OrderActive, *o.NextRunAt).
+		Updates(updates)
+	return res.RowsAffected == 1, res.Error
+}
+
+// sendSwapOrder quotes, builds and lands one execution of o, signed by the delegate once the built
+// transaction is verified and its input is reserved against the wallet's spending policy. The swap
+// is recorded in the swap history as soon as it is signed.
+func sendSwapOrder(ctx context.Context, db *gorm.DB, cfg *config.Config, o *models.SwapOrder) (*models.Swap, error) {
+	var wallet models.Wallet
+	if err := db.Where("id = ? AND user_id = ?", o.WalletID, o.UserID).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("the wallet no longer exists")
+		}
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	quoteReq := map[string]any{"inputMint": o.InputMint, "outputMint": o.OutputMint, "amount": o.RawAmount, "slippageBps": o.SlippageBps}
+	if err := postSolanaAPI(ctx, cfg.SolanaAPIURL, "/api/swap/quote", quoteReq, &raw); err != nil {
+		return nil, fmt.Errorf("quote: %w", err)
+	}
+	var q orderQuote
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return nil, fmt.Errorf("decode quote: %w", err)
+	}
+	if q.InputMint != o.InputMint || q.OutputMint != o.OutputMint || q.InAmount != strconv.FormatUint(o.RawAmount, 10) {
+		return nil, errors.New("quote does not match the order")
+	}
+	if o.Kind == models.OrderLimit {
+		worst, err := worstQuotePrice(o, &q)
+		if err != nil {
+			return nil, err
+		}
+		if !LimitReached(o, worst) {
+			return nil, errLimitNotReached
+		}
+	} else if impact, err := decimal.NewFromString(q.PriceImpactPct); err != nil ||
+		impact.Abs().Mul(decimal.NewFromInt(10_000)).GreaterThan(decimal.NewFromInt(int64(cfg.SwapMaxPriceImpactBps))) {
+		return nil, errors.New("the quote's price impact is above the limit; this purchase was skipped")
+	}
+
+	var built struct {
+		SwapTransaction string `json:"swapTransaction"`
+	}
+	buildReq := map[string]any{"quoteResponse": raw, "delegatePublicKeyString": cfg.SchedulerKey.PublicKey().String(), "ownerPublicKeyString": wallet.Address}
+	if err := postSolanaAPI(ctx, cfg.SolanaAPIURL, "/api/swap/delegated", buildReq, &built); err != nil {
+		return nil, fmt.Errorf("build swap: %w", err)
+	}
+	owner, err := solana.PublicKeyFromBase58(wallet.Address)
+	if err != nil {
+		return nil, fmt.Errorf("wallet address: %w", err)
+	}
+	if err := verifyOrderSwap(ctx, built.SwapTransaction, o, owner, cfg.SchedulerKey.PublicKey()); err != nil {
+		return nil, fmt.Errorf("refused the built swap: %w", err)
+	}
+
+	// Executions count towards the wallet's spending policy like any other signature
+	var policy models.WalletSpendingPolicy
+	err = db.Where("wallet_id = ?", wallet.ID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var spend *models.WalletSpend
+	if err == nil {
+		if spend, err = reserveDelegatedSpend(ctx, db, cfg.Prices, wallet.ID, o.UserID, policy.ID, o.InputMint, o.Amount, "swap"); err != nil {
+			return nil, err
+		}
+	}
+	// Dropped when the swap fails before it is broadcast
+	release := func() {
+		if spend == nil {
+			return
+		}
+		if err := db.Delete(spend).Error; err != nil {
+			log.Printf("[jobs] error releasing spend %d of swap order %d: %v", spend.ID, o.ID, err)
+		}
+	}
+
+	signed, err := signer.SignTransaction(cfg.SchedulerKey, built.SwapTransaction)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	tx, err := solana.TransactionFromBase64(signed)
+	if err != nil || len(tx.Signatures) == 0 {
+		release()
+		return nil, fmt.Errorf("read signature: %v", err)
+	}
+
+	swap := &models.Swap{
+		UserID: o.UserID, WalletID: wallet.ID, WalletAddress: wallet.Address, OrderID: &o.ID,
+		InputMint: o.InputMint, OutputMint: o.OutputMint,
+		InAmount: q.InAmount, QuotedOutAmount: q.OutAmount, MinOutAmount: q.OtherAmountThreshold,
+		SlippageBps: o.SlippageBps, PriceImpact: q.PriceImpactPct,
+		InputDecimals: &o.InputDecimals, OutputDecimals: &o.OutputDecimals,
+		Signature: tx.Signatures[0].String(), Status: models.SwapPending,
+	}
+	if err := db.Create(swap).Error; err != nil {
+		release()
+		return nil, fmt.Errorf("record swap: %w", err)
+	}
+
+	result, err := solanarpc.SendAndConfirm(ctx, signed)
+	if err != nil {
+		// Left pending; the settle job finds out whether it landed
+		return swap, fmt.Errorf("%w: %v", errOutcomeUnknown, err)
+	}
+	switch result.Status {
+	case solanarpc.StatusConfirmed:
+		if err := SettleSwap(ctx, db, swap); err != nil {
+			log.Printf("[jobs] error settling swap %d of order %d: %v", swap.ID, o.ID, err)
+		}
+		return swap, nil
+	case solanarpc.StatusPending:
+		// Left pending like an unknown outcome; the settle job finds out whether it landed
+		return swap, fmt.Errorf("%w: the swap was seen but not confirmed before its blockhash expired", errOutcomeUnknown)
+	case solanarpc.StatusExpired:
+		now := time.Now()
+		swap.Status, swap.SettledAt, swap.Error = models.SwapExpired, &now, "the transaction expired before it landed"
+		if _, err := SaveSettledSwap(db, swap); err != nil {
+			log.Printf("[jobs] error updating swap %d of order %d: %v", swap.ID, o.ID, err)
+		}
+		return swap, errors.New(swap.Error)
+	}
+	if err := SettleSwap(ctx, db, swap); err != nil {
+		log.Printf("[jobs] error settling swap %d of order %d: %v", swap.ID, o.ID, err)
+	}
+	return swap, fmt.Errorf("the swap failed: %s", result.Error)
+}
+
+// executeSwapOrder runs one claimed order and records the outcome on it. A limit order fills on
+// success; one whose outcome is unknown is paused, so it can't fill twice, until the settle job
+// finds its swap. Orders are paused after MaxSwapOrderFailures failures in a row.
+func executeSwapOrder(ctx context.Context, db *gorm.DB, cfg *config.Config, o *models.SwapOrder) {
+	ctx, cancel := context.WithTimeout(ctx, swapOrderTimeout)
+	defer cancel()
+
+	swap, runErr := sendSwapOrder(ctx, db, cfg, o)
+	if errors.Is(runErr, errLimitNotReached) {
+		return
+	}
+	if runErr == nil {
+		log.Printf("[jobs] swap order %d executed %s", o.ID, swap.Signature)
+		if o.Kind == models.OrderLimit {
+			if err := fillLimitOrder(db, o.ID); err != nil {
+				log.Printf("[jobs] error filling swap order %d: %v", o.ID, err)
+			}
+			return
+		}
+		if err := db.Model(&models.SwapOrder{}).Where("id = ?", o.ID).
+			Updates(map[string]any{"consecutive_failures": 0, "last_error": ""}).Error; err != nil {
+			log.Printf("[jobs] error resetting failures of swap order %d: %v", o.ID, err)
+		}
+		return
+	}
+
+	log.Printf("[jobs] swap order %d failed: %v", o.ID, runErr)
+	lastError := runErr.Error()
+	if len(lastError) > 500 {
+		lastError = lastError[:500]
+	}
+	failures := o.ConsecutiveFailures + 1
+	pause := failures >= MaxSwapOrderFailures || (o.Kind == models.OrderLimit && errors.Is(runErr, errOutcomeUnknown))
+	if err := db.Model(&models.SwapOrder{}).Where("id = ?", o.ID).
+		Updates(map[string]any{"consecutive_failures": failures, "last_error": lastError}).Error; err != nil {
+		log.Printf("[jobs] error counting failure of swap order %d: %v", o.ID, err)
+	}
+	if pause {
+		if err := db.Model(&models.SwapOrder{}).Where("id = ? AND status = ?", o.ID, models.OrderActive).
+			Update("status", models.OrderPaused).Error; err != nil {
+			log.Printf("[jobs] error pausing swap order %d: %v", o.ID, err)
+		}
+	}
+}
+
+// RunSwapOrders expires lapsed limit orders and executes every active order that is due: DCA plans
+// on their cadence, limit orders once the TEAM556 price crosses their limit.
+func RunSwapOrders(ctx context.Context, db *gorm.DB, cfg *config.Config, price PriceFunc) error {
+	now := time.Now()
+	if err := db.WithContext(ctx).Model(&models.SwapOrder{}).
+		Where("kind = ? AND status IN ? AND end_at <= ?", models.OrderLimit, []string{models.OrderActive, models.OrderPaused}, now).
+		Updates(map[string]any{"status": models.OrderExpired, "next_run_at": nil}).Error; err != nil {
+		return fmt.Errorf("expire limit orders: %w", err)
+	}
+
+	var due []models.SwapOrder
+	if err := db.WithContext(ctx).Where("status = ? AND next_run_at <= ?", models.OrderActive, now).
+		Order("next_run_at").Limit(swapOrderBatch).Find(&due).Error; err != nil {
+		return err
+	}
+
+	var (
+		usd      decimal.Decimal
+		priceErr error
+		priced   bool
+		waiting  []uint
+	)
+	sem := make(chan struct{}, swapOrderWorkers)
+	var wg sync.WaitGroup
+	for i := range due {
+		o := &due[i]
+		if o.Kind == models.OrderLimit {
+			if !priced {
+				usd, priceErr = price(ctx)
+				priced = true
+				if priceErr != nil {
+					log.Printf("[jobs] swap orders: TEAM556 price unavailable, limit orders not evaluated: %v", priceErr)
+				}
+			}
+			if priceErr != nil {
+				continue
+			}
+			if !LimitReached(o, usd) {
+				waiting = append(waiting, o.ID)
+				continue
+			}
+		}
+		claimed, err := claimSwapOrder(db.WithContext(ctx), o, now)
+		if err != nil {
+			log.Printf("[jobs] error claiming swap order %d: %v", o.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			executeSwapOrder(ctx, db, cfg, o)
+		}()
+	}
+	if len(waiting) > 0 {
+		// Checked against the price; move them behind orders that haven't been looked at yet
+		if err := db.WithContext(ctx).Model(&models.SwapOrder{}).Where("id IN ? AND status = ?", waiting, models.OrderActive).
+			Update("next_run_at", now.Add(LimitOrderCheckInterval)).Error; err != nil {
+			log.Printf("[jobs] error rescheduling limit orders: %v", err)
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// StartSwapOrders evaluates limit orders and DCA plans every minute.
+func StartSwapOrders(ctx context.Context, db *gorm.DB, cfg *config.Config, price PriceFunc) {
+	if cfg.SchedulerKey == nil || len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: scheduler key or Solana RPC upstream not configured; swap orders will not run.")
+		return
+	}
+	Every(ctx, "swap-orders", time.Minute, func(ctx context.Context) error {
+		return RunSwapOrders(ctx, db, cfg, price)
+	})
+}
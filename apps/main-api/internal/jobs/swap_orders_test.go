@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/shopspring/decimal"
+
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/signer"
+)
+
+func TestLimitReached(t *testing.T) {
+	tests := []struct {
+		side, limit, price string
+		want               bool
+	}{
+		{models.OrderBuy, "0.05", "0.04", true},
+		{models.OrderBuy, "0.05", "0.05", true},
+		{models.OrderBuy, "0.05", "0.051", false},
+		{models.OrderSell, "0.05", "0.06", true},
+		{models.OrderSell, "0.05", "0.049", false},
+		{models.OrderBuy, "0.05", "0", false},
+		{models.OrderBuy, "", "0.01", false},
+	}
+	for _, tt := range tests {
+		o := &models.SwapOrder{Kind: models.OrderLimit, Side: tt.side, LimitPrice: tt.limit}
+		if got := LimitReached(o, decimal.RequireFromString(tt.price)); got != tt.want {
+			t.Errorf("LimitReached(%s at %s, price %s) = %v, want %v", tt.side, tt.limit, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestWorstQuotePrice(t *testing.T) {
+	// 100 USDC (6 decimals) for at least 2000 TEAM556 (9 decimals)
+	buy := &models.SwapOrder{Side: models.OrderBuy, InputDecimals: 6, OutputDecimals: 9}
+	got, err := worstQuotePrice(buy, &orderQuote{InAmount: "100000000", OtherAmountThreshold: "2000000000000"})
+	if err != nil || !got.Equal(decimal.RequireFromString("0.05")) {
+		t.Errorf("buy price = %s, %v; want 0.05", got, err)
+	}
+
+	// 1000 TEAM556 for at least 45 USDC
+	sell := &models.SwapOrder{Side: models.OrderSell, InputDecimals: 9, OutputDecimals: 6}
+	got, err = worstQuotePrice(sell, &orderQuote{InAmount: "1000000000000", OtherAmountThreshold: "45000000"})
+	if err != nil || !got.Equal(decimal.RequireFromString("0.045")) {
+		t.Errorf("sell price = %s, %v; want 0.045", got, err)
+	}
+
+	if _, err := worstQuotePrice(buy, &orderQuote{InAmount: "100000000", OtherAmountThreshold: "0"}); err == nil {
+		t.Error("expected an error for a quote without a minimum output")
+	}
+}
+
+func TestCheckOrderSwap(t *testing.T) {
+	delegate, owner := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	usdc, team := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	o := &models.SwapOrder{InputMint: usdc.String(), OutputMint: team.String(), InputProgram: solana.TokenProgramID.String(),
+		InputDecimals: 6, RawAmount: 5_000_000}
+	acc, err := newOrderSwapAccounts(o, owner, delegate, solana.TokenProgramID)
+	if err != nil {
+		t.Fatalf("newOrderSwapAccounts() error = %v", err)
+	}
+	jupiter := solana.MustPublicKeyFromBase58("JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4")
+	other := signer.AssociatedTokenAddress(solana.NewWallet().PublicKey(), solana.TokenProgramID, usdc)
+
+	route := func(in uint64, source, dest solana.PublicKey, feeBps byte) solana.Instruction {
+		data := append(append([]byte{}, jupiterSharedAccountsRoute...), 0, 0, 0, 0, 0)
+		tail := make([]byte, sharedRouteTailSize)
+		binary.LittleEndian.PutUint64(tail, in)
+		tail[18] = feeBps
+		metas := solana.AccountMetaSlice{}
+		for i := 0; i < 13; i++ {
+			metas = append(metas, solana.Meta(solana.NewWallet().PublicKey()))
+		}
+		metas[sharedRouteAuthority] = solana.Meta(delegate).SIGNER()
+		metas[sharedRouteSource], metas[sharedRouteDestination] = solana.Meta(source).WRITE(), solana.Meta(dest).WRITE()
+		metas[sharedRouteSourceMint], metas[sharedRouteDestMint] = solana.Meta(usdc), solana.Meta(team)
+		return solana.NewInstruction(jupiter, metas, append(data, tail...))
+	}
+	price := func(microLamports uint64) solana.Instruction {
+		data := make([]byte, 9)
+		data[0] = 3
+		binary.LittleEndian.PutUint64(data[1:], microLamports)
+		return solana.NewInstruction(solana.MustPublicKeyFromBase58("ComputeBudget111111111111111111111111111111"), nil, data)
+	}
+	transfer := func(amount uint64) solana.Instruction {
+		return signer.DelegatedTransferInstruction(solana.TokenProgramID, usdc, owner, delegate, delegate, amount, 6)
+	}
+	createInput := signer.CreateAssociatedTokenAccountIdempotentInstruction(solana.TokenProgramID, usdc, delegate, delegate)
+
+	tests := []struct {
+		name  string
+		ixs   []solana.Instruction
+		payer solana.PublicKey
+		ok    bool
+	}{
+		{"order swap", []solana.Instruction{price(10_000), createInput, transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)}, delegate, true},
+		{"other payer", []solana.Instruction{transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)}, owner, false},
+		{"priority fee over the cap", []solana.Instruction{price(maxOrderComputeUnitPrice + 1), transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)}, delegate, false},
+		{"transfer over the amount", []solana.Instruction{transfer(6_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)}, delegate, false},
+		{"second transfer", []solana.Instruction{transfer(5_000_000), transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)}, delegate, false},
+		{"output paid elsewhere", []solana.Instruction{transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.delegateInput, 0)}, delegate, false},
+		{"swap from another account", []solana.Instruction{transfer(5_000_000), route(5_000_000, other, acc.ownerOutput, 0)}, delegate, false},
+		{"platform fee", []solana.Instruction{transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 50)}, delegate, false},
+		{"no swap", []solana.Instruction{transfer(5_000_000)}, delegate, false},
+		{"sol transfer", []solana.Instruction{transfer(5_000_000), route(5_000_000, acc.delegateInput, acc.ownerOutput, 0),
+			solana.NewInstruction(solana.SystemProgramID, solana.AccountMetaSlice{solana.Meta(delegate).WRITE().SIGNER(), solana.Meta(owner).WRITE()}, []byte{2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0})}, delegate, false},
+	}
+	for _, tt := range tests {
+		txBase64, err := signer.BuildTransaction(tt.ixs, solana.Hash{}, tt.payer)
+		if err != nil {
+			t.Fatalf("%s: BuildTransaction() error = %v", tt.name, err)
+		}
+		tx, _ := solana.TransactionFromBase64(txBase64)
+		err = checkOrderSwap(txBase64, tx.Message.AccountKeys, o, acc, map[solana.PublicKey]bool{acc.ownerInput: true, other: true})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: checkOrderSwap() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+
+	// A delegated account anywhere else in the route is refused, even with the right source and destination
+	ix := route(5_000_000, acc.delegateInput, acc.ownerOutput, 0)
+	ix.(*solana.GenericInstruction).AccountValues[10] = solana.Meta(other).WRITE()
+	txBase64, _ := signer.BuildTransaction([]solana.Instruction{transfer(5_000_000), ix}, solana.Hash{}, delegate)
+	tx, _ := solana.TransactionFromBase64(txBase64)
+	if err := checkOrderSwap(txBase64, tx.Message.AccountKeys, o, acc, map[solana.PublicKey]bool{acc.ownerInput: true, other: true}); err == nil {
+		t.Error("checkOrderSwap() accepted a route touching another delegated account")
+	}
+}
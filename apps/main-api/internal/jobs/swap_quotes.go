@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/models"
+)
+
+// swapQuoteRetention is how long expired swap quotes are kept before they are deleted.
+const swapQuoteRetention = time.Hour
+
+// PurgeExpiredSwapQuotes deletes swap quotes that expired more than swapQuoteRetention ago.
+func PurgeExpiredSwapQuotes(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Where("expires_at < ?", time.Now().Add(-swapQuoteRetention)).Delete(&models.SwapQuote{}).Error
+}
+
+// StartSwapQuotePurger periodically deletes expired swap quotes.
+func StartSwapQuotePurger(ctx context.Context, db *gorm.DB) {
+	Every(ctx, "swap-quote-purge", time.Hour, func(ctx context.Context) error {
+		return PurgeExpiredSwapQuotes(ctx, db)
+	})
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// wrappedSOLMint is the mint Jupiter quotes native SOL as; swaps wrap and unwrap it in the same transaction.
+	wrappedSOLMint = "So11111111111111111111111111111111111111112"
+	// swapSettleBatch bounds the pending swaps one run looks up.
+	swapSettleBatch = 50
+	// swapExpireAfter is how long a swap may go unseen on chain before it is marked expired.
+	// Swap transactions are built with a recent blockhash, which is only valid for about a minute.
+	swapExpireAfter = 3 * time.Minute
+)
+
+// swapAmountDelta returns the net change of mint in owner's balances within tx. Wrapped SOL
+// counts the owner's lamports too, without the fee, since the wrapped account is usually closed
+// in the same transaction; SOL spent therefore includes rent for any token account the swap opened.
+func swapAmountDelta(tx *rpcParsedTransaction, owner, mint string) (*big.Int, *uint8) {
+	delta := new(big.Int)
+	var decimals *uint8
+	if c, ok := ownerBalanceChanges(tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances, owner)[mint]; ok {
+		delta.Set(c.delta)
+		d := c.decimals
+		decimals = &d
+	}
+	if mint == wrappedSOLMint {
+		for i, k := range tx.Transaction.Message.AccountKeys {
+			if k.Pubkey != owner || i >= len(tx.Meta.PreBalances) || i >= len(tx.Meta.PostBalances) {
+				continue
+			}
+			lamports := new(big.Int).SetUint64(tx.Meta.PostBalances[i])
+			lamports.Sub(lamports, new(big.Int).SetUint64(tx.Meta.PreBalances[i]))
+			if i == 0 {
+				lamports.Add(lamports, new(big.Int).SetUint64(tx.Meta.Fee))
+			}
+			delta.Add(delta, lamports)
+			break
+		}
+		nine := uint8(9)
+		decimals = &nine
+	}
+	return delta, decimals
+}
+
+// settleSwapFromTransaction fills the outcome of swap from its landed, jsonParsed transaction.
+func settleSwapFromTransaction(swap *models.Swap, tx *rpcParsedTransaction) {
+	now := time.Now()
+	swap.SettledAt = &now
+	swap.Slot = tx.Slot
+	if tx.BlockTime != nil {
+		t := time.Unix(*tx.BlockTime, 0).UTC()
+		swap.SettledAt = &t
+	}
+	if tx.Meta == nil {
+		swap.Status = models.SwapConfirmed
+		return
+	}
+	swap.FeeLamports = tx.Meta.Fee
+	if tx.Meta.Err != nil {
+		swap.Status = models.SwapFailed
+		swap.Error = fmt.Sprintf("transaction failed: %v", tx.Meta.Err)
+		if len(swap.Error) > 500 {
+			swap.Error = swap.Error[:500]
+		}
+		return
+	}
+	swap.Status, swap.Error = models.SwapConfirmed, ""
+
+	spent, inDecimals := swapAmountDelta(tx, swap.WalletAddress, swap.InputMint)
+	received, outDecimals := swapAmountDelta(tx, swap.WalletAddress, swap.OutputMint)
+	swap.SpentInAmount = new(big.Int).Neg(spent).String()
+	swap.ReceivedOutAmount = received.String()
+	if inDecimals != nil {
+		swap.InputDecimals = inDecimals
+	}
+	if outDecimals != nil {
+		swap.OutputDecimals = outDecimals
+	}
+}
+
+// SaveSettledSwap writes swap if it is still pending in the database, and reports false when it was
+// settled elsewhere first.
+func SaveSettledSwap(db *gorm.DB, swap *models.Swap) (bool, error) {
+	res := db.Model(swap).Where("status = ?", models.SwapPending).Select("*").Omit("created_at").Updates(swap)
+	return res.RowsAffected == 1, res.Error
+}
+
+// SettleSwap looks up a pending swap's transaction and records its outcome. Swaps that haven't
+// landed swapExpireAfter after they were created are marked expired; younger ones are left pending.
+func SettleSwap(ctx context.Context, db *gorm.DB, swap *models.Swap) error {
+	if swap.Signature == "" {
+		return errors.New("swap has no signature")
+	}
+	var tx *rpcParsedTransaction
+	params := []any{swap.Signature, map[string]any{"encoding": "jsonParsed", "maxSupportedTransactionVersion": 0, "commitment": "confirmed"}}
+	if err := solanarpc.CallContext(ctx, "getTransaction", params, &tx); err != nil {
+		return fmt.Errorf("getTransaction %s: %w", swap.Signature, err)
+	}
+	if tx == nil {
+		if time.Since(swap.CreatedAt) < swapExpireAfter {
+			return nil
+		}
+		now := time.Now()
+		swap.Status, swap.SettledAt = models.SwapExpired, &now
+		if swap.Error == "" {
+			swap.Error = "the transaction expired before it landed"
+		}
+		_, err := SaveSettledSwap(db, swap)
+		return err
+	}
+
+	settleSwapFromTransaction(swap, tx)
+	// A mint the wallet held no account of before or after (rare) has no decimals in the balances
+	for _, side := range []struct {
+		mint     string
+		decimals **uint8
+	}{{swap.InputMint, &swap.InputDecimals}, {swap.OutputMint, &swap.OutputDecimals}} {
+		if swap.Status != models.SwapConfirmed || *side.decimals != nil {
+			continue
+		}
+		if _, d, err := solanarpc.MintInfo(ctx, side.mint); err == nil {
+			*side.decimals = &d
+		}
+	}
+	// The swap's sender and the settle job can both get here; only the one that settles it counts the execution
+	saved, err := SaveSettledSwap(db, swap)
+	if err != nil || !saved {
+		return err
+	}
+	if swap.OrderID != nil && swap.Status == models.SwapConfirmed {
+		if err := db.Model(&models.SwapOrder{}).Where("id = ?", *swap.OrderID).
+			Update("executions", gorm.Expr("executions + 1")).Error; err != nil {
+			return err
+		}
+		// Limit orders whose outcome was unknown were paused; this is the fill
+		return fillLimitOrder(db, *swap.OrderID)
+	}
+	return nil
+}
+
+// SettlePendingSwaps settles the oldest pending swaps.
+func SettlePendingSwaps(ctx context.Context, db *gorm.DB) error {
+	var pending []models.Swap
+	if err := db.WithContext(ctx).Where("status = ?", models.SwapPending).
+		Order("created_at").Limit(swapSettleBatch).Find(&pending).Error; err != nil {
+		return err
+	}
+	var failed int
+	for i := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := SettleSwap(ctx, db, &pending[i]); err != nil {
+			failed++
+			log.Printf("[jobs] error settling swap %d: %v", pending[i].ID, err)
+		}
+	}
+	if failed > 0 {
+		log.Printf("[jobs] swap settle: %d of %d swaps failed", failed, len(pending))
+	}
+	return nil
+}
+
+// StartSwapSettler periodically records the outcome of submitted swaps.
+func StartSwapSettler(ctx context.Context, db *gorm.DB) {
+	if len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: Solana RPC upstream not configured; swap history will not record received amounts.")
+		return
+	}
+	Every(ctx, "swap-settle", 30*time.Second, func(ctx context.Context) error {
+		return SettlePendingSwaps(ctx, db)
+	})
+}
@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/team556-mono/server/internal/models"
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// txIndexPageSize is the signatures requested per getSignaturesForAddress call (the RPC maximum).
+	txIndexPageSize = 1000
+	// txIndexMaxNewPages bounds how far back one run follows new signatures. An address with more
+	// new transactions than this between runs is caught up over several runs.
+	txIndexMaxNewPages = 10
+	// txIndexBackfillPages is how many pages of older history each run adds.
+	txIndexBackfillPages = 1
+	// txIndexParseBatch is how many transactions are fetched and parsed per address per run.
+	txIndexParseBatch = 200
+	// txIndexParseWorkers is the number of concurrent getTransaction calls per address.
+	txIndexParseWorkers = 8
+	// txIndexAddressesPerRun bounds the addresses one indexer run visits, least recently synced first.
+	txIndexAddressesPerRun = 50
+)
+
+// rpcSignature is one entry of a getSignaturesForAddress result.
+type rpcSignature struct {
+	Signature string `json:"signature"`
+	Slot      uint64 `json:"slot"`
+	BlockTime *int64 `json:"blockTime"`
+	Err       any    `json:"err"`
+}
+
+func getSignatures(ctx context.Context, address, before, until string) ([]rpcSignature, error) {
+	opts := map[string]any{"limit": txIndexPageSize, "commitment": "confirmed"}
+	if before != "" {
+		opts["before"] = before
+	}
+	if until != "" {
+		opts["until"] = until
+	}
+	var sigs []rpcSignature
+	err := solanarpc.CallContext(ctx, "getSignaturesForAddress", []any{address, opts}, &sigs)
+	return sigs, err
+}
+
+// storeSignatures inserts unparsed rows for sigs, skipping signatures already indexed.
+func storeSignatures(db *gorm.DB, address string, sigs []rpcSignature) error {
+	if len(sigs) == 0 {
+		return nil
+	}
+	rows := make([]models.WalletTransaction, len(sigs))
+	for i, s := range sigs {
+		rows[i] = models.WalletTransaction{Address: address, Signature: s.Signature, Slot: s.Slot, Success: s.Err == nil, Type: models.TxTypeOther}
+		if s.BlockTime != nil {
+			t := time.Unix(*s.BlockTime, 0).UTC()
+			rows[i].BlockTime = &t
+		}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 200).Error
+}
+
+// IndexAddress brings the transaction history of address up to date: new signatures since the
+// last run, one step of backfill towards the first transaction, then parsing of a batch of
+// fetched-but-unparsed transactions.
+func IndexAddress(ctx context.Context, db *gorm.DB, address string) error {
+	var state models.WalletIndexState
+	if err := db.Where("address = ?", address).First(&state).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		state = models.WalletIndexState{Address: address}
+	}
+
+	err := syncSignatures(ctx, db, &state)
+	if err == nil {
+		err = parsePending(ctx, db, address)
+	}
+
+	now := time.Now()
+	state.LastSyncedAt = &now
+	state.LastError = ""
+	if err != nil {
+		state.LastError = err.Error()
+	}
+	if saveErr := db.Save(&state).Error; saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// syncSignatures advances state's cursors, storing every signature it passes.
+func syncSignatures(ctx context.Context, db *gorm.DB, state *models.WalletIndexState) error {
+	// New signatures, newest first, down to the last one seen. A run that stops at the page cap
+	// keeps its place, and the next run fills the rest of the gap before NewestSignature moves.
+	newest, before := state.PendingNewest, state.ResumeBefore
+	reached := false
+	for page := 0; page < txIndexMaxNewPages; page++ {
+		sigs, err := getSignatures(ctx, state.Address, before, state.NewestSignature)
+		if err != nil {
+			return fmt.Errorf("new signatures: %w", err)
+		}
+		if len(sigs) == 0 {
+			reached = true
+			break
+		}
+		if err := storeSignatures(db, state.Address, sigs); err != nil {
+			return err
+		}
+		if newest == "" {
+			newest = sigs[0].Signature
+		}
+		before = sigs[len(sigs)-1].Signature
+		if state.OldestSignature == "" {
+			// First run: the backfill continues from the end of this page
+			state.OldestSignature = before
+			reached = true
+			break
+		}
+		if len(sigs) < txIndexPageSize {
+			reached = true
+			break
+		}
+	}
+	if reached {
+		if newest != "" {
+			state.NewestSignature = newest
+		}
+		state.PendingNewest, state.ResumeBefore = "", ""
+	} else {
+		state.PendingNewest, state.ResumeBefore = newest, before
+	}
+
+	// Older history, a few pages per run
+	for page := 0; page < txIndexBackfillPages && !state.BackfillDone && state.OldestSignature != ""; page++ {
+		sigs, err := getSignatures(ctx, state.Address, state.OldestSignature, "")
+		if err != nil {
+			return fmt.Errorf("backfill: %w", err)
+		}
+		if err := storeSignatures(db, state.Address, sigs); err != nil {
+			return err
+		}
+		if len(sigs) < txIndexPageSize {
+			state.BackfillDone = true
+		}
+		if len(sigs) > 0 {
+			state.OldestSignature = sigs[len(sigs)-1].Signature
+		}
+	}
+	if state.NewestSignature == "" && state.OldestSignature == "" {
+		// No transactions yet
+		state.BackfillDone = true
+	}
+	return nil
+}
+
+// parsePending fetches and parses up to txIndexParseBatch unparsed transactions of address, newest first.
+func parsePending(ctx context.Context, db *gorm.DB, address string) error {
+	var rows []models.WalletTransaction
+	if err := db.Where("address = ? AND parsed = ?", address, false).
+		Order("slot DESC").Limit(txIndexParseBatch).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		next     = make(chan *models.WalletTransaction)
+	)
+	for w := 0; w < txIndexParseWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range next {
+				var tx *rpcParsedTransaction
+				params := []any{row.Signature, map[string]any{"encoding": "jsonParsed", "maxSupportedTransactionVersion": 0, "commitment": "confirmed"}}
+				if err := solanarpc.CallContext(ctx, "getTransaction", params, &tx); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("getTransaction %s: %w", row.Signature, err)
+					}
+					mu.Unlock()
+					continue
+				}
+				if tx == nil {
+					// Pruned by the node; keep the row with what the signature listing told us
+					row.Parsed = true
+				} else {
+					ParseWalletTransaction(row, tx)
+				}
+				if err := db.Save(row).Error; err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- &rows[i]
+	}
+	close(next)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// IndexWalletTransactions runs IndexAddress for the least recently synced wallet addresses and
+// drops history for addresses no wallet references any more.
+func IndexWalletTransactions(ctx context.Context, db *gorm.DB) error {
+	if err := db.Where("address NOT IN (?)", db.Model(&models.Wallet{}).Select("address")).Delete(&models.WalletTransaction{}).Error; err != nil {
+		return fmt.Errorf("prune transactions: %w", err)
+	}
+	if err := db.Where("address NOT IN (?)", db.Model(&models.Wallet{}).Select("address")).Delete(&models.WalletIndexState{}).Error; err != nil {
+		return fmt.Errorf("prune index state: %w", err)
+	}
+
+	var addresses []string
+	active := db.Model(&models.Wallet{}).Distinct("address").Where("archived_at IS NULL")
+	err := db.Table("(?) AS w", active).
+		Joins("LEFT JOIN wallet_index_states s ON s.address = w.address").
+		Order("s.last_synced_at ASC NULLS FIRST").
+		Limit(txIndexAddressesPerRun).
+		Pluck("w.address", &addresses).Error
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := IndexAddress(ctx, db, address); err != nil {
+			failed++
+			log.Printf("[jobs] transaction index for %s: %v", address, err)
+		}
+	}
+	if failed > 0 {
+		log.Printf("[jobs] transaction index: %d of %d addresses failed", failed, len(addresses))
+	}
+	return nil
+}
+
+// StartTransactionIndexer periodically indexes transaction history for every active wallet address.
+func StartTransactionIndexer(ctx context.Context, db *gorm.DB) {
+	if len(solanarpc.Upstreams()) == 0 {
+		log.Println("Warning: no Solana RPC upstream configured; transaction history indexing is disabled.")
+		return
+	}
+	Every(ctx, "tx-index", 2*time.Minute, func(ctx context.Context) error {
+		return IndexWalletTransactions(ctx, db)
+	})
+}
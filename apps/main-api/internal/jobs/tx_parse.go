@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/team556-mono/server/internal/models"
+)
+
+// NativeSOL is the Token value recorded for native SOL balance changes.
+const NativeSOL = "SOL"
+
+// rpcParsedTransaction is the subset of a jsonParsed getTransaction result the indexer reads.
+type rpcParsedTransaction struct {
+	Slot      uint64 `json:"slot"`
+	BlockTime *int64 `json:"blockTime"`
+	Meta      *struct {
+		Err               any               `json:"err"`
+		Fee               uint64            `json:"fee"`
+		PreBalances       []uint64          `json:"preBalances"`
+		PostBalances      []uint64          `json:"postBalances"`
+		PreTokenBalances  []rpcTokenBalance `json:"preTokenBalances"`
+		PostTokenBalances []rpcTokenBalance `json:"postTokenBalances"`
+	} `json:"meta"`
+	Transaction struct {
+		Message struct {
+			AccountKeys []rpcAccountKey `json:"accountKeys"`
+		} `json:"message"`
+	} `json:"transaction"`
+}
+
+type rpcAccountKey struct {
+	Pubkey string `json:"pubkey"`
+}
+
+type rpcTokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UITokenAmount struct {
+		Amount   string `json:"amount"`
+		Decimals uint8  `json:"decimals"`
+	} `json:"uiTokenAmount"`
+}
+
+// balanceChange is the net change of one token for one owner within a transaction.
+type balanceChange struct {
+	mint     string
+	delta    *big.Int
+	decimals uint8
+}
+
+func (b balanceChange) uiAmount() string {
+	return decimal.NewFromBigInt(new(big.Int).Abs(b.delta), -int32(b.decimals)).String()
+}
+
+// ownerBalanceChanges returns the net change of every token owned by owner, keyed by mint.
+func ownerBalanceChanges(pre, post []rpcTokenBalance, owner string) map[string]*balanceChange {
+	changes := map[string]*balanceChange{}
+	apply := func(balances []rpcTokenBalance, sign int) {
+		for _, b := range balances {
+			if b.Owner != owner {
+				continue
+			}
+			amount, ok := new(big.Int).SetString(b.UITokenAmount.Amount, 10)
+			if !ok {
+				continue
+			}
+			c, ok := changes[b.Mint]
+			if !ok {
+				c = &balanceChange{mint: b.Mint, delta: new(big.Int), decimals: b.UITokenAmount.Decimals}
+				changes[b.Mint] = c
+			}
+			if sign < 0 {
+				c.delta.Sub(c.delta, amount)
+			} else {
+				c.delta.Add(c.delta, amount)
+			}
+		}
+	}
+	apply(pre, -1)
+	apply(post, 1)
+	return changes
+}
+
+// ParseWalletTransaction fills the parsed fields of row (Type, Token, Amount, Counterparty, Fee,
+// swap fields, Success, BlockTime) from a jsonParsed transaction, from row.Address's point of view.
+// The primary change is a non-SOL token when one moved, since SOL often only moves for rent.
+func ParseWalletTransaction(row *models.WalletTransaction, tx *rpcParsedTransaction) {
+	row.Parsed = true
+	row.Type = models.TxTypeOther
+	if tx.Slot != 0 {
+		row.Slot = tx.Slot
+	}
+	if tx.BlockTime != nil {
+		t := time.Unix(*tx.BlockTime, 0).UTC()
+		row.BlockTime = &t
+	}
+	if tx.Meta == nil {
+		return
+	}
+	meta := tx.Meta
+	row.Success = meta.Err == nil
+
+	keys := tx.Transaction.Message.AccountKeys
+	ownerIndex := -1
+	for i, k := range keys {
+		if k.Pubkey == row.Address {
+			ownerIndex = i
+			break
+		}
+	}
+
+	var changes []balanceChange
+	solDeltas := make([]*big.Int, len(keys))
+	for i := range keys {
+		solDeltas[i] = new(big.Int)
+		if i < len(meta.PreBalances) && i < len(meta.PostBalances) {
+			solDeltas[i].SetUint64(meta.PostBalances[i])
+			solDeltas[i].Sub(solDeltas[i], new(big.Int).SetUint64(meta.PreBalances[i]))
+		}
+	}
+	if ownerIndex >= 0 {
+		if ownerIndex == 0 {
+			// The fee payer is always first; report the fee separately from the transfer amount
+			row.Fee = meta.Fee
+			solDeltas[0].Add(solDeltas[0], new(big.Int).SetUint64(meta.Fee))
+		}
+		if solDeltas[ownerIndex].Sign() != 0 {
+			changes = append(changes, balanceChange{mint: NativeSOL, delta: solDeltas[ownerIndex], decimals: 9})
+		}
+	}
+	tokenChanges := ownerBalanceChanges(meta.PreTokenBalances, meta.PostTokenBalances, row.Address)
+	for _, c := range tokenChanges {
+		if c.delta.Sign() != 0 {
+			changes = append(changes, *c)
+		}
+	}
+
+	var out, in []balanceChange
+	for _, c := range changes {
+		if c.delta.Sign() < 0 {
+			out = append(out, c)
+		} else {
+			in = append(in, c)
+		}
+	}
+	primary := func(list []balanceChange) balanceChange {
+		sort.Slice(list, func(i, j int) bool {
+			if (list[i].mint == NativeSOL) != (list[j].mint == NativeSOL) {
+				return list[j].mint == NativeSOL
+			}
+			return list[i].mint < list[j].mint
+		})
+		return list[0]
+	}
+
+	var main balanceChange
+	switch {
+	case len(out) > 0 && len(in) > 0:
+		row.Type = models.TxTypeSwap
+		main = primary(out)
+		received := primary(in)
+		row.ReceivedToken, row.ReceivedAmount = received.mint, received.uiAmount()
+	case len(out) > 0:
+		row.Type = models.TxTypeSend
+		main = primary(out)
+	case len(in) > 0:
+		row.Type = models.TxTypeReceive
+		main = primary(in)
+	default:
+		return
+	}
+	row.Token, row.Amount = main.mint, main.uiAmount()
+	if row.Type != models.TxTypeSwap {
+		row.Counterparty = counterparty(main, keys, solDeltas, meta.PreTokenBalances, meta.PostTokenBalances, row.Address)
+	}
+}
+
+// counterparty returns the address whose balance of change.mint moved the most in the opposite direction.
+func counterparty(change balanceChange, keys []rpcAccountKey, solDeltas []*big.Int, pre, post []rpcTokenBalance, owner string) string {
+	best, bestAddr := new(big.Int), ""
+	consider := func(addr string, delta *big.Int) {
+		if addr == owner || addr == "" || delta.Sign() == change.delta.Sign() || delta.Sign() == 0 {
+			return
+		}
+		if abs := new(big.Int).Abs(delta); abs.Cmp(best) > 0 {
+			best, bestAddr = abs, addr
+		}
+	}
+	if change.mint == NativeSOL {
+		for i, k := range keys {
+			consider(k.Pubkey, solDeltas[i])
+		}
+		return bestAddr
+	}
+	seen := map[string]bool{}
+	var owners []string
+	for _, b := range append(append([]rpcTokenBalance{}, pre...), post...) {
+		if b.Mint == change.mint && !seen[b.Owner] {
+			seen[b.Owner] = true
+			owners = append(owners, b.Owner)
+		}
+	}
+	for _, o := range owners {
+		if c, ok := ownerBalanceChanges(pre, post, o)[change.mint]; ok {
+			consider(o, c.delta)
+		}
+	}
+	return bestAddr
+}
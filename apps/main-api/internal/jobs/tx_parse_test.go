@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/team556-mono/server/internal/models"
+)
+
+const (
+	testOwner = "Owner1111111111111111111111111111111111111"
+	testOther = "Other1111111111111111111111111111111111111"
+	testMintA = "MintA111111111111111111111111111111111111"
+	testMintB = "MintB111111111111111111111111111111111111"
+)
+
+func parseFixture(t *testing.T, raw string) models.WalletTransaction {
+	t.Helper()
+	var tx rpcParsedTransaction
+	if err := json.Unmarshal([]byte(raw), &tx); err != nil {
+		t.Fatalf("fixture: %v", err)
+	}
+	row := models.WalletTransaction{Address: testOwner}
+	ParseWalletTransaction(&row, &tx)
+	return row
+}
+
+func TestParseWalletTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want models.WalletTransaction
+	}{
+		{
+			name: "SOL send net of fee",
+			raw: `{"slot":10,"blockTime":1700000000,"meta":{"err":null,"fee":5000,
+				"preBalances":[2000000000,0],"postBalances":[999995000,1000000000],
+				"preTokenBalances":[],"postTokenBalances":[]},
+				"transaction":{"message":{"accountKeys":[{"pubkey":"` + testOwner + `"},{"pubkey":"` + testOther + `"}]}}}`,
+			want: models.WalletTransaction{Type: models.TxTypeSend, Token: NativeSOL, Amount: "1", Counterparty: testOther, Fee: 5000, Success: true},
+		},
+		{
+			name: "token receive",
+			raw: `{"slot":11,"meta":{"err":null,"fee":5000,
+				"preBalances":[1000000000,0,0],"postBalances":[999995000,0,0],
+				"preTokenBalances":[{"accountIndex":1,"mint":"` + testMintA + `","owner":"` + testOther + `","uiTokenAmount":{"amount":"500","decimals":2}},
+				                    {"accountIndex":2,"mint":"` + testMintA + `","owner":"` + testOwner + `","uiTokenAmount":{"amount":"0","decimals":2}}],
+				"postTokenBalances":[{"accountIndex":1,"mint":"` + testMintA + `","owner":"` + testOther + `","uiTokenAmount":{"amount":"250","decimals":2}},
+				                     {"accountIndex":2,"mint":"` + testMintA + `","owner":"` + testOwner + `","uiTokenAmount":{"amount":"250","decimals":2}}]},
+				"transaction":{"message":{"accountKeys":[{"pubkey":"` + testOther + `"},{"pubkey":"ata1"},{"pubkey":"ata2"}]}}}`,
+			want: models.WalletTransaction{Type: models.TxTypeReceive, Token: testMintA, Amount: "2.5", Counterparty: testOther, Success: true},
+		},
+		{
+			name: "swap SOL for token",
+			raw: `{"slot":12,"meta":{"err":null,"fee":5000,
+				"preBalances":[3000000000],"postBalances":[1999995000],
+				"preTokenBalances":[],
+				"postTokenBalances":[{"accountIndex":1,"mint":"` + testMintB + `","owner":"` + testOwner + `","uiTokenAmount":{"amount":"42","decimals":0}}]},
+				"transaction":{"message":{"accountKeys":[{"pubkey":"` + testOwner + `"}]}}}`,
+			want: models.WalletTransaction{Type: models.TxTypeSwap, Token: NativeSOL, Amount: "1", ReceivedToken: testMintB, ReceivedAmount: "42", Fee: 5000, Success: true},
+		},
+		{
+			name: "failed transaction only pays the fee",
+			raw: `{"slot":13,"meta":{"err":{"InstructionError":[0,"Custom"]},"fee":5000,
+				"preBalances":[1000000000],"postBalances":[999995000],"preTokenBalances":[],"postTokenBalances":[]},
+				"transaction":{"message":{"accountKeys":[{"pubkey":"` + testOwner + `"}]}}}`,
+			want: models.WalletTransaction{Type: models.TxTypeOther, Fee: 5000, Success: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFixture(t, tt.raw)
+			if !got.Parsed {
+				t.Error("Parsed = false")
+			}
+			if got.Type != tt.want.Type || got.Token != tt.want.Token || got.Amount != tt.want.Amount ||
+				got.Counterparty != tt.want.Counterparty || got.Fee != tt.want.Fee || got.Success != tt.want.Success ||
+				got.ReceivedToken != tt.want.ReceivedToken || got.ReceivedAmount != tt.want.ReceivedAmount {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettleSwapFromTransaction(t *testing.T) {
+	raw := `{"slot":20,"blockTime":1700000000,"meta":{"err":null,"fee":5000,
+		"preBalances":[3000000000],"postBalances":[1999995000],
+		"preTokenBalances":[{"accountIndex":1,"mint":"` + testMintB + `","owner":"` + testOwner + `","uiTokenAmount":{"amount":"8","decimals":6}}],
+		"postTokenBalances":[{"accountIndex":1,"mint":"` + testMintB + `","owner":"` + testOwner + `","uiTokenAmount":{"amount":"50","decimals":6}}]},
+		"transaction":{"message":{"accountKeys":[{"pubkey":"` + testOwner + `"}]}}}`
+	var tx rpcParsedTransaction
+	if err := json.Unmarshal([]byte(raw), &tx); err != nil {
+		t.Fatalf("fixture: %v", err)
+	}
+	swap := models.Swap{WalletAddress: testOwner, InputMint: wrappedSOLMint, OutputMint: testMintB, Status: models.SwapPending}
+	settleSwapFromTransaction(&swap, &tx)
+	if swap.Status != models.SwapConfirmed || swap.SpentInAmount != "1000000000" || swap.ReceivedOutAmount != "42" || swap.FeeLamports != 5000 {
+		t.Errorf("got status %s, spent %s, received %s, fee %d", swap.Status, swap.SpentInAmount, swap.ReceivedOutAmount, swap.FeeLamports)
+	}
+	if swap.InputDecimals == nil || *swap.InputDecimals != 9 || swap.OutputDecimals == nil || *swap.OutputDecimals != 6 {
+		t.Errorf("decimals = %v, %v", swap.InputDecimals, swap.OutputDecimals)
+	}
+
+	failed := tx
+	failed.Meta.Err = map[string]any{"InstructionError": []any{0, "Custom"}}
+	swap = models.Swap{WalletAddress: testOwner, InputMint: wrappedSOLMint, OutputMint: testMintB, Status: models.SwapPending}
+	settleSwapFromTransaction(&swap, &failed)
+	if swap.Status != models.SwapFailed || swap.ReceivedOutAmount != "" {
+		t.Errorf("failed swap: status %s, received %q", swap.Status, swap.ReceivedOutAmount)
+	}
+}
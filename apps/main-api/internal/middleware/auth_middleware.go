@@ -7,6 +7,9 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/models"
 )
 
 // CustomClaims struct including standard claims and userID
@@ -15,8 +18,10 @@ type CustomClaims struct {
 	jwt.RegisteredClaims
 }
 
-// AuthMiddleware creates a Fiber middleware for JWT authentication.
-func AuthMiddleware(jwtSecret string) fiber.Handler {
+// AuthMiddleware creates a Fiber middleware for JWT authentication. Tokens of deleted accounts, and
+// of accounts scheduled for deletion, are refused: deleting an account signs out every device, and
+// only a fresh login cancels the deletion.
+func AuthMiddleware(jwtSecret string, db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -55,6 +60,21 @@ func AuthMiddleware(jwtSecret string) fiber.Handler {
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Token has expired"})
 			}
 
+			var user models.User
+			if err := db.Select("id", "deletion_scheduled_for").Where("id = ?", claims.UserID).Take(&user).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+				}
+				log.Printf("Error checking account of user %d: %v", claims.UserID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+			}
+			if user.DeletionScheduledFor != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Account scheduled for deletion",
+					"message": "Log in again to cancel the deletion.",
+				})
+			}
+
 			// Token is valid, set userID in locals
 			c.Locals("userID", claims.UserID) // Use the UserID from the custom claims
 			log.Printf("Authenticated user %d", claims.UserID)
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AddressBookContact is a labeled address a user sends to.
+// Unique per (user_id, address)
+type AddressBookContact struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `gorm:"not null;uniqueIndex:idx_contacts_user_address" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	Label      string     `gorm:"size:50;not null" json:"label"`
+	Address    string     `gorm:"size:44;not null;uniqueIndex:idx_contacts_user_address" json:"address"`
+	Note       string     `gorm:"size:500" json:"note,omitempty"`
+	Favorite   bool       `gorm:"not null;default:false" json:"favorite"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Last send made with this contact selected
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Data export lifecycle
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+	DataExportExpired = "expired"
+)
+
+// DataExport is a user's request for a copy of their personal data.
+// The ZIP archive is stored inline until ExpiresAt, then dropped by the export job.
+type DataExport struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID    uint   `gorm:"not null;index" json:"user_id"`
+	User      User   `gorm:"foreignKey:UserID" json:"-"`
+	Status    string `gorm:"type:varchar(16);not null;default:'pending';index" json:"status"`
+	Error     string `gorm:"type:text" json:"-"`
+	Archive   []byte `gorm:"type:bytea" json:"-"`
+	SizeBytes int64  `gorm:"default:0" json:"size_bytes"`
+
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+}
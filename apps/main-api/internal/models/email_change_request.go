@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailChangeRequest tracks a pending change of User.Email.
+// The address is only swapped once the code sent to NewEmail is confirmed.
+type EmailChangeRequest struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	OldEmail  string    `gorm:"not null" json:"old_email"`
+	NewEmail  string    `gorm:"not null;index" json:"new_email"`
+	CodeHash  string    `gorm:"not null" json:"-"` // bcrypt hash of the 6-digit confirmation code
+	Attempts  int       `gorm:"default:0" json:"-"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Payment request statuses
+const (
+	PaymentRequestPending   = "pending"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestUnderpaid = "underpaid"
+	PaymentRequestExpired   = "expired"
+)
+
+// PaymentRequest is a Solana Pay request created for a merchant through /v1/solana/payment-request.
+// The payment watcher finds the transaction carrying ReferenceKey and checks what the merchant received.
+type PaymentRequest struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Reference      string `gorm:"size:128;not null;uniqueIndex" json:"reference"` // As generated by the merchant's plugin
+	ReferenceKey   string `gorm:"size:44;not null" json:"reference_key"`          // The on-chain reference, sha256 of Reference
+	MerchantWallet string `gorm:"size:44;not null;index" json:"merchant_wallet"`  // Receives the payment
+	Amount         string `gorm:"size:64;not null" json:"amount"`                 // SOL
+	Description    string `gorm:"size:255" json:"description,omitempty"`
+	OrderID        *int   `json:"order_id,omitempty"`
+
+	Status         string     `gorm:"size:16;not null;default:pending;index" json:"status"`
+	Signature      string     `gorm:"size:88" json:"signature,omitempty"`
+	ReceivedAmount string     `gorm:"size:64" json:"received_amount,omitempty"` // SOL the merchant received in that transaction
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
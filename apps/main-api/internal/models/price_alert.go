@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Price alert kinds
+const (
+	AlertAbove  = "above"  // Price at or above TargetUSD
+	AlertBelow  = "below"  // Price at or below TargetUSD
+	AlertChange = "change" // Price moved by ChangePercent within the last WindowMinutes
+)
+
+// PriceAlert notifies a user once when a token's USD price meets its condition, then disables
+// itself until the user turns it back on.
+type PriceAlert struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint   `gorm:"not null;index" json:"user_id"`
+	Mint   string `gorm:"size:44;not null" json:"mint"`
+	Kind   string `gorm:"size:8;not null" json:"kind"`
+
+	TargetUSD     string `gorm:"size:32" json:"target_usd,omitempty"`     // above and below
+	ChangePercent string `gorm:"size:16" json:"change_percent,omitempty"` // change; signed, -10 fires on a 10% drop
+	WindowMinutes int    `json:"window_minutes,omitempty"`                // change
+
+	Active       bool       `gorm:"not null;default:true;index" json:"active"`
+	TriggeredAt  *time.Time `json:"triggered_at,omitempty"`
+	TriggeredUSD string     `gorm:"size:32" json:"triggered_usd,omitempty"` // Price that fired it
+}
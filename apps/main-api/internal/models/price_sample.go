@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceSample is a token's aggregated USD price as recorded by the price sampler, about once a
+// minute. Candles are built from these. After a week only the samples that open, close, top and
+// bottom each hour are kept, and after a year those of each day.
+type PriceSample struct {
+	ID        uint            `gorm:"primarykey" json:"-"`
+	Mint      string          `gorm:"size:44;not null;index:idx_price_samples_mint_time,priority:1" json:"mint"`
+	SampledAt time.Time       `gorm:"not null;index:idx_price_samples_mint_time,priority:2" json:"sampled_at"`
+	USD       decimal.Decimal `gorm:"column:usd;type:numeric(38,18);not null" json:"usd"`
+	Source    string          `gorm:"size:64" json:"source"` // Sources the price was aggregated from
+}
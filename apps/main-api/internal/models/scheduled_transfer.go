@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Scheduled transfer states
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"    // by the user, or after repeated failures
+	ScheduleCompleted = "completed" // past its end date
+	ScheduleCancelled = "cancelled"
+)
+
+// ScheduledTransfer sends a fixed amount of an SPL token from one of the user's wallets on a cron
+// cadence until EndAt. The wallet's password is only needed when the schedule is created: the wallet
+// approves the scheduler's delegate key for the total of its schedules and swap orders and each run
+// is signed by the delegate.
+type ScheduledTransfer struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	WalletID uint   `gorm:"not null;index" json:"wallet_id"`
+	Wallet   Wallet `gorm:"foreignKey:WalletID" json:"-"`
+	Label    string `gorm:"size:50" json:"label,omitempty"`
+
+	Recipient    string `gorm:"size:44;not null" json:"recipient"` // Owner address; tokens go to its associated token account
+	ContactID    *uint  `json:"contact_id,omitempty"`
+	Mint         string `gorm:"size:44;not null" json:"mint"`
+	Symbol       string `gorm:"size:16" json:"symbol,omitempty"` // Empty for tokens the app doesn't know
+	TokenProgram string `gorm:"size:44;not null" json:"token_program"`
+	Decimals     uint8  `gorm:"not null" json:"decimals"`
+	Amount       string `gorm:"size:64;not null" json:"amount"` // UI amount per run
+	RawAmount    uint64 `gorm:"not null" json:"raw_amount"`
+
+	Cadence   string     `gorm:"size:100;not null" json:"cadence"` // Five-field cron expression, UTC
+	StartAt   time.Time  `gorm:"not null" json:"start_at"`
+	EndAt     time.Time  `gorm:"not null" json:"end_at"`
+	NextRunAt *time.Time `gorm:"index" json:"next_run_at,omitempty"` // Nil once the schedule has no runs left
+
+	Status              string `gorm:"size:16;not null;default:active;index" json:"status"`
+	ConsecutiveFailures int    `gorm:"not null;default:0" json:"consecutive_failures"`
+	ApprovalSignature   string `gorm:"size:88" json:"approval_signature,omitempty"` // Last approval covering this schedule
+}
+
+// Scheduled transfer execution outcomes
+const (
+	ExecutionConfirmed = "confirmed"
+	ExecutionFailed    = "failed"
+	ExecutionUnknown   = "unknown" // Sent but unconfirmed when the runner gave up; check the signature
+)
+
+// ScheduledTransferExecution is one run of a scheduled transfer.
+type ScheduledTransferExecution struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ScheduledTransferID uint      `gorm:"not null;index:idx_schedule_executions,priority:1" json:"scheduled_transfer_id"`
+	UserID              uint      `gorm:"not null;index" json:"user_id"`
+	ScheduledFor        time.Time `gorm:"not null;index:idx_schedule_executions,priority:2,sort:desc" json:"scheduled_for"`
+	Status              string    `gorm:"size:16;not null" json:"status"`
+	Signature           string    `gorm:"size:88" json:"signature,omitempty"`
+	Error               string    `gorm:"size:500" json:"error,omitempty"`
+	Attempts            int       `gorm:"not null;default:0" json:"attempts"` // Broadcasts made by SendAndConfirm
+}
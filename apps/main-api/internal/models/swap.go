@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// SwapQuote is a Jupiter quote issued to a user by /swap/quote. /swap/execute only accepts quotes
+// by ID, so the route, amounts and slippage that get signed are the ones the server checked.
+// A quote can be executed once, before ExpiresAt.
+type SwapQuote struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID        uint           `gorm:"not null;index" json:"user_id"`
+	InputMint     string         `gorm:"size:44;not null" json:"input_mint"`
+	OutputMint    string         `gorm:"size:44;not null" json:"output_mint"`
+	InAmount      string         `gorm:"size:32;not null" json:"in_amount"`  // Raw amounts, as quoted
+	OutAmount     string         `gorm:"size:32;not null" json:"out_amount"` // Expected output before slippage
+	SlippageBps   int            `gorm:"not null" json:"slippage_bps"`
+	PriceImpact   string         `gorm:"size:32" json:"price_impact"` // Fraction, e.g. "0.012" for 1.2%
+	QuoteResponse datatypes.JSON `gorm:"type:jsonb;not null" json:"-"`
+
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // Set while a swap from this quote is being executed
+}
+
+// Swap statuses
+const (
+	SwapPending   = "pending"   // Submitted; waiting for the transaction to land
+	SwapConfirmed = "confirmed" // Landed and succeeded; the received amounts are filled in
+	SwapFailed    = "failed"    // Rejected on submit or landed with an error
+	SwapExpired   = "expired"   // Never landed before its blockhash expired
+)
+
+// Swap is one swap executed through /swap/execute or by a swap order. It is created with the quoted
+// amounts when the transaction is signed and settled with what actually moved once it lands.
+type Swap struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID        uint   `gorm:"not null;index" json:"user_id"`
+	WalletID      uint   `gorm:"not null;index" json:"wallet_id"`
+	WalletAddress string `gorm:"size:44;not null" json:"wallet_address"`
+	QuoteID       string `gorm:"size:32" json:"quote_id"`
+	OrderID       *uint  `gorm:"index" json:"order_id,omitempty"` // Set for limit order and DCA executions
+
+	InputMint       string `gorm:"size:44;not null" json:"input_mint"`
+	OutputMint      string `gorm:"size:44;not null" json:"output_mint"`
+	InAmount        string `gorm:"size:32;not null" json:"in_amount"`         // Raw amounts, as quoted
+	QuotedOutAmount string `gorm:"size:32;not null" json:"quoted_out_amount"` // Expected output before slippage
+	MinOutAmount    string `gorm:"size:32" json:"min_out_amount"`             // Least output the slippage tolerance accepts
+	SlippageBps     int    `gorm:"not null" json:"slippage_bps"`
+	PriceImpact     string `gorm:"size:32" json:"price_impact"`
+
+	Signature string `gorm:"size:88;not null;uniqueIndex" json:"signature"`
+	Status    string `gorm:"size:16;not null;default:pending;index" json:"status"`
+	Error     string `gorm:"size:500" json:"error,omitempty"`
+
+	// Filled in once the transaction lands
+	SpentInAmount     string     `gorm:"size:32" json:"spent_in_amount,omitempty"`     // Raw input that left the wallet
+	ReceivedOutAmount string     `gorm:"size:32" json:"received_out_amount,omitempty"` // Raw output that arrived
+	InputDecimals     *uint8     `json:"input_decimals,omitempty"`
+	OutputDecimals    *uint8     `json:"output_decimals,omitempty"`
+	FeeLamports       uint64     `json:"fee_lamports"`
+	Slot              uint64     `json:"slot,omitempty"`
+	SettledAt         *time.Time `json:"settled_at,omitempty"`
+}
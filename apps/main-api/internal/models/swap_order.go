@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Swap order kinds
+const (
+	OrderLimit = "limit" // Swap once when the TEAM556 price crosses LimitPrice
+	OrderDCA   = "dca"   // Swap a fixed amount on a cron cadence until EndAt
+)
+
+// Swap order sides, from TEAM556's point of view
+const (
+	OrderBuy  = "buy"  // Spend USDC on TEAM556
+	OrderSell = "sell" // Sell TEAM556 for USDC
+)
+
+// Swap order states
+const (
+	OrderActive    = "active"
+	OrderPaused    = "paused"    // after repeated failures
+	OrderFilled    = "filled"    // limit order executed
+	OrderCompleted = "completed" // DCA plan past its end date
+	OrderExpired   = "expired"   // limit order past its end date without filling
+	OrderCancelled = "cancelled"
+)
+
+// SwapOrder is a limit order or DCA plan between TEAM556 and USDC on one of the user's wallets.
+// Like scheduled transfers, the password is only needed when the order is placed: the wallet
+// approves the scheduler's delegate key for the input token and each execution is a swap the
+// delegate signs, with the output paid into the wallet's own token account.
+type SwapOrder struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	WalletID uint   `gorm:"not null;index" json:"wallet_id"`
+	Wallet   Wallet `gorm:"foreignKey:WalletID" json:"-"`
+
+	Kind           string `gorm:"size:8;not null" json:"kind"`
+	Side           string `gorm:"size:4;not null" json:"side"`
+	InputMint      string `gorm:"size:44;not null" json:"input_mint"`
+	OutputMint     string `gorm:"size:44;not null" json:"output_mint"`
+	InputProgram   string `gorm:"size:44;not null" json:"input_program"`
+	InputDecimals  uint8  `gorm:"not null" json:"input_decimals"`
+	OutputDecimals uint8  `gorm:"not null" json:"output_decimals"`
+	Amount         string `gorm:"size:64;not null" json:"amount"` // UI input amount per execution
+	RawAmount      uint64 `gorm:"not null" json:"raw_amount"`
+	SlippageBps    int    `gorm:"not null" json:"slippage_bps"`
+
+	LimitPrice string     `gorm:"size:32" json:"limit_price,omitempty"` // USD per TEAM556; buys fill at or below it, sells at or above
+	Cadence    string     `gorm:"size:100" json:"cadence,omitempty"`    // DCA only; five-field cron expression, UTC
+	EndAt      time.Time  `gorm:"not null" json:"end_at"`               // Limit orders expire, DCA plans stop
+	NextRunAt  *time.Time `gorm:"index" json:"next_run_at,omitempty"`   // Next evaluation; nil once the order is done
+
+	Status              string `gorm:"size:16;not null;default:active;index" json:"status"`
+	Executions          int    `gorm:"not null;default:0" json:"executions"` // Swaps that landed
+	ConsecutiveFailures int    `gorm:"not null;default:0" json:"consecutive_failures"`
+	LastError           string `gorm:"size:500" json:"last_error,omitempty"`
+	ApprovalSignature   string `gorm:"size:88" json:"approval_signature,omitempty"` // Last approval covering this order
+}
@@ -28,6 +28,15 @@ type User struct {
 	MFASecretEncrypted *string    `json:"-"` // Encrypted TOTP secret (AES-GCM); never expose via JSON
 	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
 
+	// Login throttling: failures before FailedLoginsResetAt are ignored when counting
+	LockedUntil         *time.Time `json:"-"`
+	FailedLoginsResetAt *time.Time `json:"-"`
+
+	// Account deletion: purged after DeletionScheduledFor unless the user logs in first
+	DeletionRequestedAt  *time.Time `json:"deletion_requested_at,omitempty"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty" gorm:"index"`
+	PurgedAt             *time.Time `json:"-"`
+
 	// POS Wallet Addresses for receiving payments
 	PrimaryWalletAddress   string  `json:"primary_wallet_address" gorm:"size:44;default:''"`
 	SecondaryWalletAddress *string `json:"secondary_wallet_address,omitempty" gorm:"size:44"`
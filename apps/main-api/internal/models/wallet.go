@@ -14,9 +14,28 @@ type Wallet struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
-	UserID  uint   `json:"user_id" gorm:"not null;index"` // Foreign key to User
-	Address string `json:"address" gorm:"uniqueIndex;not null"`
+	UserID uint `json:"user_id" gorm:"not null;index;uniqueIndex:idx_wallets_user_address,priority:1"` // Foreign key to User
+	// An address can be owned by one wallet row, but any number of users may watch it
+	Address string `json:"address" gorm:"not null;uniqueIndex:idx_wallets_owned_address,where:watch_only = false;uniqueIndex:idx_wallets_user_address,priority:2"`
 	Name    string `json:"name" gorm:"not null"` // e.g., "My Main Wallet"
+
+	IsDefault  bool       `json:"is_default" gorm:"not null;default:false"` // Used when a request doesn't pick a wallet
+	WatchOnly  bool       `json:"watch_only" gorm:"not null;default:false"` // Address only (e.g. cold storage); no secret, can't sign
+	ArchivedAt *time.Time `json:"archived_at,omitempty" gorm:"index"`       // Hidden from lists and never the default; keys are kept
+
+	// Key derivation. Wallets derived from the same recovery phrase point SeedWalletID at the wallet the
+	// phrase was created with and differ by bip44 account index. An empty path means signer.DefaultDerivationPath.
+	DerivationPath string `json:"derivation_path,omitempty" gorm:"size:64"`
+	AccountIndex   uint32 `json:"account_index" gorm:"not null;default:0"`
+	SeedWalletID   *uint  `json:"seed_wallet_id,omitempty" gorm:"index"`
+
+	// What EncryptedMnemonic holds: "mnemonic" (bip39 phrase) or "private_key" (base58 secret key of an imported wallet)
+	SecretType string     `json:"secret_type" gorm:"size:16;not null;default:mnemonic"`
+	ImportedAt *time.Time `json:"imported_at,omitempty"` // Set for wallets brought in from another app
+
+	// Set when the secret is no longer encrypted with the account password (e.g. after a password reset).
+	// Signing is blocked until the user recovers the wallet with its recovery phrase or previous password.
+	RecoveryRequiredAt *time.Time `json:"recovery_required_at,omitempty"`
 	// Balance could be stored here or derived from transactions/external sources
 	// Balance decimal.Decimal `json:"balance" gorm:"type:numeric;default:0"`
 
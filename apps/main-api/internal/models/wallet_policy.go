@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SpendingPolicySettings are the user-editable limits of a wallet spending policy.
+// Nil limits are unlimited.
+type SpendingPolicySettings struct {
+	DailyLimitUSD    *decimal.Decimal `gorm:"type:numeric(20,2)" json:"daily_limit_usd"`  // Outflow allowed per rolling 24 hours
+	PerTxLimitUSD    *decimal.Decimal `gorm:"type:numeric(20,2)" json:"per_tx_limit_usd"` // Outflow allowed per transaction
+	AllowlistEnabled bool             `gorm:"not null;default:false" json:"allowlist_enabled"`
+	// How long loosening changes and newly allowlisted addresses wait before taking effect
+	ChangeDelayHours int `gorm:"not null;default:24" json:"change_delay_hours"`
+}
+
+// WalletSpendingPolicy limits what /wallet/sign-transaction will sign for one wallet.
+// Tightening changes apply at once; loosening ones are held in Pending until PendingEffectiveAt,
+// so someone who learns the password cannot lift the limits and drain the wallet straight away.
+type WalletSpendingPolicy struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	WalletID uint   `gorm:"not null;uniqueIndex" json:"wallet_id"`
+	Wallet   Wallet `gorm:"foreignKey:WalletID" json:"-"`
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+
+	SpendingPolicySettings `gorm:"embedded"`
+
+	Pending            *SpendingPolicySettings `gorm:"type:jsonb;serializer:json" json:"pending,omitempty"`
+	PendingEffectiveAt *time.Time              `json:"pending_effective_at,omitempty"`
+	// A requested recovery phrase reveal can be completed from then, for a day; it is held like a
+	// loosening change since the phrase lifts every limit
+	PhraseRevealAvailableAt *time.Time `json:"phrase_reveal_available_at,omitempty"`
+}
+
+// WalletAllowlistEntry is a recipient a wallet with an allowlist policy may send to once ActiveAt has passed.
+type WalletAllowlistEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	WalletID uint      `gorm:"not null;uniqueIndex:idx_allowlist_wallet_address" json:"wallet_id"`
+	Wallet   Wallet    `gorm:"foreignKey:WalletID" json:"-"`
+	UserID   uint      `gorm:"not null;index" json:"user_id"`
+	Address  string    `gorm:"size:44;not null;uniqueIndex:idx_allowlist_wallet_address" json:"address"`
+	Label    string    `gorm:"size:50" json:"label,omitempty"`
+	ActiveAt time.Time `gorm:"not null" json:"active_at"`
+}
+
+// WalletSpend records the USD outflow of a transaction signed under a spending policy.
+// Every signature counts towards the daily limit whether or not it was broadcast.
+type WalletSpend struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_wallet_spends_wallet_created,priority:2" json:"created_at"`
+
+	WalletID  uint            `gorm:"not null;index:idx_wallet_spends_wallet_created,priority:1" json:"wallet_id"`
+	UserID    uint            `gorm:"not null;index" json:"user_id"`
+	AmountUSD decimal.Decimal `gorm:"type:numeric(20,2);not null" json:"amount_usd"`
+}
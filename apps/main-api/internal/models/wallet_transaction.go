@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// Wallet transaction types assigned by the history indexer, from the indexed address's point of view
+const (
+	TxTypeSend    = "send"    // value left the address
+	TxTypeReceive = "receive" // value arrived at the address
+	TxTypeSwap    = "swap"    // one token left and another arrived
+	TxTypeOther   = "other"   // no balance change besides fees (approvals, account setup, failed transactions)
+)
+
+// WalletTransaction is one on-chain transaction touching an indexed address. Rows are keyed by
+// address rather than wallet so an address watched by several users is indexed once.
+// Rows are inserted from signature listings and completed (Parsed) once the transaction is fetched.
+type WalletTransaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Address   string     `gorm:"size:44;not null;uniqueIndex:idx_wallet_tx_address_signature;index:idx_wallet_tx_address_slot,priority:1" json:"address"`
+	Signature string     `gorm:"size:88;not null;uniqueIndex:idx_wallet_tx_address_signature" json:"signature"`
+	Slot      uint64     `gorm:"not null;index:idx_wallet_tx_address_slot,priority:2,sort:desc" json:"slot"`
+	BlockTime *time.Time `json:"block_time,omitempty"`
+	Success   bool       `gorm:"not null" json:"success"`
+	Parsed    bool       `gorm:"not null;default:false;index" json:"-"`
+
+	Type         string `gorm:"size:16;not null;default:other;index" json:"type"`
+	Token        string `gorm:"size:44" json:"token"`          // Mint of the primary balance change; "SOL" for native SOL
+	Amount       string `gorm:"size:64" json:"amount"`         // Absolute UI amount of the primary balance change
+	Counterparty string `gorm:"size:44" json:"counterparty"`   // Address on the other side, when one can be identified
+	Fee          uint64 `gorm:"not null;default:0" json:"fee"` // Lamports; only charged to the fee payer
+
+	// Swaps only: the token and amount that arrived (Token/Amount are what left)
+	ReceivedToken  string `gorm:"size:44" json:"received_token,omitempty"`
+	ReceivedAmount string `gorm:"size:64" json:"received_amount,omitempty"`
+}
+
+// WalletIndexState tracks how far the history indexer has got for one address.
+// NewestSignature is the "until" cursor for picking up new transactions; OldestSignature is
+// the "before" cursor the backfill continues from until the address's first transaction. When a run
+// can't reach NewestSignature, ResumeBefore is where the next run continues and PendingNewest
+// becomes NewestSignature once the gap is filled.
+type WalletIndexState struct {
+	Address   string    `gorm:"primaryKey;size:44" json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NewestSignature string     `gorm:"size:88" json:"newest_signature"`
+	OldestSignature string     `gorm:"size:88" json:"oldest_signature"`
+	ResumeBefore    string     `gorm:"size:88" json:"resume_before,omitempty"`
+	PendingNewest   string     `gorm:"size:88" json:"pending_newest,omitempty"`
+	BackfillDone    bool       `gorm:"not null;default:false" json:"backfill_done"`
+	LastSyncedAt    *time.Time `gorm:"index" json:"last_synced_at,omitempty"`
+	LastError       string     `gorm:"type:text" json:"-"`
+}
@@ -0,0 +1,113 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultFXRatesURL serves USD exchange rates without a key.
+const DefaultFXRatesURL = "https://open.er-api.com/v6/latest/USD"
+
+// Currencies prices can be converted to.
+var Currencies = []string{"USD", "EUR", "GBP", "CAD"}
+
+// FXRates converts USD amounts to other currencies. Rates come from URL, which must answer with
+// USD-based rates as {"rates": {"EUR": 0.92, ...}} (open.er-api.com and frankfurter.app both do),
+// and are cached for TTL. While URL is unreachable the last rates are served until they are MaxAge
+// old. Static rates are used for currencies the URL doesn't provide, or when no fetched rates are
+// young enough.
+type FXRates struct {
+	URL    string
+	TTL    time.Duration
+	MaxAge time.Duration // Zero serves fetched rates however old they get
+	Static map[string]decimal.Decimal
+
+	mu        sync.Mutex
+	rates     map[string]decimal.Decimal
+	ratesAt   time.Time // When rates were fetched
+	fetchedAt time.Time // Last fetch attempt
+	now       func() time.Time
+}
+
+func (f *FXRates) clock() time.Time {
+	if f.now != nil {
+		return f.now()
+	}
+	return time.Now()
+}
+
+// ParseStaticRates parses "EUR:0.92,GBP:0.79" into USD-based rates.
+func ParseStaticRates(spec string) (map[string]decimal.Decimal, error) {
+	rates := map[string]decimal.Decimal{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(entry, ":")
+		rate, err := decimal.NewFromString(strings.TrimSpace(value))
+		if !ok || err != nil || !rate.IsPositive() {
+			return nil, fmt.Errorf("invalid rate %q, want CODE:rate", entry)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates, nil
+}
+
+// Rate returns how many units of currency one USD buys.
+func (f *FXRates) Rate(ctx context.Context, currency string) (decimal.Decimal, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "USD" {
+		return decimal.NewFromInt(1), nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var fetchErr error
+	now := f.clock()
+	if f.URL != "" && now.Sub(f.fetchedAt) >= f.TTL {
+		rates, err := f.fetch(ctx)
+		if err == nil {
+			f.rates, f.ratesAt = rates, now
+		}
+		// Failures are retried after TTL too, rather than on every request
+		f.fetchedAt, fetchErr = now, err
+	}
+	if f.MaxAge > 0 && now.Sub(f.ratesAt) > f.MaxAge {
+		f.rates = nil
+	}
+	if rate, ok := f.rates[currency]; ok && rate.IsPositive() {
+		return rate, nil
+	}
+	if rate, ok := f.Static[currency]; ok {
+		return rate, nil
+	}
+	if fetchErr != nil {
+		return decimal.Zero, fmt.Errorf("fx rates: %w", fetchErr)
+	}
+	return decimal.Zero, fmt.Errorf("no exchange rate for %s", currency)
+}
+
+func (f *FXRates) fetch(ctx context.Context) (map[string]decimal.Decimal, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Rates map[string]decimal.Decimal `json:"rates"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Rates) == 0 {
+		return nil, fmt.Errorf("%s returned no rates", f.URL)
+	}
+	return resp.Rates, nil
+}
@@ -0,0 +1,333 @@
+// Package pricing aggregates token USD prices from several sources, with an in-memory cache.
+package pricing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoPrice is returned when no source has a fresh price for a mint and nothing usable is cached.
+var ErrNoPrice = errors.New("no price available")
+
+// Quote is one source's USD price for a mint.
+type Quote struct {
+	USD decimal.Decimal
+	// When the source last updated the price; quotes older than the service's MaxAge are ignored
+	UpdatedAt time.Time
+}
+
+// Source is a price feed. Prices returns what it has for mints, leaving out mints it doesn't know.
+type Source interface {
+	Name() string
+	Prices(ctx context.Context, mints []string) (map[string]Quote, error)
+}
+
+// Price is an aggregated USD price.
+type Price struct {
+	Mint string          `json:"mint"`
+	USD  decimal.Decimal `json:"usd"`
+	// Sources that contributed, joined with "+", e.g. "alchemy+jupiter"
+	Source string `json:"source"`
+	// The oldest update among the contributing quotes
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Set when every source failed and the price is the last one cached
+	Stale bool `json:"stale,omitempty"`
+}
+
+type cacheEntry struct {
+	price     Price
+	fetchedAt time.Time
+	// No source had a price at fetchedAt and none was cached
+	none bool
+}
+
+// Service serves prices from cache, refreshing them from every source at once. The price is the
+// median of the fresh quotes, so a single source going down or drifting doesn't move it far; when
+// no source answers, the last price is served as stale until it is MaxAge old.
+type Service struct {
+	Sources []Source
+	// How long an aggregated price is served before the sources are asked again
+	TTL time.Duration
+	// Quotes older than this are ignored, and stale prices aren't served past it
+	MaxAge time.Duration
+	// Bounds one refresh across all sources
+	Timeout time.Duration
+	// Bounds the cached mints; beyond it the least recently fetched are evicted
+	MaxEntries int
+
+	mu       sync.Mutex
+	cache    map[string]cacheEntry
+	inflight map[string]*refreshFlight // Mints being refreshed, so concurrent misses wait instead of fetching again
+	now      func() time.Time
+}
+
+// refreshFlight is one refresh of a batch of mints; done is closed once they are cached.
+type refreshFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// DefaultMaxEntries bounds the price cache unless the service sets its own limit.
+const DefaultMaxEntries = 10_000
+
+// NewService returns a service over sources.
+func NewService(sources []Source, ttl, maxAge time.Duration) *Service {
+	return &Service{Sources: sources, TTL: ttl, MaxAge: maxAge, Timeout: 10 * time.Second, MaxEntries: DefaultMaxEntries}
+}
+
+func (s *Service) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// Price returns the USD price of mint.
+func (s *Service) Price(ctx context.Context, mint string) (Price, error) {
+	prices, err := s.Prices(ctx, []string{mint})
+	if p, ok := prices[mint]; ok {
+		return p, nil
+	}
+	if err == nil {
+		err = ErrNoPrice
+	}
+	return Price{}, err
+}
+
+// USDPrices returns the USD price of each mint that has one.
+func (s *Service) USDPrices(ctx context.Context, mints []string) (map[string]decimal.Decimal, error) {
+	prices, err := s.Prices(ctx, mints)
+	out := make(map[string]decimal.Decimal, len(prices))
+	for mint, p := range prices {
+		out[mint] = p.USD
+	}
+	return out, err
+}
+
+// Prices returns the prices of mints, leaving out those without one. Mints not cached within TTL
+// are refreshed in one batch per source; mints another call is already refreshing are waited for
+// instead, so unrelated lookups never wait on each other. The error is set only when every source
+// failed; cached and stale prices are still returned alongside it.
+func (s *Service) Prices(ctx context.Context, mints []string) (map[string]Price, error) {
+	prices := make(map[string]Price, len(mints))
+	now := s.clock()
+	var mine []string
+	var flight *refreshFlight
+	waits := map[*refreshFlight][]string{}
+	s.mu.Lock()
+	missing := s.cachedLocked(now, mints, prices)
+	if len(missing) > 0 {
+		flight = &refreshFlight{done: make(chan struct{})}
+		if s.inflight == nil {
+			s.inflight = map[string]*refreshFlight{}
+		}
+		for _, mint := range missing {
+			if other, ok := s.inflight[mint]; ok {
+				waits[other] = append(waits[other], mint)
+				continue
+			}
+			s.inflight[mint] = flight
+			mine = append(mine, mint)
+		}
+	}
+	s.mu.Unlock()
+
+	var err error
+	if len(mine) > 0 {
+		// Finished before waiting on others, so two calls waiting on each other's mints can't deadlock
+		func() {
+			defer s.finish(flight, mine)
+			var refreshed map[string]Price
+			refreshed, err = s.refresh(ctx, mine)
+			s.store(mine, refreshed, prices)
+			flight.err = err
+		}()
+	}
+	for other, wanted := range waits {
+		select {
+		case <-other.done:
+		case <-ctx.Done():
+			return prices, ctx.Err()
+		}
+		s.mu.Lock()
+		if left := s.cachedLocked(s.clock(), wanted, prices); len(left) > 0 && err == nil {
+			err = other.err
+		}
+		s.mu.Unlock()
+	}
+	return prices, err
+}
+
+// finish marks a refresh of mints done, waking whoever waits for it.
+func (s *Service) finish(flight *refreshFlight, mints []string) {
+	s.mu.Lock()
+	for _, mint := range mints {
+		if s.inflight[mint] == flight {
+			delete(s.inflight, mint)
+		}
+	}
+	s.mu.Unlock()
+	close(flight.done)
+}
+
+// store caches the outcome of refreshing mints and adds the prices to prices.
+func (s *Service) store(mints []string, refreshed map[string]Price, prices map[string]Price) {
+	now := s.clock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache == nil {
+		s.cache = map[string]cacheEntry{}
+	}
+	for _, mint := range mints {
+		if p, ok := refreshed[mint]; ok {
+			s.cache[mint] = cacheEntry{price: p, fetchedAt: now}
+			prices[mint] = p
+			continue
+		}
+		// Nothing fresh: fall back to the last price while it is young enough. Either way the
+		// outcome is cached for TTL too, so an outage doesn't send every request to the sources.
+		if e, ok := s.cache[mint]; ok && !e.none && now.Sub(e.price.UpdatedAt) <= s.MaxAge {
+			e.price.Stale = true
+			s.cache[mint] = cacheEntry{price: e.price, fetchedAt: now}
+			prices[mint] = e.price
+			continue
+		}
+		s.cache[mint] = cacheEntry{fetchedAt: now, none: true}
+	}
+	s.evictLocked(now)
+}
+
+// evictLocked brings the cache back under MaxEntries, first dropping entries too old to be served
+// even as stale, then the least recently fetched, down to nine tenths of the limit so eviction
+// doesn't run on every refresh.
+func (s *Service) evictLocked(now time.Time) {
+	if s.MaxEntries <= 0 || len(s.cache) <= s.MaxEntries {
+		return
+	}
+	for mint, e := range s.cache {
+		if now.Sub(e.fetchedAt) >= s.TTL && (e.none || now.Sub(e.price.UpdatedAt) > s.MaxAge) {
+			delete(s.cache, mint)
+		}
+	}
+	target := s.MaxEntries * 9 / 10
+	if len(s.cache) <= target {
+		return
+	}
+	byAge := make([]string, 0, len(s.cache))
+	for mint := range s.cache {
+		byAge = append(byAge, mint)
+	}
+	sort.Slice(byAge, func(i, j int) bool { return s.cache[byAge[i]].fetchedAt.Before(s.cache[byAge[j]].fetchedAt) })
+	for _, mint := range byAge[:len(byAge)-target] {
+		delete(s.cache, mint)
+	}
+}
+
+// cachedLocked adds the mints cached within TTL that have a price to prices, and returns the ones
+// not cached within TTL. s.mu must be held.
+func (s *Service) cachedLocked(now time.Time, mints []string, prices map[string]Price) []string {
+	var missing []string
+	for _, mint := range mints {
+		if _, seen := prices[mint]; seen || slices.Contains(missing, mint) {
+			continue
+		}
+		if e, ok := s.cache[mint]; ok && now.Sub(e.fetchedAt) < s.TTL {
+			if !e.none && (!e.price.Stale || now.Sub(e.price.UpdatedAt) <= s.MaxAge) {
+				prices[mint] = e.price
+			}
+			continue
+		}
+		missing = append(missing, mint)
+	}
+	return missing
+}
+
+// refresh asks every source for mints concurrently and aggregates their fresh quotes.
+func (s *Service) refresh(ctx context.Context, mints []string) (map[string]Price, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	results := make([]map[string]Quote, len(s.Sources))
+	errs := make([]error, len(s.Sources))
+	var wg sync.WaitGroup
+	for i, src := range s.Sources {
+		wg.Add(1)
+		go func(i int, src Source) {
+			defer wg.Done()
+			results[i], errs[i] = src.Prices(ctx, mints)
+			if errs[i] != nil {
+				log.Printf("[pricing] %s: %v", src.Name(), errs[i])
+			}
+		}(i, src)
+	}
+	wg.Wait()
+
+	now := s.clock()
+	prices := make(map[string]Price, len(mints))
+	for _, mint := range mints {
+		var quotes []namedQuote
+		for i, src := range s.Sources {
+			q, ok := results[i][mint]
+			if !ok || !q.USD.IsPositive() || now.Sub(q.UpdatedAt) > s.MaxAge {
+				continue
+			}
+			quotes = append(quotes, namedQuote{src.Name(), q})
+		}
+		if p, ok := aggregate(mint, quotes); ok {
+			prices[mint] = p
+		}
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(s.Sources) {
+		if failed == 0 {
+			return prices, ErrNoPrice
+		}
+		return prices, errors.Join(errs...)
+	}
+	return prices, nil
+}
+
+type namedQuote struct {
+	source string
+	Quote
+}
+
+// aggregate takes the median of quotes; with an even count, the mean of the middle two.
+func aggregate(mint string, quotes []namedQuote) (Price, bool) {
+	if len(quotes) == 0 {
+		return Price{}, false
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].USD.LessThan(quotes[j].USD) })
+	mid := len(quotes) / 2
+	usd := quotes[mid].USD
+	if len(quotes)%2 == 0 {
+		usd = quotes[mid-1].USD.Add(usd).Div(decimal.NewFromInt(2))
+	}
+
+	names := make([]string, len(quotes))
+	updatedAt := quotes[0].UpdatedAt
+	for i, q := range quotes {
+		names[i] = q.source
+		if q.UpdatedAt.Before(updatedAt) {
+			updatedAt = q.UpdatedAt
+		}
+	}
+	sort.Strings(names)
+	return Price{Mint: mint, USD: usd, Source: strings.Join(names, "+"), UpdatedAt: updatedAt}, true
+}
@@ -0,0 +1,257 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type fakeSource struct {
+	name   string
+	quotes map[string]Quote
+	err    error
+	calls  int
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Prices(ctx context.Context, mints []string) (map[string]Quote, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := map[string]Quote{}
+	for _, m := range mints {
+		if q, ok := f.quotes[m]; ok {
+			out[m] = q
+		}
+	}
+	return out, nil
+}
+
+func quote(usd string, at time.Time) Quote {
+	return Quote{USD: decimal.RequireFromString(usd), UpdatedAt: at}
+}
+
+func TestServiceMedianAndStaleness(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	a := &fakeSource{name: "a", quotes: map[string]Quote{"X": quote("1.00", now), "Y": quote("2", now)}}
+	b := &fakeSource{name: "b", quotes: map[string]Quote{"X": quote("1.10", now)}}
+	c := &fakeSource{name: "c", quotes: map[string]Quote{"X": quote("5.00", now)}}
+	old := &fakeSource{name: "old", quotes: map[string]Quote{"Y": quote("9", now.Add(-time.Hour))}}
+	s := NewService([]Source{a, b, c, old}, time.Minute, 5*time.Minute)
+	s.now = func() time.Time { return now }
+
+	prices, err := s.Prices(context.Background(), []string{"X", "Y", "Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := prices["X"]; !x.USD.Equal(decimal.RequireFromString("1.10")) || x.Source != "a+b+c" {
+		t.Errorf("X = %s from %s, want the median 1.10 from a+b+c", x.USD, x.Source)
+	}
+	// The hour-old quote is ignored
+	if y := prices["Y"]; !y.USD.Equal(decimal.NewFromInt(2)) || y.Source != "a" {
+		t.Errorf("Y = %s from %s, want 2 from a", y.USD, y.Source)
+	}
+	if _, ok := prices["Z"]; ok {
+		t.Error("Z has no quotes and should be left out")
+	}
+
+	// Served from cache within TTL, including the miss
+	if _, err := s.Prices(context.Background(), []string{"X", "Z"}); err != nil || a.calls != 1 {
+		t.Errorf("second lookup: err %v, %d source calls, want 1", err, a.calls)
+	}
+}
+
+func TestServiceFallback(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	a := &fakeSource{name: "a", quotes: map[string]Quote{"X": quote("1", now)}}
+	b := &fakeSource{name: "b", quotes: map[string]Quote{"X": quote("3", now)}}
+	s := NewService([]Source{a, b}, time.Minute, 5*time.Minute)
+	s.now = func() time.Time { return now }
+
+	p, err := s.Price(context.Background(), "X")
+	if err != nil || !p.USD.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("Price = %s, %v; want the mean of the two, 2", p.USD, err)
+	}
+
+	// One source down: the other is used
+	now = now.Add(2 * time.Minute)
+	a.err = errors.New("down")
+	b.quotes["X"] = quote("3", now)
+	if p, err = s.Price(context.Background(), "X"); err != nil || !p.USD.Equal(decimal.NewFromInt(3)) || p.Source != "b" || p.Stale {
+		t.Fatalf("Price = %+v, %v; want 3 from b", p, err)
+	}
+
+	// Both down: the last price, marked stale, until it is MaxAge old
+	now = now.Add(2 * time.Minute)
+	b.err = errors.New("down")
+	if p, err = s.Price(context.Background(), "X"); err != nil || !p.USD.Equal(decimal.NewFromInt(3)) || !p.Stale {
+		t.Fatalf("Price = %+v, %v; want stale 3", p, err)
+	}
+	now = now.Add(4 * time.Minute)
+	if _, err = s.Price(context.Background(), "X"); err == nil {
+		t.Fatal("expected an error once the cached price is older than MaxAge")
+	}
+}
+
+// gatedSource holds lookups that include "SLOW" until gate is closed.
+type gatedSource struct {
+	gate    chan struct{}
+	started chan struct{}
+	calls   atomic.Int32
+}
+
+func (g *gatedSource) Name() string { return "gated" }
+
+func (g *gatedSource) Prices(ctx context.Context, mints []string) (map[string]Quote, error) {
+	g.calls.Add(1)
+	out := map[string]Quote{}
+	for _, m := range mints {
+		if m == "SLOW" {
+			g.started <- struct{}{}
+			<-g.gate
+		}
+		out[m] = quote("1", time.Now())
+	}
+	return out, nil
+}
+
+func TestServiceConcurrentMisses(t *testing.T) {
+	src := &gatedSource{gate: make(chan struct{}), started: make(chan struct{}, 1)}
+	s := NewService([]Source{src}, time.Minute, time.Hour)
+
+	var wg sync.WaitGroup
+	slow := func() {
+		defer wg.Done()
+		if _, err := s.Price(context.Background(), "SLOW"); err != nil {
+			t.Errorf("Price(SLOW) error = %v", err)
+		}
+	}
+	wg.Add(1)
+	go slow()
+	<-src.started
+
+	// A different mint is fetched while SLOW is still being refreshed
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Price(context.Background(), "FAST")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Price(FAST) error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Price(FAST) waited on the refresh of another mint")
+	}
+
+	// A second miss on SLOW waits for the refresh in flight instead of fetching again
+	wg.Add(1)
+	go slow()
+	time.Sleep(50 * time.Millisecond)
+	close(src.gate)
+	wg.Wait()
+	if got := src.calls.Load(); got != 2 {
+		t.Errorf("source called %d times, want 2 (SLOW once, FAST once)", got)
+	}
+}
+
+func TestServiceEviction(t *testing.T) {
+	now := time.Now()
+	quotes := map[string]Quote{}
+	for i := 0; i < 20; i++ {
+		quotes["M"+strconv.Itoa(i)] = quote("1", now)
+	}
+	s := NewService([]Source{&fakeSource{name: "a", quotes: quotes}}, time.Minute, time.Hour)
+	s.MaxEntries = 10
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		if _, err := s.Price(context.Background(), "M"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("Price(M%d) error = %v", i, err)
+		}
+		if len(s.cache) > s.MaxEntries {
+			t.Fatalf("cache holds %d mints, want at most %d", len(s.cache), s.MaxEntries)
+		}
+	}
+	if _, ok := s.cache["M19"]; !ok {
+		t.Error("the most recently fetched mint was evicted")
+	}
+	if _, ok := s.cache["M0"]; ok {
+		t.Error("the least recently fetched mint is still cached")
+	}
+}
+
+func TestFXRates(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"result":"success","base_code":"USD","rates":{"USD":1,"EUR":0.92,"GBP":0.79}}`))
+	}))
+	defer srv.Close()
+
+	static, err := ParseStaticRates("cad:1.37, EUR:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fx := &FXRates{URL: srv.URL, TTL: time.Hour, Static: static}
+	for currency, want := range map[string]string{"USD": "1", "eur": "0.92", "GBP": "0.79", "CAD": "1.37"} {
+		rate, err := fx.Rate(context.Background(), currency)
+		if err != nil || !rate.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("Rate(%s) = %s, %v; want %s", currency, rate, err, want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("rates fetched %d times, want once within TTL", calls)
+	}
+	if _, err := fx.Rate(context.Background(), "JPY"); err == nil {
+		t.Error("expected an error for a currency without a rate")
+	}
+	if _, err := ParseStaticRates("EUR"); err == nil {
+		t.Error("expected an error for a rate without a value")
+	}
+}
+
+func TestFXRatesMaxAge(t *testing.T) {
+	failing := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"rates":{"USD":1,"EUR":0.92,"GBP":0.79}}`))
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	fx := &FXRates{URL: srv.URL, TTL: time.Hour, MaxAge: 24 * time.Hour, Static: map[string]decimal.Decimal{"EUR": decimal.RequireFromString("0.9")}}
+	fx.now = func() time.Time { return now }
+	if _, err := fx.Rate(context.Background(), "GBP"); err != nil {
+		t.Fatalf("Rate(GBP) error = %v", err)
+	}
+
+	// While the endpoint is down the last rates are served until they are MaxAge old
+	failing = true
+	now = now.Add(2 * time.Hour)
+	if rate, err := fx.Rate(context.Background(), "GBP"); err != nil || !rate.Equal(decimal.RequireFromString("0.79")) {
+		t.Errorf("Rate(GBP) during outage = %s, %v; want the last rate 0.79", rate, err)
+	}
+	now = now.Add(24 * time.Hour)
+	if _, err := fx.Rate(context.Background(), "GBP"); err == nil {
+		t.Error("expected an error once the last rates are older than MaxAge")
+	}
+	if rate, err := fx.Rate(context.Background(), "EUR"); err != nil || !rate.Equal(decimal.RequireFromString("0.9")) {
+		t.Errorf("Rate(EUR) = %s, %v; want the static fallback 0.9", rate, err)
+	}
+}
@@ -0,0 +1,242 @@
+package pricing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/team556-mono/server/internal/solanarpc"
+)
+
+const (
+	// alchemyMaxAddresses is the most token addresses Alchemy accepts in one by-address request.
+	alchemyMaxAddresses = 25
+	alchemyNetwork      = "solana-mainnet"
+	// jupiterMaxIDs is the most mints the Jupiter price API takes in one request.
+	jupiterMaxIDs = 50
+	// DefaultJupiterPriceURL is Jupiter's keyless price endpoint.
+	DefaultJupiterPriceURL = "https://lite-api.jup.ag/price/v3"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// getJSON sends req and decodes a 200 response into out.
+func getJSON(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// AlchemySource reads Alchemy's token prices by address.
+type AlchemySource struct {
+	APIKey string
+}
+
+func (a *AlchemySource) Name() string { return "alchemy" }
+
+func (a *AlchemySource) Prices(ctx context.Context, mints []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote, len(mints))
+	apiURL := fmt.Sprintf("https://api.g.alchemy.com/prices/v1/%s/tokens/by-address", a.APIKey)
+	for start := 0; start < len(mints); start += alchemyMaxAddresses {
+		end := min(start+alchemyMaxAddresses, len(mints))
+		type address struct {
+			Network string `json:"network"`
+			Address string `json:"address"`
+		}
+		var request struct {
+			Addresses []address `json:"addresses"`
+		}
+		for _, mint := range mints[start:end] {
+			request.Addresses = append(request.Addresses, address{alchemyNetwork, mint})
+		}
+		payload, err := json.Marshal(request)
+		if err != nil {
+			return quotes, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(payload))
+		if err != nil {
+			return quotes, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var resp struct {
+			Data []struct {
+				Address      string `json:"address"`
+				TokenAddress string `json:"tokenAddress"`
+				Prices       []struct {
+					Currency      string `json:"currency"`
+					Value         string `json:"value"`
+					LastUpdatedAt string `json:"lastUpdatedAt"`
+				} `json:"prices"`
+			} `json:"data"`
+		}
+		if err := getJSON(req, &resp); err != nil {
+			return quotes, err
+		}
+		for _, token := range resp.Data {
+			mint := token.Address
+			if mint == "" {
+				mint = token.TokenAddress
+			}
+			for _, p := range token.Prices {
+				if !strings.EqualFold(p.Currency, "usd") {
+					continue
+				}
+				if usd, err := decimal.NewFromString(p.Value); err == nil {
+					updatedAt, err := time.Parse(time.RFC3339, p.LastUpdatedAt)
+					if err != nil {
+						updatedAt = time.Now()
+					}
+					quotes[mint] = Quote{USD: usd, UpdatedAt: updatedAt}
+				}
+				break
+			}
+		}
+	}
+	return quotes, nil
+}
+
+// JupiterSource reads Jupiter's price API, which derives prices from recent swaps.
+type JupiterSource struct {
+	URL string
+}
+
+func (j *JupiterSource) Name() string { return "jupiter" }
+
+func (j *JupiterSource) Prices(ctx context.Context, mints []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote, len(mints))
+	for start := 0; start < len(mints); start += jupiterMaxIDs {
+		end := min(start+jupiterMaxIDs, len(mints))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL+"?ids="+url.QueryEscape(strings.Join(mints[start:end], ",")), nil)
+		if err != nil {
+			return quotes, err
+		}
+		var resp map[string]*struct {
+			USDPrice json.Number `json:"usdPrice"`
+		}
+		if err := getJSON(req, &resp); err != nil {
+			return quotes, err
+		}
+		now := time.Now()
+		for mint, p := range resp {
+			if p == nil {
+				continue
+			}
+			if usd, err := decimal.NewFromString(p.USDPrice.String()); err == nil {
+				quotes[mint] = Quote{USD: usd, UpdatedAt: now}
+			}
+		}
+	}
+	return quotes, nil
+}
+
+// Pool is an AMM pool pairing a token with USDC, read through its two reserve vaults.
+type Pool struct {
+	Mint       string
+	BaseVault  string // Token account holding the pool's Mint reserve
+	QuoteVault string // Token account holding the pool's USDC reserve
+}
+
+// ParsePools parses "mint:baseVault:quoteVault" entries separated by commas.
+func ParsePools(spec string) ([]Pool, error) {
+	var pools []Pool
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid pool %q, want mint:baseVault:quoteVault", entry)
+		}
+		pools = append(pools, Pool{Mint: parts[0], BaseVault: parts[1], QuoteVault: parts[2]})
+	}
+	return pools, nil
+}
+
+// PoolSource prices tokens from the reserves of their USDC pools on chain, taking USDC at $1.
+// It only knows the mints it has a pool for.
+type PoolSource struct {
+	Pools []Pool
+}
+
+func (p *PoolSource) Name() string { return "pool" }
+
+func (p *PoolSource) Prices(ctx context.Context, mints []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote, len(mints))
+	var pools []Pool
+	var vaults []string
+	for _, pool := range p.Pools {
+		for _, mint := range mints {
+			if pool.Mint == mint {
+				pools = append(pools, pool)
+				vaults = append(vaults, pool.BaseVault, pool.QuoteVault)
+				break
+			}
+		}
+	}
+	if len(vaults) == 0 {
+		return quotes, nil
+	}
+
+	var res struct {
+		Value []*struct {
+			Data struct {
+				Parsed struct {
+					Info struct {
+						TokenAmount struct {
+							Amount   string `json:"amount"`
+							Decimals int32  `json:"decimals"`
+						} `json:"tokenAmount"`
+					} `json:"info"`
+				} `json:"parsed"`
+			} `json:"data"`
+		} `json:"value"`
+	}
+	params := []any{vaults, map[string]any{"encoding": "jsonParsed", "commitment": "confirmed"}}
+	if err := solanarpc.CallContext(ctx, "getMultipleAccounts", params, &res); err != nil {
+		return quotes, err
+	}
+	if len(res.Value) != len(vaults) {
+		return quotes, errors.New("getMultipleAccounts returned the wrong number of accounts")
+	}
+	reserve := func(i int) decimal.Decimal {
+		if res.Value[i] == nil {
+			return decimal.Zero
+		}
+		amount := res.Value[i].Data.Parsed.Info.TokenAmount
+		d, err := decimal.NewFromString(amount.Amount)
+		if err != nil {
+			return decimal.Zero
+		}
+		return d.Shift(-amount.Decimals)
+	}
+	now := time.Now()
+	for i, pool := range pools {
+		base, quote := reserve(2*i), reserve(2*i+1)
+		if base.IsPositive() && quote.IsPositive() {
+			quotes[pool.Mint] = Quote{USD: quote.DivRound(base, 18), UpdatedAt: now}
+		}
+	}
+	return quotes, nil
+}
@@ -0,0 +1,94 @@
+// Package push sends notifications to devices registered through /api/notifications/push/devices,
+// which hold Expo push tokens.
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultURL is Expo's push API.
+const DefaultURL = "https://exp.host/--/api/v2/push/send"
+
+// maxBatch is the most messages Expo accepts in one request.
+const maxBatch = 100
+
+// Message is a notification for one device.
+type Message struct {
+	To    string         `json:"to"`
+	Title string         `json:"title"`
+	Body  string         `json:"body"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// Client sends messages through the Expo push API.
+type Client struct {
+	URL         string
+	AccessToken string // Only needed when the Expo project requires authenticated pushes
+
+	http *http.Client
+}
+
+// NewClient returns a client for url, or Expo's API when url is empty.
+func NewClient(url, accessToken string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{URL: url, AccessToken: accessToken, http: &http.Client{Timeout: 15 * time.Second}}
+}
+
+type ticket struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+// Send delivers messages and returns the tokens Expo reports as no longer registered, which should
+// be deactivated. Other per-message errors are not reported; push is best effort.
+func (c *Client) Send(ctx context.Context, messages []Message) ([]string, error) {
+	var unregistered []string
+	for start := 0; start < len(messages); start += maxBatch {
+		batch := messages[start:min(start+maxBatch, len(messages))]
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return unregistered, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+		if err != nil {
+			return unregistered, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		if c.AccessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return unregistered, err
+		}
+		var out struct {
+			Data []ticket `json:"data"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return unregistered, fmt.Errorf("push API returned status %d", resp.StatusCode)
+		}
+		if err != nil {
+			return unregistered, fmt.Errorf("decoding push API response: %w", err)
+		}
+		// Tickets come back in message order
+		for i, t := range out.Data {
+			if i < len(batch) && t.Status == "error" && t.Details.Error == "DeviceNotRegistered" {
+				unregistered = append(unregistered, batch[i].To)
+			}
+		}
+	}
+	return unregistered, nil
+}
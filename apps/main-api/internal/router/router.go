@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/team556-mono/server/internal/config"
 	"github.com/team556-mono/server/internal/email"
+	"github.com/team556-mono/server/internal/events"
 	"github.com/team556-mono/server/internal/handlers"
 	"github.com/team556-mono/server/internal/middleware"
 	"github.com/team556-mono/server/internal/security"
@@ -14,7 +15,7 @@ import (
 	"time"
 )
 
-func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *email.Client) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *email.Client, hub *events.Hub) {
 	// Middleware
 	app.Use(logger.New())
 
@@ -28,7 +29,7 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 	api := app.Group("/api")
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret, emailClient)
+	authHandler := handlers.NewAuthHandler(db, cfg, emailClient)
 	swapHandler := handlers.NewSwapHandler(db, cfg)
 	priceHandler := handlers.NewPriceHandler(cfg)
 	presaleHandler := handlers.NewPresaleHandler(db, cfg.JWTSecret, cfg.SolanaAPIURL)
@@ -37,15 +38,15 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 	// Groups Routes
 	auth := api.Group("/auth")
 	wallet := api.Group("/wallet")
-	swap := api.Group("/swap", middleware.AuthMiddleware(cfg.JWTSecret))
-	firearms := api.Group("/firearms", middleware.AuthMiddleware(cfg.JWTSecret))
-	ammos := api.Group("/ammos", middleware.AuthMiddleware(cfg.JWTSecret))
-	gear := api.Group("/gear", middleware.AuthMiddleware(cfg.JWTSecret))
-	presale := api.Group("/presale", middleware.AuthMiddleware(cfg.JWTSecret))
-	distributorsGroup := api.Group("/distributors", middleware.AuthMiddleware(cfg.JWTSecret))
-	distConnGroup := api.Group("/distributor-connections", middleware.AuthMiddleware(cfg.JWTSecret))
-	notifications := api.Group("/notifications", middleware.AuthMiddleware(cfg.JWTSecret))
-	referrals := api.Group("/referrals", middleware.AuthMiddleware(cfg.JWTSecret))
+	swap := api.Group("/swap", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	firearms := api.Group("/firearms", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	ammos := api.Group("/ammos", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	gear := api.Group("/gear", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	presale := api.Group("/presale", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	distributorsGroup := api.Group("/distributors", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	distConnGroup := api.Group("/distributor-connections", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	notifications := api.Group("/notifications", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	referrals := api.Group("/referrals", middleware.AuthMiddleware(cfg.JWTSecret, db))
 	v1 := api.Group("/v1")
 
 	// Public, Rate-Limited Routes
@@ -62,7 +63,22 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 			})
 		},
 	})
-	api.Get("/price/team556-usdc", priceLimiter, priceHandler.HandleGetTeam556UsdcPriceAlchemy)
+	api.Get("/price/team556-usdc", priceLimiter, priceHandler.HandleGetTeam556UsdcPrice)
+	// Price history and token prices (unauthenticated; charts and the POS poll more often than the plugin)
+	pricesLimiter := limiter.New(limiter.Config{
+		Max:        60,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests, please try again later.",
+			})
+		},
+	})
+	api.Get("/price/history", pricesLimiter, handlers.PriceHistoryHandler(db))
+	api.Get("/price/tokens", pricesLimiter, priceHandler.HandleGetTokenPrices)
 	
 	// Public referral validation endpoint (for signup process)
 	api.Post("/referrals/validate", referralHandler.ValidateReferralCode)
@@ -71,14 +87,18 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/signup", authHandler.Register)
 	auth.Post("/login", limiter.New(security.SensitiveLimiter(10, time.Minute)), authHandler.Login)
+	auth.Get("/unlock", limiter.New(security.SensitiveLimiter(10, time.Minute)), authHandler.UnlockAccountPage)
+	auth.Post("/unlock", limiter.New(security.SensitiveLimiter(10, time.Minute)), authHandler.UnlockAccount)
 	auth.Post("/logout", authHandler.Logout)
-	auth.Get("/me", middleware.AuthMiddleware(cfg.JWTSecret), authHandler.GetMe)
-	auth.Post("/verify-email", middleware.AuthMiddleware(cfg.JWTSecret), authHandler.VerifyEmail)
-	auth.Post("/resend-verification", middleware.AuthMiddleware(cfg.JWTSecret), authHandler.ResendVerificationEmail)
-	auth.Post("/delete-account", middleware.AuthMiddleware(cfg.JWTSecret), authHandler.DeleteAccount)
+	auth.Get("/me", middleware.AuthMiddleware(cfg.JWTSecret, db), authHandler.GetMe)
+	auth.Post("/verify-email", middleware.AuthMiddleware(cfg.JWTSecret, db), authHandler.VerifyEmail)
+	auth.Post("/resend-verification", middleware.AuthMiddleware(cfg.JWTSecret, db), authHandler.ResendVerificationEmail)
+	auth.Post("/delete-account", middleware.AuthMiddleware(cfg.JWTSecret, db), authHandler.DeleteAccount)
 
 	// Security routes under /me
-	me := api.Group("/me", middleware.AuthMiddleware(cfg.JWTSecret))
+	me := api.Group("/me", middleware.AuthMiddleware(cfg.JWTSecret, db))
+	// Live updates (server-sent events); the limiter only bounds reconnects
+	me.Get("/stream", limiter.New(security.SensitiveLimiter(20, time.Minute)), handlers.StreamHandler(cfg, hub))
 	secHandler := handlers.NewSecurityHandler(db, cfg, emailClient)
 	me.Get("/security", secHandler.GetSecurityOverview)
 	// Apply rate limiting for sensitive routes
@@ -88,6 +108,12 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 	me.Post("/mfa/verify", limiter.New(security.SensitiveLimiter(20, time.Minute)), secHandler.VerifyMFA)
 	me.Delete("/mfa", limiter.New(security.SensitiveLimiter(5, time.Minute)), secHandler.DisableMFA)
 	me.Post("/mfa/recovery/rotate", limiter.New(security.SensitiveLimiter(5, time.Minute)), secHandler.RotateRecoveryCodes)
+	me.Post("/email", limiter.New(security.SensitiveLimiter(5, time.Minute)), secHandler.RequestEmailChange)
+	me.Post("/email/confirm", limiter.New(security.SensitiveLimiter(10, time.Minute)), secHandler.ConfirmEmailChange)
+	auth.Get("/email-change/cancel", limiter.New(security.SensitiveLimiter(10, time.Minute)), secHandler.CancelEmailChange)
+	me.Post("/export", limiter.New(security.SensitiveLimiter(3, time.Hour)), secHandler.RequestDataExport)
+	me.Get("/export", secHandler.GetDataExport)
+	api.Get("/exports/download", limiter.New(security.SensitiveLimiter(10, time.Minute)), secHandler.DownloadDataExport)
 	me.Get("/sessions", secHandler.ListSessions)
 	me.Delete("/sessions/:id", secHandler.RevokeSession)
 
@@ -109,25 +135,58 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config, emailClient *e
 notifications.Post("/resend-verification", authHandler.ResendVerificationEmail)
 	notifications.Post("/push/devices", notificationHandler.RegisterPushDevice)
 	notifications.Delete("/push/devices", notificationHandler.UnregisterPushDevice)
+	notifications.Get("/price-alerts", handlers.ListPriceAlertsHandler(db))
+	notifications.Post("/price-alerts", handlers.CreatePriceAlertHandler(db, cfg))
+	notifications.Put("/price-alerts/:id", handlers.UpdatePriceAlertHandler(db, cfg))
+	notifications.Delete("/price-alerts/:id", handlers.DeletePriceAlertHandler(db))
 	// Password Reset Routes
 	auth.Post("/request-password-reset", authHandler.RequestPasswordReset)
 	auth.Post("/reset-password", authHandler.ResetPassword)
 
 	// Wallet Routes
-	wallet.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	wallet.Use(middleware.AuthMiddleware(cfg.JWTSecret, db))
 	wallet.Post("/create", handlers.CreateWalletHandler(db, cfg))
+	wallet.Get("/list", handlers.ListWalletsHandler(db))
+	wallet.Post("/accounts", handlers.CreateWalletAccountHandler(db))
+	wallet.Post("/watch", handlers.AddWatchOnlyWalletHandler(db))
+	wallet.Get("/portfolio", handlers.GetPortfolioHandler(db, cfg))
+	wallet.Post("/import/preview", limiter.New(security.SensitiveLimiter(20, time.Minute)), handlers.PreviewWalletImportHandler(db))
+	wallet.Post("/import", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.ImportWalletHandler(db, cfg))
+	wallet.Patch("/:id", handlers.RenameWalletHandler(db))
+	wallet.Post("/:id/default", handlers.SetDefaultWalletHandler(db))
+	wallet.Post("/:id/archive", handlers.ArchiveWalletHandler(db))
+	wallet.Post("/:id/restore", handlers.RestoreWalletHandler(db))
+	wallet.Get("/contacts", handlers.ListContactsHandler(db))
+	wallet.Post("/contacts", handlers.CreateContactHandler(db))
+	wallet.Post("/contacts/check", handlers.CheckRecipientHandler(db))
+	wallet.Patch("/contacts/:id", handlers.UpdateContactHandler(db))
+	wallet.Delete("/contacts/:id", handlers.DeleteContactHandler(db))
+	wallet.Get("/scheduled-transfers", handlers.ListScheduledTransfersHandler(db))
+	wallet.Post("/scheduled-transfers", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.CreateScheduledTransferHandler(db, cfg))
+	wallet.Get("/scheduled-transfers/:id", handlers.GetScheduledTransferHandler(db))
+	wallet.Post("/scheduled-transfers/:id/pause", handlers.PauseScheduledTransferHandler(db))
+	wallet.Post("/scheduled-transfers/:id/resume", handlers.ResumeScheduledTransferHandler(db))
+	wallet.Delete("/scheduled-transfers/:id", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.CancelScheduledTransferHandler(db, cfg))
+	wallet.Get("/:id/policy", handlers.GetSpendingPolicyHandler(db))
+	wallet.Put("/:id/policy", handlers.UpdateSpendingPolicyHandler(db))
+	wallet.Delete("/:id/policy/pending", handlers.CancelPendingPolicyChangeHandler(db))
+	wallet.Post("/:id/allowlist", handlers.AddAllowlistEntryHandler(db))
+	wallet.Delete("/:id/allowlist/:entryId", handlers.RemoveAllowlistEntryHandler(db))
+	wallet.Post("/:id/recover", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.RecoverWalletHandler(db, cfg))
 	wallet.Get("/balance", handlers.GetWalletBalanceHandler(db, cfg))
 	wallet.Get("/balance/team", handlers.GetWalletTeamTokenBalanceHandler(db, cfg))
 	wallet.Post("/presale/check", handlers.CheckPresaleCode(db))
 	wallet.Post("/presale/redeem", handlers.RedeemPresaleCode(db))
+	wallet.Post("/inspect-transaction", handlers.InspectTransactionHandler(db, cfg))
+	wallet.Post("/priority-fees", handlers.PriorityFeesHandler())
 	wallet.Post("/sign-transaction", handlers.SignTransactionHandler(db, cfg))
 	wallet.Post("/send-transaction", handlers.SendTransactionHandler(db, cfg))
 	wallet.Post("/transactions", handlers.GetTransactionsHandler(db, cfg))
 	wallet.Post("/webhook", handlers.SendWebhookHandler(db, cfg))
-	wallet.Post("/recovery-phrase", handlers.GetRecoveryPhraseHandler(db))
+	wallet.Post("/recovery-phrase", handlers.GetRecoveryPhraseHandler(db, emailClient))
 
 	// POS Wallet Routes (for configuring receiving addresses)
-	posWallet := api.Group("/pos-wallet", middleware.AuthMiddleware(cfg.JWTSecret))
+	posWallet := api.Group("/pos-wallet", middleware.AuthMiddleware(cfg.JWTSecret, db))
 	posWallet.Get("/addresses", handlers.GetPOSWalletAddressesHandler(db))
 	posWallet.Patch("/primary", handlers.UpdatePrimaryWalletAddressHandler(db))
 	posWallet.Patch("/secondary", handlers.UpdateSecondaryWalletAddressHandler(db))
@@ -138,6 +197,12 @@ notifications.Post("/resend-verification", authHandler.ResendVerificationEmail)
 	swap.Post("/quote", swapHandler.HandleGetSwapQuote)
 	swap.Post("/execute", swapHandler.HandleExecuteSwap)
 	swap.Post("/create-token-accounts", swapHandler.HandleCreateTokenAccounts)
+	swap.Get("/history", swapHandler.HandleGetSwapHistory)
+	swap.Get("/orders", handlers.ListSwapOrdersHandler(db))
+	swap.Post("/orders", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.CreateSwapOrderHandler(db, cfg))
+	swap.Get("/orders/:id", handlers.GetSwapOrderHandler(db))
+	swap.Post("/orders/:id/resume", handlers.ResumeSwapOrderHandler(db))
+	swap.Delete("/orders/:id", limiter.New(security.SensitiveLimiter(5, time.Minute)), handlers.CancelSwapOrderHandler(db, cfg))
 
 	// --- Firearm Routes ---
 	firearms.Post("/", handlers.CreateFirearmHandler(db, cfg))
@@ -161,7 +226,7 @@ notifications.Post("/resend-verification", authHandler.ResendVerificationEmail)
 	gear.Delete("/:id", handlers.DeleteGearHandler(db, cfg))
 
 	// --- Documents Routes ---
-	documents := api.Group("/documents", middleware.AuthMiddleware(cfg.JWTSecret))
+	documents := api.Group("/documents", middleware.AuthMiddleware(cfg.JWTSecret, db))
 	documents.Post("/", handlers.CreateDocumentHandler(db, cfg))
 	documents.Get("/", handlers.GetDocumentsHandler(db, cfg))
 	documents.Get("/:id", handlers.GetDocumentByIDHandler(db, cfg))
@@ -169,7 +234,7 @@ notifications.Post("/resend-verification", authHandler.ResendVerificationEmail)
 	documents.Delete("/:id", handlers.DeleteDocumentHandler(db, cfg))
 
 	// --- NFA Routes ---
-	nfa := api.Group("/nfa", middleware.AuthMiddleware(cfg.JWTSecret))
+	nfa := api.Group("/nfa", middleware.AuthMiddleware(cfg.JWTSecret, db))
 	nfa.Post("/", handlers.CreateNFAHandler(db, cfg))
 	nfa.Get("/", handlers.GetNFAItemsHandler(db, cfg))
 	nfa.Get("/:id", handlers.GetNFAByIDHandler(db, cfg))
@@ -193,5 +258,6 @@ notifications.Post("/resend-verification", authHandler.ResendVerificationEmail)
 	api.Post("/solana/rpc", handlers.SolanaRpcProxy) // direct without version prefix to match existing clients
 
 	// Payment request helper
-	v1.Post("/solana/payment-request", handlers.HandleCreateSolanaPaymentRequest)
+	// Unauthenticated (called by merchants' WP plugins) and records rows, so rate-limited per IP
+	v1.Post("/solana/payment-request", limiter.New(security.SensitiveLimiter(30, time.Minute)), handlers.HandleCreateSolanaPaymentRequest(db))
 }
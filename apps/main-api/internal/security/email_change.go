@@ -0,0 +1,204 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/models"
+)
+
+// EmailChangeCancelPurpose identifies cancel-link tokens sent to the old address.
+const EmailChangeCancelPurpose = "email_change_cancel"
+
+const (
+	// EmailChangeTTL is how long a pending change (and its cancel link) stays valid.
+	EmailChangeTTL         = 30 * time.Minute
+	emailChangeMaxAttempts = 5
+)
+
+var (
+	ErrEmailInUse           = errors.New("email address is already in use")
+	ErrEmailUnchanged       = errors.New("new email matches the current email")
+	ErrNoPendingEmailChange = errors.New("no pending email change")
+	ErrInvalidEmailCode     = errors.New("invalid confirmation code")
+)
+
+func randomNumericCode(n int) (string, error) {
+	var b strings.Builder
+	ten := big.NewInt(10)
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte('0' + byte(d.Int64()))
+	}
+	return b.String(), nil
+}
+
+func auditEmailChange(tx *gorm.DB, userID uint, action, ip string, meta map[string]any) error {
+	raw, _ := json.Marshal(meta)
+	ipCopy := ip
+	return tx.Create(&models.SecurityAuditLog{UserID: userID, Action: action, IP: &ipCopy, Meta: datatypes.JSON(raw)}).Error
+}
+
+// BeginEmailChange supersedes any pending request and creates a new one.
+// It returns the request and the plaintext code to send to the new address.
+func BeginEmailChange(db *gorm.DB, user *models.User, newEmail, ip string) (*models.EmailChangeRequest, string, error) {
+	newEmail = strings.ToLower(strings.TrimSpace(newEmail))
+	if newEmail == strings.ToLower(user.Email) {
+		return nil, "", ErrEmailUnchanged
+	}
+	// Unscoped: soft-deleted accounts still hold their address in the unique index.
+	var count int64
+	if err := db.Unscoped().Model(&models.User{}).Where("email = ?", newEmail).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count > 0 {
+		return nil, "", ErrEmailInUse
+	}
+
+	code, err := randomNumericCode(6)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req := models.EmailChangeRequest{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		CodeHash:  string(hash),
+		ExpiresAt: time.Now().Add(EmailChangeTTL),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.EmailChangeRequest{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", user.ID).
+			Update("cancelled_at", &now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&req).Error; err != nil {
+			return err
+		}
+		return auditEmailChange(tx, user.ID, "email_change_requested", ip, map[string]any{"new_email": newEmail})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &req, code, nil
+}
+
+func pendingEmailChange(db *gorm.DB, userID uint) (*models.EmailChangeRequest, error) {
+	var req models.EmailChangeRequest
+	err := db.Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPendingEmailChange
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ConfirmEmailChange verifies the code and swaps the address. Sessions other than
+// keepSessionID are revoked and a matching NotificationSettings.ContactEmail follows the change.
+func ConfirmEmailChange(db *gorm.DB, userID uint, code string, keepSessionID uint, ip string) (*models.EmailChangeRequest, error) {
+	req, err := pendingEmailChange(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Count the attempt before checking the code, in one conditional update, so concurrent
+	// guesses can't share a count and get past the cap
+	res := db.Model(&models.EmailChangeRequest{}).
+		Where("id = ? AND attempts < ? AND cancelled_at IS NULL", req.ID, emailChangeMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidEmailCode
+	}
+	if bcrypt.CompareHashAndPassword([]byte(req.CodeHash), []byte(code)) != nil {
+		_ = db.Model(&models.EmailChangeRequest{}).
+			Where("id = ? AND attempts >= ? AND cancelled_at IS NULL", req.ID, emailChangeMaxAttempts).
+			Update("cancelled_at", time.Now()).Error
+		return nil, ErrInvalidEmailCode
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", req.NewEmail, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailInUse
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("email", req.NewEmail).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(req).Update("confirmed_at", &now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.NotificationSettings{}).
+			Where("user_id = ? AND LOWER(contact_email) = ?", userID, strings.ToLower(req.OldEmail)).
+			Update("contact_email", req.NewEmail).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND id <> ? AND is_revoked = false", userID, keepSessionID).
+			Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+		return auditEmailChange(tx, userID, "email_changed", ip, map[string]any{"old_email": req.OldEmail, "new_email": req.NewEmail})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// CancelEmailChange cancels the pending request referenced by a cancel-link token.
+func CancelEmailChange(db *gorm.DB, secret, token, ip string) (*models.EmailChangeRequest, error) {
+	claims, err := ParseLinkToken(secret, EmailChangeCancelPurpose, token)
+	if err != nil {
+		return nil, errors.New("invalid or expired cancel link")
+	}
+	var req models.EmailChangeRequest
+	if err := db.Where("id = ? AND user_id = ?", claims.Ref, claims.UID).First(&req).Error; err != nil {
+		return nil, err
+	}
+	if req.ConfirmedAt != nil || req.CancelledAt != nil {
+		return nil, ErrNoPendingEmailChange
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&req).Update("cancelled_at", &now).Error; err != nil {
+			return err
+		}
+		return auditEmailChange(tx, req.UserID, "email_change_cancelled", ip, map[string]any{"new_email": req.NewEmail})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// EmailChangeRef binds a cancel link to a single request.
+func EmailChangeRef(req *models.EmailChangeRequest) string {
+	return strconv.FormatUint(uint64(req.ID), 10)
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LinkClaims are carried by signed links sent over email (account unlock, cancel links, downloads).
+// Ref binds the link to a piece of server state so it stops working once that state changes.
+type LinkClaims struct {
+	Purpose string `json:"purpose"`
+	UID     uint   `json:"uid"`
+	Ref     string `json:"ref,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// linkKey derives a signing key distinct from the session JWT key so a link token
+// can never be replayed as a Bearer token.
+func linkKey(secret string) []byte {
+	sum := sha256.Sum256([]byte("team556-link-token:" + secret))
+	return sum[:]
+}
+
+// IssueLinkToken signs a short-lived token for an emailed link.
+func IssueLinkToken(secret, purpose string, userID uint, ref string, ttl time.Duration) (string, error) {
+	if secret == "" {
+		return "", errors.New("link signing secret not configured")
+	}
+	now := time.Now()
+	claims := LinkClaims{
+		Purpose: purpose,
+		UID:     userID,
+		Ref:     ref,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(linkKey(secret))
+}
+
+// ParseLinkToken validates signature, expiry and purpose of a link token.
+func ParseLinkToken(secret, purpose, token string) (*LinkClaims, error) {
+	var claims LinkClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return linkKey(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !parsed.Valid || claims.Purpose != purpose || claims.UID == 0 {
+		return nil, errors.New("invalid link token")
+	}
+	return &claims, nil
+}
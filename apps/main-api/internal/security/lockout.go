@@ -0,0 +1,266 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/team556-mono/server/internal/config"
+	"github.com/team556-mono/server/internal/models"
+)
+
+// UnlockLinkPurpose identifies account unlock link tokens.
+const UnlockLinkPurpose = "account_unlock"
+
+// LockoutPolicy controls per-account login throttling, independent of the per-IP limiter.
+type LockoutPolicy struct {
+	Window        time.Duration // failures older than this are not counted
+	DelayAfter    int           // failures allowed before progressive delays kick in
+	BaseDelay     time.Duration // first delay; doubles with every further failure
+	MaxDelay      time.Duration
+	LockThreshold int // failures within Window that lock the account
+	LockDuration  time.Duration
+}
+
+// LockoutPolicyFromConfig builds the policy from configuration, applying sane defaults.
+func LockoutPolicyFromConfig(cfg *config.Config) LockoutPolicy {
+	p := LockoutPolicy{
+		Window:        cfg.LoginLockoutWindow,
+		DelayAfter:    3,
+		BaseDelay:     2 * time.Second,
+		MaxDelay:      time.Minute,
+		LockThreshold: cfg.LoginLockoutThreshold,
+		LockDuration:  cfg.LoginLockoutDuration,
+	}
+	if p.Window <= 0 {
+		p.Window = 15 * time.Minute
+	}
+	if p.LockThreshold <= 0 {
+		p.LockThreshold = 10
+	}
+	if p.LockDuration <= 0 {
+		p.LockDuration = 30 * time.Minute
+	}
+	return p
+}
+
+// ProgressiveDelay returns how long a caller must wait after the latest failure
+// before another attempt is evaluated, given the number of recent failures.
+func ProgressiveDelay(p LockoutPolicy, failures int) time.Duration {
+	if failures < p.DelayAfter || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.DelayAfter; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// LoginGate is the outcome of CheckLoginAllowed.
+type LoginGate struct {
+	Allowed    bool
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+// failureCutoff is the earliest failure timestamp that still counts toward throttling.
+func failureCutoff(p LockoutPolicy, user *models.User, now time.Time) time.Time {
+	cutoff := now.Add(-p.Window)
+	if user.FailedLoginsResetAt != nil && user.FailedLoginsResetAt.After(cutoff) {
+		cutoff = *user.FailedLoginsResetAt
+	}
+	// An expired lockout starts a fresh count.
+	if user.LockedUntil != nil && !now.Before(*user.LockedUntil) && user.LockedUntil.After(cutoff) {
+		cutoff = *user.LockedUntil
+	}
+	return cutoff
+}
+
+func recentFailures(db *gorm.DB, p LockoutPolicy, user *models.User, now time.Time) (int, *time.Time, error) {
+	var rows []models.LoginActivity
+	if err := db.Where("user_id = ? AND status = ? AND created_at > ?", user.ID, "failed_login", failureCutoff(p, user, now)).
+		Order("created_at DESC").Find(&rows).Error; err != nil {
+		return 0, nil, err
+	}
+	if len(rows) == 0 {
+		return 0, nil, nil
+	}
+	return len(rows), &rows[0].CreatedAt, nil
+}
+
+// CheckLoginAllowed decides whether a password attempt for user may be evaluated now.
+// It must run before the password comparison so a correct guess is still refused while throttled.
+func CheckLoginAllowed(db *gorm.DB, p LockoutPolicy, user *models.User, now time.Time) (LoginGate, error) {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return LoginGate{Locked: true, RetryAfter: user.LockedUntil.Sub(now)}, nil
+	}
+	failures, last, err := recentFailures(db, p, user, now)
+	if err != nil {
+		return LoginGate{}, err
+	}
+	if delay := ProgressiveDelay(p, failures); delay > 0 && last != nil {
+		if wait := last.Add(delay).Sub(now); wait > 0 {
+			return LoginGate{RetryAfter: wait}, nil
+		}
+	}
+	return LoginGate{Allowed: true}, nil
+}
+
+// RecordFailedLogin stores a failed attempt and locks the account once the threshold
+// is reached. It returns true when this failure caused a new lockout.
+func RecordFailedLogin(db *gorm.DB, p LockoutPolicy, user *models.User, ip, ua string, now time.Time) (bool, error) {
+	if err := db.Create(&models.LoginActivity{UserID: user.ID, Status: "failed_login", IP: ip, UserAgent: ua}).Error; err != nil {
+		return false, err
+	}
+	failures, _, err := recentFailures(db, p, user, now)
+	if err != nil {
+		return false, err
+	}
+	if failures < p.LockThreshold {
+		return false, nil
+	}
+	until := now.Add(p.LockDuration)
+	if err := db.Unscoped().Model(user).Update("locked_until", &until).Error; err != nil {
+		return false, err
+	}
+	user.LockedUntil = &until
+	meta, _ := json.Marshal(map[string]any{"failures": failures, "locked_until": until.UTC()})
+	ipCopy := ip
+	_ = db.Create(&models.SecurityAuditLog{UserID: user.ID, Action: "account_locked", IP: &ipCopy, Meta: datatypes.JSON(meta)}).Error
+	return true, nil
+}
+
+// ResetFailedLogins clears any lockout and discards earlier failures from the count.
+func ResetFailedLogins(db *gorm.DB, user *models.User, now time.Time) error {
+	if err := db.Model(user).Updates(map[string]any{
+		"locked_until":           gorm.Expr("NULL"),
+		"failed_logins_reset_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	user.LockedUntil = nil
+	user.FailedLoginsResetAt = &now
+	return nil
+}
+
+// maxUnknownLoginEmails bounds how many unregistered emails UnknownLogins tracks at once.
+const maxUnknownLoginEmails = 10_000
+
+// UnknownLogins throttles logins to emails without an account the same way LockoutPolicy throttles
+// accounts, so the delays and lockouts a caller sees don't reveal whether an email is registered.
+// It is kept in memory: a restart only forgets attempts that could never have succeeded.
+type UnknownLogins struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	locked   map[string]time.Time
+}
+
+// NewUnknownLogins returns an empty tracker.
+func NewUnknownLogins() *UnknownLogins {
+	return &UnknownLogins{failures: map[string][]time.Time{}, locked: map[string]time.Time{}}
+}
+
+// recentLocked returns the failures of email that still count, dropping the rest. u.mu must be held.
+func (u *UnknownLogins) recentLocked(p LockoutPolicy, email string, now time.Time) []time.Time {
+	cutoff := now.Add(-p.Window)
+	if until, ok := u.locked[email]; ok && !now.Before(until) {
+		// An expired lockout starts a fresh count, as it does for accounts
+		delete(u.locked, email)
+		if until.After(cutoff) {
+			cutoff = until
+		}
+	}
+	kept := u.failures[email][:0]
+	for _, at := range u.failures[email] {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(u.failures, email)
+	} else {
+		u.failures[email] = kept
+	}
+	return kept
+}
+
+// Check is CheckLoginAllowed for an email without an account.
+func (u *UnknownLogins) Check(p LockoutPolicy, email string, now time.Time) LoginGate {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if until, ok := u.locked[email]; ok && now.Before(until) {
+		return LoginGate{Locked: true, RetryAfter: until.Sub(now)}
+	}
+	failures := u.recentLocked(p, email, now)
+	if delay := ProgressiveDelay(p, len(failures)); delay > 0 && len(failures) > 0 {
+		if wait := failures[len(failures)-1].Add(delay).Sub(now); wait > 0 {
+			return LoginGate{RetryAfter: wait}
+		}
+	}
+	return LoginGate{Allowed: true}
+}
+
+// RecordFailure is RecordFailedLogin for an email without an account.
+func (u *UnknownLogins) RecordFailure(p LockoutPolicy, email string, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.failures[email]; !ok && len(u.failures) >= maxUnknownLoginEmails {
+		u.pruneLocked(p, now)
+	}
+	failures := append(u.recentLocked(p, email, now), now)
+	u.failures[email] = failures
+	if len(failures) >= p.LockThreshold {
+		u.locked[email] = now.Add(p.LockDuration)
+	}
+}
+
+// pruneLocked drops emails whose failures no longer count, and if that isn't enough, forgets the
+// ones without a lockout. u.mu must be held.
+func (u *UnknownLogins) pruneLocked(p LockoutPolicy, now time.Time) {
+	for email := range u.failures {
+		u.recentLocked(p, email, now)
+	}
+	if len(u.failures) < maxUnknownLoginEmails {
+		return
+	}
+	for email := range u.failures {
+		if _, ok := u.locked[email]; !ok {
+			delete(u.failures, email)
+		}
+	}
+}
+
+// UnlockRef binds an unlock link to a specific lockout so it cannot be reused for later ones.
+func UnlockRef(lockedUntil time.Time) string {
+	return strconv.FormatInt(lockedUntil.Unix(), 10)
+}
+
+// UnlockAccount validates an emailed unlock token and lifts the matching lockout.
+func UnlockAccount(db *gorm.DB, secret, token, ip string) (*models.User, error) {
+	claims, err := ParseLinkToken(secret, UnlockLinkPurpose, token)
+	if err != nil {
+		return nil, errors.New("invalid or expired unlock link")
+	}
+	var user models.User
+	if err := db.First(&user, claims.UID).Error; err != nil {
+		return nil, err
+	}
+	if user.LockedUntil == nil || UnlockRef(*user.LockedUntil) != claims.Ref {
+		return nil, errors.New("unlock link is no longer valid")
+	}
+	if err := ResetFailedLogins(db, &user, time.Now()); err != nil {
+		return nil, err
+	}
+	ipCopy := ip
+	_ = db.Create(&models.SecurityAuditLog{UserID: user.ID, Action: "account_unlocked", IP: &ipCopy}).Error
+	return &user, nil
+}
@@ -0,0 +1,83 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	p := LockoutPolicy{DelayAfter: 3, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}
+
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "No failures", failures: 0, want: 0},
+		{name: "Below delay threshold", failures: 2, want: 0},
+		{name: "At delay threshold", failures: 3, want: 2 * time.Second},
+		{name: "Doubles per failure", failures: 5, want: 8 * time.Second},
+		{name: "Capped at max delay", failures: 9, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProgressiveDelay(p, tt.failures); got != tt.want {
+				t.Errorf("ProgressiveDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkTokenRoundTrip(t *testing.T) {
+	token, err := IssueLinkToken("secret", UnlockLinkPurpose, 42, "1700000000", time.Minute)
+	if err != nil {
+		t.Fatalf("IssueLinkToken() error = %v", err)
+	}
+
+	claims, err := ParseLinkToken("secret", UnlockLinkPurpose, token)
+	if err != nil {
+		t.Fatalf("ParseLinkToken() error = %v", err)
+	}
+	if claims.UID != 42 || claims.Ref != "1700000000" {
+		t.Errorf("ParseLinkToken() claims = %+v, want uid 42 ref 1700000000", claims)
+	}
+
+	if _, err := ParseLinkToken("secret", "other_purpose", token); err == nil {
+		t.Error("ParseLinkToken() accepted a token issued for a different purpose")
+	}
+	if _, err := ParseLinkToken("other-secret", UnlockLinkPurpose, token); err == nil {
+		t.Error("ParseLinkToken() accepted a token signed with a different secret")
+	}
+}
+
+func TestUnknownLogins(t *testing.T) {
+	p := LockoutPolicy{Window: 15 * time.Minute, DelayAfter: 3, BaseDelay: 2 * time.Second, MaxDelay: time.Minute, LockThreshold: 5, LockDuration: 30 * time.Minute}
+	u := NewUnknownLogins()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if gate := u.Check(p, "nobody@example.com", now); !gate.Allowed {
+			t.Fatalf("attempt %d refused: %+v", i+1, gate)
+		}
+		u.RecordFailure(p, "nobody@example.com", now)
+	}
+	if gate := u.Check(p, "nobody@example.com", now.Add(time.Second)); gate.Allowed || gate.Locked || gate.RetryAfter != time.Second {
+		t.Errorf("after 3 failures gate = %+v, want a 1s delay", gate)
+	}
+
+	now = now.Add(time.Minute)
+	u.RecordFailure(p, "nobody@example.com", now)
+	u.RecordFailure(p, "nobody@example.com", now)
+	if gate := u.Check(p, "nobody@example.com", now); !gate.Locked || gate.RetryAfter != p.LockDuration {
+		t.Errorf("after 5 failures gate = %+v, want locked for %v", gate, p.LockDuration)
+	}
+	if gate := u.Check(p, "other@example.com", now); !gate.Allowed {
+		t.Errorf("other email gate = %+v, want allowed", gate)
+	}
+
+	// An expired lockout starts a fresh count
+	if gate := u.Check(p, "nobody@example.com", now.Add(p.LockDuration)); !gate.Allowed {
+		t.Errorf("after the lockout gate = %+v, want allowed", gate)
+	}
+}
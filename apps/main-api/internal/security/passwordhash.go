@@ -0,0 +1,173 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher produces and checks self-describing password hashes.
+// Encoded hashes carry their algorithm and parameters so they can be verified after defaults change.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded, password string) (bool, error)
+	// Handles reports whether encoded was produced by this algorithm.
+	Handles(encoded string) bool
+	// NeedsRehash reports whether encoded should be replaced by a fresh Hash.
+	NeedsRehash(encoded string) bool
+}
+
+var ErrUnknownPasswordHash = errors.New("unrecognized password hash format")
+
+// Argon2idHasher encodes hashes in the PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2id follows the RFC 9106 second recommended option (64 MiB, 3 passes).
+var DefaultArgon2id = Argon2idHasher{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt, key   []byte
+}
+
+func decodeArgon2id(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	var p argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	return &p, nil
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (h Argon2idHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory < h.Memory || p.iterations < h.Iterations || p.parallelism != h.Parallelism ||
+		uint32(len(p.salt)) < h.SaltLength || uint32(len(p.key)) < h.KeyLength
+}
+
+// BcryptHasher verifies legacy $2a$/$2b$/$2y$ hashes.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) Handles(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
+
+// Passwords hashes with Current and verifies against any of Legacy.
+type Passwords struct {
+	Current PasswordHasher
+	Legacy  []PasswordHasher
+}
+
+// DefaultPasswords hashes new passwords with Argon2id and still accepts bcrypt hashes.
+var DefaultPasswords = Passwords{
+	Current: DefaultArgon2id,
+	Legacy:  []PasswordHasher{BcryptHasher{Cost: bcrypt.DefaultCost}},
+}
+
+// Hash encodes password with the current algorithm.
+func (p Passwords) Hash(password string) (string, error) {
+	return p.Current.Hash(password)
+}
+
+// Verify checks password against encoded. rehash is true when the password matched
+// but encoded uses a legacy algorithm or weaker parameters than Current.
+func (p Passwords) Verify(encoded, password string) (ok bool, rehash bool, err error) {
+	if p.Current.Handles(encoded) {
+		ok, err = p.Current.Verify(encoded, password)
+		return ok, ok && p.Current.NeedsRehash(encoded), err
+	}
+	for _, h := range p.Legacy {
+		if h.Handles(encoded) {
+			ok, err = h.Verify(encoded, password)
+			return ok, ok, err
+		}
+	}
+	return false, false, ErrUnknownPasswordHash
+}
+
+// HashPassword hashes password with DefaultPasswords.
+func HashPassword(password string) (string, error) {
+	return DefaultPasswords.Hash(password)
+}
+
+// VerifyPassword checks password with DefaultPasswords; see Passwords.Verify.
+func VerifyPassword(encoded, password string) (ok bool, rehash bool, err error) {
+	return DefaultPasswords.Verify(encoded, password)
+}
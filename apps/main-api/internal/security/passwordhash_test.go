@@ -0,0 +1,54 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordsVerify(t *testing.T) {
+	fast := Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	p := Passwords{Current: fast, Legacy: []PasswordHasher{BcryptHasher{Cost: bcrypt.MinCost}}}
+
+	argonHash, err := p.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("Hash() = %q, want PHC argon2id encoding", argonHash)
+	}
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	stronger := Passwords{Current: Argon2idHasher{Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+
+	tests := []struct {
+		name       string
+		p          Passwords
+		encoded    string
+		password   string
+		wantOK     bool
+		wantRehash bool
+	}{
+		{name: "Argon2id match", p: p, encoded: argonHash, password: "correct horse", wantOK: true},
+		{name: "Argon2id mismatch", p: p, encoded: argonHash, password: "wrong", wantOK: false},
+		{name: "Bcrypt match upgrades", p: p, encoded: string(bcryptHash), password: "correct horse", wantOK: true, wantRehash: true},
+		{name: "Bcrypt mismatch", p: p, encoded: string(bcryptHash), password: "wrong", wantOK: false},
+		{name: "Weaker Argon2id params upgrade", p: stronger, encoded: argonHash, password: "correct horse", wantOK: true, wantRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := tt.p.Verify(tt.encoded, tt.password)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Errorf("Verify() = (%v, %v), want (%v, %v)", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+
+	if _, _, err := p.Verify("plaintext", "plaintext"); err != ErrUnknownPasswordHash {
+		t.Errorf("Verify(unknown) error = %v, want ErrUnknownPasswordHash", err)
+	}
+}
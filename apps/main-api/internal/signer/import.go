@@ -0,0 +1,114 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/lyonnee/key25519/bip44"
+	"github.com/tyler-smith/go-bip39"
+	"gorm.io/datatypes"
+
+	"github.com/team556-mono/server/internal/crypto"
+)
+
+// Kinds of secret a wallet can be encrypted from.
+const (
+	SecretMnemonic   = "mnemonic"    // bip39 phrase; the key is derived at the wallet's path
+	SecretPrivateKey = "private_key" // base58 ed25519 secret key as exported by Phantom/Solflare
+)
+
+var (
+	ErrInvalidMnemonic  = errors.New("invalid recovery phrase")
+	ErrInvalidSecretKey = errors.New("invalid secret key")
+	ErrInvalidPath      = errors.New("invalid derivation path")
+)
+
+// solanaPathPrefix is the bip44 purpose and Solana coin type every supported path starts with.
+const solanaPathPrefix = "m/44'/501'"
+
+// CommonDerivationPaths are the paths other Solana wallets use, in the order to try them when importing.
+var CommonDerivationPaths = []string{
+	DefaultDerivationPath, // Phantom, Solflare, Backpack
+	"m/44'/501'/0'",       // Trust Wallet, older Solflare and Ledger accounts
+	"m/44'/501'",          // root account used by some early wallets
+	AccountPath(1),
+	AccountPath(2),
+}
+
+// NormalizeMnemonic lowercases a recovery phrase, collapses whitespace and checks the bip39 checksum.
+func NormalizeMnemonic(mnemonic string) (string, error) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	if !bip39.IsMnemonicValid(normalized) {
+		return "", ErrInvalidMnemonic
+	}
+	return normalized, nil
+}
+
+// ValidateDerivationPath accepts hardened Solana paths of the form m/44'/501'[/a'[/b']].
+func ValidateDerivationPath(path string) error {
+	if path != solanaPathPrefix && !strings.HasPrefix(path, solanaPathPrefix+"/") {
+		return fmt.Errorf("%w: must start with %s", ErrInvalidPath, solanaPathPrefix)
+	}
+	indices, err := bip44.ParsePath(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+	if len(indices) > 4 {
+		return fmt.Errorf("%w: too many levels", ErrInvalidPath)
+	}
+	for _, index := range indices {
+		if index < 0x80000000 {
+			return fmt.Errorf("%w: ed25519 only supports hardened levels", ErrInvalidPath)
+		}
+	}
+	return nil
+}
+
+// AccountIndex returns the bip44 account level of a valid Solana path, e.g. 3 for m/44'/501'/3'/0',
+// or 0 for paths without one.
+func AccountIndex(path string) uint32 {
+	indices, err := bip44.ParsePath(path)
+	if err != nil || len(indices) < 3 || indices[2] < 0x80000000 {
+		return 0
+	}
+	return indices[2] - 0x80000000
+}
+
+// ParseSecretKey decodes a base58 secret key. Both the 64-byte form (seed followed by public key)
+// and a bare 32-byte seed are accepted; for the 64-byte form the embedded public key must match.
+func ParseSecretKey(secretKey string) (*Keypair, error) {
+	raw, err := solana.PrivateKeyFromBase58(strings.TrimSpace(secretKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSecretKey, err)
+	}
+	defer Zero(raw)
+	switch len(raw) {
+	case ed25519.PrivateKeySize:
+		key := solana.PrivateKey(ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize]))
+		if !key.PublicKey().Equals(solana.PublicKeyFromBytes(raw[ed25519.SeedSize:])) {
+			Zero(key)
+			return nil, fmt.Errorf("%w: public key does not match", ErrInvalidSecretKey)
+		}
+		return &Keypair{key: key}, nil
+	case ed25519.SeedSize:
+		return &Keypair{key: solana.PrivateKey(ed25519.NewKeyFromSeed(raw))}, nil
+	}
+	return nil, fmt.Errorf("%w: expected 32 or 64 bytes, got %d", ErrInvalidSecretKey, len(raw))
+}
+
+// UnlockEncrypted decrypts a stored wallet secret of the given kind and returns its signing key.
+// path is ignored for private keys.
+func UnlockEncrypted(kind, encryptedSecret string, metadata datatypes.JSON, password, path string) (*Keypair, error) {
+	if kind != SecretPrivateKey {
+		return DeriveFromEncrypted(encryptedSecret, metadata, password, path)
+	}
+	secret, err := crypto.DecryptMnemonicBytes(encryptedSecret, metadata, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+	defer Zero(secret)
+	return ParseSecretKey(string(secret))
+}
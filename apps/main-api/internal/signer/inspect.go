@@ -0,0 +1,489 @@
+package signer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	computeBudgetProgramID = solana.MustPublicKeyFromBase58("ComputeBudget111111111111111111111111111111")
+	memoV1ProgramID        = solana.MustPublicKeyFromBase58("Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo")
+	jupiterV6ProgramID     = solana.MustPublicKeyFromBase58("JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4")
+)
+
+// lamportsPerSignature is the base fee charged per required signature.
+const lamportsPerSignature = 5000
+
+// Runtime compute-unit limits used when a transaction doesn't set its own.
+const (
+	defaultComputeUnitsPerInstruction = 200_000
+	maxComputeUnits                   = 1_400_000
+)
+
+// Instruction types reported in a TxSummary.
+const (
+	InstrSOLTransfer   = "sol_transfer"
+	InstrSPLTransfer   = "spl_transfer"
+	InstrATACreate     = "ata_create"
+	InstrJupiterSwap   = "jupiter_swap"
+	InstrMemo          = "memo"
+	InstrComputeBudget = "compute_budget"
+	InstrCreateAccount = "create_account"
+	InstrTokenApprove  = "token_approve"
+	InstrTokenRevoke   = "token_revoke"
+	InstrSetAuthority  = "set_authority"
+	InstrCloseAccount  = "close_account"
+	InstrTokenBurn     = "token_burn"
+	InstrAssign        = "assign"
+	InstrSystemOther   = "system_other"
+	InstrTokenOther    = "token_other"
+	InstrUnknown       = "unknown"
+)
+
+// Warning codes reported in a TxSummary.
+const (
+	WarnUnknownProgram     = "unknown_program"
+	WarnAuthorityChange    = "authority_change"
+	WarnCloseAccount       = "close_account"
+	WarnTokenApproval      = "token_approval"
+	WarnTokenBurn          = "token_burn"
+	WarnNotFeePayer        = "not_fee_payer"
+	WarnUnresolvedAccounts = "unresolved_accounts"
+	WarnOtherSigners       = "other_signers"
+)
+
+// TxSummary is a human-readable description of a transaction before it is signed.
+type TxSummary struct {
+	FeePayer             string   `json:"feePayer"`
+	Signers              []string `json:"signers"`
+	EstimatedFeeLamports uint64   `json:"estimatedFeeLamports"` // Signature fees plus the priority fee at the full compute-unit limit
+	// Compute budget, as set by the transaction's compute budget instructions or the runtime defaults
+	ComputeUnitLimit              uint32               `json:"computeUnitLimit"`
+	ComputeUnitPriceMicroLamports uint64               `json:"computeUnitPriceMicroLamports"`
+	Instructions                  []InstructionSummary `json:"instructions"`
+	BalanceChanges                []BalanceChange      `json:"balanceChanges"`
+	Warnings                      []TxWarning          `json:"warnings"`
+	// Addresses the owner sends value to: SOL transfer destinations, accounts it funds the creation
+	// of, owners of token accounts created by the transaction, and token account destinations of the
+	// owner's token transfers. Token accounts the transaction doesn't create are listed as themselves
+	// until ResolveTokenRecipients replaces them with their owners.
+	Recipients []string `json:"recipients"`
+	// Token account destinations in Recipients whose owner isn't known from the transaction
+	TokenAccountRecipients []string `json:"tokenAccountRecipients"`
+}
+
+// InstructionSummary classifies one top-level instruction.
+type InstructionSummary struct {
+	Index       int    `json:"index"`
+	ProgramID   string `json:"programId"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// BalanceChange is the statically-derived change to the owner's holdings of one asset.
+// Mint is "SOL" for native lamports, or "unknown:<source account>" for unchecked SPL transfers whose
+// mint is not in the instruction. Amount is in UI units when decimals are known, raw units otherwise.
+type BalanceChange struct {
+	Mint      string `json:"mint"`
+	Amount    string `json:"amount"`
+	RawAmount string `json:"rawAmount"`
+	Decimals  *uint8 `json:"decimals,omitempty"`
+}
+
+// TxWarning flags something the user should confirm explicitly.
+type TxWarning struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Instruction *int   `json:"instruction,omitempty"`
+}
+
+type inspector struct {
+	owner    solana.PublicKey
+	keys     solana.PublicKeySlice
+	summary  *TxSummary
+	deltas   map[string]*big.Int // keyed by mint
+	decimals map[string]uint8
+	order    []string
+
+	cuLimit    *uint32 // set by SetComputeUnitLimit
+	cuPrice    uint64  // set by SetComputeUnitPrice
+	budgetOnly int     // compute budget instructions, which don't get the default allowance
+
+	createdATAs map[solana.PublicKey]solana.PublicKey // token accounts created here, to their owners
+	tokenDests  []solana.PublicKey                    // token accounts the owner transfers to
+}
+
+// InspectTransaction decodes a base64 wire-format transaction and describes what it does
+// from owner's point of view. Balance changes are derived from instruction data only;
+// swap outputs and other program-dependent effects are not simulated.
+func InspectTransaction(txBase64 string, owner solana.PublicKey) (*TxSummary, error) {
+	tx, err := solana.TransactionFromBase64(txBase64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTx, err)
+	}
+	msg := tx.Message
+	if len(msg.AccountKeys) == 0 {
+		return nil, fmt.Errorf("%w: no account keys", ErrInvalidTx)
+	}
+
+	numSigners := int(msg.Header.NumRequiredSignatures)
+	if numSigners > len(msg.AccountKeys) {
+		return nil, fmt.Errorf("%w: header requires more signers than accounts", ErrInvalidTx)
+	}
+	in := &inspector{
+		owner: owner,
+		keys:  msg.AccountKeys,
+		summary: &TxSummary{
+			FeePayer:             msg.AccountKeys[0].String(),
+			EstimatedFeeLamports: uint64(numSigners) * lamportsPerSignature,
+			Instructions:         []InstructionSummary{},
+			BalanceChanges:       []BalanceChange{},
+			Warnings:             []TxWarning{},
+			Recipients:           []string{},
+
+			TokenAccountRecipients: []string{},
+		},
+		deltas:      map[string]*big.Int{},
+		decimals:    map[string]uint8{},
+		createdATAs: map[solana.PublicKey]solana.PublicKey{},
+	}
+	for _, k := range msg.AccountKeys[:numSigners] {
+		in.summary.Signers = append(in.summary.Signers, k.String())
+		if !k.Equals(owner) && !k.Equals(msg.AccountKeys[0]) {
+			in.warn(WarnOtherSigners, nil, fmt.Sprintf("Transaction also requires a signature from %s", k))
+		}
+	}
+	if !msg.AccountKeys[0].Equals(owner) {
+		in.warn(WarnNotFeePayer, nil, fmt.Sprintf("Network fee is paid by %s, not your wallet", msg.AccountKeys[0]))
+	}
+	if msg.IsVersioned() && msg.NumLookups() > 0 {
+		in.warn(WarnUnresolvedAccounts, nil, "Some accounts are loaded from address lookup tables and are shown as unresolved")
+	}
+
+	for i, ix := range msg.Instructions {
+		if int(ix.ProgramIDIndex) >= len(msg.AccountKeys) {
+			return nil, fmt.Errorf("%w: instruction %d references a missing program", ErrInvalidTx, i)
+		}
+		in.inspect(i, msg.AccountKeys[ix.ProgramIDIndex], ix.Accounts, ix.Data)
+	}
+	// A token account created in the same transaction is already listed by its owner
+	for _, dest := range in.tokenDests {
+		if _, ok := in.createdATAs[dest]; ok {
+			continue
+		}
+		in.addRecipient(dest)
+		if !dest.IsZero() && !slices.Contains(in.summary.TokenAccountRecipients, dest.String()) {
+			in.summary.TokenAccountRecipients = append(in.summary.TokenAccountRecipients, dest.String())
+		}
+	}
+
+	// Priority fee: price (micro-lamports) times the compute-unit limit, rounded up
+	limit := uint32(min((len(msg.Instructions)-in.budgetOnly)*defaultComputeUnitsPerInstruction, maxComputeUnits))
+	if in.cuLimit != nil {
+		limit = min(*in.cuLimit, maxComputeUnits)
+	}
+	in.summary.ComputeUnitLimit, in.summary.ComputeUnitPriceMicroLamports = limit, in.cuPrice
+	priority := new(big.Int).Mul(new(big.Int).SetUint64(in.cuPrice), big.NewInt(int64(limit)))
+	priority.Add(priority, big.NewInt(999_999)).Div(priority, big.NewInt(1_000_000))
+	if priority.IsUint64() {
+		in.summary.EstimatedFeeLamports += priority.Uint64()
+	}
+	if msg.AccountKeys[0].Equals(owner) {
+		in.addDelta("SOL", new(big.Int).Neg(new(big.Int).SetUint64(in.summary.EstimatedFeeLamports)), 9)
+	}
+
+	for _, k := range in.order {
+		d := in.deltas[k]
+		if d.Sign() == 0 {
+			continue
+		}
+		change := BalanceChange{Mint: k, RawAmount: d.String(), Amount: d.String()}
+		if dec, ok := in.decimals[k]; ok {
+			decCopy := dec
+			change.Decimals = &decCopy
+			change.Amount = decimal.NewFromBigInt(d, -int32(dec)).String()
+		}
+		in.summary.BalanceChanges = append(in.summary.BalanceChanges, change)
+	}
+	return in.summary, nil
+}
+
+func (in *inspector) warn(code string, index *int, message string) {
+	in.summary.Warnings = append(in.summary.Warnings, TxWarning{Code: code, Message: message, Instruction: index})
+}
+
+// account resolves an instruction account index; lookup-table accounts resolve to the zero key.
+func (in *inspector) account(accounts []uint16, n int) solana.PublicKey {
+	if n >= len(accounts) || int(accounts[n]) >= len(in.keys) {
+		return solana.PublicKey{}
+	}
+	return in.keys[accounts[n]]
+}
+
+func (in *inspector) addRecipient(k solana.PublicKey) {
+	if k.IsZero() || k.Equals(in.owner) {
+		return
+	}
+	for _, r := range in.summary.Recipients {
+		if r == k.String() {
+			return
+		}
+	}
+	in.summary.Recipients = append(in.summary.Recipients, k.String())
+}
+
+func (in *inspector) addDelta(mint string, amount *big.Int, decimals int) {
+	if _, ok := in.deltas[mint]; !ok {
+		in.deltas[mint] = new(big.Int)
+		in.order = append(in.order, mint)
+	}
+	in.deltas[mint].Add(in.deltas[mint], amount)
+	if decimals >= 0 {
+		in.decimals[mint] = uint8(decimals)
+	}
+}
+
+func (in *inspector) inspect(i int, program solana.PublicKey, accounts []uint16, data []byte) {
+	idx := i
+	s := InstructionSummary{Index: i, ProgramID: program.String()}
+	switch {
+	case program.Equals(solana.SystemProgramID):
+		in.inspectSystem(&s, accounts, data)
+	case program.Equals(solana.TokenProgramID), program.Equals(solana.Token2022ProgramID):
+		in.inspectToken(&s, program, accounts, data)
+	case program.Equals(solana.SPLAssociatedTokenAccountProgramID):
+		s.Type = InstrATACreate
+		s.Description = fmt.Sprintf("Create token account %s for %s (mint %s)", in.account(accounts, 1), in.account(accounts, 2), in.account(accounts, 3))
+		if in.account(accounts, 0).Equals(in.owner) {
+			s.Description += "; rent paid by your wallet"
+		}
+		in.createdATAs[in.account(accounts, 1)] = in.account(accounts, 2)
+		in.addRecipient(in.account(accounts, 2))
+	case program.Equals(jupiterV6ProgramID):
+		s.Type = InstrJupiterSwap
+		s.Description = "Jupiter swap (amounts determined by the route; compare with the quote)"
+	case program.Equals(solana.MemoProgramID), program.Equals(memoV1ProgramID):
+		s.Type = InstrMemo
+		if utf8.Valid(data) {
+			s.Description = fmt.Sprintf("Memo: %q", string(data))
+		} else {
+			s.Description = "Memo (binary data)"
+		}
+	case program.Equals(computeBudgetProgramID):
+		s.Type = InstrComputeBudget
+		s.Description = "Set compute budget / priority fee"
+		in.budgetOnly++
+		switch {
+		case len(data) >= 5 && data[0] == 2: // SetComputeUnitLimit { units: u32 }
+			units := binary.LittleEndian.Uint32(data[1:5])
+			in.cuLimit = &units
+			s.Description = fmt.Sprintf("Set compute unit limit to %d", units)
+		case len(data) >= 9 && data[0] == 3: // SetComputeUnitPrice { micro_lamports: u64 }
+			in.cuPrice = binary.LittleEndian.Uint64(data[1:9])
+			s.Description = fmt.Sprintf("Set priority fee to %d micro-lamports per compute unit", in.cuPrice)
+		}
+	default:
+		s.Type = InstrUnknown
+		s.Description = fmt.Sprintf("Call to unrecognized program %s", program)
+		in.warn(WarnUnknownProgram, &idx, fmt.Sprintf("Instruction %d calls an unrecognized program %s", i, program))
+	}
+	in.summary.Instructions = append(in.summary.Instructions, s)
+}
+
+func lamportsString(l uint64) string {
+	return decimal.NewFromBigInt(new(big.Int).SetUint64(l), -9).String()
+}
+
+func (in *inspector) inspectSystem(s *InstructionSummary, accounts []uint16, data []byte) {
+	idx := s.Index
+	if len(data) < 4 {
+		s.Type, s.Description = InstrSystemOther, "System program instruction"
+		return
+	}
+	switch binary.LittleEndian.Uint32(data[:4]) {
+	case 0: // CreateAccount { lamports, space, owner }
+		s.Type = InstrCreateAccount
+		from, newAcc := in.account(accounts, 0), in.account(accounts, 1)
+		if len(data) >= 12 {
+			lamports := binary.LittleEndian.Uint64(data[4:12])
+			s.Description = fmt.Sprintf("Create account %s funded with %s SOL", newAcc, lamportsString(lamports))
+			if from.Equals(in.owner) {
+				in.addDelta("SOL", new(big.Int).Neg(new(big.Int).SetUint64(lamports)), 9)
+				in.addRecipient(newAcc)
+			}
+		} else {
+			s.Description = fmt.Sprintf("Create account %s", newAcc)
+		}
+	case 1: // Assign { owner }
+		s.Type = InstrAssign
+		target := in.account(accounts, 0)
+		s.Description = fmt.Sprintf("Assign account %s to a new program", target)
+		if len(data) >= 36 {
+			s.Description = fmt.Sprintf("Assign account %s to program %s", target, solana.PublicKeyFromBytes(data[4:36]))
+		}
+		if target.Equals(in.owner) {
+			in.warn(WarnAuthorityChange, &idx, "This transaction transfers ownership of your wallet account to another program")
+		}
+	case 2: // Transfer { lamports }
+		s.Type = InstrSOLTransfer
+		from, to := in.account(accounts, 0), in.account(accounts, 1)
+		if len(data) < 12 {
+			s.Description = "SOL transfer"
+			return
+		}
+		lamports := binary.LittleEndian.Uint64(data[4:12])
+		s.Description = fmt.Sprintf("Transfer %s SOL from %s to %s", lamportsString(lamports), from, to)
+		amt := new(big.Int).SetUint64(lamports)
+		if from.Equals(in.owner) {
+			in.addDelta("SOL", new(big.Int).Neg(amt), 9)
+			in.addRecipient(to)
+		}
+		if to.Equals(in.owner) {
+			in.addDelta("SOL", amt, 9)
+		}
+	default:
+		s.Type, s.Description = InstrSystemOther, "System program instruction"
+	}
+}
+
+// ownerATA is the owner's associated token account for mint under tokenProgram.
+func (in *inspector) ownerATA(tokenProgram, mint solana.PublicKey) solana.PublicKey {
+	return AssociatedTokenAddress(in.owner, tokenProgram, mint)
+}
+
+// AssociatedTokenAddress is owner's associated token account for mint under tokenProgram,
+// or the zero key if none can be derived.
+func AssociatedTokenAddress(owner, tokenProgram, mint solana.PublicKey) solana.PublicKey {
+	addr, _, err := solana.FindProgramAddress([][]byte{owner[:], tokenProgram[:], mint[:]}, solana.SPLAssociatedTokenAccountProgramID)
+	if err != nil {
+		return solana.PublicKey{}
+	}
+	return addr
+}
+
+var authorityTypes = []string{"mint tokens", "freeze account", "account owner", "close account"}
+
+func (in *inspector) inspectToken(s *InstructionSummary, program solana.PublicKey, accounts []uint16, data []byte) {
+	idx := s.Index
+	if len(data) < 1 {
+		s.Type, s.Description = InstrTokenOther, "Token program instruction"
+		return
+	}
+	amountAt := func(off int) (uint64, bool) {
+		if len(data) < off+8 {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint64(data[off : off+8]), true
+	}
+
+	switch data[0] {
+	case 3: // Transfer { amount } [source, destination, authority]
+		s.Type = InstrSPLTransfer
+		amount, _ := amountAt(1)
+		source, dest, authority := in.account(accounts, 0), in.account(accounts, 1), in.account(accounts, 2)
+		s.Description = fmt.Sprintf("Transfer %d raw token units from %s to %s", amount, source, dest)
+		if authority.Equals(in.owner) {
+			// Mint is not part of an unchecked transfer; key the change by source account.
+			in.addDelta("unknown:"+source.String(), new(big.Int).Neg(new(big.Int).SetUint64(amount)), -1)
+			in.tokenDests = append(in.tokenDests, dest)
+		}
+	case 12: // TransferChecked { amount, decimals } [source, mint, destination, authority]
+		s.Type = InstrSPLTransfer
+		amount, _ := amountAt(1)
+		decimals := 0
+		if len(data) >= 10 {
+			decimals = int(data[9])
+		}
+		source, mint, dest, authority := in.account(accounts, 0), in.account(accounts, 1), in.account(accounts, 2), in.account(accounts, 3)
+		ui := decimal.NewFromBigInt(new(big.Int).SetUint64(amount), -int32(decimals)).String()
+		s.Description = fmt.Sprintf("Transfer %s of token %s from %s to %s", ui, mint, source, dest)
+		amt := new(big.Int).SetUint64(amount)
+		if authority.Equals(in.owner) {
+			in.addDelta(mint.String(), new(big.Int).Neg(amt), decimals)
+			if !dest.Equals(in.ownerATA(program, mint)) {
+				in.tokenDests = append(in.tokenDests, dest)
+			}
+		}
+		if dest.Equals(in.ownerATA(program, mint)) {
+			in.addDelta(mint.String(), amt, decimals)
+		}
+	case 4, 13: // Approve / ApproveChecked
+		s.Type = InstrTokenApprove
+		amount, _ := amountAt(1)
+		delegateIdx := 1
+		if data[0] == 13 {
+			delegateIdx = 2
+		}
+		delegate := in.account(accounts, delegateIdx)
+		s.Description = fmt.Sprintf("Allow %s to spend up to %d raw units from %s", delegate, amount, in.account(accounts, 0))
+		in.warn(WarnTokenApproval, &idx, fmt.Sprintf("Grants %s permission to move tokens from your account without further approval", delegate))
+	case 5:
+		s.Type = InstrTokenRevoke
+		s.Description = fmt.Sprintf("Revoke delegate on %s", in.account(accounts, 0))
+	case 6: // SetAuthority { authority_type, new_authority: COption<Pubkey> }
+		s.Type = InstrSetAuthority
+		kind := "authority"
+		if len(data) >= 2 && int(data[1]) < len(authorityTypes) {
+			kind = authorityTypes[data[1]]
+		}
+		newAuthority := "none"
+		if len(data) >= 35 && data[2] == 1 {
+			newAuthority = solana.PublicKeyFromBytes(data[3:35]).String()
+		}
+		s.Description = fmt.Sprintf("Change %s authority of %s to %s", kind, in.account(accounts, 0), newAuthority)
+		in.warn(WarnAuthorityChange, &idx, fmt.Sprintf("Changes the %s authority of %s to %s", kind, in.account(accounts, 0), newAuthority))
+	case 8, 15: // Burn / BurnChecked [account, mint, owner]
+		s.Type = InstrTokenBurn
+		amount, _ := amountAt(1)
+		mint := in.account(accounts, 1)
+		s.Description = fmt.Sprintf("Burn %d raw units of token %s", amount, mint)
+		if in.account(accounts, 2).Equals(in.owner) {
+			decimals := -1
+			if data[0] == 15 && len(data) >= 10 {
+				decimals = int(data[9])
+			}
+			in.addDelta(mint.String(), new(big.Int).Neg(new(big.Int).SetUint64(amount)), decimals)
+		}
+		in.warn(WarnTokenBurn, &idx, "Permanently destroys tokens")
+	case 9: // CloseAccount [account, destination, owner]
+		s.Type = InstrCloseAccount
+		account, dest := in.account(accounts, 0), in.account(accounts, 1)
+		s.Description = fmt.Sprintf("Close token account %s; rent goes to %s", account, dest)
+		msg := fmt.Sprintf("Closes token account %s", account)
+		if !dest.Equals(in.owner) {
+			msg += fmt.Sprintf(" and sends its rent to %s, not your wallet", dest)
+		}
+		in.warn(WarnCloseAccount, &idx, msg)
+	default:
+		s.Type = InstrTokenOther
+		s.Description = fmt.Sprintf("Token program instruction %d", data[0])
+	}
+}
+
+// ResolveTokenRecipients replaces the token accounts in TokenAccountRecipients with their owners,
+// as looked up on chain (token account to owner), so recipients can be compared with wallet
+// addresses. Accounts missing from owners stay listed as themselves; ones owned by owner are
+// dropped.
+func (s *TxSummary) ResolveTokenRecipients(owner solana.PublicKey, owners map[string]string) {
+	recipients := make([]string, 0, len(s.Recipients))
+	unresolved := []string{}
+	for _, r := range s.Recipients {
+		if slices.Contains(s.TokenAccountRecipients, r) {
+			if o, ok := owners[r]; ok {
+				r = o
+			} else {
+				unresolved = append(unresolved, r)
+			}
+		}
+		if r != owner.String() && !slices.Contains(recipients, r) {
+			recipients = append(recipients, r)
+		}
+	}
+	s.Recipients, s.TokenAccountRecipients = recipients, unresolved
+}
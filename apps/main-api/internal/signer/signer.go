@@ -0,0 +1,125 @@
+// Package signer derives wallet keys and signs Solana transactions inside main-api,
+// so mnemonics and private keys are never sent to another service.
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/lyonnee/key25519/bip32"
+	"github.com/lyonnee/key25519/bip44"
+	"golang.org/x/crypto/pbkdf2"
+	"gorm.io/datatypes"
+
+	"github.com/team556-mono/server/internal/crypto"
+)
+
+// DefaultDerivationPath is the standard Solana account path used by wallets created in the app.
+const DefaultDerivationPath = "m/44'/501'/0'/0'"
+
+// AccountPath returns the standard Solana path for bip44 account index account.
+// AccountPath(0) is DefaultDerivationPath.
+func AccountPath(account uint32) string {
+	return fmt.Sprintf("m/44'/501'/%d'/0'", account)
+}
+
+var (
+	ErrDecryptFailed    = errors.New("failed to decrypt wallet mnemonic")
+	ErrInvalidTx        = errors.New("invalid transaction")
+	ErrNotASigner       = errors.New("wallet is not a required signer of this transaction")
+	ErrMissingBlockhash = errors.New("transaction has no recent blockhash")
+)
+
+// Keypair is an ed25519 signing key derived from a wallet mnemonic.
+// Call Zero as soon as signing is done.
+type Keypair struct {
+	key solana.PrivateKey
+}
+
+// PublicKey returns the wallet address for the keypair.
+func (k *Keypair) PublicKey() solana.PublicKey {
+	return k.key.PublicKey()
+}
+
+// Zero wipes the private key. The keypair is unusable afterwards.
+func (k *Keypair) Zero() {
+	Zero(k.key)
+	k.key = nil
+}
+
+// Zero overwrites b in place.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// seedFromMnemonic is BIP-39 mnemonic-to-seed with an empty passphrase. It works on bytes
+// (unlike bip39.NewSeed) so the mnemonic can be wiped; English wordlist mnemonics are already NFKD.
+func seedFromMnemonic(mnemonic []byte) []byte {
+	return pbkdf2.Key(mnemonic, []byte("mnemonic"), 2048, 64, sha512.New)
+}
+
+// DeriveKeypair derives the ed25519 key at path (SLIP-0010, hardened) from mnemonic.
+// The caller still owns and should zero mnemonic.
+func DeriveKeypair(mnemonic []byte, path string) (*Keypair, error) {
+	indices, err := bip44.ParsePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse derivation path %q: %w", path, err)
+	}
+	seed := seedFromMnemonic(mnemonic)
+	defer Zero(seed)
+
+	key := bip32.GenerateMasterKey(seed)
+	for _, index := range indices {
+		next := bip32.CKDPriv(key, index)
+		Zero(key.PrivKey)
+		key = next
+	}
+	defer Zero(key.PrivKey)
+
+	return &Keypair{key: solana.PrivateKey(ed25519.NewKeyFromSeed(key.PrivKey))}, nil
+}
+
+// DeriveFromEncrypted decrypts a stored wallet mnemonic with password and derives the key at path.
+func DeriveFromEncrypted(encryptedMnemonic string, metadata datatypes.JSON, password, path string) (*Keypair, error) {
+	mnemonic, err := crypto.DecryptMnemonicBytes(encryptedMnemonic, metadata, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+	defer Zero(mnemonic)
+	return DeriveKeypair(mnemonic, path)
+}
+
+// SignTransaction adds kp's signature to a base64 wire-format transaction (legacy or v0)
+// and returns it re-encoded. Signatures from other signers are preserved.
+func SignTransaction(kp *Keypair, txBase64 string) (string, error) {
+	tx, err := solana.TransactionFromBase64(txBase64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTx, err)
+	}
+	if tx.Message.RecentBlockhash.IsZero() {
+		return "", ErrMissingBlockhash
+	}
+	pub := kp.PublicKey()
+	if !tx.IsSigner(pub) {
+		return "", ErrNotASigner
+	}
+	if _, err := tx.PartialSign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(pub) {
+			return &kp.key
+		}
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("sign transaction: %w", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("encode signed transaction: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,224 @@
+package signer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+)
+
+func unsignedTransfer(t *testing.T, from, to solana.PublicKey) string {
+	t.Helper()
+	blockhash := solana.MustHashFromBase58("4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZAMdL4VZHirAn")
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{system.NewTransferInstruction(1000, from, to).Build()},
+		blockhash,
+		solana.TransactionPayer(from),
+	)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+	b64, err := tx.ToBase64()
+	if err != nil {
+		t.Fatalf("ToBase64() error = %v", err)
+	}
+	return b64
+}
+
+func TestSignTransaction(t *testing.T) {
+	owner := &Keypair{key: solana.NewWallet().PrivateKey}
+	other := solana.NewWallet().PublicKey()
+
+	signed, err := SignTransaction(owner, unsignedTransfer(t, owner.PublicKey(), other))
+	if err != nil {
+		t.Fatalf("SignTransaction() error = %v", err)
+	}
+	tx, err := solana.TransactionFromBase64(signed)
+	if err != nil {
+		t.Fatalf("TransactionFromBase64() error = %v", err)
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		t.Errorf("VerifySignatures() error = %v", err)
+	}
+
+	if _, err := SignTransaction(owner, unsignedTransfer(t, other, owner.PublicKey())); !errors.Is(err, ErrNotASigner) {
+		t.Errorf("SignTransaction(not a signer) error = %v, want ErrNotASigner", err)
+	}
+	if _, err := SignTransaction(owner, "not-a-transaction"); !errors.Is(err, ErrInvalidTx) {
+		t.Errorf("SignTransaction(garbage) error = %v, want ErrInvalidTx", err)
+	}
+
+	owner.Zero()
+	if owner.key != nil {
+		t.Error("Zero() did not clear the key")
+	}
+}
+
+func TestInspectTransaction(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	other := solana.NewWallet().PublicKey()
+
+	summary, err := InspectTransaction(unsignedTransfer(t, owner, other), owner)
+	if err != nil {
+		t.Fatalf("InspectTransaction() error = %v", err)
+	}
+	if len(summary.Instructions) != 1 || summary.Instructions[0].Type != InstrSOLTransfer {
+		t.Fatalf("Instructions = %+v, want one %s", summary.Instructions, InstrSOLTransfer)
+	}
+	if len(summary.BalanceChanges) != 1 || summary.BalanceChanges[0].Mint != "SOL" || summary.BalanceChanges[0].RawAmount != "-6000" {
+		t.Errorf("BalanceChanges = %+v, want SOL -6000 (transfer plus fee)", summary.BalanceChanges)
+	}
+	if len(summary.Warnings) != 0 {
+		t.Errorf("Warnings = %+v, want none", summary.Warnings)
+	}
+
+	summary, err = InspectTransaction(unsignedTransfer(t, other, owner), owner)
+	if err != nil {
+		t.Fatalf("InspectTransaction(incoming) error = %v", err)
+	}
+	if len(summary.BalanceChanges) != 1 || summary.BalanceChanges[0].RawAmount != "1000" {
+		t.Errorf("BalanceChanges(incoming) = %+v, want SOL +1000", summary.BalanceChanges)
+	}
+}
+
+func TestInspectTransactionPriorityFee(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	other := solana.NewWallet().PublicKey()
+	limit := []byte{2, 0x10, 0x27, 0, 0}                // SetComputeUnitLimit(10_000)
+	price := []byte{3, 0x40, 0x42, 0x0f, 0, 0, 0, 0, 0} // SetComputeUnitPrice(1_000_000)
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{
+			solana.NewInstruction(computeBudgetProgramID, solana.AccountMetaSlice{}, limit),
+			solana.NewInstruction(computeBudgetProgramID, solana.AccountMetaSlice{}, price),
+			system.NewTransferInstruction(1000, owner, other).Build(),
+		},
+		solana.MustHashFromBase58("4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZAMdL4VZHirAn"),
+		solana.TransactionPayer(owner),
+	)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+	b64, err := tx.ToBase64()
+	if err != nil {
+		t.Fatalf("ToBase64() error = %v", err)
+	}
+
+	summary, err := InspectTransaction(b64, owner)
+	if err != nil {
+		t.Fatalf("InspectTransaction() error = %v", err)
+	}
+	if summary.ComputeUnitLimit != 10_000 || summary.ComputeUnitPriceMicroLamports != 1_000_000 {
+		t.Errorf("compute budget = %d units at %d, want 10000 at 1000000", summary.ComputeUnitLimit, summary.ComputeUnitPriceMicroLamports)
+	}
+	if summary.EstimatedFeeLamports != 15_000 {
+		t.Errorf("EstimatedFeeLamports = %d, want 15000 (signature plus priority fee)", summary.EstimatedFeeLamports)
+	}
+	if len(summary.BalanceChanges) != 1 || summary.BalanceChanges[0].RawAmount != "-16000" {
+		t.Errorf("BalanceChanges = %+v, want SOL -16000", summary.BalanceChanges)
+	}
+}
+
+func TestInspectTransactionTokenRecipients(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	contact := solana.NewWallet().PublicKey()
+	mint := solana.NewWallet().PublicKey()
+	blockhash := solana.MustHashFromBase58("4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZAMdL4VZHirAn")
+	dest := AssociatedTokenAddress(contact, solana.TokenProgramID, mint).String()
+
+	// The destination token account already exists, so its owner has to be looked up
+	b64, err := BuildTransaction([]solana.Instruction{
+		DelegatedTransferInstruction(solana.TokenProgramID, mint, owner, contact, owner, 1000, 6),
+	}, blockhash, owner)
+	if err != nil {
+		t.Fatalf("BuildTransaction() error = %v", err)
+	}
+	summary, err := InspectTransaction(b64, owner)
+	if err != nil {
+		t.Fatalf("InspectTransaction() error = %v", err)
+	}
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != dest {
+		t.Fatalf("Recipients = %v, want [%s]", summary.Recipients, dest)
+	}
+	if len(summary.TokenAccountRecipients) != 1 || summary.TokenAccountRecipients[0] != dest {
+		t.Fatalf("TokenAccountRecipients = %v, want [%s]", summary.TokenAccountRecipients, dest)
+	}
+	summary.ResolveTokenRecipients(owner, map[string]string{dest: contact.String()})
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != contact.String() || len(summary.TokenAccountRecipients) != 0 {
+		t.Errorf("resolved Recipients = %v (unresolved %v), want [%s]", summary.Recipients, summary.TokenAccountRecipients, contact)
+	}
+
+	// A token account created by the transaction is listed by its owner straight away
+	b64, err = BuildTransaction([]solana.Instruction{
+		CreateAssociatedTokenAccountIdempotentInstruction(solana.TokenProgramID, mint, contact, owner),
+		DelegatedTransferInstruction(solana.TokenProgramID, mint, owner, contact, owner, 1000, 6),
+	}, blockhash, owner)
+	if err != nil {
+		t.Fatalf("BuildTransaction() error = %v", err)
+	}
+	if summary, err = InspectTransaction(b64, owner); err != nil {
+		t.Fatalf("InspectTransaction() error = %v", err)
+	}
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != contact.String() || len(summary.TokenAccountRecipients) != 0 {
+		t.Errorf("Recipients = %v (unresolved %v), want [%s]", summary.Recipients, summary.TokenAccountRecipients, contact)
+	}
+}
+
+func TestAccountPath(t *testing.T) {
+	if got := AccountPath(0); got != DefaultDerivationPath {
+		t.Errorf("AccountPath(0) = %q, want %q", got, DefaultDerivationPath)
+	}
+	if got, want := AccountPath(3), "m/44'/501'/3'/0'"; got != want {
+		t.Errorf("AccountPath(3) = %q, want %q", got, want)
+	}
+}
+
+func TestAccountIndex(t *testing.T) {
+	for path, want := range map[string]uint32{AccountPath(3): 3, "m/44'/501'/2'": 2, "m/44'/501'": 0, DefaultDerivationPath: 0} {
+		if got := AccountIndex(path); got != want {
+			t.Errorf("AccountIndex(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	wallet := solana.NewWallet()
+	kp, err := ParseSecretKey(wallet.PrivateKey.String())
+	if err != nil {
+		t.Fatalf("ParseSecretKey() error = %v", err)
+	}
+	if !kp.PublicKey().Equals(wallet.PublicKey()) {
+		t.Errorf("PublicKey() = %s, want %s", kp.PublicKey(), wallet.PublicKey())
+	}
+
+	tampered := append(solana.PrivateKey{}, wallet.PrivateKey[:32]...)
+	tampered = append(tampered, solana.NewWallet().PublicKey().Bytes()...)
+	if _, err := ParseSecretKey(tampered.String()); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ParseSecretKey(mismatched public key) error = %v, want ErrInvalidSecretKey", err)
+	}
+}
+
+func TestValidateDerivationPath(t *testing.T) {
+	for _, path := range CommonDerivationPaths {
+		if err := ValidateDerivationPath(path); err != nil {
+			t.Errorf("ValidateDerivationPath(%q) error = %v", path, err)
+		}
+	}
+	for _, path := range []string{"", "m/44'/60'/0'/0'", "m/44'/501'/0'/0", "m/44'/501'x"} {
+		if err := ValidateDerivationPath(path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("ValidateDerivationPath(%q) error = %v, want ErrInvalidPath", path, err)
+		}
+	}
+}
+
+func TestNormalizeMnemonic(t *testing.T) {
+	const phrase = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	got, err := NormalizeMnemonic("  Abandon abandon abandon abandon abandon abandon\n abandon abandon abandon abandon abandon ABOUT ")
+	if err != nil || got != phrase {
+		t.Errorf("NormalizeMnemonic() = %q, %v; want %q", got, err, phrase)
+	}
+	if _, err := NormalizeMnemonic(strings.Replace(phrase, "about", "abandon", 1)); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Errorf("NormalizeMnemonic(bad checksum) error = %v, want ErrInvalidMnemonic", err)
+	}
+}
@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// SPL token instruction discriminators. The layouts are shared by the Token and Token-2022
+// programs, so the builders below take the program that owns the mint.
+const (
+	tokenIxRevoke          = 5
+	tokenIxTransferChecked = 12
+	tokenIxApproveChecked  = 13
+)
+
+func tokenAmountData(ix byte, amount uint64, decimals uint8) []byte {
+	data := make([]byte, 10)
+	data[0] = ix
+	binary.LittleEndian.PutUint64(data[1:9], amount)
+	data[9] = decimals
+	return data
+}
+
+// ApproveCheckedInstruction lets delegate move up to amount of mint out of owner's associated token account.
+func ApproveCheckedInstruction(program, mint, owner, delegate solana.PublicKey, amount uint64, decimals uint8) solana.Instruction {
+	return solana.NewInstruction(program, solana.AccountMetaSlice{
+		solana.Meta(AssociatedTokenAddress(owner, program, mint)).WRITE(),
+		solana.Meta(mint),
+		solana.Meta(delegate),
+		solana.Meta(owner).SIGNER(),
+	}, tokenAmountData(tokenIxApproveChecked, amount, decimals))
+}
+
+// RevokeInstruction removes the delegate of owner's associated token account for mint.
+func RevokeInstruction(program, mint, owner solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(program, solana.AccountMetaSlice{
+		solana.Meta(AssociatedTokenAddress(owner, program, mint)).WRITE(),
+		solana.Meta(owner).SIGNER(),
+	}, []byte{tokenIxRevoke})
+}
+
+// DelegatedTransferInstruction moves amount of mint from owner's associated token account to
+// recipient's, signed by the delegate owner approved.
+func DelegatedTransferInstruction(program, mint, owner, recipient, delegate solana.PublicKey, amount uint64, decimals uint8) solana.Instruction {
+	return solana.NewInstruction(program, solana.AccountMetaSlice{
+		solana.Meta(AssociatedTokenAddress(owner, program, mint)).WRITE(),
+		solana.Meta(mint),
+		solana.Meta(AssociatedTokenAddress(recipient, program, mint)).WRITE(),
+		solana.Meta(delegate).SIGNER(),
+	}, tokenAmountData(tokenIxTransferChecked, amount, decimals))
+}
+
+// CreateAssociatedTokenAccountIdempotentInstruction creates owner's associated token account for
+// mint, paid by payer, and does nothing if it already exists.
+func CreateAssociatedTokenAccountIdempotentInstruction(program, mint, owner, payer solana.PublicKey) solana.Instruction {
+	return solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, solana.AccountMetaSlice{
+		solana.Meta(payer).WRITE().SIGNER(),
+		solana.Meta(AssociatedTokenAddress(owner, program, mint)).WRITE(),
+		solana.Meta(owner),
+		solana.Meta(mint),
+		solana.Meta(solana.SystemProgramID),
+		solana.Meta(program),
+	}, []byte{1})
+}
+
+// BuildTransaction assembles an unsigned legacy transaction paid by payer and returns it base64 encoded.
+func BuildTransaction(instructions []solana.Instruction, blockhash solana.Hash, payer solana.PublicKey) (string, error) {
+	tx, err := solana.NewTransaction(instructions, blockhash, solana.TransactionPayer(payer))
+	if err != nil {
+		return "", fmt.Errorf("build transaction: %w", err)
+	}
+	return tx.ToBase64()
+}
@@ -0,0 +1,185 @@
+package solanarpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+var (
+	// ErrAccountNotFound is returned for accounts that don't exist on chain.
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrNotAMint is returned by MintInfo for accounts that aren't token mints.
+	ErrNotAMint = errors.New("not a token mint")
+)
+
+var addressLookupTableProgramID = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+// LatestBlockhash returns a confirmed blockhash to build a transaction with.
+func LatestBlockhash(ctx context.Context) (solana.Hash, error) {
+	var res struct {
+		Value struct {
+			Blockhash string `json:"blockhash"`
+		} `json:"value"`
+	}
+	if err := CallContext(ctx, "getLatestBlockhash", []any{map[string]any{"commitment": "confirmed"}}, &res); err != nil {
+		return solana.Hash{}, err
+	}
+	return solana.HashFromBase58(res.Value.Blockhash)
+}
+
+// AccountExists reports whether address holds an account.
+func AccountExists(ctx context.Context, address string) (bool, error) {
+	var res struct {
+		Value *struct{} `json:"value"`
+	}
+	if err := CallContext(ctx, "getAccountInfo", []any{address, map[string]any{"encoding": "base64", "dataSlice": map[string]int{"offset": 0, "length": 0}}}, &res); err != nil {
+		return false, err
+	}
+	return res.Value != nil, nil
+}
+
+// MintInfo returns the token program that owns mint and the mint's decimals.
+func MintInfo(ctx context.Context, mint string) (solana.PublicKey, uint8, error) {
+	var res struct {
+		Value *struct {
+			Owner string `json:"owner"`
+			Data  struct {
+				Parsed struct {
+					Type string `json:"type"`
+					Info struct {
+						Decimals uint8 `json:"decimals"`
+					} `json:"info"`
+				} `json:"parsed"`
+			} `json:"data"`
+		} `json:"value"`
+	}
+	if err := CallContext(ctx, "getAccountInfo", []any{mint, map[string]any{"encoding": "jsonParsed"}}, &res); err != nil {
+		return solana.PublicKey{}, 0, err
+	}
+	if res.Value == nil {
+		return solana.PublicKey{}, 0, ErrAccountNotFound
+	}
+	program, err := solana.PublicKeyFromBase58(res.Value.Owner)
+	if err != nil {
+		return solana.PublicKey{}, 0, err
+	}
+	if res.Value.Data.Parsed.Type != "mint" || (!program.Equals(solana.TokenProgramID) && !program.Equals(solana.Token2022ProgramID)) {
+		return solana.PublicKey{}, 0, fmt.Errorf("%s: %w", mint, ErrNotAMint)
+	}
+	return program, res.Value.Data.Parsed.Info.Decimals, nil
+}
+
+// maxMultipleAccounts is the most addresses getMultipleAccounts takes in one call.
+const maxMultipleAccounts = 100
+
+// tokenAccountInfo holds the fields of a parsed token account read here.
+type tokenAccountInfo struct {
+	Owner    string `json:"owner"`
+	Delegate string `json:"delegate"`
+}
+
+// tokenAccounts maps each of addresses that is a token account to its parsed state. Addresses
+// that don't exist or aren't token accounts are left out.
+func tokenAccounts(ctx context.Context, addresses []string) (map[string]tokenAccountInfo, error) {
+	accounts := make(map[string]tokenAccountInfo, len(addresses))
+	for start := 0; start < len(addresses); start += maxMultipleAccounts {
+		batch := addresses[start:min(start+maxMultipleAccounts, len(addresses))]
+		var res struct {
+			Value []*struct {
+				Owner string          `json:"owner"`
+				Data  json.RawMessage `json:"data"` // Accounts the node can't parse come back as [data, encoding]
+			} `json:"value"`
+		}
+		if err := CallContext(ctx, "getMultipleAccounts", []any{batch, map[string]any{"encoding": "jsonParsed"}}, &res); err != nil {
+			return nil, err
+		}
+		for i, acc := range res.Value {
+			if i >= len(batch) || acc == nil {
+				continue
+			}
+			if acc.Owner != solana.TokenProgramID.String() && acc.Owner != solana.Token2022ProgramID.String() {
+				continue
+			}
+			var data struct {
+				Parsed struct {
+					Type string           `json:"type"`
+					Info tokenAccountInfo `json:"info"`
+				} `json:"parsed"`
+			}
+			if err := json.Unmarshal(acc.Data, &data); err != nil || data.Parsed.Type != "account" || data.Parsed.Info.Owner == "" {
+				continue
+			}
+			accounts[batch[i]] = data.Parsed.Info
+		}
+	}
+	return accounts, nil
+}
+
+// TokenAccountOwners maps each of addresses that is a token account to its owner. Addresses that
+// don't exist or aren't token accounts are left out.
+func TokenAccountOwners(ctx context.Context, addresses []string) (map[string]string, error) {
+	accounts, err := tokenAccounts(ctx, addresses)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]string, len(accounts))
+	for address, acc := range accounts {
+		owners[address] = acc.Owner
+	}
+	return owners, nil
+}
+
+// TokenAccountDelegates maps each of addresses that is a token account with a delegate to that
+// delegate.
+func TokenAccountDelegates(ctx context.Context, addresses []string) (map[string]string, error) {
+	accounts, err := tokenAccounts(ctx, addresses)
+	if err != nil {
+		return nil, err
+	}
+	delegates := map[string]string{}
+	for address, acc := range accounts {
+		if acc.Delegate != "" {
+			delegates[address] = acc.Delegate
+		}
+	}
+	return delegates, nil
+}
+
+// lookupTableMetaSize is the size of an address lookup table's header, before its addresses.
+const lookupTableMetaSize = 56
+
+// LookupTableAddresses returns the addresses stored in the address lookup table at table.
+func LookupTableAddresses(ctx context.Context, table string) (solana.PublicKeySlice, error) {
+	var res struct {
+		Value *struct {
+			Owner string   `json:"owner"`
+			Data  []string `json:"data"`
+		} `json:"value"`
+	}
+	if err := CallContext(ctx, "getAccountInfo", []any{table, map[string]any{"encoding": "base64"}}, &res); err != nil {
+		return nil, err
+	}
+	if res.Value == nil {
+		return nil, ErrAccountNotFound
+	}
+	if res.Value.Owner != addressLookupTableProgramID.String() || len(res.Value.Data) == 0 {
+		return nil, fmt.Errorf("%s is not an address lookup table", table)
+	}
+	data, err := base64.StdEncoding.DecodeString(res.Value.Data[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < lookupTableMetaSize || (len(data)-lookupTableMetaSize)%solana.PublicKeyLength != 0 {
+		return nil, fmt.Errorf("%s: malformed address lookup table", table)
+	}
+	addresses := make(solana.PublicKeySlice, 0, (len(data)-lookupTableMetaSize)/solana.PublicKeyLength)
+	for off := lookupTableMetaSize; off < len(data); off += solana.PublicKeyLength {
+		addresses = append(addresses, solana.PublicKeyFromBytes(data[off:off+solana.PublicKeyLength]))
+	}
+	return addresses, nil
+}
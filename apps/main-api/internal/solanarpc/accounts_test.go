@@ -0,0 +1,29 @@
+package solanarpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenAccountOwners(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"value":[
+			{"owner":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","data":{"parsed":{"type":"account","info":{"owner":"Contact111"}}}},
+			{"owner":"11111111111111111111111111111111","data":["","base64"]},
+			null
+		]}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("SOLANA_MAINNET_RPC_URL", srv.URL)
+	t.Setenv("SOLANA_FALLBACK_RPC_URL", "")
+
+	owners, err := TokenAccountOwners(context.Background(), []string{"TokenAccount", "Wallet", "Missing"})
+	if err != nil {
+		t.Fatalf("TokenAccountOwners() error = %v", err)
+	}
+	if len(owners) != 1 || owners["TokenAccount"] != "Contact111" {
+		t.Errorf("TokenAccountOwners() = %v, want only TokenAccount owned by Contact111", owners)
+	}
+}
@@ -0,0 +1,66 @@
+package solanarpc
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// TokenBalance is an owner's balance of one mint, summed over its token accounts.
+type TokenBalance struct {
+	Mint     string
+	Raw      *big.Int
+	Decimals uint8
+}
+
+// Balances returns the lamports held by address and its non-zero SPL token balances, classic and
+// Token-2022, summed per mint.
+func Balances(ctx context.Context, address string) (uint64, []TokenBalance, error) {
+	var sol struct {
+		Value uint64 `json:"value"`
+	}
+	if err := CallContext(ctx, "getBalance", []any{address, map[string]any{"commitment": "confirmed"}}, &sol); err != nil {
+		return 0, nil, err
+	}
+
+	tokens := []TokenBalance{}
+	byMint := map[string]int{}
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		var accounts struct {
+			Value []struct {
+				Account struct {
+					Data struct {
+						Parsed struct {
+							Info struct {
+								Mint        string `json:"mint"`
+								TokenAmount struct {
+									Amount   string `json:"amount"`
+									Decimals uint8  `json:"decimals"`
+								} `json:"tokenAmount"`
+							} `json:"info"`
+						} `json:"parsed"`
+					} `json:"data"`
+				} `json:"account"`
+			} `json:"value"`
+		}
+		params := []any{address, map[string]any{"programId": program.String()}, map[string]any{"encoding": "jsonParsed", "commitment": "confirmed"}}
+		if err := CallContext(ctx, "getTokenAccountsByOwner", params, &accounts); err != nil {
+			return 0, nil, err
+		}
+		for _, acct := range accounts.Value {
+			info := acct.Account.Data.Parsed.Info
+			raw, ok := new(big.Int).SetString(info.TokenAmount.Amount, 10)
+			if !ok || raw.Sign() == 0 {
+				continue
+			}
+			if i, ok := byMint[info.Mint]; ok {
+				tokens[i].Raw.Add(tokens[i].Raw, raw)
+				continue
+			}
+			byMint[info.Mint] = len(tokens)
+			tokens = append(tokens, TokenBalance{Mint: info.Mint, Raw: raw, Decimals: info.TokenAmount.Decimals})
+		}
+	}
+	return sol.Value, tokens, nil
+}
@@ -0,0 +1,123 @@
+// Package solanarpc is a minimal JSON-RPC client for the Solana upstreams main-api talks to.
+package solanarpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrNotConfigured is returned when no upstream RPC URL is configured.
+var ErrNotConfigured = errors.New("no Solana RPC upstream configured")
+
+// RPCError is an error the node returned for a request, such as a failed preflight simulation.
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: rpc error %d: %s", e.Method, e.Code, e.Message)
+}
+
+// Upstreams returns the configured RPC endpoints in the order they should be tried:
+// SOLANA_MAINNET_RPC_URL (or an Alchemy URL built from GLOBAL__ALCHEMY_API_KEY), then SOLANA_FALLBACK_RPC_URL.
+func Upstreams() []string {
+	upstreamPrimary := os.Getenv("SOLANA_MAINNET_RPC_URL")
+	if upstreamPrimary == "" {
+		// Fallback: construct Alchemy endpoint from existing global key to avoid extra secrets
+		if key := os.Getenv("GLOBAL__ALCHEMY_API_KEY"); key != "" {
+			upstreamPrimary = "https://solana-mainnet.g.alchemy.com/v2/" + key
+		}
+	}
+	if upstreamPrimary == "" {
+		return nil
+	}
+	upstreams := []string{upstreamPrimary}
+	if fb := os.Getenv("SOLANA_FALLBACK_RPC_URL"); fb != "" {
+		upstreams = append(upstreams, fb)
+	}
+	return upstreams
+}
+
+// Call makes a single JSON-RPC call, trying each upstream in turn, and decodes the "result" field into result.
+func Call(method string, params []any, result any) error {
+	return CallContext(context.Background(), method, params, result)
+}
+
+// CallContext is Call with a context bounding the requests.
+func CallContext(ctx context.Context, method string, params []any, result any) error {
+	upstreams := Upstreams()
+	if len(upstreams) == 0 {
+		return ErrNotConfigured
+	}
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, url := range upstreams {
+		respBody, status, err := ForwardContext(ctx, url, body)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+		if status != http.StatusOK {
+			lastErr = fmt.Errorf("%s: upstream returned status %d", method, status)
+			continue
+		}
+		var rpcResp struct {
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+			lastErr = fmt.Errorf("%s: decode response: %w", method, err)
+			continue
+		}
+		if rpcResp.Error != nil {
+			// The node rejected the request itself; another upstream won't do better
+			return &RPCError{Method: method, Code: rpcResp.Error.Code, Message: rpcResp.Error.Message}
+		}
+		return json.Unmarshal(rpcResp.Result, result)
+	}
+	return lastErr
+}
+
+// Forward posts a raw JSON-RPC body to url and returns the response body and status.
+func Forward(url string, body []byte) ([]byte, int, error) {
+	return ForwardContext(context.Background(), url, body)
+}
+
+// ForwardContext is Forward with a context bounding the request.
+func ForwardContext(ctx context.Context, url string, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return respBody, resp.StatusCode, nil
+}
@@ -0,0 +1,68 @@
+package solanarpc
+
+import (
+	"context"
+	"sort"
+)
+
+// maxPriorityFeeMicroLamports caps estimated compute-unit prices so a brief fee spike can't
+// make a tier absurdly expensive (0.001 SOL at 200k compute units).
+const maxPriorityFeeMicroLamports = 5_000_000
+
+// PriorityFeeTier is a suggested compute-unit price.
+type PriorityFeeTier struct {
+	Name                        string `json:"name"`
+	MicroLamportsPerComputeUnit uint64 `json:"microLamportsPerComputeUnit"`
+}
+
+// priorityFeePercentiles maps each tier to the percentile of recent fees it pays.
+var priorityFeePercentiles = []struct {
+	name       string
+	percentile int
+}{
+	{"low", 25},
+	{"medium", 50},
+	{"high", 75},
+	{"urgent", 95},
+}
+
+// RecentPriorityFees returns the prioritization fees (micro-lamports per compute unit) paid in
+// recent slots by transactions that write-locked any of accounts, or by all transactions when
+// accounts is empty.
+func RecentPriorityFees(ctx context.Context, accounts []string) ([]uint64, error) {
+	params := []any{}
+	if len(accounts) > 0 {
+		params = append(params, accounts)
+	}
+	var samples []struct {
+		Slot              uint64 `json:"slot"`
+		PrioritizationFee uint64 `json:"prioritizationFee"`
+	}
+	if err := CallContext(ctx, "getRecentPrioritizationFees", params, &samples); err != nil {
+		return nil, err
+	}
+	fees := make([]uint64, len(samples))
+	for i, s := range samples {
+		fees[i] = s.PrioritizationFee
+	}
+	return fees, nil
+}
+
+// PriorityFeeTiers turns recent fee samples into tiers, cheapest first. Each tier pays a
+// percentile of the samples, capped at maxPriorityFeeMicroLamports.
+func PriorityFeeTiers(fees []uint64) []PriorityFeeTier {
+	sorted := append([]uint64(nil), fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	tiers := make([]PriorityFeeTier, len(priorityFeePercentiles))
+	for i, p := range priorityFeePercentiles {
+		tiers[i].Name = p.name
+		if len(sorted) == 0 {
+			continue
+		}
+		// Nearest-rank percentile
+		rank := (p.percentile*len(sorted) + 99) / 100
+		tiers[i].MicroLamportsPerComputeUnit = min(sorted[max(rank, 1)-1], maxPriorityFeeMicroLamports)
+	}
+	return tiers
+}
@@ -0,0 +1,29 @@
+package solanarpc
+
+import "testing"
+
+func TestPriorityFeeTiers(t *testing.T) {
+	tests := []struct {
+		name string
+		fees []uint64
+		want []uint64
+	}{
+		{"no samples", nil, []uint64{0, 0, 0, 0}},
+		{"one sample", []uint64{700}, []uint64{700, 700, 700, 700}},
+		{"percentiles of unsorted samples", []uint64{10, 1, 9, 2, 8, 3, 7, 4, 6, 5}, []uint64{3, 5, 8, 10}},
+		{"capped", []uint64{1, 2, 3, maxPriorityFeeMicroLamports * 10}, []uint64{1, 2, 3, maxPriorityFeeMicroLamports}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiers := PriorityFeeTiers(tt.fees)
+			if len(tiers) != len(tt.want) {
+				t.Fatalf("got %d tiers, want %d", len(tiers), len(tt.want))
+			}
+			for i, tier := range tiers {
+				if tier.MicroLamportsPerComputeUnit != tt.want[i] {
+					t.Errorf("%s = %d, want %d", tier.Name, tier.MicroLamportsPerComputeUnit, tt.want[i])
+				}
+			}
+		})
+	}
+}
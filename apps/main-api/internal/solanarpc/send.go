@@ -0,0 +1,163 @@
+package solanarpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// Final statuses reported by SendAndConfirm.
+const (
+	StatusConfirmed = "confirmed" // Landed without error at confirmed commitment or better
+	StatusFailed    = "failed"    // Rejected by preflight, or landed with an error
+	StatusExpired   = "expired"   // Its blockhash expired and it was not seen; it can never land now
+	StatusPending   = "pending"   // Its blockhash expired but it was seen unconfirmed, or couldn't be looked up; it may still land
+)
+
+// rebroadcastInterval is how often an unconfirmed transaction is re-sent and its status polled.
+const rebroadcastInterval = 2 * time.Second
+
+// SendResult is the final outcome of SendAndConfirm.
+type SendResult struct {
+	Signature string `json:"signature"`
+	Status    string `json:"status"`
+	Slot      uint64 `json:"slot,omitempty"`
+	Error     string `json:"error,omitempty"` // Preflight or on-chain error when Status is failed
+	Attempts  int    `json:"attempts"`        // Times the transaction was broadcast
+}
+
+type signatureStatus struct {
+	Slot               uint64 `json:"slot"`
+	Err                any    `json:"err"`
+	ConfirmationStatus string `json:"confirmationStatus"`
+}
+
+// SendAndConfirm broadcasts a base64 signed transaction and keeps re-broadcasting it to every
+// upstream until it is confirmed, fails, or its blockhash expires. The first send runs preflight
+// so simulation errors come back at once. It reports expired only once the blockhash is verified
+// invalid and the signature is nowhere to be found, so callers may safely send a new transaction;
+// a pending result must not be retried. It returns an error when the outcome is unknown, e.g. no
+// upstream was reachable or ctx ended first.
+func SendAndConfirm(ctx context.Context, signedTx string) (*SendResult, error) {
+	tx, err := solana.TransactionFromBase64(signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("decode transaction: %w", err)
+	}
+	if len(tx.Signatures) == 0 {
+		return nil, errors.New("transaction is not signed")
+	}
+	res := &SendResult{Signature: tx.Signatures[0].String()}
+	blockhash := tx.Message.RecentBlockhash.String()
+
+	var sig string
+	err = CallContext(ctx, "sendTransaction", []any{signedTx, map[string]any{
+		"encoding":            "base64",
+		"preflightCommitment": "confirmed",
+		"maxRetries":          0,
+	}}, &sig)
+	res.Attempts++
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		res.Status, res.Error = StatusFailed, rpcErr.Message
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rebroadcast, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "sendTransaction", "params": []any{signedTx, map[string]any{
+		"encoding":      "base64",
+		"skipPreflight": true,
+		"maxRetries":    0,
+	}}})
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(rebroadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if done, _, err := checkSignature(ctx, res, false); done || err != nil {
+			return res, err
+		}
+		var valid struct {
+			Value bool `json:"value"`
+		}
+		if err := CallContext(ctx, "isBlockhashValid", []any{blockhash, map[string]any{"commitment": "confirmed"}}, &valid); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// Treat a failed check as still valid; the next round asks again
+			valid.Value = true
+		}
+		if !valid.Value {
+			// It may have landed in the last round; look once more across history before giving up
+			done, seen, err := checkSignature(ctx, res, true)
+			if done || err != nil {
+				return res, err
+			}
+			res.Status = StatusExpired
+			if seen {
+				res.Status = StatusPending
+			}
+			return res, nil
+		}
+		broadcast(ctx, rebroadcast)
+		res.Attempts++
+	}
+}
+
+// checkSignature fills res and reports true once the transaction has a final status. seen is
+// true when it is known at some commitment short of confirmed, or when the lookup failed, since
+// then it can't be ruled out.
+func checkSignature(ctx context.Context, res *SendResult, searchHistory bool) (done, seen bool, err error) {
+	var statuses struct {
+		Value []*signatureStatus `json:"value"`
+	}
+	err = CallContext(ctx, "getSignatureStatuses", []any{[]string{res.Signature}, map[string]any{"searchTransactionHistory": searchHistory}}, &statuses)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, false, ctx.Err()
+		}
+		return false, true, nil
+	}
+	if len(statuses.Value) == 0 || statuses.Value[0] == nil {
+		return false, false, nil
+	}
+	st := statuses.Value[0]
+	if st.Err != nil {
+		errJSON, _ := json.Marshal(st.Err)
+		res.Status, res.Slot, res.Error = StatusFailed, st.Slot, string(errJSON)
+		return true, true, nil
+	}
+	res.Slot = st.Slot
+	if st.ConfirmationStatus == "confirmed" || st.ConfirmationStatus == "finalized" {
+		res.Status = StatusConfirmed
+		return true, true, nil
+	}
+	return false, true, nil
+}
+
+// broadcast posts body to every upstream at once, ignoring the responses.
+func broadcast(ctx context.Context, body []byte) {
+	var wg sync.WaitGroup
+	for _, url := range Upstreams() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			ForwardContext(ctx, url, body)
+		}(url)
+	}
+	wg.Wait()
+}
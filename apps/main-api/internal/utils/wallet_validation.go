@@ -70,4 +70,36 @@ func FormatAddressForDisplay(address string, prefixLength, suffixLength int) str
 // IsAddressEqual compares two wallet addresses (case-sensitive, whitespace-trimmed)
 func IsAddressEqual(addr1, addr2 string) bool {
 	return NormalizeWalletAddress(addr1) == NormalizeWalletAddress(addr2)
-}
\ No newline at end of file
+}
+
+// Address-poisoning attacks send dust from a generated address that starts and ends like one the
+// victim uses, hoping it gets copied from history. These bound what counts as a lookalike.
+const (
+	lookalikePrefixLength = 3 // Leading characters a lookalike shares with the real address
+	lookalikeSuffixLength = 3 // Trailing characters a lookalike shares with the real address
+	lookalikeMaxDiff      = 3 // Differing positions still considered a lookalike regardless of prefix/suffix
+)
+
+// IsLookalikeAddress reports whether candidate is a different address that could be mistaken for
+// known: it shows the same truncated form (as FormatAddressForDisplay renders it) or differs in only
+// a few characters.
+func IsLookalikeAddress(candidate, known string) bool {
+	candidate, known = NormalizeWalletAddress(candidate), NormalizeWalletAddress(known)
+	if candidate == known || candidate == "" || known == "" {
+		return false
+	}
+	if len(candidate) > lookalikePrefixLength+lookalikeSuffixLength+3 &&
+		FormatAddressForDisplay(candidate, lookalikePrefixLength, lookalikeSuffixLength) == FormatAddressForDisplay(known, lookalikePrefixLength, lookalikeSuffixLength) {
+		return true
+	}
+	if len(candidate) != len(known) {
+		return false
+	}
+	diff := 0
+	for i := range candidate {
+		if candidate[i] != known[i] {
+			diff++
+		}
+	}
+	return diff <= lookalikeMaxDiff
+}
@@ -245,4 +245,57 @@ func TestIsAddressEqual(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestIsLookalikeAddress(t *testing.T) {
+	const known = "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU"
+	tests := []struct {
+		name      string
+		candidate string
+		want      bool
+	}{
+		{
+			name:      "Same address",
+			candidate: known,
+			want:      false,
+		},
+		{
+			name:      "Same address with whitespace",
+			candidate: " " + known + " ",
+			want:      false,
+		},
+		{
+			name:      "Poisoned: same prefix and suffix",
+			candidate: "7xKq9Vb1mZ4wPp3NcRrT5uYhLdE8fGjKsW2aBvCgAsU",
+			want:      true,
+		},
+		{
+			name:      "One character changed in the middle",
+			candidate: "7xKXtg2CW87d97TXJSDpbD5jBkhfTqA83TZRuJosgAsU",
+			want:      true,
+		},
+		{
+			name:      "Unrelated address",
+			candidate: "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+			want:      false,
+		},
+		{
+			name:      "Same prefix only",
+			candidate: "7xKq9Vb1mZ4wPp3NcRrT5uYhLdE8fGjKsW2aBvCgZZZ",
+			want:      false,
+		},
+		{
+			name:      "Empty",
+			candidate: "",
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsLookalikeAddress(tt.candidate, known); got != tt.want {
+				t.Errorf("IsLookalikeAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+-- Migration: Per-account login lockout
+-- Created: 2026-10-18
+-- Purpose: Track temporary lockouts and failure-count resets so repeated failed logins against one account are throttled regardless of source IP
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS failed_logins_reset_at TIMESTAMPTZ NULL;
+
+-- Failed attempts are counted per user within a sliding window
+CREATE INDEX IF NOT EXISTS idx_login_activities_user_status_created ON login_activities(user_id, status, created_at);
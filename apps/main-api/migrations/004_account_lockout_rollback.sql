@@ -0,0 +1,9 @@
+-- Rollback Migration: Per-account login lockout
+-- Created: 2026-10-18
+-- Purpose: Remove lockout columns and the login activity lookup index
+
+DROP INDEX IF EXISTS idx_login_activities_user_status_created;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS failed_logins_reset_at,
+  DROP COLUMN IF EXISTS locked_until;
@@ -0,0 +1,21 @@
+-- Migration: Email change requests
+-- Created: 2026-10-18
+-- Purpose: Hold pending User.Email changes until the new address confirms a code
+
+CREATE TABLE IF NOT EXISTS email_change_requests (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMPTZ NULL,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  old_email TEXT NOT NULL,
+  new_email TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  expires_at TIMESTAMPTZ NOT NULL,
+  confirmed_at TIMESTAMPTZ NULL,
+  cancelled_at TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_user_id ON email_change_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_new_email ON email_change_requests(new_email);
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_deleted_at ON email_change_requests(deleted_at);
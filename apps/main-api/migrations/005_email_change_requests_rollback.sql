@@ -0,0 +1,5 @@
+-- Rollback Migration: Email change requests
+-- Created: 2026-10-18
+-- Purpose: Drop the email change request table
+
+DROP TABLE IF EXISTS email_change_requests;
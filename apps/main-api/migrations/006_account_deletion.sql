@@ -0,0 +1,13 @@
+-- Migration: Account deletion grace period and purge
+-- Created: 2026-10-18
+-- Purpose: Schedule account deletions after a grace period and record when the purge job has removed the user's data
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS deletion_scheduled_for TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS purged_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_for ON users(deletion_scheduled_for);
+
+-- Accounts soft-deleted before this migration are not scheduled here: the purge job schedules them
+-- MAIN_API__ACCOUNT_DELETION_GRACE_PERIOD from its first run, which SQL can't read
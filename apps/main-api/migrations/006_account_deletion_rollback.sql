@@ -0,0 +1,10 @@
+-- Rollback Migration: Account deletion grace period and purge
+-- Created: 2026-10-18
+-- Purpose: Drop the deletion scheduling columns (purged rows stay anonymized)
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_for;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS purged_at,
+  DROP COLUMN IF EXISTS deletion_scheduled_for,
+  DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,22 @@
+-- Migration: Personal data exports
+-- Created: 2026-10-18
+-- Purpose: Track user-requested data exports; the ZIP archive is stored inline until its download link expires
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  deleted_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id),
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  error TEXT,
+  archive BYTEA,
+  size_bytes BIGINT DEFAULT 0,
+  completed_at TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ,
+  downloaded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_deleted_at ON data_exports(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);
@@ -0,0 +1,5 @@
+-- Rollback Migration: Personal data exports
+-- Created: 2026-10-18
+-- Purpose: Drop the data export table
+
+DROP TABLE IF EXISTS data_exports;
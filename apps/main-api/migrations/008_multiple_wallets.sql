@@ -0,0 +1,28 @@
+-- Migration: Multiple wallets per user
+-- Created: 2026-10-18
+-- Purpose: Let users hold several named wallets, pick a default, archive wallets, and derive extra bip44 accounts from one recovery phrase
+
+ALTER TABLE wallets
+  ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS derivation_path VARCHAR(64) NULL,
+  ADD COLUMN IF NOT EXISTS account_index INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS seed_wallet_id BIGINT NULL REFERENCES wallets(id);
+
+CREATE INDEX IF NOT EXISTS idx_wallets_archived_at ON wallets(archived_at);
+CREATE INDEX IF NOT EXISTS idx_wallets_seed_wallet_id ON wallets(seed_wallet_id);
+
+-- Every existing wallet was created at the first Solana account
+UPDATE wallets SET derivation_path = 'm/44''/501''/0''/0''' WHERE derivation_path IS NULL;
+
+-- Existing users have one wallet; make each user's oldest live wallet the default
+UPDATE wallets SET is_default = TRUE
+  WHERE id IN (
+    SELECT DISTINCT ON (user_id) id FROM wallets
+    WHERE deleted_at IS NULL
+    ORDER BY user_id, id
+  );
+
+-- At most one default wallet per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_one_default_per_user
+  ON wallets(user_id) WHERE is_default AND deleted_at IS NULL;
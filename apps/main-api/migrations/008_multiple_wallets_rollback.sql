@@ -0,0 +1,15 @@
+-- Rollback Migration: Multiple wallets per user
+-- Created: 2026-10-18
+-- Purpose: Drop default/archive/derivation columns from wallets
+-- Note: wallets derived at account_index > 0 can no longer be signed for after rollback; move their funds first
+
+DROP INDEX IF EXISTS idx_wallets_one_default_per_user;
+DROP INDEX IF EXISTS idx_wallets_seed_wallet_id;
+DROP INDEX IF EXISTS idx_wallets_archived_at;
+
+ALTER TABLE wallets
+  DROP COLUMN IF EXISTS seed_wallet_id,
+  DROP COLUMN IF EXISTS account_index,
+  DROP COLUMN IF EXISTS derivation_path,
+  DROP COLUMN IF EXISTS archived_at,
+  DROP COLUMN IF EXISTS is_default;
@@ -0,0 +1,13 @@
+-- Migration: Wallet import
+-- Created: 2026-10-18
+-- Purpose: Store wallets imported from a recovery phrase or a base58 secret key
+
+ALTER TABLE wallets
+  ADD COLUMN IF NOT EXISTS secret_type VARCHAR(16) NOT NULL DEFAULT 'mnemonic',
+  ADD COLUMN IF NOT EXISTS imported_at TIMESTAMPTZ NULL;
+
+-- Imported wallets record the account level of their path, so accounts derived from them continue after it
+UPDATE wallets
+  SET account_index = substring(derivation_path from '^m/44''/501''/([0-9]+)''')::INTEGER
+  WHERE imported_at IS NOT NULL AND secret_type = 'mnemonic' AND account_index = 0
+    AND derivation_path ~ '^m/44''/501''/[0-9]+''';
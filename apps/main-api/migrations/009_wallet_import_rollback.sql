@@ -0,0 +1,8 @@
+-- Rollback Migration: Wallet import
+-- Created: 2026-10-18
+-- Purpose: Drop wallet import columns
+-- Note: wallets imported from a secret key can no longer be signed for after rollback; move their funds first
+
+ALTER TABLE wallets
+  DROP COLUMN IF EXISTS imported_at,
+  DROP COLUMN IF EXISTS secret_type;
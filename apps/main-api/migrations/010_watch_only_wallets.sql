@@ -0,0 +1,11 @@
+-- Migration: Watch-only wallets
+-- Created: 2026-10-18
+-- Purpose: Let users track addresses they hold elsewhere (e.g. cold storage) without storing a secret
+
+ALTER TABLE wallets
+  ADD COLUMN IF NOT EXISTS watch_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Addresses stay unique among owned wallets, but several users may watch the same address
+DROP INDEX IF EXISTS idx_wallets_address;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_owned_address ON wallets(address) WHERE watch_only = false;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_address ON wallets(user_id, address);
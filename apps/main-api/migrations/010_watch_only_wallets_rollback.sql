@@ -0,0 +1,12 @@
+-- Rollback Migration: Watch-only wallets
+-- Created: 2026-10-18
+-- Purpose: Remove watch-only wallets and restore the global unique address index
+
+DELETE FROM wallets WHERE watch_only = true;
+
+DROP INDEX IF EXISTS idx_wallets_user_address;
+DROP INDEX IF EXISTS idx_wallets_owned_address;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_address ON wallets(address);
+
+ALTER TABLE wallets
+  DROP COLUMN IF EXISTS watch_only;
@@ -0,0 +1,6 @@
+-- Migration: Wallet recovery after password reset
+-- Created: 2026-10-18
+-- Purpose: Flag wallets whose secret is still encrypted with a password the account no longer uses
+
+ALTER TABLE wallets
+  ADD COLUMN IF NOT EXISTS recovery_required_at TIMESTAMPTZ NULL;
@@ -0,0 +1,7 @@
+-- Rollback Migration: Wallet recovery after password reset
+-- Created: 2026-10-18
+-- Purpose: Drop the wallet recovery flag
+-- Note: flagged wallets still can't be decrypted with the account password after rollback
+
+ALTER TABLE wallets
+  DROP COLUMN IF EXISTS recovery_required_at;
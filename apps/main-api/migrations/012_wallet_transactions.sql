@@ -0,0 +1,40 @@
+-- Migration: Wallet transaction history index
+-- Created: 2026-10-18
+-- Purpose: Store parsed on-chain history per wallet address, with the indexer's cursors per address
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  address VARCHAR(44) NOT NULL,
+  signature VARCHAR(88) NOT NULL,
+  slot BIGINT NOT NULL,
+  block_time TIMESTAMPTZ,
+  success BOOLEAN NOT NULL,
+  parsed BOOLEAN NOT NULL DEFAULT FALSE,
+  type VARCHAR(16) NOT NULL DEFAULT 'other',
+  token VARCHAR(44),
+  amount VARCHAR(64),
+  counterparty VARCHAR(44),
+  fee BIGINT NOT NULL DEFAULT 0,
+  received_token VARCHAR(44),
+  received_amount VARCHAR(64)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_tx_address_signature ON wallet_transactions(address, signature);
+CREATE INDEX IF NOT EXISTS idx_wallet_tx_address_slot ON wallet_transactions(address, slot DESC);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_parsed ON wallet_transactions(parsed);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_type ON wallet_transactions(type);
+
+CREATE TABLE IF NOT EXISTS wallet_index_states (
+  address VARCHAR(44) PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  newest_signature VARCHAR(88),
+  oldest_signature VARCHAR(88),
+  backfill_done BOOLEAN NOT NULL DEFAULT FALSE,
+  last_synced_at TIMESTAMPTZ,
+  last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_index_states_last_synced_at ON wallet_index_states(last_synced_at);
@@ -0,0 +1,6 @@
+-- Rollback Migration: Wallet transaction history index
+-- Created: 2026-10-18
+-- Purpose: Drop the transaction history index; history falls back to the solana-api proxy
+
+DROP TABLE IF EXISTS wallet_index_states;
+DROP TABLE IF EXISTS wallet_transactions;
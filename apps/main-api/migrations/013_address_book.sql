@@ -0,0 +1,17 @@
+-- Migration: Address book
+-- Created: 2026-10-18
+-- Purpose: Labeled recipient addresses per user, used for send-to-contact and lookalike address warnings
+
+CREATE TABLE IF NOT EXISTS address_book_contacts (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  label VARCHAR(50) NOT NULL,
+  address VARCHAR(44) NOT NULL,
+  note VARCHAR(500),
+  favorite BOOLEAN NOT NULL DEFAULT FALSE,
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_user_address ON address_book_contacts(user_id, address);
@@ -0,0 +1,5 @@
+-- Rollback Migration: Address book
+-- Created: 2026-10-18
+-- Purpose: Drop saved contacts
+
+DROP TABLE IF EXISTS address_book_contacts;
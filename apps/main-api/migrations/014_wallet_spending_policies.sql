@@ -0,0 +1,44 @@
+-- Migration: Wallet spending policies
+-- Created: 2026-10-18
+-- Purpose: Per-wallet USD limits and recipient allowlists enforced before server-side signing
+
+CREATE TABLE IF NOT EXISTS wallet_spending_policies (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  daily_limit_usd NUMERIC(20,2),
+  per_tx_limit_usd NUMERIC(20,2),
+  allowlist_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  change_delay_hours INTEGER NOT NULL DEFAULT 24,
+  pending JSONB,
+  pending_effective_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_spending_policies_wallet_id ON wallet_spending_policies(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_spending_policies_user_id ON wallet_spending_policies(user_id);
+
+CREATE TABLE IF NOT EXISTS wallet_allowlist_entries (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  address VARCHAR(44) NOT NULL,
+  label VARCHAR(50),
+  active_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_allowlist_wallet_address ON wallet_allowlist_entries(wallet_id, address);
+CREATE INDEX IF NOT EXISTS idx_wallet_allowlist_entries_user_id ON wallet_allowlist_entries(user_id);
+
+CREATE TABLE IF NOT EXISTS wallet_spends (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  wallet_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  amount_usd NUMERIC(20,2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_spends_wallet_created ON wallet_spends(wallet_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_spends_user_id ON wallet_spends(user_id);
@@ -0,0 +1,7 @@
+-- Rollback Migration: Wallet spending policies
+-- Created: 2026-10-18
+-- Purpose: Drop spending policies; signing is no longer limited
+
+DROP TABLE IF EXISTS wallet_spends;
+DROP TABLE IF EXISTS wallet_allowlist_entries;
+DROP TABLE IF EXISTS wallet_spending_policies;
@@ -0,0 +1,47 @@
+-- Migration: Scheduled transfers
+-- Created: 2026-10-18
+-- Purpose: Recurring SPL token transfers executed by a delegate the wallet approved, with a run history
+
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  label VARCHAR(50),
+  recipient VARCHAR(44) NOT NULL,
+  contact_id BIGINT,
+  mint VARCHAR(44) NOT NULL,
+  symbol VARCHAR(16),
+  token_program VARCHAR(44) NOT NULL,
+  decimals SMALLINT NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  raw_amount BIGINT NOT NULL,
+  cadence VARCHAR(100) NOT NULL,
+  start_at TIMESTAMPTZ NOT NULL,
+  end_at TIMESTAMPTZ NOT NULL,
+  next_run_at TIMESTAMPTZ,
+  status VARCHAR(16) NOT NULL DEFAULT 'active',
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  approval_signature VARCHAR(88)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_user_id ON scheduled_transfers(user_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_wallet_id ON scheduled_transfers(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_next_run_at ON scheduled_transfers(next_run_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_status ON scheduled_transfers(status);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfer_executions (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  scheduled_transfer_id BIGINT NOT NULL REFERENCES scheduled_transfers(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL,
+  scheduled_for TIMESTAMPTZ NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  signature VARCHAR(88),
+  error VARCHAR(500),
+  attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_executions ON scheduled_transfer_executions(scheduled_transfer_id, scheduled_for DESC);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_executions_user_id ON scheduled_transfer_executions(user_id);
@@ -0,0 +1,6 @@
+-- Rollback Migration: Scheduled transfers
+-- Created: 2026-10-18
+-- Purpose: Drop scheduled transfers and their run history; approvals already granted stay on chain
+
+DROP TABLE IF EXISTS scheduled_transfer_executions;
+DROP TABLE IF EXISTS scheduled_transfers;
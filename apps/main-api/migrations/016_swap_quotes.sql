@@ -0,0 +1,21 @@
+-- Migration: Swap quotes
+-- Created: 2026-10-18
+-- Purpose: Server-issued swap quotes that /swap/execute accepts by ID, once, before they expire
+
+CREATE TABLE IF NOT EXISTS swap_quotes (
+  id VARCHAR(32) PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  input_mint VARCHAR(44) NOT NULL,
+  output_mint VARCHAR(44) NOT NULL,
+  in_amount VARCHAR(32) NOT NULL,
+  out_amount VARCHAR(32) NOT NULL,
+  slippage_bps INTEGER NOT NULL,
+  price_impact VARCHAR(32),
+  quote_response JSONB NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_swap_quotes_user_id ON swap_quotes(user_id);
+CREATE INDEX IF NOT EXISTS idx_swap_quotes_expires_at ON swap_quotes(expires_at);
@@ -0,0 +1,5 @@
+-- Rollback Migration: Swap quotes
+-- Created: 2026-10-18
+-- Purpose: Drop server-issued swap quotes
+
+DROP TABLE IF EXISTS swap_quotes;
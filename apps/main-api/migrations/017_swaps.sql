@@ -0,0 +1,35 @@
+-- Migration: Swap history
+-- Created: 2026-10-18
+-- Purpose: Record every executed swap with its quoted amounts and what actually moved on chain
+
+CREATE TABLE IF NOT EXISTS swaps (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  wallet_id BIGINT NOT NULL,
+  wallet_address VARCHAR(44) NOT NULL,
+  quote_id VARCHAR(32),
+  input_mint VARCHAR(44) NOT NULL,
+  output_mint VARCHAR(44) NOT NULL,
+  in_amount VARCHAR(32) NOT NULL,
+  quoted_out_amount VARCHAR(32) NOT NULL,
+  min_out_amount VARCHAR(32),
+  slippage_bps INTEGER NOT NULL,
+  price_impact VARCHAR(32),
+  signature VARCHAR(88) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  error VARCHAR(500),
+  spent_in_amount VARCHAR(32),
+  received_out_amount VARCHAR(32),
+  input_decimals SMALLINT,
+  output_decimals SMALLINT,
+  fee_lamports BIGINT NOT NULL DEFAULT 0,
+  slot BIGINT NOT NULL DEFAULT 0,
+  settled_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_swaps_user_id ON swaps(user_id);
+CREATE INDEX IF NOT EXISTS idx_swaps_wallet_id ON swaps(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_swaps_status ON swaps(status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_swaps_signature ON swaps(signature);
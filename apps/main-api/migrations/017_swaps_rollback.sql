@@ -0,0 +1,5 @@
+-- Rollback Migration: Swap history
+-- Created: 2026-10-18
+-- Purpose: Drop the swap history
+
+DROP TABLE IF EXISTS swaps;
@@ -0,0 +1,38 @@
+-- Migration: Swap orders
+-- Created: 2026-10-18
+-- Purpose: TEAM556 limit orders and DCA plans executed through a delegated approval, linked to the swaps they made
+
+CREATE TABLE IF NOT EXISTS swap_orders (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  wallet_id BIGINT NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+  kind VARCHAR(8) NOT NULL,
+  side VARCHAR(4) NOT NULL,
+  input_mint VARCHAR(44) NOT NULL,
+  output_mint VARCHAR(44) NOT NULL,
+  input_program VARCHAR(44) NOT NULL,
+  input_decimals SMALLINT NOT NULL,
+  output_decimals SMALLINT NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  raw_amount BIGINT NOT NULL,
+  slippage_bps INTEGER NOT NULL,
+  limit_price VARCHAR(32),
+  cadence VARCHAR(100),
+  end_at TIMESTAMPTZ NOT NULL,
+  next_run_at TIMESTAMPTZ,
+  status VARCHAR(16) NOT NULL DEFAULT 'active',
+  executions INTEGER NOT NULL DEFAULT 0,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  last_error VARCHAR(500),
+  approval_signature VARCHAR(88)
+);
+
+CREATE INDEX IF NOT EXISTS idx_swap_orders_user_id ON swap_orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_swap_orders_wallet_id ON swap_orders(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_swap_orders_next_run_at ON swap_orders(next_run_at);
+CREATE INDEX IF NOT EXISTS idx_swap_orders_status ON swap_orders(status);
+
+ALTER TABLE swaps ADD COLUMN IF NOT EXISTS order_id BIGINT;
+CREATE INDEX IF NOT EXISTS idx_swaps_order_id ON swaps(order_id);
@@ -0,0 +1,7 @@
+-- Rollback Migration: Swap orders
+-- Created: 2026-10-18
+-- Purpose: Drop swap orders and unlink their swaps; approvals already granted stay on chain
+
+DROP INDEX IF EXISTS idx_swaps_order_id;
+ALTER TABLE swaps DROP COLUMN IF EXISTS order_id;
+DROP TABLE IF EXISTS swap_orders;
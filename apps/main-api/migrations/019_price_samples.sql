@@ -0,0 +1,13 @@
+-- Migration: Price samples
+-- Created: 2026-10-18
+-- Purpose: Minute-by-minute USD prices of TEAM556 and SOL, from which price history candles are built
+
+CREATE TABLE IF NOT EXISTS price_samples (
+  id BIGSERIAL PRIMARY KEY,
+  mint VARCHAR(44) NOT NULL,
+  sampled_at TIMESTAMPTZ NOT NULL,
+  usd NUMERIC(38,18) NOT NULL,
+  source VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_samples_mint_time ON price_samples(mint, sampled_at);
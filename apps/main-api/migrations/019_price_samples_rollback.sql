@@ -0,0 +1,5 @@
+-- Rollback Migration: Price samples
+-- Created: 2026-10-18
+-- Purpose: Drop the recorded price history
+
+DROP TABLE IF EXISTS price_samples;
@@ -0,0 +1,23 @@
+-- Migration: Payment requests
+-- Created: 2026-10-18
+-- Purpose: Solana Pay requests created for merchants, watched on chain until paid or expired
+
+CREATE TABLE IF NOT EXISTS payment_requests (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  reference VARCHAR(128) NOT NULL,
+  reference_key VARCHAR(44) NOT NULL,
+  merchant_wallet VARCHAR(44) NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  description VARCHAR(255),
+  order_id BIGINT,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  signature VARCHAR(88),
+  received_amount VARCHAR(64),
+  paid_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_requests_reference ON payment_requests(reference);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_merchant_wallet ON payment_requests(merchant_wallet);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_status ON payment_requests(status);
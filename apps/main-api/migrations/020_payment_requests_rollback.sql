@@ -0,0 +1,5 @@
+-- Rollback Migration: Payment requests
+-- Created: 2026-10-18
+-- Purpose: Drop recorded payment requests
+
+DROP TABLE IF EXISTS payment_requests;
@@ -0,0 +1,21 @@
+-- Migration: Price alerts
+-- Created: 2026-10-18
+-- Purpose: Per-user TEAM556 and SOL price alerts, delivered by email and push and disabled once fired
+
+CREATE TABLE IF NOT EXISTS price_alerts (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMPTZ,
+  updated_at TIMESTAMPTZ,
+  user_id BIGINT NOT NULL,
+  mint VARCHAR(44) NOT NULL,
+  kind VARCHAR(8) NOT NULL,
+  target_usd VARCHAR(32),
+  change_percent VARCHAR(16),
+  window_minutes BIGINT,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  triggered_at TIMESTAMPTZ,
+  triggered_usd VARCHAR(32)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_alerts_user_id ON price_alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_price_alerts_active ON price_alerts(active);
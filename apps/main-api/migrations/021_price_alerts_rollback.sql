@@ -0,0 +1,5 @@
+-- Rollback Migration: Price alerts
+-- Created: 2026-10-18
+-- Purpose: Drop users' price alerts
+
+DROP TABLE IF EXISTS price_alerts;
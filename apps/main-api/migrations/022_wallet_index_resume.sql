@@ -0,0 +1,7 @@
+-- Migration: Wallet index resume cursor
+-- Created: 2026-10-18
+-- Purpose: Let the transaction indexer finish a gap of new signatures over several runs instead of skipping it
+
+ALTER TABLE wallet_index_states
+  ADD COLUMN IF NOT EXISTS resume_before VARCHAR(88),
+  ADD COLUMN IF NOT EXISTS pending_newest VARCHAR(88);
@@ -0,0 +1,7 @@
+-- Rollback Migration: Wallet index resume cursor
+-- Created: 2026-10-18
+-- Purpose: Drop the resume cursor (an interrupted catch-up is abandoned)
+
+ALTER TABLE wallet_index_states
+  DROP COLUMN IF EXISTS pending_newest,
+  DROP COLUMN IF EXISTS resume_before;
@@ -0,0 +1,6 @@
+-- Migration: Delayed recovery phrase reveal
+-- Created: 2026-10-18
+-- Purpose: Hold recovery phrase reveals of wallets under a spending policy for the policy's change delay
+
+ALTER TABLE wallet_spending_policies
+  ADD COLUMN IF NOT EXISTS phrase_reveal_available_at TIMESTAMPTZ NULL;
@@ -0,0 +1,6 @@
+-- Rollback Migration: Delayed recovery phrase reveal
+-- Created: 2026-10-18
+-- Purpose: Drop the reveal hold (requested reveals are forgotten)
+
+ALTER TABLE wallet_spending_policies
+  DROP COLUMN IF EXISTS phrase_reveal_available_at;
@@ -23,6 +23,31 @@ export interface UpdateNotificationSettingsRequest {
   marketing_opt_in?: boolean
 }
 
+export type PriceAlertKind = 'above' | 'below' | 'change'
+
+export interface PriceAlert {
+  id: number
+  created_at: string
+  updated_at: string
+  user_id: number
+  mint: string
+  kind: PriceAlertKind
+  target_usd?: string
+  change_percent?: string
+  window_minutes?: number
+  active: boolean
+  triggered_at?: string
+  triggered_usd?: string
+}
+
+export interface PriceAlertRequest {
+  mint?: string // TEAM556 by default, or 'SOL'
+  kind: PriceAlertKind
+  targetUsd?: string // above and below
+  changePercent?: string // change; signed, '-10' fires on a 10% drop
+  windowMinutes?: number // change; 5 to 10080
+}
+
 export const notificationsApi = {
   getSettings: (token: string | null) =>
     apiClient<NotificationSettingsResponse>({ method: 'GET', endpoint: '/notifications/settings', token }),
@@ -38,4 +63,17 @@ export const notificationsApi = {
 
   unregisterPushDevice: (token: string | null, deviceToken: string) =>
     apiClient<void>({ method: 'DELETE', endpoint: '/notifications/push/devices', token, body: { token: deviceToken } }),
+
+  getPriceAlerts: (token: string | null) =>
+    apiClient<{ alerts: PriceAlert[] }>({ method: 'GET', endpoint: '/notifications/price-alerts', token }),
+
+  createPriceAlert: (token: string | null, body: PriceAlertRequest) =>
+    apiClient<PriceAlert>({ method: 'POST', endpoint: '/notifications/price-alerts', token, body }),
+
+  // Replaces the condition and arms the alert again, e.g. after it fired
+  updatePriceAlert: (token: string | null, id: number, body: PriceAlertRequest) =>
+    apiClient<PriceAlert>({ method: 'PUT', endpoint: `/notifications/price-alerts/${id}`, token, body }),
+
+  deletePriceAlert: (token: string | null, id: number) =>
+    apiClient<void>({ method: 'DELETE', endpoint: `/notifications/price-alerts/${id}`, token }),
 }
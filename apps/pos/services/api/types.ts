@@ -157,11 +157,13 @@ export interface GetQuoteRequest {
 
 export interface GetQuoteResponse {
   quoteResponse: QuoteResponseV6
+  quoteId: string // Pass to executeSwap before expiresAt
+  expiresAt: string
 }
 
 export interface ExecuteSwapRequest {
   password: string
-  quoteResponse: QuoteResponseV6
+  quoteId: string
   publicKey?: string // Add optional public key for token account creation
 }
 
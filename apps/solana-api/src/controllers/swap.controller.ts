@@ -15,12 +15,16 @@ interface GetQuoteRequestBody {
 interface PostSwapRequestBody {
   quoteResponse: QuoteResponse
   userPublicKeyString: string // User's public key as a base58 string (REQUIRED)
-  userPrivateKeyBase64?: string // Optional: base64 encoded private key string
 }
 
-interface CreateTokenAccountsRequestBody {
+interface PostDelegatedSwapRequestBody {
+  quoteResponse: QuoteResponse
+  delegatePublicKeyString: string // Approved delegate that signs and pays the fee
+  ownerPublicKeyString: string // Wallet whose tokens are swapped and which receives the output
+}
+
+interface SignedTransactionRequestBody {
   signedTransaction: string // Base64 encoded signed transaction
-  userPrivateKey: string // Expect base64 encoded private key string
 }
 
 /**
@@ -57,12 +61,46 @@ export const handleGetQuote = async (req: Request, res: Response) => {
   }
 }
 
+/**
+ * Handles requests to build a swap of an owner's tokens by an approved delegate (limit orders and DCA).
+ * Returns the unsigned transaction; main-api signs it with the delegate key and lands it.
+ */
+export const handlePostDelegatedSwap = async (req: Request<{}, {}, PostDelegatedSwapRequestBody>, res: Response) => {
+  const { quoteResponse, delegatePublicKeyString, ownerPublicKeyString } = req.body
+
+  if (!quoteResponse || !delegatePublicKeyString || !ownerPublicKeyString) {
+    return res
+      .status(400)
+      .json({ error: 'Missing required fields: quoteResponse, delegatePublicKeyString, ownerPublicKeyString' })
+  }
+
+  try {
+    const result = await swapService.buildDelegatedSwapTransaction(
+      quoteResponse,
+      delegatePublicKeyString,
+      ownerPublicKeyString
+    )
+    res.status(200).json({
+      status: 'needs_signature',
+      swapTransaction: result.swapTransaction,
+      lastValidBlockHeight: result.lastValidBlockHeight
+    })
+  } catch (error: any) {
+    res.status(500).json({
+      status: 'error',
+      error: 'Failed to build delegated swap transaction',
+      details: error.message || error
+    })
+  }
+}
+
 /**
  * Handles requests to generate a swap transaction.
- * Expects quoteResponse and userPublicKeyString in request body.
+ * Expects quoteResponse and userPublicKeyString in request body and returns the unsigned transaction.
+ * Signing happens in main-api; the signed transaction comes back via /submit.
  */
 export const handlePostSwap = async (req: Request<{}, {}, PostSwapRequestBody>, res: Response) => {
-  const { quoteResponse, userPublicKeyString, userPrivateKeyBase64 } = req.body
+  const { quoteResponse, userPublicKeyString } = req.body
 
   if (!quoteResponse || !userPublicKeyString) {
     return res.status(400).json({ error: 'Missing required fields: quoteResponse, userPublicKeyString' })
@@ -70,7 +108,7 @@ export const handlePostSwap = async (req: Request<{}, {}, PostSwapRequestBody>,
 
   try {
     // Execute swap transaction - will now check for required token accounts
-    const result = await swapService.executeSwapTransaction(quoteResponse, userPublicKeyString, userPrivateKeyBase64)
+    const result = await swapService.executeSwapTransaction(quoteResponse, userPublicKeyString)
 
     // Check if token accounts need to be created
     if (typeof result === 'object' && 'requiresTokenAccounts' in result) {
@@ -84,10 +122,10 @@ export const handlePostSwap = async (req: Request<{}, {}, PostSwapRequestBody>,
       })
     }
 
-    // If we got here, it's a successful transaction signature
     res.status(200).json({
-      status: 'success',
-      signature: result // This is the transaction signature string
+      status: 'needs_signature',
+      swapTransaction: result.swapTransaction,
+      lastValidBlockHeight: result.lastValidBlockHeight
     })
   } catch (error: any) {
     // Enhanced error logging for specific error types
@@ -115,10 +153,10 @@ export const handlePostSwap = async (req: Request<{}, {}, PostSwapRequestBody>,
  * Expects signedTransaction (from the frontend after user approval) in request body.
  */
 export const handleCreateTokenAccounts = async (
-  req: Request<{}, {}, CreateTokenAccountsRequestBody>,
+  req: Request<{}, {}, SignedTransactionRequestBody>,
   res: Response
 ) => {
-  const { signedTransaction, userPrivateKey } = req.body
+  const { signedTransaction } = req.body
 
   if (!signedTransaction) {
     return res.status(400).json({
@@ -147,3 +185,27 @@ export const handleCreateTokenAccounts = async (
     })
   }
 }
+
+/**
+ * Submits a swap transaction signed by main-api and waits for confirmation.
+ * Expects signedTransaction in request body.
+ */
+export const handleSubmitSwap = async (req: Request<{}, {}, SignedTransactionRequestBody>, res: Response) => {
+  const { signedTransaction } = req.body
+
+  if (!signedTransaction) {
+    return res.status(400).json({ status: 'error', error: 'Missing required field: signedTransaction' })
+  }
+
+  try {
+    const signature = await swapService.submitTokenAccountTransaction(signedTransaction)
+    res.status(200).json({ status: 'success', signature })
+  } catch (error: any) {
+    res.status(500).json({
+      status: 'error',
+      error: 'Failed to execute swap transaction',
+      details: error.message || error,
+      errorType: error.name || 'Unknown'
+    })
+  }
+}
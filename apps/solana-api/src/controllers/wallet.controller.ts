@@ -37,12 +37,6 @@ const addressSchema = z.object({
   )
 })
 
-// --- Zod Schema for Sign Transaction ---
-const signTransactionSchema = z.object({
-  mnemonic: z.string().refine(bip39.validateMnemonic, { message: 'Invalid mnemonic phrase' }),
-  unsignedTransaction: z.string().min(1, { message: 'Unsigned transaction is required' }) // Expect base64 string
-})
-
 // --- Zod Schema for Send Transaction ---
 const sendTransactionSchema = z.object({
   signedTransaction: z.string().min(1, { message: 'Signed transaction is required' }) // Expect base64 string
@@ -357,65 +351,6 @@ export const getTeamTokenBalance = async (req: Request, res: Response) => {
   }
 }
 
-export const signTransaction = async (req: Request, res: Response) => {
-  try {
-    // 1. Validate Request Body
-    const validationResult = signTransactionSchema.safeParse(req.body)
-    if (!validationResult.success) {
-      console.warn('Invalid signTransaction request body:', { errors: validationResult.error.errors, body: req.body })
-      return res.status(400).json({ error: 'Invalid request body', details: validationResult.error.flatten() })
-    }
-
-    const { mnemonic, unsignedTransaction } = validationResult.data
-
-    // 2. Derive Keypair from Mnemonic
-    const seed = await bip39.mnemonicToSeed(mnemonic, '') // Use empty passphrase
-    const derivationPath = "m/44'/501'/0'/0'"
-    const derivedSeed = derivePath(derivationPath, seed.toString('hex')).key
-    const keypair = Keypair.fromSeed(derivedSeed)
-    const derivedPublicKey = keypair.publicKey.toBase58()
-
-    // 3. Deserialize Unsigned Transaction
-    const transactionBuffer = Buffer.from(unsignedTransaction, 'base64')
-    const oldTransaction = Transaction.from(transactionBuffer)
-
-    // Log expected fee payer
-    const expectedFeePayer = oldTransaction.feePayer?.toBase58()
-
-    // 4. Check if the transaction's feePayer needs to be updated
-    if (expectedFeePayer && expectedFeePayer !== derivedPublicKey) {
-      // Set the fee payer explicitly to our keypair's public key
-      oldTransaction.feePayer = keypair.publicKey
-      // NOTE: We DO NOT modify the instructions themselves, only the transaction's feePayer field.
-    } else {
-      // Ensure fee payer is set if it was null
-      if (!oldTransaction.feePayer) {
-        oldTransaction.feePayer = keypair.publicKey
-      }
-    }
-
-    // 5. Ensure the transaction has a recent blockhash
-    if (!oldTransaction.recentBlockhash) {
-      oldTransaction.recentBlockhash = await getLatestBlockhash()
-    }
-
-    // 6. Sign the transaction (original object, potentially with updated feePayer)
-    oldTransaction.sign(keypair)
-
-    // 7. Serialize and return
-    try {
-      const signedTxBuffer = oldTransaction.serialize()
-      const signedTxBase64 = signedTxBuffer.toString('base64')
-      return res.status(200).json({ signedTransaction: signedTxBase64 })
-    } catch (serializeError) {
-      throw serializeError
-    }
-  } catch (error: unknown) {
-    const errorMessage = error instanceof Error ? error.message : 'Unknown error during signing'
-    return res.status(500).json({ message: 'Failed to sign transaction', error: errorMessage })
-  }
-}
-
 export const sendTransaction = async (req: Request, res: Response) => {
   try {
     // 1. Validate Request Body
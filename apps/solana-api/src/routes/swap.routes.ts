@@ -1,5 +1,11 @@
 import express, { Router } from 'express';
-import { handleGetQuote, handlePostSwap, handleCreateTokenAccounts } from '../controllers/swap.controller';
+import {
+  handleGetQuote,
+  handlePostSwap,
+  handlePostDelegatedSwap,
+  handleCreateTokenAccounts,
+  handleSubmitSwap
+} from '../controllers/swap.controller';
 
 const router: Router = express.Router();
 
@@ -7,10 +13,16 @@ const router: Router = express.Router();
 // POST because we send parameters in the request body
 router.post('/quote', handleGetQuote);
 
-// Route to get swap transaction
+// Route to get the unsigned swap transaction
 // POST because we send the quote object and user public key in the body
 router.post('/swap', handlePostSwap);
 
+// Route to get the unsigned swap transaction for a delegate swapping an owner's tokens (limit orders, DCA)
+router.post('/delegated', handlePostDelegatedSwap);
+
+// Route to submit a swap transaction signed by main-api
+router.post('/submit', handleSubmitSwap);
+
 // Route to handle token account creation
 // POST because we send the signed transaction in the body
 router.post('/create-token-accounts', handleCreateTokenAccounts);
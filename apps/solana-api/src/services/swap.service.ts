@@ -20,6 +20,12 @@ import {
   BlockhashWithExpiryBlockHeight, // Import for type clarity
   SendTransactionError // Import SendTransactionError
 } from '@solana/web3.js'
+import {
+  createAssociatedTokenAccountIdempotentInstruction,
+  createTransferCheckedInstruction,
+  getAssociatedTokenAddressSync,
+  getMint
+} from '@solana/spl-token'
 import dotenv from 'dotenv'
 import path from 'path'
 import bs58 from 'bs58'
@@ -207,18 +213,20 @@ export const createTokenAccountTransaction = async (
 }
 
 /**
- * Executes the swap transaction.
+ * Builds the unsigned swap transaction for the caller to sign.
  * @param {QuoteResponse} quoteResponse - The quote details.
  * @param {string} userPublicKeyString - The public key of the user performing the swap.
- * @param {string} [userPrivateKeyBase64] - The base64 encoded private key of the user.
- * @returns {Promise<string | { requiresTokenAccounts: true; createAccountTransaction: string; missingAccounts: { mint: string; address: string }[] }>} Either the transaction signature or a token account setup request.
+ * @returns Either the unsigned base64 swap transaction or a token account setup request.
  */
 export const executeSwapTransaction = async (
   quoteResponse: QuoteResponse,
-  userPublicKeyString: string,
-  userPrivateKeyBase64?: string
+  userPublicKeyString: string
 ): Promise<
-  | string
+  | {
+      requiresSignature: true
+      swapTransaction: string
+      lastValidBlockHeight: number
+    }
   | {
       requiresTokenAccounts: true
       createAccountTransaction: string
@@ -227,41 +235,15 @@ export const executeSwapTransaction = async (
 > => {
   // Use confirmed commitment for better reliability
   const connection = new Connection(process.env.GLOBAL__MAINNET_RPC_URL || '', 'confirmed')
-  let userKeypair: Keypair | null = null
   let userPublicKey: PublicKey
 
   try {
-    // Always derive PublicKey from the provided string
     try {
       userPublicKey = new PublicKey(userPublicKeyString)
     } catch (e) {
       throw new Error(`Invalid userPublicKey provided: ${userPublicKeyString}`)
     }
 
-    // Derive keypair only if private key is provided
-    if (userPrivateKeyBase64) {
-      let privateKeyBytes: Uint8Array | null = null
-      try {
-        privateKeyBytes = Buffer.from(userPrivateKeyBase64, 'base64')
-        // Derive the keypair from the 32-byte seed
-        userKeypair = Keypair.fromSeed(privateKeyBytes)
-        privateKeyBytes.fill(0) // Clear sensitive data immediately
-        privateKeyBytes = null // Dereference
-
-        // Verify derived public key matches the provided one
-        if (userKeypair.publicKey.toBase58() !== userPublicKey.toBase58()) {
-          throw new Error('Provided userPublicKey does not match the derived public key from the private key.')
-        }
-      } catch (error) {
-        if (privateKeyBytes) privateKeyBytes.fill(0) // Ensure cleanup on error
-        throw new Error(
-          `Failed to derive or verify keypair: ${error instanceof Error ? error.message : 'Unknown error'}`
-        )
-      }
-    } else {
-      console.log('No private key provided. Proceeding with public key only for token account checks.')
-    }
-
     // Log the tokens being swapped to help diagnose issues
     if (!quoteResponse.inputMint || !quoteResponse.outputMint) {
       throw new Error('Missing input or output mint in quote response')
@@ -304,16 +286,9 @@ export const executeSwapTransaction = async (
       }
     }
 
-    // --- Full Swap Execution Logic ---
-    // This part should ONLY run if the private key was provided
-    if (!userKeypair) {
-      // If accounts exist BUT no private key was provided, we cannot proceed with the swap.
-      // This scenario might happen if the client calls again after creating accounts but still without the private key.
-      throw new Error('Token accounts are ready, but userPrivateKey is required to sign the swap transaction.')
-    }
-
+    // --- Build the swap transaction; main-api signs it and submits via /swap/submit ---
     // 2. Get swap instructions from Jupiter
-    const instructionsResponse = await getSwapInstructionsFromJupiter(userKeypair.publicKey, quoteResponse)
+    const instructionsResponse = await getSwapInstructionsFromJupiter(userPublicKey, quoteResponse)
     if (!instructionsResponse) {
       throw new Error('Failed to get swap instructions from Jupiter.')
     }
@@ -333,19 +308,6 @@ export const executeSwapTransaction = async (
     // 4. Create Transaction Instructions Array
     // Combine all instructions from Jupiter response
     // Order matters: Compute Budget -> Setup -> Swap -> Cleanup -> Other
-    const instructionDataToTransactionInstruction = (instruction: any): TransactionInstruction | null => {
-      if (instruction === null || instruction === undefined) return null
-      return new TransactionInstruction({
-        programId: new PublicKey(instruction.programId),
-        keys: instruction.accounts.map((key: any) => ({
-          pubkey: new PublicKey(key.pubkey),
-          isSigner: key.isSigner,
-          isWritable: key.isWritable
-        })),
-        data: Buffer.from(instruction.data, 'base64')
-      })
-    }
-
     const instructions: TransactionInstruction[] = [
       ...computeBudgetInstructions.map(instructionDataToTransactionInstruction),
       ...setupInstructions.map(instructionDataToTransactionInstruction),
@@ -353,89 +315,25 @@ export const executeSwapTransaction = async (
       instructionDataToTransactionInstruction(cleanupInstruction)
     ].filter((ix): ix is TransactionInstruction => ix !== null)
 
-    // 8. Fetch latest blockhash immediately before sending
-    // Re-fetch latest blockhash *just* before sending for maximum validity
+    // 8. Fetch latest blockhash; the signed transaction must land before lastValidBlockHeight
     const latestBlockHash = await connection.getLatestBlockhash('confirmed')
 
     // 9. Compile message and create transaction
     const messageV0 = new TransactionMessage({
-      payerKey: userPublicKey, // Ensure this is the PublicKey object
+      payerKey: userPublicKey,
       recentBlockhash: latestBlockHash.blockhash,
-      instructions // The collected instructions including priority fee
+      instructions
     }).compileToV0Message(addressLookupTableAccounts)
 
     const transaction = new VersionedTransaction(messageV0)
 
-    // 11. Send the transaction (Removed skipPreflight to see simulation errors)
-    transaction.sign([userKeypair])
-
-    const txid = await connection.sendTransaction(transaction, {
-      maxRetries: 2 // Retry sending a couple of times if needed
-    })
-
-    // 12. Confirm the transaction using the blockhash used for sending
-    let confirmationStatus: { value: { err: any | null } } | null = null
-    try {
-      const confirmationPromise = connection.confirmTransaction(
-        {
-          signature: txid,
-          blockhash: latestBlockHash.blockhash, // Use the same blockhash used to send
-          lastValidBlockHeight: latestBlockHash.lastValidBlockHeight
-        },
-        'confirmed'
-      )
-
-      // Add timeout logic
-      const timeoutPromise = new Promise<never>((_, reject) =>
-        setTimeout(() => reject(new Error('Transaction confirmation timed out after 30 seconds.')), 30000)
-      )
-
-      confirmationStatus = await Promise.race([confirmationPromise, timeoutPromise])
-    } catch (error) {
-      if (error instanceof Error && error.message.includes('timed out')) {
-        // Try to get status one last time if timed out
-        const lastStatus = await connection.getSignatureStatus(txid, { searchTransactionHistory: false })
-        throw new Error(
-          `Transaction confirmation timed out. Signature: ${txid}. Last known status: ${lastStatus?.value?.confirmationStatus || 'unknown'}`
-        )
-      } else {
-        throw error
-      }
-    } finally {
-      console.timeEnd(`confirm-${txid}`)
-    }
-
-    // Check transaction status from confirmTransaction result
-    if (confirmationStatus?.value?.err) {
-      // Try to get more details if possible
-      if (txid) {
-        try {
-          const failedTx = await connection.getTransaction(txid, {
-            commitment: 'confirmed',
-            maxSupportedTransactionVersion: 0
-          })
-          console.error('Failed transaction details:', JSON.stringify(failedTx?.meta?.err))
-        } catch (txError) {
-          console.error('Could not fetch failed transaction details:', txError)
-        }
-        throw new Error(
-          `Transaction failed confirmation: ${JSON.stringify(confirmationStatus.value.err)}. Signature: ${txid}`
-        )
-      } else {
-        throw new Error(`Transaction failed confirmation: ${JSON.stringify(confirmationStatus.value.err)}`)
-      }
-    } else if (confirmationStatus) {
-      // If err is null, the transaction reached 'confirmed' commitment.
-      console.log(`Transaction successfully confirmed. Signature: ${txid}`)
-    } else {
-      // Should not happen if timeout error is caught correctly, but as a safeguard
-      throw new Error('Transaction confirmation status unclear after process completed.')
+    // 10. Return the unsigned transaction; private keys never reach this service
+    return {
+      requiresSignature: true,
+      swapTransaction: Buffer.from(transaction.serialize()).toString('base64'),
+      lastValidBlockHeight: latestBlockHash.lastValidBlockHeight
     }
-    return txid // Return the transaction signature upon successful confirmation
   } catch (error: any) {
-    // Clear keypair if it exists in case of error
-    // Note: privateKeyBytes should already be cleared
-    userKeypair = null
     if (error instanceof Error) {
       // Re-throw the original error message for clarity
       throw new Error(`Swap execution failed: ${error.message}`)
@@ -446,6 +344,123 @@ export const executeSwapTransaction = async (
   }
 } // End of executeSwapTransaction
 
+/**
+ * Converts an instruction from a Jupiter swap-instructions response.
+ */
+function instructionDataToTransactionInstruction(instruction: any): TransactionInstruction | null {
+  if (instruction === null || instruction === undefined) return null
+  return new TransactionInstruction({
+    programId: new PublicKey(instruction.programId),
+    keys: instruction.accounts.map((key: any) => ({
+      pubkey: new PublicKey(key.pubkey),
+      isSigner: key.isSigner,
+      isWritable: key.isWritable
+    })),
+    data: Buffer.from(instruction.data, 'base64')
+  })
+}
+
+/**
+ * Builds an unsigned swap of the owner's tokens by a delegate the owner approved on its input token account.
+ * In one transaction the delegate moves the quoted input amount into its own token account, swaps it
+ * through Jupiter and has the output paid straight into the owner's token account, so the swap either
+ * happens completely or not at all. The delegate pays the fee and signs in main-api; the owner doesn't sign.
+ * Both mints must be SPL tokens and the owner's output token account must already exist.
+ * @param quoteResponse - The quote to execute (ExactIn).
+ * @param delegatePublicKeyString - The approved delegate, which signs and pays the fee.
+ * @param ownerPublicKeyString - The wallet whose tokens are swapped and which receives the output.
+ */
+export const buildDelegatedSwapTransaction = async (
+  quoteResponse: QuoteResponse,
+  delegatePublicKeyString: string,
+  ownerPublicKeyString: string
+): Promise<{ swapTransaction: string; lastValidBlockHeight: number }> => {
+  const connection = new Connection(process.env.GLOBAL__MAINNET_RPC_URL || '', 'confirmed')
+  let delegate: PublicKey
+  let owner: PublicKey
+  try {
+    delegate = new PublicKey(delegatePublicKeyString)
+    owner = new PublicKey(ownerPublicKeyString)
+  } catch (e) {
+    throw new Error('Invalid delegate or owner public key')
+  }
+  if (quoteResponse.swapMode && quoteResponse.swapMode !== 'ExactIn') {
+    throw new Error('Delegated swaps must be ExactIn')
+  }
+
+  try {
+    const inputMint = new PublicKey(quoteResponse.inputMint)
+    const outputMint = new PublicKey(quoteResponse.outputMint)
+    const [inputMintInfo, outputMintInfo] = await connection.getMultipleAccountsInfo([inputMint, outputMint])
+    if (!inputMintInfo || !outputMintInfo) {
+      throw new Error('Unknown input or output mint')
+    }
+    const inputProgram = inputMintInfo.owner
+    const outputProgram = outputMintInfo.owner
+    const inputMintData = await getMint(connection, inputMint, 'confirmed', inputProgram)
+
+    const ownerInput = getAssociatedTokenAddressSync(inputMint, owner, false, inputProgram)
+    const ownerOutput = getAssociatedTokenAddressSync(outputMint, owner, false, outputProgram)
+    const delegateInput = getAssociatedTokenAddressSync(inputMint, delegate, false, inputProgram)
+    if (!(await connection.getAccountInfo(ownerOutput))) {
+      throw new Error("The owner's output token account does not exist")
+    }
+
+    const instructionsResponse = await jupiterApi.swapInstructionsPost({
+      swapRequest: {
+        quoteResponse,
+        userPublicKey: delegate.toBase58(),
+        destinationTokenAccount: ownerOutput.toBase58(),
+        // main-api only signs shared-accounts routes, whose legs never touch the user's accounts
+        useSharedAccounts: true,
+        wrapAndUnwrapSol: false,
+        dynamicComputeUnitLimit: true
+      }
+    })
+    const {
+      computeBudgetInstructions,
+      setupInstructions,
+      swapInstruction,
+      cleanupInstruction,
+      addressLookupTableAddresses
+    } = instructionsResponse
+    const addressLookupTableAccounts = await getAdressLookupTableAccounts(connection, addressLookupTableAddresses)
+
+    const instructions: TransactionInstruction[] = [
+      ...computeBudgetInstructions.map(instructionDataToTransactionInstruction),
+      // The delegate's input account is reused across swaps, so it is only paid for once
+      createAssociatedTokenAccountIdempotentInstruction(delegate, delegateInput, delegate, inputMint, inputProgram),
+      createTransferCheckedInstruction(
+        ownerInput,
+        inputMint,
+        delegateInput,
+        delegate,
+        BigInt(quoteResponse.inAmount),
+        inputMintData.decimals,
+        [],
+        inputProgram
+      ),
+      ...setupInstructions.map(instructionDataToTransactionInstruction),
+      instructionDataToTransactionInstruction(swapInstruction),
+      instructionDataToTransactionInstruction(cleanupInstruction)
+    ].filter((ix): ix is TransactionInstruction => ix !== null)
+
+    const latestBlockHash = await connection.getLatestBlockhash('confirmed')
+    const messageV0 = new TransactionMessage({
+      payerKey: delegate,
+      recentBlockhash: latestBlockHash.blockhash,
+      instructions
+    }).compileToV0Message(addressLookupTableAccounts)
+
+    return {
+      swapTransaction: Buffer.from(new VersionedTransaction(messageV0).serialize()).toString('base64'),
+      lastValidBlockHeight: latestBlockHash.lastValidBlockHeight
+    }
+  } catch (error: any) {
+    throw new Error(`Delegated swap build failed: ${error instanceof Error ? error.message : 'unknown error'}`)
+  }
+}
+
 /**
  * Get the associated token address for a given mint and owner.
  */
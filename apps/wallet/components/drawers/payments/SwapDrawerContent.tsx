@@ -78,6 +78,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
   const [password, setPassword] = useState('')
   const [step, setStep] = useState<StepType>('form')
   const [quoteResponse, setQuoteResponse] = useState<QuoteResponseV6 | null>(null)
+  const [quoteId, setQuoteId] = useState<string | null>(null)
   const [isQuoteLoading, setIsQuoteLoading] = useState(false)
   const [exchangeRate, setExchangeRate] = useState<string | null>(null) // Add state for exchange rate
   // Add state for token account setup
@@ -123,6 +124,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
       if (!fetchAmount || isNaN(parseFloat(fetchAmount)) || parseFloat(fetchAmount) <= 0) {
         setEstimatedReceiveAmount('')
         setQuoteResponse(null)
+        setQuoteId(null)
         setIsQuoteLoading(false)
         setError(null) // Clear previous errors if amount is invalid
         return
@@ -131,6 +133,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
       setIsQuoteLoading(true)
       setError(null)
       setQuoteResponse(null) // Clear previous quote
+      setQuoteId(null)
       setEstimatedReceiveAmount('') // Clear previous estimate
 
       try {
@@ -175,6 +178,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
         if (response && response.quoteResponse) {
           const quote: QuoteResponseV6 = response.quoteResponse
           setQuoteResponse(quote)
+          setQuoteId(response.quoteId)
 
           // Calculate and set exchange rate
           try {
@@ -207,6 +211,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
         const message = err.response?.data?.error || err.message || 'Failed to fetch swap quote.'
         setError(message)
         setQuoteResponse(null)
+        setQuoteId(null)
         setEstimatedReceiveAmount('')
         setExchangeRate(null) // Clear rate on error
       } finally {
@@ -304,7 +309,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
       setError('Password is required.')
       return
     }
-    if (!quoteResponse) {
+    if (!quoteResponse || !quoteId) {
       setError('Swap quote is not available. Please try again.')
       return
     }
@@ -324,7 +329,7 @@ export const SwapDrawerContent: React.FC<SwapDrawerProps> = ({
       // Call the backend API to execute the swap
       const swapPayload = {
         password: effectivePassword, // Send plain password for backend decryption
-        quoteResponse: quoteResponse, // Send the fetched quote response
+        quoteId, // The server only executes quotes it issued
         publicKey: userWalletAddress // Send the wallet's public key
       }
       const response = await executeSwap(swapPayload, token)
@@ -72,5 +72,8 @@ export * from './nfa';
 // Export all functions from referrals.ts
 export * from './referrals';
 
+// Export all functions from prices.ts
+export * from './prices';
+
 // Export the ApiClientError and apiClient for direct use if needed
 export { apiClient, ApiClientError } from './client';
@@ -0,0 +1,31 @@
+import { apiClient } from './client'
+import type { FiatCurrency, PriceHistoryParams, PriceHistoryResponse, TokenPricesResponse } from './types'
+
+/**
+ * Fetches OHLC price candles for TEAM556 or SOL. Public; no auth token needed.
+ * @param params - Optional mint, resolution and time range.
+ * @returns A promise resolving to the candles, oldest first.
+ * @throws An ApiClientError if the request fails.
+ */
+export const getPriceHistory = async (params: PriceHistoryParams = {}): Promise<PriceHistoryResponse> => {
+  return apiClient<PriceHistoryResponse>({
+    method: 'GET',
+    endpoint: '/price/history',
+    params: params as Record<string, string | number>
+  })
+}
+
+/**
+ * Fetches the prices of up to 100 mints in a fiat currency. Public; no auth token needed.
+ * @param mints - Mint addresses, or 'SOL' for native SOL.
+ * @param currency - The currency to price in; defaults to USD.
+ * @returns A promise resolving to the prices, keyed by mint.
+ * @throws An ApiClientError if the request fails.
+ */
+export const getTokenPrices = async (mints: string[], currency: FiatCurrency = 'USD'): Promise<TokenPricesResponse> => {
+  return apiClient<TokenPricesResponse>({
+    method: 'GET',
+    endpoint: '/price/tokens',
+    params: { mints: mints.join(','), currency }
+  })
+}
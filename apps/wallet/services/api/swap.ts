@@ -5,7 +5,13 @@ import type {
   ExecuteSwapRequest,
   ExecuteSwapResponseWithStatus,
   SubmitTokenAccountsRequest,
-  SubmitTokenAccountsResponse
+  SubmitTokenAccountsResponse,
+  SwapHistoryParams,
+  SwapHistoryResponse,
+  SwapOrder,
+  SwapOrderStatus,
+  CreateSwapOrderRequest,
+  SwapOrderDetailResponse
 } from './types'
 
 /**
@@ -74,3 +80,103 @@ export const submitTokenAccountTransaction = async (
     body: payload,
   });
 };
+
+/**
+ * Fetches the user's executed swaps, newest first, with quoted and realized prices.
+ * @param params - Optional paging and filters.
+ * @param token - The user's auth token.
+ * @returns A promise resolving to a page of swap history.
+ * @throws An ApiClientError if the request fails.
+ */
+export const getSwapHistory = async (
+  params: SwapHistoryParams,
+  token: string | null
+): Promise<SwapHistoryResponse> => {
+  if (!token) {
+    return Promise.reject(new Error('Authentication token not provided.'))
+  }
+  return apiClient<SwapHistoryResponse>({
+    method: 'GET',
+    endpoint: '/swap/history',
+    token,
+    params: params as Record<string, string | number>
+  })
+}
+
+/**
+ * Places a TEAM556 limit order or DCA plan.
+ * @param payload - The order details and the wallet password for the delegate approval.
+ * @param token - The user's auth token.
+ * @returns A promise resolving to the created order.
+ * @throws An ApiClientError if the request fails.
+ */
+export const createSwapOrder = async (payload: CreateSwapOrderRequest, token: string | null): Promise<SwapOrder> => {
+  if (!token) {
+    return Promise.reject(new Error('Authentication token not provided.'))
+  }
+  return apiClient<SwapOrder>({
+    method: 'POST',
+    endpoint: '/swap/orders',
+    token,
+    body: payload
+  })
+}
+
+/**
+ * Fetches the user's swap orders, newest first.
+ * @param status - Optional status filter.
+ * @param token - The user's auth token.
+ * @returns A promise resolving to the orders.
+ * @throws An ApiClientError if the request fails.
+ */
+export const getSwapOrders = async (
+  status: SwapOrderStatus | undefined,
+  token: string | null
+): Promise<{ orders: SwapOrder[] }> => {
+  if (!token) {
+    return Promise.reject(new Error('Authentication token not provided.'))
+  }
+  return apiClient<{ orders: SwapOrder[] }>({
+    method: 'GET',
+    endpoint: '/swap/orders',
+    token,
+    params: status ? { status } : undefined
+  })
+}
+
+/**
+ * Fetches one swap order with the swaps it executed.
+ * @param id - The order ID.
+ * @param token - The user's auth token.
+ * @returns A promise resolving to the order and its swaps.
+ * @throws An ApiClientError if the request fails.
+ */
+export const getSwapOrder = async (id: number, token: string | null): Promise<SwapOrderDetailResponse> => {
+  if (!token) {
+    return Promise.reject(new Error('Authentication token not provided.'))
+  }
+  return apiClient<SwapOrderDetailResponse>({
+    method: 'GET',
+    endpoint: `/swap/orders/${id}`,
+    token
+  })
+}
+
+/**
+ * Cancels a swap order. With the wallet password the delegate approval is also lowered or revoked.
+ * @param id - The order ID.
+ * @param password - Optional wallet password.
+ * @param token - The user's auth token.
+ * @throws An ApiClientError if the request fails.
+ */
+export const cancelSwapOrder = async (id: number, password: string | undefined, token: string | null) => {
+  if (!token) {
+    return Promise.reject(new Error('Authentication token not provided.'))
+  }
+  return apiClient<{ cancelled: boolean; approvalUpdated: boolean; signature?: string }>({
+    method: 'DELETE',
+    endpoint: `/swap/orders/${id}`,
+    token,
+    body: password ? { password } : undefined
+  })
+}
@@ -158,11 +158,13 @@ export interface GetQuoteRequest {
 
 export interface GetQuoteResponse {
   quoteResponse: QuoteResponseV6
+  quoteId: string // Pass to executeSwap before expiresAt
+  expiresAt: string
 }
 
 export interface ExecuteSwapRequest {
   password: string
-  quoteResponse: QuoteResponseV6
+  quoteId: string
   publicKey?: string // Add optional public key for token account creation
 }
 
@@ -196,6 +198,97 @@ export interface ExecuteSwapResponseWithStatus {
   message?: string
 }
 
+export type SwapStatus = 'pending' | 'confirmed' | 'failed' | 'expired'
+
+// One executed swap; amounts without the Raw suffix are whole tokens, prices are output per input
+export interface SwapHistoryItem {
+  id: number
+  createdAt: string
+  settledAt?: string
+  walletAddress: string
+  status: SwapStatus
+  error?: string
+  signature: string
+  inputMint: string
+  inputSymbol: string
+  outputMint: string
+  outputSymbol: string
+  slippageBps: number
+  priceImpactPct: string
+  feeLamports: number
+  inAmountRaw: string
+  quotedOutAmountRaw: string
+  minOutAmountRaw?: string
+  spentInAmountRaw?: string
+  receivedOutAmountRaw?: string
+  inAmount?: string
+  quotedOutAmount?: string
+  spentInAmount?: string
+  receivedOutAmount?: string
+  quotedPrice?: string
+  realizedPrice?: string
+}
+
+export interface SwapHistoryParams {
+  page?: number
+  page_size?: number
+  wallet_id?: number
+  mint?: string
+  status?: SwapStatus
+}
+
+export interface SwapHistoryResponse {
+  swaps: SwapHistoryItem[]
+  page: number
+  pageSize: number
+  total: number
+}
+
+export type SwapOrderKind = 'limit' | 'dca'
+export type SwapOrderSide = 'buy' | 'sell'
+export type SwapOrderStatus = 'active' | 'paused' | 'filled' | 'completed' | 'expired' | 'cancelled'
+
+// A TEAM556 limit order or DCA plan; buys spend USDC, sells spend TEAM556
+export interface SwapOrder {
+  id: number
+  created_at: string
+  updated_at: string
+  wallet_id: number
+  kind: SwapOrderKind
+  side: SwapOrderSide
+  input_mint: string
+  output_mint: string
+  amount: string
+  slippage_bps: number
+  limit_price?: string
+  cadence?: string
+  end_at: string
+  next_run_at?: string
+  status: SwapOrderStatus
+  executions: number
+  consecutive_failures: number
+  last_error?: string
+  approval_signature?: string
+}
+
+export interface CreateSwapOrderRequest {
+  walletId?: number
+  kind: SwapOrderKind
+  side: SwapOrderSide
+  amount: string
+  limitPrice?: string
+  cadence?: string
+  startAt?: string
+  endAt: string
+  slippageBps?: number
+  password: string
+}
+
+export interface SwapOrderDetailResponse {
+  order: SwapOrder
+  swaps: SwapHistoryItem[]
+}
+
 // Add other shared types here as needed
 
 // --- TRANSACTION HISTORY ---
@@ -223,3 +316,49 @@ export interface GetTransactionsResponse {
 }
 
 
+
+// --- PRICES ---
+
+export type CandleResolution = '1m' | '1h' | '1d'
+
+// OHLC USD prices within one bucket starting at `time` (unix seconds, UTC)
+export interface PriceCandle {
+  time: number
+  open: string
+  high: string
+  low: string
+  close: string
+  samples: number
+}
+
+export interface PriceHistoryParams {
+  mint?: string // Defaults to TEAM556; 'SOL' for SOL
+  resolution?: CandleResolution
+  from?: number // Unix seconds
+  to?: number
+}
+
+export interface PriceHistoryResponse {
+  mint: string
+  resolution: CandleResolution
+  from: number
+  to: number
+  candles: PriceCandle[] // Buckets without samples are left out
+}
+
+export type FiatCurrency = 'USD' | 'EUR' | 'GBP' | 'CAD'
+
+export interface TokenPrice {
+  price: string // In the requested currency
+  usd: string
+  source: string
+  updatedAt: string
+  stale?: boolean
+}
+
+export interface TokenPricesResponse {
+  currency: FiatCurrency
+  rate: string // Units of currency per USD
+  prices: Record<string, TokenPrice> // Keyed by the mint as requested
+  unpriced: string[]
+}